@@ -0,0 +1,179 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package alactest provides conformance helpers for checking ALAC decoding
+// output, so that downstream users can run their own comparisons without
+// copying this module's test internals.
+package alactest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+// MaxFrameDiffs is the largest number of differing frames CompareDecoders reports.
+const MaxFrameDiffs = 5
+
+// compareChunkFrames is the number of sample frames compared per iteration.
+const compareChunkFrames = 4096
+
+// ErrFormatMismatch indicates a stream whose decoded format differs from the
+// bit depth and channel count the comparison expects.
+var ErrFormatMismatch = errors.New("alactest: stream format mismatch")
+
+// FrameDiff describes one sample frame that differs between two streams.
+type FrameDiff struct {
+	// Frame is the 0-based index of the sample frame.
+	Frame int64
+	// A and B hold the frame's samples, one per channel in output order, with
+	// the scale of alac.DecodeAllInt32. A side is nil once its stream has ended.
+	A, B []int32
+}
+
+// String formats the difference for test logs.
+func (d FrameDiff) String() string {
+	return fmt.Sprintf("frame %d: a=%v b=%v", d.Frame, d.A, d.B)
+}
+
+// CompareDecoders decodes the ALAC M4A streams a and b in lockstep and
+// returns the first MaxFrameDiffs sample frames that differ, in order. A
+// stream that ends early differs at each frame the other still has. An empty
+// result means both decoded to identical PCM.
+//
+// Both streams must decode to bitDepth and channels; otherwise the error wraps
+// ErrFormatMismatch. Decoding errors are returned as is.
+func CompareDecoders(a, b io.ReadSeeker, bitDepth, channels int) ([]FrameDiff, error) {
+	bytesPerSample, err := alac.BytesPerSampleChecked(bitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	decA, err := openStream(a, "a", bitDepth, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	decB, err := openStream(b, "b", bitDepth, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	frameBytes := bytesPerSample * channels
+	bufA := make([]byte, compareChunkFrames*frameBytes)
+	bufB := make([]byte, compareChunkFrames*frameBytes)
+
+	var (
+		diffs []FrameDiff
+		frame int64
+	)
+
+	for len(diffs) < MaxFrameDiffs {
+		nA, err := readFrames(decA, bufA, "a")
+		if err != nil {
+			return nil, err
+		}
+
+		nB, err := readFrames(decB, bufB, "b")
+		if err != nil {
+			return nil, err
+		}
+
+		if nA == 0 && nB == 0 {
+			break
+		}
+
+		for idx := 0; idx < max(nA, nB) && len(diffs) < MaxFrameDiffs; idx += frameBytes {
+			if idx < nA && idx < nB && bytes.Equal(bufA[idx:idx+frameBytes], bufB[idx:idx+frameBytes]) {
+				continue
+			}
+
+			diffs = append(diffs, FrameDiff{
+				Frame: frame + int64(idx/frameBytes),
+				A:     frameSamples(bufA[:nA], idx, bytesPerSample, channels),
+				B:     frameSamples(bufB[:nB], idx, bytesPerSample, channels),
+			})
+		}
+
+		frame += int64(max(nA, nB) / frameBytes)
+	}
+
+	return diffs, nil
+}
+
+// openStream opens one side of the comparison and checks its format.
+func openStream(rs io.ReadSeeker, name string, bitDepth, channels int) (*alac.Decoder, error) {
+	dec, err := alac.NewDecoder(rs)
+	if err != nil {
+		return nil, fmt.Errorf("stream %s: %w", name, err)
+	}
+
+	if format := dec.Format(); format.BitDepth != bitDepth || format.Channels != channels {
+		return nil, fmt.Errorf("%w: stream %s is %d-bit %d-channel, want %d-bit %d-channel",
+			ErrFormatMismatch, name, format.BitDepth, format.Channels, bitDepth, channels)
+	}
+
+	return dec, nil
+}
+
+// readFrames fills buf from dec, returning fewer bytes only at the end of the stream.
+func readFrames(dec *alac.Decoder, buf []byte, name string) (int, error) {
+	n, err := io.ReadFull(dec, buf)
+
+	if err == io.EOF { //nolint:errorlint // io.ReadFull returns io.EOF unwrapped.
+		return 0, nil
+	}
+
+	// A short read is the end of the stream, unless the source itself was truncated.
+	if errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, alac.ErrTruncatedStream) {
+		return n, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("stream %s: %w", name, err)
+	}
+
+	return n, nil
+}
+
+// frameSamples decodes the frame at byte offset off of pcm, or returns nil past its end.
+//
+//nolint:gosec // Integer conversions reinterpret little-endian PCM bytes.
+func frameSamples(pcm []byte, off, bytesPerSample, channels int) []int32 {
+	if off >= len(pcm) {
+		return nil
+	}
+
+	samples := make([]int32, channels)
+
+	for ch := range samples {
+		b := pcm[off+ch*bytesPerSample:]
+
+		switch bytesPerSample {
+		case 2:
+			samples[ch] = int32(int16(uint16(b[0]) | uint16(b[1])<<8))
+		case 3:
+			samples[ch] = int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		default:
+			samples[ch] = int32(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+		}
+	}
+
+	return samples
+}
@@ -0,0 +1,134 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alactest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+// ErrMismatch indicates decoded PCM that differs from the reference decode.
+var ErrMismatch = errors.New("alactest: decoded PCM differs from reference")
+
+// VerifyAgainst decodes the ALAC M4A stream rs packet by packet and compares
+// its PCM byte for byte with a reference decode of the same stream, such as
+// the output of another decoder read from a pipe. Each call to ref returns
+// the next chunk of reference PCM, of any length, in the decoder's output
+// layout; io.EOF, with or without a final chunk, ends it. Only one packet and
+// one chunk are held at a time, so collections of any size can be verified
+// in constant memory.
+//
+// The first difference, including either side ending before the other,
+// returns an error wrapping ErrMismatch that gives its sample frame and byte
+// offset in the PCM. Decoding errors and errors from ref are returned wrapped.
+func VerifyAgainst(rs io.ReadSeeker, ref func() ([]byte, error)) error {
+	dec, err := alac.NewDecoder(rs)
+	if err != nil {
+		return fmt.Errorf("decoder: %w", err)
+	}
+
+	bytesPerFrame := int64(dec.Format().BytesPerFrame())
+	mismatch := func(offset int64, what string) error {
+		return fmt.Errorf("%w: frame %d, byte %d: %s", ErrMismatch, offset/bytesPerFrame, offset, what)
+	}
+
+	var (
+		pending  []byte // reference PCM not compared yet
+		refEnded bool
+		offset   int64 // bytes compared so far
+	)
+
+	for {
+		pcm, err := dec.ReadZeroCopy()
+		if err == io.EOF { //nolint:errorlint // ReadZeroCopy returns io.EOF unwrapped.
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("decoder: %w", err)
+		}
+
+		for len(pcm) > 0 {
+			if len(pending) == 0 {
+				if refEnded {
+					return mismatch(offset, "reference ends first")
+				}
+
+				if pending, refEnded, err = nextChunk(ref); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			n := min(len(pcm), len(pending))
+			if idx := firstDifference(pcm[:n], pending[:n]); idx >= 0 {
+				return mismatch(offset+int64(idx), fmt.Sprintf("decoded 0x%02x, reference 0x%02x", pcm[idx], pending[idx]))
+			}
+
+			pcm = pcm[n:]
+			pending = pending[n:]
+			offset += int64(n)
+		}
+	}
+
+	for len(pending) == 0 && !refEnded {
+		if pending, refEnded, err = nextChunk(ref); err != nil {
+			return err
+		}
+	}
+
+	if len(pending) > 0 {
+		return mismatch(offset, "decoder ends first")
+	}
+
+	return nil
+}
+
+// nextChunk calls ref, reporting io.EOF as the end of the reference.
+func nextChunk(ref func() ([]byte, error)) ([]byte, bool, error) {
+	chunk, err := ref()
+	if err == io.EOF { //nolint:errorlint // io.EOF is the documented end marker.
+		return chunk, true, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("reference: %w", err)
+	}
+
+	return chunk, false, nil
+}
+
+// firstDifference returns the index of the first byte differing between a
+// and b, of equal length, or -1 if they are equal.
+func firstDifference(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return -1
+	}
+
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return idx
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,25 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// WithRejectAncillary makes decoding fail on data stream (DSE) and fill (FIL)
+// elements, which are otherwise skipped. Neither carries audio, and some
+// encoders are not expected to write them: this lets conformance tools flag
+// their presence. The error wraps ErrDecode and ErrAncillaryElement.
+func WithRejectAncillary() Option {
+	return func(o *options) { o.rejectAncillary = true }
+}
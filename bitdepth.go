@@ -0,0 +1,76 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"slices"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// WithOutputBitDepth converts decoded samples to depth bits (16, 20, 24 or
+// 32) instead of the stream's own bit depth, and PCMFormat.BitDepth reports
+// depth. This is a format coercion, not resampling.
+//
+// Widening is exact: samples are shifted left. Reducing is lossy: samples are
+// truncated, or with dither set, TPDF dither is added and the result rounded.
+// Conversion only applies to SampleInt output. The default is no conversion.
+func WithOutputBitDepth(depth int, dither bool) Option {
+	return func(o *options) {
+		o.outputBitDepth = depth
+		o.dither = dither
+	}
+}
+
+// WithDitherSeed seeds the dither noise of WithOutputBitDepth. Dither is
+// deterministic: decoders with the same seed produce identical output from
+// the same input, which keeps tests reproducible. Without this option a fixed
+// default seed is used; pass a varying value, such as the current time in
+// nanoseconds, for noise that differs between runs. Zero selects the default.
+func WithDitherSeed(seed uint64) Option {
+	return func(o *options) { o.ditherSeed = seed }
+}
+
+// setOutputBitDepth applies WithOutputBitDepth to a decoder built for the
+// stream's native depth.
+func (d *PacketDecoder) setOutputBitDepth(settings options) error {
+	depth := settings.outputBitDepth
+	if depth == 0 || depth == int(d.config.BitDepth) {
+		return nil
+	}
+
+	if depth < 0 || depth > 255 || !slices.Contains(alacBitDepths, uint8(depth)) {
+		return fmt.Errorf("%w: %w: output depth %d", ErrConfig, alacint.ErrBitDepth, depth)
+	}
+
+	if settings.sampleFormat != SampleInt {
+		return fmt.Errorf("%w: %w: output bit depth requires %s samples, not %s",
+			ErrConfig, alacint.ErrSampleFormat, SampleInt, settings.sampleFormat)
+	}
+
+	d.outDepth = uint8(depth)
+	d.sampleBytes = alacint.BytesPerSample(d.outDepth)
+	d.format.BitDepth = depth
+	d.stageBuf = make([]byte, int(d.config.FrameLength)*int(d.config.NumChannels)*d.frameBytes)
+
+	if settings.dither {
+		d.dither = alacint.NewDither(settings.ditherSeed)
+	}
+
+	return nil
+}
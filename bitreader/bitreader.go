@@ -0,0 +1,53 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package bitreader exposes the MSB-first bit reader the ALAC decoder uses,
+// for callers parsing ancillary bitstream elements (such as DSE contents)
+// from packets they extract themselves.
+package bitreader
+
+import (
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// BitReader reads a big-endian, MSB-first bitstream.
+type BitReader interface {
+	// Read reads numBits bits (at most 16) and returns them right-aligned.
+	Read(numBits uint8) uint32
+	// ReadOne reads a single bit.
+	ReadOne() uint8
+	// ByteAlign advances to the next byte boundary, if not already on one.
+	ByteAlign()
+	// Position returns the read position as a byte offset and a bit offset
+	// (0-7, from the most significant bit) within that byte.
+	Position() (byteOffset, bitOffset int)
+}
+
+var _ BitReader = (*alacint.BitBuffer)(nil)
+
+// New returns a BitReader over a copy of data, positioned at its first bit.
+//
+// Like the decoder's own reader it does not bounds-check each read: the copy
+// is padded with the decoder's 8 zero bytes, so reads starting less than 48
+// bits past the end return zeros, and reads beyond that may panic. Compare
+// Position with len(data) to detect an overrun.
+func New(data []byte) BitReader { //nolint:ireturn // The concrete reader type is internal.
+	var bits alacint.BitBuffer
+
+	bits.Reset(data)
+
+	return &bits
+}
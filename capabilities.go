@@ -0,0 +1,40 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import "slices"
+
+// SupportedBitDepths returns the bit depths the decoder accepts, in
+// increasing order: 16, 20, 24 and 32. The slice is a fresh copy.
+func SupportedBitDepths() []int {
+	depths := make([]int, len(alacBitDepths))
+	for idx, depth := range alacBitDepths {
+		depths[idx] = int(depth)
+	}
+
+	return depths
+}
+
+// MaxChannels returns the largest channel count the decoder accepts (8, for 7.1).
+func MaxChannels() int { return len(channelLayoutOffsets) }
+
+// IsSupported reports whether a stream of the given bit depth and channel
+// count can be decoded, as PacketConfig.Validate would accept it. It does
+// not depend on the platform: every supported configuration decodes everywhere.
+func IsSupported(bitDepth, channels int) bool {
+	return slices.Contains(SupportedBitDepths(), bitDepth) && channels >= 1 && channels <= MaxChannels()
+}
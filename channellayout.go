@@ -0,0 +1,306 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"math/bits"
+	"slices"
+	"strconv"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// ChannelLabel identifies the speaker a channel feeds, by its Core Audio
+// AudioChannelLabel value.
+type ChannelLabel uint32
+
+// Speaker labels, numbered as in Core Audio. Other labels found in a chan box
+// are reported by value.
+const (
+	LabelLeft ChannelLabel = iota + 1
+	LabelRight
+	LabelCenter
+	LabelLFE
+	LabelLeftSurround
+	LabelRightSurround
+	LabelLeftCenter
+	LabelRightCenter
+	LabelCenterSurround
+	LabelLeftSurroundDirect
+	LabelRightSurroundDirect
+	LabelTopCenterSurround
+	LabelVerticalHeightLeft
+	LabelVerticalHeightCenter
+	LabelVerticalHeightRight
+	LabelTopBackLeft
+	LabelTopBackCenter
+	LabelTopBackRight
+)
+
+// Speaker labels outside the contiguous range.
+const (
+	LabelRearSurroundLeft ChannelLabel = iota + 33
+	LabelRearSurroundRight
+	LabelLeftWide
+	LabelRightWide
+	LabelLFE2
+)
+
+// bitmapLabels is the number of channel bitmap bits that map to a label:
+// bit n stands for label n+1.
+const bitmapLabels = 18
+
+// standardChannelOrder ranks the known labels in the output order of the
+// standard layouts (see channelLayoutOffsets), extended with the rarer ones.
+//
+//nolint:gochecknoglobals
+var standardChannelOrder = []ChannelLabel{
+	LabelLeft, LabelRight, LabelCenter, LabelLFE, LabelLeftSurround, LabelRightSurround,
+	LabelLeftCenter, LabelRightCenter, LabelCenterSurround, LabelRearSurroundLeft, LabelRearSurroundRight,
+	LabelLeftSurroundDirect, LabelRightSurroundDirect, LabelLeftWide, LabelRightWide, LabelTopCenterSurround,
+	LabelVerticalHeightLeft, LabelVerticalHeightCenter, LabelVerticalHeightRight,
+	LabelTopBackLeft, LabelTopBackCenter, LabelTopBackRight, LabelLFE2,
+}
+
+//nolint:gochecknoglobals
+var channelLabelNames = map[ChannelLabel]string{
+	LabelLeft:                 "L",
+	LabelRight:                "R",
+	LabelCenter:               "C",
+	LabelLFE:                  "LFE",
+	LabelLeftSurround:         "Ls",
+	LabelRightSurround:        "Rs",
+	LabelLeftCenter:           "Lc",
+	LabelRightCenter:          "Rc",
+	LabelCenterSurround:       "Cs",
+	LabelLeftSurroundDirect:   "Lsd",
+	LabelRightSurroundDirect:  "Rsd",
+	LabelTopCenterSurround:    "Ts",
+	LabelVerticalHeightLeft:   "Vhl",
+	LabelVerticalHeightCenter: "Vhc",
+	LabelVerticalHeightRight:  "Vhr",
+	LabelTopBackLeft:          "Tbl",
+	LabelTopBackCenter:        "Tbc",
+	LabelTopBackRight:         "Tbr",
+	LabelRearSurroundLeft:     "Rls",
+	LabelRearSurroundRight:    "Rrs",
+	LabelLeftWide:             "Lw",
+	LabelRightWide:            "Rw",
+	LabelLFE2:                 "LFE2",
+}
+
+// String returns the conventional abbreviation of the speaker, such as "L"
+// or "LFE", or "label N" for labels without one.
+func (l ChannelLabel) String() string {
+	if name, ok := channelLabelNames[l]; ok {
+		return name
+	}
+
+	return "label " + strconv.FormatUint(uint64(l), 10)
+}
+
+// alacChannelLabels holds the bitstream order of each channel count's
+// default layout, as documented on channelLayoutOffsets.
+//
+//nolint:gochecknoglobals
+var alacChannelLabels = [8][]ChannelLabel{
+	{LabelCenter},
+	{LabelLeft, LabelRight},
+	{LabelCenter, LabelLeft, LabelRight},
+	{LabelCenter, LabelLeft, LabelRight, LabelCenterSurround},
+	{LabelCenter, LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround},
+	{LabelCenter, LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround, LabelLFE},
+	{
+		LabelCenter, LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround,
+		LabelCenterSurround, LabelLFE,
+	},
+	{
+		LabelCenter, LabelLeftCenter, LabelRightCenter, LabelLeft, LabelRight,
+		LabelLeftSurround, LabelRightSurround, LabelLFE,
+	},
+}
+
+// layoutTagLabels lists the channels of the layout tags named in
+// channelLayoutTagNames, by layout ID, in the order Core Audio defines.
+//
+//nolint:gochecknoglobals
+var layoutTagLabels = map[uint32][]ChannelLabel{
+	100: {LabelCenter},
+	101: {LabelLeft, LabelRight},
+	102: {LabelLeft, LabelRight},
+	108: {LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround},
+	113: {LabelLeft, LabelRight, LabelCenter},
+	114: {LabelCenter, LabelLeft, LabelRight},
+	115: {LabelLeft, LabelRight, LabelCenter, LabelCenterSurround},
+	116: {LabelCenter, LabelLeft, LabelRight, LabelCenterSurround},
+	117: {LabelLeft, LabelRight, LabelCenter, LabelLeftSurround, LabelRightSurround},
+	118: {LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround, LabelCenter},
+	119: {LabelLeft, LabelCenter, LabelRight, LabelLeftSurround, LabelRightSurround},
+	120: {LabelCenter, LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround},
+	121: {LabelLeft, LabelRight, LabelCenter, LabelLFE, LabelLeftSurround, LabelRightSurround},
+	122: {LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround, LabelCenter, LabelLFE},
+	123: {LabelLeft, LabelCenter, LabelRight, LabelLeftSurround, LabelRightSurround, LabelLFE},
+	124: {LabelCenter, LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround, LabelLFE},
+	125: {
+		LabelLeft, LabelRight, LabelCenter, LabelLFE, LabelLeftSurround, LabelRightSurround,
+		LabelCenterSurround,
+	},
+	126: {
+		LabelLeft, LabelRight, LabelCenter, LabelLFE, LabelLeftSurround, LabelRightSurround,
+		LabelLeftCenter, LabelRightCenter,
+	},
+	127: {
+		LabelCenter, LabelLeftCenter, LabelRightCenter, LabelLeft, LabelRight,
+		LabelLeftSurround, LabelRightSurround, LabelLFE,
+	},
+	128: {
+		LabelLeft, LabelRight, LabelCenter, LabelLFE, LabelLeftSurround, LabelRightSurround,
+		LabelRearSurroundLeft, LabelRearSurroundRight,
+	},
+	141: {
+		LabelCenter, LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround,
+		LabelCenterSurround,
+	},
+	142: {
+		LabelCenter, LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround,
+		LabelCenterSurround, LabelLFE,
+	},
+	143: {
+		LabelCenter, LabelLeft, LabelRight, LabelLeftSurround, LabelRightSurround,
+		LabelRearSurroundLeft, LabelRearSurroundRight,
+	},
+}
+
+// ChannelLayout returns the speaker of each output channel, in output order
+// and after any WithChannelSelection. The labels come from the track's chan
+// box, in whichever of its three forms it is written: a layout tag, a channel
+// bitmap or a list of channel descriptions. Tags and descriptions list the
+// channels in bitstream order, as Apple's encoder writes them. A bitmap only
+// names the speakers: they fill the output channels of the default layout in
+// standard order. Without a chan box matching the channel count, the default
+// ALAC layout applies.
+//
+// Channel descriptions, unlike tags and bitmaps, also set the output order:
+// when they name distinct speakers known to this package, the channels are
+// arranged in the standard order (L, R, C, LFE, Ls, Rs, ...) by label instead
+// of by the fixed mapping of the default layout. The two channels of a pair
+// element stay together, in bitstream order. WithNativeChannelOrder keeps
+// bitstream order regardless.
+func (s *Decoder) ChannelLayout() []ChannelLabel {
+	numChan := int(s.dec.config.NumChannels)
+	offsets := &s.dec.layoutOffsets[numChan-1]
+
+	layout := make([]ChannelLabel, numChan)
+	for idx, label := range s.labels {
+		layout[offsets[idx]] = label
+	}
+
+	if s.dec.selection == nil {
+		return layout
+	}
+
+	selected := make([]ChannelLabel, len(s.dec.selection))
+	for idx, ch := range s.dec.selection {
+		selected[idx] = layout[ch]
+	}
+
+	return selected
+}
+
+// streamLabels returns the label of each bitstream channel from the chan box
+// layout, and whether they came from channel descriptions.
+func streamLabels(layout mp4int.ChannelLayout, numChan int) ([]ChannelLabel, bool) {
+	switch {
+	case layout.Tag == mp4int.ChannelLayoutUseDescriptions && len(layout.Labels) == numChan:
+		labels := make([]ChannelLabel, numChan)
+		for idx, label := range layout.Labels {
+			labels[idx] = ChannelLabel(label)
+		}
+
+		return labels, true
+	case layout.Tag == mp4int.ChannelLayoutUseBitmap && layout.Bitmap < 1<<bitmapLabels &&
+		bits.OnesCount32(layout.Bitmap) == numChan:
+		// Bit order is standard order: the speakers fill the default output positions.
+		speakers := make([]ChannelLabel, 0, numChan)
+		for bit := range bitmapLabels {
+			if layout.Bitmap&(1<<bit) != 0 {
+				speakers = append(speakers, ChannelLabel(bit+1))
+			}
+		}
+
+		labels := make([]ChannelLabel, numChan)
+		for idx, pos := range channelLayoutOffsets[numChan-1][:numChan] {
+			labels[idx] = speakers[pos]
+		}
+
+		return labels, false
+	case int(layout.Tag&0xFFFF) == numChan:
+		if labels, ok := layoutTagLabels[layout.Tag>>16]; ok {
+			return labels, false
+		}
+	}
+
+	return alacChannelLabels[numChan-1], false
+}
+
+// alacPairStarts lists the bitstream channels opening a channel pair element
+// in the element layout of each channel count (5.1: SCE, CPE, CPE, LFE). A
+// pair is decoded into consecutive output channels, so it moves as one.
+//
+//nolint:gochecknoglobals
+var alacPairStarts = [8][]int{nil, {0}, {1}, {1}, {1, 3}, {1, 3}, {1, 3}, {1, 3, 5}}
+
+// descriptionOffsets returns the bitstream to output channel mapping that
+// arranges channels labelled by descriptions in standard order, or nil when a
+// label is unknown or repeated. Elements are ordered by the rank of their
+// first channel, pairs keeping their own order.
+func descriptionOffsets(labels []ChannelLabel) *[8][8]int {
+	numChan := len(labels)
+	pairs := alacPairStarts[numChan-1]
+
+	var elements []int // first bitstream channel of each element
+
+	for idx, label := range labels {
+		rank := slices.Index(standardChannelOrder, label)
+		if rank < 0 || slices.Index(labels, label) != idx {
+			return nil
+		}
+
+		if !slices.Contains(pairs, idx-1) {
+			elements = append(elements, idx)
+		}
+	}
+
+	slices.SortStableFunc(elements, func(a, b int) int {
+		return slices.Index(standardChannelOrder, labels[a]) - slices.Index(standardChannelOrder, labels[b])
+	})
+
+	offsets := channelLayoutOffsets
+	pos := 0
+
+	for _, first := range elements {
+		offsets[numChan-1][first] = pos
+		pos++
+
+		if slices.Contains(pairs, first) {
+			offsets[numChan-1][first+1] = pos
+			pos++
+		}
+	}
+
+	return &offsets
+}
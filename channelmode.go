@@ -0,0 +1,116 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import "slices"
+
+// ChannelMode classifies the channel layout of the decoded output.
+type ChannelMode int
+
+// Channel modes.
+const (
+	Mono ChannelMode = iota
+	Stereo
+	// Multichannel is any layout of three or more channels, such as 5.1.
+	Multichannel
+)
+
+// String returns a short name for the channel mode.
+func (m ChannelMode) String() string {
+	switch m {
+	case Mono:
+		return "mono"
+	case Stereo:
+		return "stereo"
+	case Multichannel:
+		return "multichannel"
+	default:
+		return "unknown"
+	}
+}
+
+// ChannelMode classifies the output by its channel count, after any
+// WithChannelSelection.
+func (s *Decoder) ChannelMode() ChannelMode {
+	switch s.dec.format.Channels {
+	case 1:
+		return Mono
+	case 2: //revive:disable-line:add-constant
+		return Stereo
+	default:
+		return Multichannel
+	}
+}
+
+// HasLFE reports whether the output carries an LFE channel, as labelled by
+// ChannelLayout: the default 5.1, 6.1 and 7.1 layouts have one, and
+// WithChannelSelection, if set, must keep it.
+func (s *Decoder) HasLFE() bool {
+	return slices.Contains(s.ChannelLayout(), LabelLFE)
+}
+
+// channelLayoutNames names the standard layout of each channel count, in the
+// bitstream order documented on channelLayoutOffsets.
+//
+//nolint:gochecknoglobals
+var channelLayoutNames = [8]string{"mono", "stereo", "3.0", "4.0", "5.0", "5.1", "6.1", "7.1"}
+
+// channelLayoutTagNames names Core Audio layout tags by their layout ID, the
+// upper 16 bits of the tag (the lower 16 are the channel count).
+//
+//nolint:gochecknoglobals
+var channelLayoutTagNames = map[uint32]string{
+	100: "mono",   // Mono
+	101: "stereo", // Stereo
+	102: "stereo", // StereoHeadphones
+	108: "quad",   // Quadraphonic
+	113: "3.0",    // MPEG_3_0_A
+	114: "3.0",    // MPEG_3_0_B
+	115: "4.0",    // MPEG_4_0_A
+	116: "4.0",    // MPEG_4_0_B
+	117: "5.0",    // MPEG_5_0_A
+	118: "5.0",    // MPEG_5_0_B
+	119: "5.0",    // MPEG_5_0_C
+	120: "5.0",    // MPEG_5_0_D
+	121: "5.1",    // MPEG_5_1_A
+	122: "5.1",    // MPEG_5_1_B
+	123: "5.1",    // MPEG_5_1_C
+	124: "5.1",    // MPEG_5_1_D
+	125: "6.1",    // MPEG_6_1_A
+	126: "7.1",    // MPEG_7_1_A
+	127: "7.1",    // MPEG_7_1_B
+	128: "7.1",    // MPEG_7_1_C
+	141: "6.0",    // AAC_6_0
+	142: "6.1",    // AAC_6_1
+	143: "7.0",    // AAC_7_0
+}
+
+// ChannelLayoutName returns the conventional name of the stream's channel
+// layout, such as "stereo", "5.1" or "7.1", for tagging without decoding.
+// The layout tag of the track's chan box is used when it is a known one
+// ("quad" is only reported this way) and agrees with the channel count;
+// otherwise the name follows from the channel count. It describes the
+// stream, regardless of WithChannelSelection.
+func (s *Decoder) ChannelLayoutName() string {
+	numChannels := uint32(s.dec.config.NumChannels)
+
+	if name, ok := channelLayoutTagNames[s.layout.Tag>>16]; ok && s.layout.Tag&0xFFFF == numChannels {
+		return name
+	}
+
+	return channelLayoutNames[numChannels-1]
+}
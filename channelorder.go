@@ -0,0 +1,49 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// WithNativeChannelOrder keeps multichannel output in the bitstream's MPEG
+// element order (5.1: C, L, R, Ls, Rs, LFE), as Apple's decoder returns it,
+// instead of remapping it to SMPTE order (L, R, C, LFE, Ls, Rs). Mono and
+// stereo are unaffected. WithChannelSelection indices then refer to
+// bitstream positions.
+func WithNativeChannelOrder() Option {
+	return func(o *options) { o.nativeChannelOrder = true }
+}
+
+// nativeChannelOffsets maps every bitstream channel to its own output position.
+//
+//nolint:gochecknoglobals
+var nativeChannelOffsets = [8][8]int{
+	{0},
+	{0, 1},
+	{0, 1, 2},
+	{0, 1, 2, 3},
+	{0, 1, 2, 3, 4},
+	{0, 1, 2, 3, 4, 5},
+	{0, 1, 2, 3, 4, 5, 6},
+	{0, 1, 2, 3, 4, 5, 6, 7},
+}
+
+// layoutOffsets returns the bitstream to output channel mapping for the chosen order.
+func layoutOffsets(native bool) *[8][8]int {
+	if native {
+		return &nativeChannelOffsets
+	}
+
+	return &channelLayoutOffsets
+}
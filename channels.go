@@ -0,0 +1,82 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"slices"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// WithChannelSelection outputs only the given channels, interleaved in the
+// given order, instead of every channel. Indices refer to output channel
+// positions (after the MPEG to SMPTE remap, e.g. 2 is the center channel of a
+// 5.1 stream; bitstream positions under WithNativeChannelOrder), and
+// PCMFormat.Channels reports len(indices). Every element is still decoded, as
+// channels are interleaved in the bitstream, but only the selected ones are
+// copied to the output. An empty selection keeps every channel, which is the
+// default; an out-of-range index returns ErrConfig.
+func WithChannelSelection(indices []int) Option {
+	return func(o *options) { o.channelSelection = slices.Clone(indices) }
+}
+
+// setChannelSelection applies WithChannelSelection, after any bit-depth conversion is set up.
+func (d *PacketDecoder) setChannelSelection(selection []int) error {
+	if len(selection) == 0 {
+		return nil
+	}
+
+	numChan := int(d.config.NumChannels)
+
+	for _, ch := range selection {
+		if ch < 0 || ch >= numChan {
+			return fmt.Errorf("%w: %w: selected channel %d of %d", ErrConfig, alacint.ErrChannelCount, ch, numChan)
+		}
+	}
+
+	frameLen := int(d.config.FrameLength)
+
+	d.selection = selection
+	d.format.Channels = len(selection)
+
+	if d.stageBuf == nil {
+		d.stageBuf = make([]byte, frameLen*numChan*d.frameBytes)
+	}
+
+	if d.outDepth != 0 {
+		d.selectBuf = make([]byte, frameLen*len(selection)*d.frameBytes)
+	}
+
+	return nil
+}
+
+// selectChannels copies the selected channels of each interleaved frame in src
+// to dst and returns the number of bytes written.
+func selectChannels(dst, src []byte, selection []int, numChan, sampleBytes int) int {
+	frameBytes := numChan * sampleBytes
+	written := 0
+
+	for frame := 0; frame+frameBytes <= len(src); frame += frameBytes {
+		for _, ch := range selection {
+			start := frame + ch*sampleBytes
+			written += copy(dst[written:written+sampleBytes], src[start:start+sampleBytes])
+		}
+	}
+
+	return written
+}
@@ -25,7 +25,6 @@ package main
 
 import (
 	"bytes"
-	"encoding/binary"
 	"flag"
 	"fmt"
 	"io"
@@ -89,19 +88,12 @@ func run(format, inputPath string) int {
 		pcmFormat.SampleRate, pcmFormat.BitDepth, pcmFormat.Channels)
 
 	if format == formatWAV {
-		// WAV header requires total data size upfront.
-		pcm, err := io.ReadAll(dec)
-		if err != nil {
+		// The header is sized from the sample table, so PCM streams straight through.
+		if _, err := alac.EncodeWAV(os.Stdout, dec); err != nil {
 			fmt.Fprintf(os.Stderr, "decode: %v\n", err)
 
 			return 1
 		}
-
-		if err := writeWAV(os.Stdout, pcm, pcmFormat); err != nil {
-			fmt.Fprintf(os.Stderr, "write: %v\n", err)
-
-			return 1
-		}
 	} else {
 		if _, err := io.Copy(os.Stdout, dec); err != nil {
 			fmt.Fprintf(os.Stderr, "write: %v\n", err)
@@ -131,39 +123,3 @@ func openInput(path string) (io.ReadSeeker, func(), error) {
 
 	return f, func() { _ = f.Close() }, nil
 }
-
-// writeWAV writes a standard PCM WAV to writer.
-func writeWAV(writer io.Writer, pcm []byte, format alac.PCMFormat) error {
-	bytesPerSample := format.BitDepth / 8
-	blockAlign := format.Channels * bytesPerSample
-	byteRate := format.SampleRate * blockAlign
-	dataSize := len(pcm)
-
-	var hdr [44]byte
-
-	copy(hdr[0:4], "RIFF")
-	binary.LittleEndian.PutUint32(hdr[4:8], uint32(36+dataSize))
-	copy(hdr[8:12], "WAVE")
-
-	copy(hdr[12:16], "fmt ")
-	binary.LittleEndian.PutUint32(hdr[16:20], 16)
-	binary.LittleEndian.PutUint16(hdr[20:22], 1) // PCM
-	binary.LittleEndian.PutUint16(hdr[22:24], uint16(format.Channels))
-	binary.LittleEndian.PutUint32(hdr[24:28], uint32(format.SampleRate))
-	binary.LittleEndian.PutUint32(hdr[28:32], uint32(byteRate))
-	binary.LittleEndian.PutUint16(hdr[32:34], uint16(blockAlign))
-	binary.LittleEndian.PutUint16(hdr[34:36], uint16(format.BitDepth))
-
-	copy(hdr[36:40], "data")
-	binary.LittleEndian.PutUint32(hdr[40:44], uint32(dataSize))
-
-	if _, err := writer.Write(hdr[:]); err != nil {
-		return fmt.Errorf("writing WAV header: %w", err)
-	}
-
-	if _, err := writer.Write(pcm); err != nil {
-		return fmt.Errorf("writing WAV data: %w", err)
-	}
-
-	return nil
-}
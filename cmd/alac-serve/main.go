@@ -0,0 +1,254 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// alac-serve serves an ALAC M4A file over HTTP as WAV, with byte-range
+// support so that browsers and players can seek.
+//
+// Usage:
+//
+//	alac-serve [-addr host:port] <input.m4a>
+//
+// Every request decodes the file afresh. Range requests seek the decoder to
+// the first requested frame: whole packets are skipped without being decoded.
+//
+//nolint:gosec // Integer conversions are bounded by audio format constraints; file paths from CLI args.
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/version"
+)
+
+// wavHeaderSize is the size of the canonical PCM WAV header.
+const wavHeaderSize = 44
+
+// errNegativeOffset is returned when seeking before the start of the WAV.
+var errNegativeOffset = errors.New("seek before start of stream")
+
+func main() {
+	showVersion := flag.Bool("version", false, "print version and exit")
+	addr := flag.String("addr", "127.0.0.1:8080", "listen address")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-addr host:port] <input.m4a>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Fprintln(os.Stdout, version.String())
+		os.Exit(0)
+	}
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	os.Exit(run(*addr, flag.Arg(0)))
+}
+
+func run(addr, inputPath string) int {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "serving %s on http://%s/\n", inputPath, listener.Addr())
+
+	server := &http.Server{
+		Handler:           handler(inputPath),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if err := server.Serve(listener); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+// handler serves inputPath as WAV.
+func handler(inputPath string) http.Handler {
+	name := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath)) + ".wav"
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		file, err := os.Open(inputPath)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		// Reject non-ALAC input cheaply, before the sample table is built.
+		if _, err := alac.ProbeFormat(file); err != nil {
+			http.Error(writer, err.Error(), http.StatusUnsupportedMediaType)
+
+			return
+		}
+
+		wav, err := newWAVSource(file)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		http.ServeContent(writer, req, name, info.ModTime(), wav)
+	})
+}
+
+// wavSource presents a decoded ALAC stream as a seekable WAV file.
+type wavSource struct {
+	dec        *alac.Decoder
+	header     []byte
+	frameBytes int64
+	size       int64 // header plus PCM data
+	pos        int64
+}
+
+// newWAVSource opens the decoder and measures the PCM length for the header.
+func newWAVSource(input io.ReadSeeker) (*wavSource, error) {
+	// Media time: frame 0 is the first decoded frame, so byte offsets map directly to frames.
+	dec, err := alac.NewDecoder(input, alac.WithMediaTime())
+	if err != nil {
+		return nil, fmt.Errorf("opening decoder: %w", err)
+	}
+
+	// Skipping to the end decodes only the final, possibly partial, packet.
+	frames, err := dec.Skip(math.MaxInt64)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("measuring stream: %w", err)
+	}
+
+	if _, err := dec.Seek(0); err != nil {
+		return nil, fmt.Errorf("rewinding: %w", err)
+	}
+
+	format := dec.Format()
+	frameBytes := int64(format.BytesPerFrame())
+	dataSize := frames * frameBytes
+
+	return &wavSource{
+		dec:        dec,
+		header:     wavHeader(format, dataSize),
+		frameBytes: frameBytes,
+		size:       wavHeaderSize + dataSize,
+	}, nil
+}
+
+// Read implements io.Reader.
+func (w *wavSource) Read(p []byte) (int, error) {
+	if w.pos < wavHeaderSize {
+		n := copy(p, w.header[w.pos:])
+		w.pos += int64(n)
+
+		return n, nil
+	}
+
+	n, err := w.dec.Read(p)
+	w.pos += int64(n)
+
+	return n, err //nolint:wrapcheck // io.Reader contract: io.EOF is returned unwrapped.
+}
+
+// Seek implements io.Seeker. The decoder is positioned on the frame holding
+// the offset, then the bytes before the offset within that frame are discarded.
+func (w *wavSource) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += w.pos
+	case io.SeekEnd:
+		offset += w.size
+	default:
+	}
+
+	if offset < 0 {
+		return w.pos, errNegativeOffset
+	}
+
+	data := max(offset-wavHeaderSize, 0)
+
+	if _, err := w.dec.Seek(0); err != nil {
+		return w.pos, fmt.Errorf("seeking: %w", err)
+	}
+
+	if _, err := w.dec.Skip(data / w.frameBytes); err != nil && !errors.Is(err, io.EOF) {
+		return w.pos, fmt.Errorf("skipping: %w", err)
+	}
+
+	if partial := data % w.frameBytes; partial != 0 {
+		if _, err := io.CopyN(io.Discard, w.dec, partial); err != nil && !errors.Is(err, io.EOF) {
+			return w.pos, fmt.Errorf("skipping: %w", err)
+		}
+	}
+
+	w.pos = offset
+
+	return offset, nil
+}
+
+// wavHeader returns a canonical PCM WAV header. 20-bit audio is left-aligned
+// in 3 bytes, so it is declared as 24-bit.
+func wavHeader(format alac.PCMFormat, dataSize int64) []byte {
+	blockAlign := format.BytesPerFrame()
+	hdr := make([]byte, wavHeaderSize)
+
+	copy(hdr[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(min(36+dataSize, math.MaxUint32)))
+	copy(hdr[8:12], "WAVE")
+
+	copy(hdr[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(hdr[16:20], 16)
+	binary.LittleEndian.PutUint16(hdr[20:22], 1) // PCM
+	binary.LittleEndian.PutUint16(hdr[22:24], uint16(format.Channels))
+	binary.LittleEndian.PutUint32(hdr[24:28], uint32(format.SampleRate))
+	binary.LittleEndian.PutUint32(hdr[28:32], uint32(format.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(hdr[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(hdr[34:36], uint16(format.BytesPerSample()*8))
+
+	copy(hdr[36:40], "data")
+	binary.LittleEndian.PutUint32(hdr[40:44], uint32(min(dataSize, math.MaxUint32)))
+
+	return hdr
+}
@@ -19,6 +19,7 @@ package alac
 import (
 	"encoding/binary"
 	"fmt"
+	"slices"
 
 	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
 )
@@ -37,45 +38,117 @@ type PacketConfig struct {
 	SampleRate    uint32
 }
 
+// MarshalCookie serializes the configuration as the 24-byte
+// ALACSpecificConfig that ParseMagicCookie reads (compatible version 0),
+// without atom wrappers.
+func (c PacketConfig) MarshalCookie() []byte {
+	data := make([]byte, configSize)
+	binary.BigEndian.PutUint32(data[0:4], c.FrameLength)
+	data[5] = c.BitDepth
+	data[6] = c.PB
+	data[7] = c.MB
+	data[8] = c.KB
+	data[9] = c.NumChannels
+	binary.BigEndian.PutUint16(data[10:12], c.MaxRun)
+	binary.BigEndian.PutUint32(data[12:16], c.MaxFrameBytes)
+	binary.BigEndian.PutUint32(data[16:20], c.AvgBitRate)
+	binary.BigEndian.PutUint32(data[20:24], c.SampleRate)
+
+	return data
+}
+
+// String formats the configuration on one line, e.g.
+// "44100Hz/16bit/2ch frame=4096 pb=40 mb=10 kb=14 maxRun=255".
+func (c PacketConfig) String() string {
+	return fmt.Sprintf("%dHz/%dbit/%dch frame=%d pb=%d mb=%d kb=%d maxRun=%d",
+		c.SampleRate, c.BitDepth, c.NumChannels, c.FrameLength, c.PB, c.MB, c.KB, c.MaxRun)
+}
+
+// Validate reports whether the decoder can handle the configuration: a
+// supported bit depth, 1 to 8 channels, and non-zero frame length and sample
+// rate. Errors wrap ErrConfig. The FrameLength cap of WithMaxFrameLength is
+// checked separately, by NewPacketDecoder.
+func (c PacketConfig) Validate() error {
+	if !slices.Contains(alacBitDepths, c.BitDepth) {
+		return fmt.Errorf("%w: %w: %d", ErrConfig, alacint.ErrBitDepth, c.BitDepth)
+	}
+
+	if c.NumChannels == 0 || int(c.NumChannels) > len(channelLayoutOffsets) {
+		return fmt.Errorf("%w: %w: %d", ErrConfig, alacint.ErrChannelCount, c.NumChannels)
+	}
+
+	// Buffers are sized from the frame length.
+	if c.FrameLength == 0 {
+		return fmt.Errorf("%w: %w", ErrConfig, alacint.ErrZeroFrameLength)
+	}
+
+	// Durations and seek targets divide by the sample rate.
+	if c.SampleRate == 0 {
+		return fmt.Errorf("%w: %w", ErrConfig, alacint.ErrSampleRate)
+	}
+
+	return nil
+}
+
 const (
 	configSize     = 24 // ALACSpecificConfig binary size.
 	atomHeaderSize = 12 // MPEG4 atom header: size (4) + type (4) + payload (4).
 )
 
+// DefaultFrameLength is the frame length of Apple's encoder, assumed under
+// WithLenient for configurations that leave FrameLength zero.
+const DefaultFrameLength = 4096
+
 // ParseMagicCookie reads an ALACSpecificConfig from a magic cookie byte slice.
-// Handles legacy wrappers ('frma' and 'alac' atoms).
+// Handles legacy wrappers ('frma' and 'alac' atoms). The configuration read
+// is checked with Validate.
 func ParseMagicCookie(cookie []byte) (PacketConfig, error) {
-	data := cookie
+	return parseMagicCookie(cookie, false)
+}
 
-	// Skip 'frma' atom if present: [size:4][type:'frma'][format:'alac']
-	if len(data) >= atomHeaderSize && data[4] == 'f' && data[5] == 'r' && data[6] == 'm' && data[7] == 'a' {
-		data = data[atomHeaderSize:]
+// parseMagicCookie is ParseMagicCookie, letting a zero FrameLength through
+// when lenient is set for NewPacketDecoder to replace.
+func parseMagicCookie(cookie []byte, lenient bool) (PacketConfig, error) {
+	// Skip 'frma' atom if present: [size:4][type:'frma'][format:'alac'],
+	// then the 'alac' atom header if present: [size:4][type:'alac'][version:4].
+	data := skipAtomHeader(skipAtomHeader(cookie, "frma"), "alac")
+
+	if len(data) < configSize {
+		return PacketConfig{}, fmt.Errorf("%w: %w", ErrConfig, alacint.ErrInvalidCookie)
 	}
 
-	// Skip 'alac' atom header if present: [size:4][type:'alac'][version:4]
-	if len(data) >= atomHeaderSize && data[4] == 'a' && data[5] == 'l' && data[6] == 'a' && data[7] == 'c' {
-		data = data[atomHeaderSize:]
+	specific := parseSpecificConfig(data)
+	if specific.CompatibleVersion > 0 {
+		return PacketConfig{}, fmt.Errorf("%w: %w: %d",
+			ErrConfig, alacint.ErrUnsupportedVersion, specific.CompatibleVersion)
 	}
 
-	if len(data) < configSize {
-		return PacketConfig{}, fmt.Errorf("%w: %w", ErrConfig, alacint.ErrInvalidCookie)
+	config := specific.packetConfig()
+
+	if err := defaultFrameLength(config, lenient).Validate(); err != nil {
+		return PacketConfig{}, err
 	}
 
-	compatibleVersion := data[4]
-	if compatibleVersion > 0 {
-		return PacketConfig{}, fmt.Errorf("%w: %w: %d", ErrConfig, alacint.ErrUnsupportedVersion, compatibleVersion)
+	return config, nil
+}
+
+// defaultFrameLength returns config with a zero FrameLength replaced by
+// DefaultFrameLength when lenient is set.
+func defaultFrameLength(config PacketConfig, lenient bool) PacketConfig {
+	if lenient && config.FrameLength == 0 {
+		config.FrameLength = DefaultFrameLength
+	}
+
+	return config
+}
+
+// skipAtomHeader returns data past its first atomHeaderSize bytes if they are
+// the header of a fourCC atom, and data unchanged otherwise, including when
+// it is too short to hold the header.
+func skipAtomHeader(data []byte, fourCC string) []byte {
+	if len(data) < atomHeaderSize || string(data[4:8]) != fourCC {
+		return data
 	}
 
-	return PacketConfig{
-		FrameLength:   binary.BigEndian.Uint32(data[0:4]),
-		BitDepth:      data[5],
-		PB:            data[6],
-		MB:            data[7],
-		KB:            data[8],
-		NumChannels:   data[9],
-		MaxRun:        binary.BigEndian.Uint16(data[10:12]),
-		MaxFrameBytes: binary.BigEndian.Uint32(data[12:16]),
-		AvgBitRate:    binary.BigEndian.Uint32(data[16:20]),
-		SampleRate:    binary.BigEndian.Uint32(data[20:24]),
-	}, nil
+	return data[atomHeaderSize:]
 }
@@ -0,0 +1,117 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"io"
+
+	mkvint "github.com/mycophonic/saprobe-alac/internal/mkv"
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+	oggint "github.com/mycophonic/saprobe-alac/internal/ogg"
+)
+
+// findTrack locates the first ALAC track of an MP4, Matroska or Ogg stream,
+// telling them apart by the stream's first bytes. Matroska and Ogg tracks are
+// returned in the MP4 form, without timing or edit list. The offsets of Ogg
+// packets, which pages may split, index their packet data instead of the
+// stream: spans locates it, and is nil for the other containers.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func findTrack(
+	rs io.ReadSeeker, trace func(event string, args ...any),
+) (mp4int.Track, ContainerType, []oggint.Span, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return mp4int.Track{}, ContainerUnknown, nil, fmt.Errorf("%w: seeking to start: %w", ErrNoTrack, err)
+	}
+
+	var magic [4]byte
+
+	// A short stream is left for the MP4 parser to reject.
+	n, _ := io.ReadFull(rs, magic[:])
+
+	switch {
+	case mkvint.IsMatroska(magic[:n]):
+		mkvTrack, err := mkvint.FindALACTrack(rs, trace)
+		if err != nil {
+			return mp4int.Track{}, ContainerUnknown, nil, fmt.Errorf("%w: %w", ErrNoTrack, err)
+		}
+
+		track := mp4int.Track{
+			ID:      uint32(min(mkvTrack.Number, uint64(^uint32(0)))),
+			Cookie:  mkvTrack.Cookie,
+			Samples: make([]mp4int.SampleInfo, len(mkvTrack.Packets)),
+		}
+
+		for idx, packet := range mkvTrack.Packets {
+			track.Samples[idx] = mp4int.SampleInfo{Offset: packet.Offset, Size: packet.Size}
+		}
+
+		return track, ContainerMatroska, nil, nil
+	case oggint.IsOgg(magic[:n]):
+		oggTrack, err := oggint.FindALACTrack(rs, trace)
+		if err != nil {
+			return mp4int.Track{}, ContainerUnknown, nil, fmt.Errorf("%w: %w", ErrNoTrack, err)
+		}
+
+		track := mp4int.Track{
+			ID:      oggTrack.Serial,
+			Cookie:  oggTrack.Cookie,
+			Samples: make([]mp4int.SampleInfo, len(oggTrack.Packets)),
+		}
+
+		for idx, packet := range oggTrack.Packets {
+			track.Samples[idx] = mp4int.SampleInfo{Offset: packet.Offset, Size: packet.Size}
+		}
+
+		return track, ContainerOgg, oggTrack.Spans, nil
+	default:
+		track, err := mp4int.FindALACTrack(rs, trace)
+		if err != nil {
+			return mp4int.Track{}, ContainerUnknown, nil, fmt.Errorf("%w: %w", ErrNoTrack, err)
+		}
+
+		if track.Fragmented {
+			return track, ContainerFragmentedMP4, nil, nil
+		}
+
+		return track, ContainerMP4, nil, nil
+	}
+}
+
+// packetReader returns the reader packets are fetched from: rs itself, or
+// the Ogg packet data that spans locate in it.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func packetReader(rs io.ReadSeeker, spans []oggint.Span) io.ReadSeeker {
+	if spans == nil {
+		return rs
+	}
+
+	return oggint.NewReader(rs, spans)
+}
+
+// packetReaderAt is packetReader for a source of the given size read through
+// io.ReaderAt. The Ogg packet data is read with ReadAt, so concurrent use
+// stays safe.
+func packetReaderAt(r io.ReaderAt, size int64, spans []oggint.Span) io.ReaderAt {
+	if spans == nil {
+		return r
+	}
+
+	return oggint.NewReader(io.NewSectionReader(r, 0, size), spans)
+}
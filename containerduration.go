@@ -0,0 +1,77 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// ContainerDuration returns the presentation duration declared by the movie
+// header (mvhd), or 0 if it declares none. Unlike Duration, it is not derived
+// from the packets: when the two disagree, the file was likely truncated or
+// edited, and Warnings reports the mismatch.
+func (s *Decoder) ContainerDuration() time.Duration {
+	return s.containerDuration
+}
+
+// checkContainerDuration records the mvhd duration and warns when it
+// disagrees with the media by more than one frame. The final packet may be
+// partial, so the media ends anywhere within it. An edit list legitimately
+// trims priming and padding, so then only a longer declared duration is
+// suspicious.
+func (s *Decoder) checkContainerDuration(track mp4int.Track) {
+	timescale := uint64(track.MovieTimescale)
+	if timescale == 0 || track.MovieDuration == 0 {
+		return
+	}
+
+	s.containerDuration = time.Duration(min(mulDiv(track.MovieDuration, uint64(time.Second), timescale), math.MaxInt64))
+
+	declared := int64(min(mulDiv(track.MovieDuration, uint64(s.dec.config.SampleRate), timescale), math.MaxInt64))
+	end := s.packetFrame(len(s.samples))
+
+	var lastStart int64
+	if len(s.samples) > 0 {
+		lastStart = s.packetFrame(len(s.samples) - 1)
+	}
+
+	if declared > end+1 || (!track.HasEditList && declared < lastStart) {
+		s.warnings = append(s.warnings, Warning{
+			Packet:  -1,
+			Channel: -1,
+			Err: fmt.Errorf("%w: mvhd declares %d frames, packets hold %d to %d",
+				mp4int.ErrDuration, declared, lastStart, end),
+		})
+	}
+}
+
+// mulDiv returns a*b/c, saturating instead of overflowing.
+func mulDiv(a, b, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	if hi >= c {
+		return math.MaxUint64
+	}
+
+	quo, _ := bits.Div64(hi, lo, c)
+
+	return quo
+}
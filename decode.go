@@ -18,119 +18,517 @@
 package alac
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/bits"
+	"sort"
 	"time"
 
-	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
 	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
 )
 
-// Decoder streams decoded PCM from an ALAC M4A/MP4 source.
-// The MP4 container (sample table, config) is parsed upfront; packets are
+// Decoder streams decoded PCM from an ALAC M4A/MP4, Matroska or Ogg source.
+// The container (sample table, config) is parsed upfront; packets are
 // decoded on demand via Read.
 type Decoder struct {
 	reader    io.ReadSeeker
+	readerAt  io.ReaderAt // when set, packets are fetched with ReadAt instead of Seek+Read
+	readAtEnd int64       // end of the last ReadAt, reported by ReaderOffset
 	dec       *PacketDecoder
 	samples   []mp4int.SampleInfo
 	sampleIdx int
+	trackID   uint32 // container track ID, checked by RefreshSampleTable
 	packetBuf []byte
+	info      StreamInfo
 
 	// Per-packet PCM buffer, drained by Read.
 	buf    []byte
 	bufOff int
 	eof    bool
+	err    error // sticky truncation error, reported once buf is drained
+
+	framesErr error // error that stopped the last Frames iteration
+
+	// Edit-list silence (WithEditListSilence).
+	gaps     []silenceGap
+	gapIdx   int
+	gapBytes int64 // silent bytes left to emit for the active gap
+
+	preroll []mp4int.SampleRange // packets sample groups mark as not independently decodable
+
+	// Media frame at presentation time zero (see WithMediaTime).
+	presentationOffset int64
+
+	// Presented media range from the edit list, regardless of WithMediaTime:
+	// its first media frame, and its length in frames (0 without an edit list).
+	editStart  int64
+	editFrames int64
+
+	// End of the final packet from stts, in frames (0 without stts).
+	endFrame int64
+
+	// Every packet but the last holds FrameLength frames, so packet starts are computed.
+	constantFrames bool
+
+	specific ALACSpecificConfig // the track's cookie as stored
+
+	maxBitRate uint32               // btrt maxBitrate, or the cookie's average bit rate
+	layout     mp4int.ChannelLayout // Core Audio channel layout from the chan box, zero when absent
+	labels     []ChannelLabel       // speaker of each bitstream channel (ChannelLayout)
+
+	// mvhd duration, and container-level warnings such as its mismatch with the media.
+	containerDuration time.Duration
+	warnings          []Warning
+
+	// Final frame padding (WithPaddedFinalFrame).
+	padFinal     bool
+	finalPadding int
+
+	// Media frames dropped at each end (WithTrimStart, WithTrimEnd).
+	trimStart int64
+	trimEnd   int64
+
+	// End the stream at the first undecodable packet (WithStopOnError).
+	stopOnError bool
+
+	// The buffered packet was decoded by ReadStereoFrames: its samples are in
+	// the packet decoder's mix buffers, and buf holds only their length.
+	planarBuf bool
+
+	stats   *decodeStats    // nil without WithStats
+	timings []time.Duration // per-packet decode time, nil without WithPacketTiming
 }
 
-// NewDecoder opens an M4A/MP4 stream containing ALAC audio and returns
-// a streaming decoder. The container structure is parsed immediately; PCM data
-// is decoded packet-by-packet on demand via Read.
+// NewDecoder opens an M4A/MP4, Matroska or Ogg stream containing ALAC audio
+// and returns a streaming decoder. The container structure is parsed
+// immediately; PCM data is decoded packet-by-packet on demand via Read.
 //
 //nolint:varnamelen // rs is idiomatic for io.ReadSeeker
-func NewDecoder(rs io.ReadSeeker) (*Decoder, error) {
-	cookie, samples, err := mp4int.FindALACTrack(rs)
+func NewDecoder(rs io.ReadSeeker, opts ...Option) (*Decoder, error) {
+	settings := newOptions(opts)
+
+	track, container, spans, err := findTrack(rs, settings.trace)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", ErrNoTrack, err)
+		return nil, err
 	}
 
-	config, err := ParseMagicCookie(cookie)
+	config, err := parseMagicCookie(track.Cookie, settings.lenient)
 	if err != nil {
 		return nil, fmt.Errorf("parsing ALAC config: %w", err)
 	}
 
-	dec, err := NewPacketDecoder(config)
+	decoder, err := newDecoder(track, config, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	bps := alacint.BytesPerSample(config.BitDepth)
-	frameBytes := int(config.FrameLength) * int(config.NumChannels) * bps
+	decoder.reader = packetReader(rs, spans)
+	decoder.info.Container = container
+
+	return decoder, nil
+}
+
+// NewDecoderWithConfig is like NewDecoder, but decodes with config instead of
+// the magic cookie embedded in the container. The container must still hold
+// an ALAC track, whose sample table is used; its cookie is not parsed, so a
+// damaged one does not prevent decoding. This is a recovery tool for files
+// whose bitstream is intact but whose cookie is wrong (e.g. a bad MaxRun).
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func NewDecoderWithConfig(rs io.ReadSeeker, config PacketConfig, opts ...Option) (*Decoder, error) {
+	settings := newOptions(opts)
+
+	track, container, spans, err := findTrack(rs, settings.trace)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := newDecoder(track, config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder.reader = packetReader(rs, spans)
+	decoder.info.Container = container
+
+	return decoder, nil
+}
+
+// NewDecoderAt opens an M4A/MP4 source of the given size through io.ReaderAt.
+// Both container parsing and packet reads use ReadAt, so no seek cursor is
+// shared with other users of r: independent decoders (or goroutines fetching
+// packets in parallel) can operate on the same source concurrently.
+func NewDecoderAt(r io.ReaderAt, size int64, opts ...Option) (*Decoder, error) {
+	settings := newOptions(opts)
+
+	track, container, spans, err := findTrack(io.NewSectionReader(r, 0, size), settings.trace)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := parseMagicCookie(track.Cookie, settings.lenient)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ALAC config: %w", err)
+	}
+
+	decoder, err := newDecoder(track, config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder.readerAt = packetReaderAt(r, size, spans)
+	decoder.info.Container = container
+
+	return decoder, nil
+}
+
+// newDecoder builds a Decoder from a parsed track; the caller attaches the packet source.
+func newDecoder(track mp4int.Track, config PacketConfig, opts []Option) (*Decoder, error) {
+	dec, err := NewPacketDecoder(config, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	config = dec.config // with any FrameLength WithLenient assumed
+
+	indexFrames(track, config)
+
+	frameBytes := int(config.FrameLength) * dec.format.Channels * dec.sampleBytes
+
+	settings := newOptions(opts)
+
+	var gaps []silenceGap
+	if settings.editListSilence {
+		gaps = silenceGaps(track.Edits, track.MovieTimescale, config.SampleRate)
+	}
 
-	return &Decoder{
-		reader:  rs,
+	editStart := presentationOffset(track.Edits, track.MediaTimescale, config.SampleRate)
+
+	var offset int64
+	if !settings.mediaTime {
+		offset = cmp.Or(settings.trimStart, editStart)
+	}
+
+	container := ContainerMP4
+	if track.Fragmented {
+		container = ContainerFragmentedMP4
+	}
+
+	decoder := &Decoder{
 		dec:     dec,
-		samples: samples,
+		samples: track.Samples,
+		trackID: track.ID,
+		info: StreamInfo{
+			Seekable:  true,
+			Gapless:   track.HasEditList,
+			Container: container,
+		},
 		buf:     make([]byte, 0, frameBytes),
-	}, nil
+		gaps:    gaps,
+		preroll: track.PrerollSamples,
+
+		presentationOffset: offset,
+		editStart:          editStart,
+		editFrames:         presentedFrames(track.Edits, track.MovieTimescale, config.SampleRate),
+		endFrame:           timeToSampleEnd(track, config.SampleRate),
+		constantFrames:     constantFrameLength(track.Samples, config.FrameLength),
+
+		specific:   specificConfig(track.Cookie),
+		maxBitRate: cmp.Or(track.BitRate.Max, config.AvgBitRate),
+		layout:     track.ChannelLayout,
+
+		padFinal: settings.paddedFinalFrame && settings.trimEnd == 0,
+
+		trimStart: settings.trimStart,
+		trimEnd:   settings.trimEnd,
+
+		stopOnError: settings.stopOnError,
+	}
+
+	labels, described := streamLabels(track.ChannelLayout, int(config.NumChannels))
+	decoder.labels = labels
+
+	// Channel descriptions may order the channels differently from the default layout.
+	if described && !settings.nativeChannelOrder {
+		if offsets := descriptionOffsets(labels); offsets != nil {
+			dec.layoutOffsets = offsets
+		}
+	}
+
+	if settings.stats {
+		decoder.stats = &decodeStats{}
+	}
+
+	if settings.packetTiming {
+		decoder.timings = make([]time.Duration, len(track.Samples))
+	}
+
+	decoder.checkContainerDuration(track)
+
+	return decoder, nil
+}
+
+// indexFrames converts SampleInfo.FirstFrame to output frames. Without stts,
+// every packet is taken to hold FrameLength frames; stts times are rescaled
+// when the media timescale differs from the sample rate.
+func indexFrames(track mp4int.Track, config PacketConfig) {
+	samples := track.Samples
+
+	if !track.HasTimeToSample {
+		for idx := range samples {
+			samples[idx].FirstFrame = uint64(idx) * uint64(config.FrameLength)
+		}
+
+		return
+	}
+
+	timescale := uint64(track.MediaTimescale)
+	rate := uint64(config.SampleRate)
+
+	if timescale == 0 || rate == 0 || timescale == rate {
+		return
+	}
+
+	for idx := range samples {
+		hi, lo := bits.Mul64(samples[idx].FirstFrame, rate)
+		if hi >= timescale {
+			samples[idx].FirstFrame = math.MaxUint64 // saturate rather than overflow the quotient
+
+			continue
+		}
+
+		samples[idx].FirstFrame, _ = bits.Div64(hi, lo, timescale)
+	}
 }
 
 // Format returns the PCM output format.
 func (s *Decoder) Format() PCMFormat { return s.dec.Format() }
 
+// MaxBitRate returns the peak bit rate of the encoded stream in bits per
+// second, from the sample entry's btrt box. Without one, it falls back to the
+// magic cookie's average bit rate. Zero means neither is recorded.
+func (s *Decoder) MaxBitRate() uint32 { return s.maxBitRate }
+
 // Duration returns the total duration of the audio stream.
 // This is an approximation based on packet count and frame length.
+// A track without audio packets has zero duration. The result is truncated
+// to the nanosecond; DurationPrecise rounds instead.
 func (s *Decoder) Duration() time.Duration {
-	frameLength := int64(s.dec.config.FrameLength)
+	if len(s.samples) == 0 {
+		return 0
+	}
+
 	sampleRate := int64(s.dec.config.SampleRate)
-	totalFrames := int64(len(s.samples)) * frameLength
 
-	return time.Duration(totalFrames * int64(time.Second) / sampleRate)
+	return time.Duration(s.durationFrames() * int64(time.Second) / sampleRate)
 }
 
-// Position returns the current playback position in the audio stream.
-func (s *Decoder) Position() time.Duration {
-	frameLength := int64(s.dec.config.FrameLength)
+// DurationPrecise is Duration rounded half-up to the nearest nanosecond, for
+// tools that accumulate durations (A/V sync) and cannot afford the truncation bias.
+func (s *Decoder) DurationPrecise() time.Duration {
+	if len(s.samples) == 0 {
+		return 0
+	}
+
 	sampleRate := int64(s.dec.config.SampleRate)
-	currentFrame := int64(s.sampleIdx) * frameLength
 
-	return time.Duration(currentFrame * int64(time.Second) / sampleRate)
+	return time.Duration((s.durationFrames()*int64(time.Second) + sampleRate/2) / sampleRate)
+}
+
+// durationFrames returns the frame count Duration is based on.
+func (s *Decoder) durationFrames() int64 {
+	return max(int64(len(s.samples))*int64(s.dec.config.FrameLength)-s.trimStart-s.trimEnd, 0)
+}
+
+// Position returns the current playback position in the audio stream: the
+// time of the next frame Read returns. It is in presentation time unless
+// WithMediaTime is set, so it is negative while priming frames are read.
+func (s *Decoder) Position() time.Duration {
+	return frameTime(s.PositionFrames(), int64(s.dec.config.SampleRate))
+}
+
+// PositionFrames is Position as a frame count: the index of the next frame
+// Read returns, counted like Position.
+func (s *Decoder) PositionFrames() int64 {
+	return s.mediaFrame() - s.presentationOffset
+}
+
+// packetFrame returns the first frame of packet idx from the frame index.
+// For idx == len(samples) it returns the end of the stream, taking the final
+// packet to be FrameLength frames long.
+func (s *Decoder) packetFrame(idx int) int64 {
+	if idx < len(s.samples) {
+		return int64(min(s.samples[idx].FirstFrame, math.MaxInt64))
+	}
+
+	if len(s.samples) == 0 {
+		return 0
+	}
+
+	return s.packetFrame(len(s.samples)-1) + int64(s.dec.config.FrameLength)
+}
+
+// ConstantFrameLength reports whether every packet but the last holds exactly
+// FrameLength frames, as encoders write them. Seek then computes the target
+// packet directly; variable packet durations from stts need a binary search.
+func (s *Decoder) ConstantFrameLength() bool { return s.constantFrames }
+
+// constantFrameLength reports whether packet starts are multiples of frameLength.
+func constantFrameLength(samples []mp4int.SampleInfo, frameLength uint32) bool {
+	for idx, sample := range samples {
+		if sample.FirstFrame != uint64(idx)*uint64(frameLength) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// packetAt returns the packet containing media frame, the last one starting
+// at or before it. frame must precede the end of the stream.
+func (s *Decoder) packetAt(frame int64) int {
+	if s.constantFrames {
+		return int(max(frame, 0) / int64(s.dec.config.FrameLength))
+	}
+
+	return max(0, sort.Search(len(s.samples), func(idx int) bool {
+		return s.packetFrame(idx) > frame
+	})-1)
 }
 
 // Seek seeks to the specified time position in the audio stream.
-// Returns the actual position seeked to, which is always at a packet boundary.
+// Returns the actual position seeked to, which is at a packet boundary.
+// When the edit list shifts presentation time (see WithMediaTime), t is in
+// presentation time and Seek decodes the landing packet to position on the
+// exact frame instead.
 // Seeking past the end positions at the end of the stream.
 // Seeking to a negative time positions at the start.
+//
+// t is rounded to the nearest frame, so the times Seek and Position return,
+// truncated to the nanosecond, seek back to the frame they stand for.
+// SeekFrames avoids the conversion.
 func (s *Decoder) Seek(t time.Duration) (time.Duration, error) {
-	frameLength := int64(s.dec.config.FrameLength)
 	sampleRate := int64(s.dec.config.SampleRate)
 
-	// Convert time to frame number, then to sample (packet) index.
-	targetFrame := int64(t.Seconds() * float64(sampleRate))
-	targetSample := int(targetFrame / frameLength)
+	frame, err := s.SeekFrames(timeFrame(t, sampleRate))
+
+	return frameTime(frame, sampleRate), err
+}
+
+// SeekFrames is Seek with the target and result given as frame counts,
+// counted like PositionFrames.
+func (s *Decoder) SeekFrames(frame int64) (int64, error) {
+	if len(s.samples) == 0 {
+		s.buf = s.buf[:0]
+		s.bufOff = 0
+		s.eof = true
+
+		return 0, nil
+	}
+
+	// Find the packet containing the target media frame: the last one
+	// starting at or before it.
+	targetFrame := max(frame, 0) + s.presentationOffset
 
-	// Clamp to valid range.
-	targetSample = max(0, min(targetSample, len(s.samples)))
+	targetSample := len(s.samples)
+	if targetFrame < min(s.packetFrame(len(s.samples)), s.trimLimit(s.streamEnd())) {
+		targetSample = s.packetAt(targetFrame)
+	}
 
 	// Reset decoder state.
 	s.sampleIdx = targetSample
+	s.err = nil
 	s.buf = s.buf[:0]
 	s.bufOff = 0
 	s.eof = targetSample >= len(s.samples)
 
-	// Return actual position.
-	actualFrame := int64(s.sampleIdx) * frameLength
+	// Return actual position, past any frames WithTrimStart drops.
+	actualFrame := s.mediaFrame()
+
+	// Presentation time rarely starts on a packet boundary: decode into the packet.
+	if s.presentationOffset != 0 && targetFrame > actualFrame && !s.eof {
+		skipped, err := s.Skip(targetFrame - actualFrame)
+		if err != nil && err != io.EOF { //nolint:errorlint // Skip returns io.EOF unwrapped.
+			return actualFrame - s.presentationOffset, err
+		}
+
+		actualFrame += skipped
+	}
+
+	s.seekGaps(actualFrame)
 
-	return time.Duration(actualFrame * int64(time.Second) / sampleRate), nil
+	return actualFrame - s.presentationOffset, nil
+}
+
+// timeFrame converts t to a frame count at rate, rounded to the nearest
+// frame. Negative times map to frame 0.
+func timeFrame(t time.Duration, rate int64) int64 {
+	if t <= 0 {
+		return 0
+	}
+
+	hi, lo := bits.Mul64(uint64(t), uint64(rate))
+	lo, carry := bits.Add64(lo, uint64(time.Second/2), 0)
+	hi += carry
+
+	if hi >= uint64(time.Second) {
+		return math.MaxInt64 // saturate rather than overflow the quotient
+	}
+
+	frame, _ := bits.Div64(hi, lo, uint64(time.Second))
+
+	return int64(min(frame, math.MaxInt64))
+}
+
+// frameTime converts a frame count at rate to a time, truncated to the nanosecond.
+func frameTime(frame, rate int64) time.Duration {
+	return time.Duration(frame * int64(time.Second) / rate)
 }
 
 // Read reads decoded PCM bytes from the ALAC stream.
+//
+// If the source ends inside a packet, Read returns the PCM of every complete
+// packet before it, followed by an error matching both ErrTruncatedStream and
+// io.ErrUnexpectedEOF. The incomplete packet is discarded: its entropy-coded
+// payload cannot be partially validated. The error is sticky until Seek.
+//
+// Any other source error is deferred the same way: Read first returns the
+// PCM already decoded, and the error on the next call. Closing the source is
+// therefore a safe way to cancel a decode in progress.
 func (s *Decoder) Read(p []byte) (int, error) { //nolint:varnamelen // p is idiomatic for io.Reader.Read
 	total := 0
 
+	s.packPlanar()
+
 	for len(p) > 0 {
+		// Emit pending edit-list silence.
+		if s.gapBytes > 0 {
+			n, err := s.chargeGap(int(min(int64(len(p)), s.gapBytes)))
+			if err != nil {
+				if total > 0 {
+					return total, nil
+				}
+
+				return 0, err
+			}
+
+			clear(p[:n])
+			total += n
+			p = p[n:]
+
+			continue
+		}
+
+		if s.startGap(false) {
+			continue
+		}
+
 		// Drain buffered packet data.
 		if s.bufOff < len(s.buf) {
-			n := copy(p, s.buf[s.bufOff:])
+			n := copy(p, s.buf[s.bufOff:s.bufLimit()])
 			s.bufOff += n
 			total += n
 			p = p[n:]
@@ -138,53 +536,213 @@ func (s *Decoder) Read(p []byte) (int, error) { //nolint:varnamelen // p is idio
 			continue
 		}
 
-		if s.eof {
+		if err := s.fill(); err != nil {
+			if err == io.EOF && s.startGap(true) { //nolint:errorlint // fill returns io.EOF unwrapped.
+				continue
+			}
+
+			// Return the audio already copied first: fill fails again on the
+			// next call, so a source closed to cancel decoding loses nothing.
 			if total > 0 {
 				return total, nil
 			}
 
-			return 0, io.EOF
+			return 0, err
 		}
+	}
 
-		if s.sampleIdx >= len(s.samples) {
-			s.eof = true
+	return total, nil
+}
 
-			if total > 0 {
-				return total, nil
+// ReadZeroCopy returns the next run of decoded PCM without copying it: the
+// unread remainder of the current packet, or the whole next packet.
+//
+// The returned slice aliases the decoder's internal buffer. It is only valid
+// until the next call to any Decoder method that reads or repositions the
+// stream (Read, ReadZeroCopy, Skip, Seek, Frames), and must not be written
+// to or retained. Copy it if it must outlive that window.
+//
+// At the end of the stream ReadZeroCopy returns (nil, io.EOF). Like Frames,
+// it stays on the media timeline and ignores WithEditListSilence.
+func (s *Decoder) ReadZeroCopy() ([]byte, error) {
+	s.packPlanar()
+
+	for s.bufOff >= len(s.buf) {
+		if err := s.fill(); err != nil {
+			return nil, err
+		}
+	}
+
+	pcm := s.buf[s.bufOff:]
+	s.bufOff = len(s.buf)
+
+	return pcm, nil
+}
+
+// Skip advances the stream by up to frames sample frames without producing
+// output, and returns the number of frames actually skipped. Whole packets
+// are skipped without being read or decoded; only the packet the skip lands
+// in is decoded, so that Read resumes at the exact frame. If the stream ends
+// first, Skip returns the frames skipped and io.EOF.
+func (s *Decoder) Skip(frames int64) (int64, error) {
+	bytesPerFrame := int64(s.dec.format.Channels * s.dec.sampleBytes)
+
+	var skipped int64
+
+	for skipped < frames {
+		// Consume what is left of the decoded packet.
+		if buffered := int64(len(s.buf)-s.bufOff) / bytesPerFrame; buffered > 0 {
+			n := min(buffered, frames-skipped)
+			s.bufOff += int(n * bytesPerFrame)
+			skipped += n
+
+			continue
+		}
+
+		// Packets other than the last hold exactly the frames the index gives
+		// them; those the trims cut into are decoded so that only kept frames count.
+		if s.err == nil && s.sampleIdx < len(s.samples)-1 && s.untrimmedPacket(s.sampleIdx) {
+			if packetFrames := s.packetFrame(s.sampleIdx+1) - s.packetFrame(s.sampleIdx); frames-skipped >= packetFrames {
+				s.sampleIdx++
+				skipped += packetFrames
+
+				continue
 			}
+		}
 
-			return 0, io.EOF
+		if err := s.fill(); err != nil {
+			return skipped, err
 		}
+	}
+
+	return skipped, nil
+}
+
+// fill decodes the next packet into buf.
+// It returns io.EOF once every packet has been decoded.
+func (s *Decoder) fill() error {
+	if s.err != nil {
+		return s.err
+	}
 
-		// Decode next packet.
-		sample := s.samples[s.sampleIdx]
+	if s.trimmed() {
+		s.skipTrimmedPackets()
 
-		if int(sample.Size) > len(s.packetBuf) {
-			s.packetBuf = make([]byte, sample.Size)
+		if s.sampleIdx < len(s.samples)-1 && s.packetFrame(s.sampleIdx) >= s.trimLimit(s.streamEnd()) {
+			s.eof = true
+		}
+	}
+
+	if s.eof || s.sampleIdx >= len(s.samples) {
+		s.eof = true
+
+		return io.EOF
+	}
+
+	packet, err := s.loadPacket(s.sampleIdx)
+	if err != nil {
+		if errors.Is(err, ErrTruncatedStream) {
+			s.err = err
 		}
 
-		packet := s.packetBuf[:sample.Size]
+		return err
+	}
+
+	// Ensure buf has capacity for a full frame.
+	s.buf = s.buf[:cap(s.buf)]
+
+	s.dec.packet = s.sampleIdx // warnings report sample table indices
+
+	timed := s.stats != nil || s.timings != nil
+
+	var start time.Time
+	if timed {
+		start = time.Now()
+	}
+
+	n, err := s.dec.decodePacketInto(packet, s.buf)
+	if err != nil {
+		s.buf = s.buf[:0]
+		s.bufOff = 0
+
+		return s.decodeFailed(fmt.Errorf("decoding packet %d: %w", s.sampleIdx, err))
+	}
+
+	if timed {
+		elapsed := time.Since(start)
 
-		if _, err := s.reader.Seek(int64(sample.Offset), io.SeekStart); err != nil {
-			return total, fmt.Errorf("seeking to sample %d at offset %d: %w", s.sampleIdx, sample.Offset, err)
+		if s.stats != nil {
+			s.stats.record(n, s.dec.format.BytesPerFrame(), elapsed)
 		}
 
-		if _, err := io.ReadFull(s.reader, packet); err != nil {
-			return total, fmt.Errorf("reading sample %d: %w", s.sampleIdx, err)
+		if s.timings != nil {
+			s.timings[s.sampleIdx] = elapsed
 		}
+	}
+
+	s.buf = s.buf[:n]
+	s.bufOff = 0
+	s.planarBuf = s.dec.planar
+	s.sampleIdx++
+
+	if s.trimmed() {
+		s.clipTrimmed()
+	}
+
+	if s.padFinal && s.sampleIdx == len(s.samples) {
+		s.padFinalFrame()
+	}
+
+	return nil
+}
 
-		// Ensure buf has capacity for a full frame.
-		s.buf = s.buf[:cap(s.buf)]
+// loadPacket reads packet idx into the reused packet buffer and returns it.
+// The result is only valid until the next loadPacket call.
+func (s *Decoder) loadPacket(idx int) ([]byte, error) {
+	sample := s.samples[idx]
 
-		n, err := s.dec.decodePacketInto(packet, s.buf)
-		if err != nil {
-			return total, fmt.Errorf("decoding packet %d: %w", s.sampleIdx, err)
+	if int(sample.Size) > len(s.packetBuf) {
+		s.packetBuf = make([]byte, sample.Size)
+	}
+
+	packet := s.packetBuf[:sample.Size]
+
+	if err := s.readPacket(packet, idx); err != nil {
+		return nil, err
+	}
+
+	return packet, nil
+}
+
+// readPacket fills packet with the bytes of sample idx.
+func (s *Decoder) readPacket(packet []byte, idx int) error {
+	var (
+		n   int
+		err error
+	)
+
+	sample := s.samples[idx]
+
+	if s.readerAt != nil {
+		n, err = s.readerAt.ReadAt(packet, int64(sample.Offset))
+		s.readAtEnd = int64(sample.Offset) + int64(n)
+	} else {
+		if _, seekErr := s.reader.Seek(int64(sample.Offset), io.SeekStart); seekErr != nil {
+			return fmt.Errorf("seeking to sample %d at offset %d: %w", idx, sample.Offset, seekErr)
 		}
 
-		s.buf = s.buf[:n]
-		s.bufOff = 0
-		s.sampleIdx++
+		n, err = io.ReadFull(s.reader, packet)
 	}
 
-	return total, nil
+	// io.ReaderAt may report io.EOF alongside a full read at the end of the source.
+	if n == len(packet) {
+		return nil
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: sample %d: got %d of %d bytes: %w",
+			ErrTruncatedStream, idx, n, len(packet), io.ErrUnexpectedEOF)
+	}
+
+	return fmt.Errorf("reading sample %d: %w", idx, err)
 }
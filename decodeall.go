@@ -0,0 +1,133 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions reinterpret little-endian PCM bytes.
+package alac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// decodeAllChunkFrames is the number of sample frames read per iteration.
+const decodeAllChunkFrames = 4096
+
+// DecodeAllInt16 decodes an entire 16-bit ALAC stream and returns one
+// de-interleaved []int16 slice per channel (in output channel order).
+// Streams of any other bit depth return ErrConfig.
+//
+// The whole stream is held in memory: roughly 2 bytes per sample per channel.
+// Intended for short files and scripts; use NewDecoder and Read for long streams.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func DecodeAllInt16(rs io.ReadSeeker) ([][]int16, PCMFormat, error) {
+	return decodeAll(rs, func(format PCMFormat) (func([]byte) int16, error) {
+		if format.BitDepth != 16 { //revive:disable-line:add-constant
+			return nil, fmt.Errorf("%w: %w: %d (DecodeAllInt16 requires 16)", ErrConfig, alacint.ErrBitDepth, format.BitDepth)
+		}
+
+		return func(b []byte) int16 { return int16(binary.LittleEndian.Uint16(b)) }, nil
+	})
+}
+
+// DecodeAllInt32 decodes an entire ALAC stream and returns one de-interleaved
+// []int32 slice per channel (in output channel order). Samples keep the scale
+// of the byte output: 16, 24 and 32-bit samples are sign-extended, and 20-bit
+// samples stay left-aligned in 24 bits.
+//
+// The whole stream is held in memory: 4 bytes per sample per channel.
+// Intended for short files and scripts; use NewDecoder and Read for long streams.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func DecodeAllInt32(rs io.ReadSeeker) ([][]int32, PCMFormat, error) {
+	return decodeAll(rs, func(format PCMFormat) (func([]byte) int32, error) {
+		return int32SampleReader(format), nil
+	})
+}
+
+// int32SampleReader returns a function reading one integer sample of format
+// as an int32, sign-extended, with 20-bit samples left-aligned in 24 bits.
+func int32SampleReader(format PCMFormat) func([]byte) int32 {
+	switch format.BitDepth {
+	case 16:
+		return func(b []byte) int32 { return int32(int16(binary.LittleEndian.Uint16(b))) }
+	case 20, 24:
+		return func(b []byte) int32 {
+			return int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		}
+	default:
+		return func(b []byte) int32 { return int32(binary.LittleEndian.Uint32(b)) }
+	}
+}
+
+// decodeAll drains a Decoder, de-interleaving samples with the reader chosen for the stream format.
+func decodeAll[T int16 | int32](
+	rs io.ReadSeeker, //nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+	sampleReader func(PCMFormat) (func([]byte) T, error),
+) ([][]T, PCMFormat, error) {
+	dec, err := NewDecoder(rs)
+	if err != nil {
+		return nil, PCMFormat{}, err
+	}
+
+	format := dec.Format()
+
+	readSample, err := sampleReader(format)
+	if err != nil {
+		return nil, PCMFormat{}, err
+	}
+
+	bps := format.BytesPerSample()
+	bytesPerFrame := format.BytesPerFrame()
+	// A corrupt stts can claim any length: never reserve more than FrameLength per packet.
+	estimate := int(min(
+		max(dec.OutputSize(), 0)/int64(bytesPerFrame),
+		int64(len(dec.samples))*int64(dec.dec.config.FrameLength),
+	))
+
+	channels := make([][]T, format.Channels)
+	for ch := range channels {
+		channels[ch] = make([]T, 0, estimate)
+	}
+
+	buf := make([]byte, decodeAllChunkFrames*bytesPerFrame)
+	pending := 0
+
+	for {
+		n, readErr := dec.Read(buf[pending:])
+		avail := pending + n
+		whole := avail - avail%bytesPerFrame
+
+		for off := 0; off < whole; off += bytesPerFrame {
+			for ch := range channels {
+				channels[ch] = append(channels[ch], readSample(buf[off+ch*bps:]))
+			}
+		}
+
+		pending = copy(buf, buf[whole:avail])
+
+		if readErr == io.EOF { //nolint:errorlint // io.Reader contract: io.EOF is returned unwrapped.
+			return channels, format, nil
+		}
+
+		if readErr != nil {
+			return nil, PCMFormat{}, readErr
+		}
+	}
+}
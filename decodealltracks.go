@@ -0,0 +1,102 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// Track is one fully decoded ALAC track, as returned by DecodeAllTracks.
+type Track struct {
+	Format PCMFormat
+	PCM    []byte // interleaved little-endian PCM
+}
+
+// DecodeAllTracks decodes every ALAC track of an M4A/MP4 source of the given
+// size and returns them keyed by tkhd track ID. Each track gets its own
+// Decoder reading through r (see NewDecoderAt), and the tracks are decoded
+// concurrently.
+//
+// The whole of every track is held in memory. Intended for multitrack files
+// such as stems; use NewDecoderAt per track for long streams.
+func DecodeAllTracks(r io.ReaderAt, size int64, opts ...Option) (map[uint32]Track, error) {
+	settings := newOptions(opts)
+
+	tracks, err := mp4int.FindALACTracks(io.NewSectionReader(r, 0, size), settings.trace)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoTrack, err)
+	}
+
+	decoders := make(map[uint32]*Decoder, len(tracks))
+
+	for _, track := range tracks {
+		if _, dup := decoders[track.ID]; dup {
+			return nil, fmt.Errorf("%w: duplicate track ID %d", ErrNoTrack, track.ID)
+		}
+
+		config, cookieErr := parseMagicCookie(track.Cookie, settings.lenient)
+		if cookieErr != nil {
+			return nil, fmt.Errorf("parsing ALAC config of track %d: %w", track.ID, cookieErr)
+		}
+
+		decoder, decErr := newDecoder(track, config, opts)
+		if decErr != nil {
+			return nil, decErr
+		}
+
+		decoder.readerAt = r
+		decoders[track.ID] = decoder
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+
+	result := make(map[uint32]Track, len(decoders))
+
+	for id, decoder := range decoders {
+		wg.Go(func() {
+			pcm, readErr := io.ReadAll(decoder)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if readErr != nil {
+				errs = append(errs, fmt.Errorf("decoding track %d: %w", id, readErr))
+
+				return
+			}
+
+			result[id] = Track{Format: decoder.Format(), PCM: pcm}
+		})
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return result, nil
+}
@@ -19,7 +19,6 @@ package alac
 
 import (
 	"fmt"
-	"slices"
 
 	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
 )
@@ -75,37 +74,148 @@ const (
 	elemEND = 7 // End of Frame
 )
 
+// Fixed bit counts checked against the remaining packet before reading.
+const (
+	elementHeaderBits   = 4 + alacint.UnusedHeaderBits + 4 // instance tag, unused bits, flags
+	partialFrameBits    = 32                               // explicit sample count
+	predictorHeaderBits = 32                               // mix bits/res, mode/denShift, pbFactor/numCoefs
+)
+
 // PacketDecoder decodes ALAC audio packets into interleaved LE signed PCM.
 type PacketDecoder struct {
 	config      PacketConfig
 	format      PCMFormat
+	sampleBytes int // bytes per output sample
+	frameBytes  int // bytes per sample written by the element decoders
 	mixBufferU  []int32
 	mixBufferV  []int32
 	predictor   []int32
 	shiftBuffer []uint16
 	bits        alacint.BitBuffer // reusable bit reader (avoids per-packet allocation)
+
+	// Set while DecodePaddedPacket reads the caller's buffer in place.
+	noCopy bool
+
+	// Set while Decoder.ReadStereoFrames decodes a stereo stream: element
+	// decoders leave output channel 0 in mixBufferU and channel 1 in
+	// mixBufferV, unmixed, instead of packing output.
+	planar bool
+
+	// Optional prediction parameter capture (WithFrameParamSink).
+	paramSink  func(FrameParams)
+	params     FrameParams
+	paramCoefs [2][alacint.MaxCoefs]int16 // backs ChannelParams.Coefs
+
+	// Optional bit-depth conversion (WithOutputBitDepth) and channel
+	// selection (WithChannelSelection), applied to a staged native frame.
+	outDepth  uint8
+	dither    *alacint.Dither
+	selection []int  // output channels to keep, in order
+	stageBuf  []byte // native-layout frame awaiting conversion or selection
+	selectBuf []byte // selected channels awaiting conversion
+
+	// Lenient decoding (WithLenient).
+	lenient  bool
+	warnings []Warning
+	packet   int // index reported in warnings
+
+	// Packet size check (WithPacketSizeCheck).
+	sizeCheck bool
+
+	// Fail on DSE and FIL elements (WithRejectAncillary).
+	rejectAncillary bool
+
+	// Bitstream channel to output position: SMPTE, or as is under WithNativeChannelOrder.
+	layoutOffsets *[8][8]int
+
+	// Optional entropy coding counts (WithEntropyStats).
+	entropy *entropyCounts
+
+	// Set once a packet exceeds MaxFrameBytes or does not end cleanly (IntegrityHint).
+	irregular bool
+
+	// Output cap (WithMaxOutputBytes): bytes allowed and produced so far.
+	maxOutput int64
+	produced  int64
+
+	// Bitstream validation (Validate): entropy decode only, no PCM output.
+	validateOnly    bool
+	elementChannels int // channels carried by the last packet's elements
 }
 
 // NewPacketDecoder creates a new ALAC packet decoder from the given configuration.
-func NewPacketDecoder(config PacketConfig) (*PacketDecoder, error) {
-	if !slices.Contains(alacBitDepths, config.BitDepth) {
-		return nil, fmt.Errorf("%w: %w: %d", ErrConfig, alacint.ErrBitDepth, config.BitDepth)
+// Under WithLenient, a zero FrameLength, as some minimal raw configurations
+// carry, is taken as DefaultFrameLength and recorded as a Warning;
+// PacketConfig.Validate still rejects it.
+func NewPacketDecoder(config PacketConfig, opts ...Option) (*PacketDecoder, error) {
+	settings := newOptions(opts)
+
+	zeroFrameLength := config.FrameLength == 0
+	config = defaultFrameLength(config, settings.lenient)
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.FrameLength > settings.maxFrameLength {
+		return nil, fmt.Errorf("%w: %w: %d > %d", ErrConfig, alacint.ErrFrameLength,
+			config.FrameLength, settings.maxFrameLength)
 	}
 
-	frameLen := int(config.FrameLength)
+	var sampleBytes int
 
-	return &PacketDecoder{
+	switch settings.sampleFormat {
+	case SampleInt:
+		sampleBytes = alacint.BytesPerSample(config.BitDepth)
+	case SampleFloat32:
+		sampleBytes = 4
+	case SampleFloat64:
+		sampleBytes = 8
+	default:
+		return nil, fmt.Errorf("%w: %w: %d", ErrConfig, alacint.ErrSampleFormat, settings.sampleFormat)
+	}
+
+	dec := &PacketDecoder{
 		config: config,
 		format: PCMFormat{
-			SampleRate: int(config.SampleRate),
-			BitDepth:   int(config.BitDepth),
-			Channels:   int(config.NumChannels),
+			SampleRate:   int(config.SampleRate),
+			BitDepth:     int(config.BitDepth),
+			Channels:     int(config.NumChannels),
+			SampleFormat: settings.sampleFormat,
 		},
-		mixBufferU:  make([]int32, frameLen),
-		mixBufferV:  make([]int32, frameLen),
-		predictor:   make([]int32, frameLen),
-		shiftBuffer: make([]uint16, frameLen*2), // stereo worst case
-	}, nil
+		sampleBytes: sampleBytes,
+		frameBytes:  sampleBytes,
+		paramSink:   settings.frameParamSink,
+		lenient:     settings.lenient,
+		sizeCheck:   settings.packetSizeCheck,
+		maxOutput:   settings.maxOutputBytes,
+
+		rejectAncillary: settings.rejectAncillary,
+		layoutOffsets:   layoutOffsets(settings.nativeChannelOrder),
+	}
+
+	if settings.entropyStats {
+		dec.entropy = &entropyCounts{}
+	}
+
+	if zeroFrameLength {
+		err := fmt.Errorf("%w: assuming %d", alacint.ErrZeroFrameLength, DefaultFrameLength)
+		dec.warnings = append(dec.warnings, Warning{Packet: -1, Channel: -1, Err: err})
+	}
+
+	if err := dec.setScratch(settings.scratch); err != nil {
+		return nil, err
+	}
+
+	if err := dec.setOutputBitDepth(settings); err != nil {
+		return nil, err
+	}
+
+	if err := dec.setChannelSelection(settings.channelSelection); err != nil {
+		return nil, err
+	}
+
+	return dec, nil
 }
 
 // Format returns the PCM output format.
@@ -115,9 +225,7 @@ func (d *PacketDecoder) Format() PCMFormat {
 
 // DecodePacket decodes a single ALAC packet into interleaved LE signed PCM bytes.
 func (d *PacketDecoder) DecodePacket(packet []byte) ([]byte, error) {
-	numChan := int(d.config.NumChannels)
-	bps := alacint.BytesPerSample(d.config.BitDepth)
-	output := make([]byte, int(d.config.FrameLength)*numChan*bps)
+	output := make([]byte, int(d.config.FrameLength)*d.format.Channels*d.sampleBytes)
 
 	n, err := d.decodePacketInto(packet, output)
 	if err != nil {
@@ -129,15 +237,70 @@ func (d *PacketDecoder) DecodePacket(packet []byte) ([]byte, error) {
 
 // decodePacketInto decodes a single ALAC packet into the provided output buffer.
 // Returns the number of bytes written. The output buffer must be large enough
-// to hold one full frame (FrameLength * NumChannels * BytesPerSample).
+// to hold one full frame (FrameLength * Channels * BytesPerSample).
 func (d *PacketDecoder) decodePacketInto(packet, output []byte) (int, error) {
-	d.bits.Reset(packet)
+	if err := d.outputLimitReached(); err != nil {
+		return 0, err
+	}
+
+	n, err := d.decodeConverted(packet, output)
+	if err != nil {
+		return 0, err
+	}
+
+	return d.chargeOutput(n), nil
+}
+
+// decodeConverted decodes a packet into output, applying any channel
+// selection and bit-depth conversion.
+func (d *PacketDecoder) decodeConverted(packet, output []byte) (int, error) {
+	defer func() { d.packet++ }()
+
+	if d.stageBuf == nil {
+		return d.decodeFrame(packet, output)
+	}
+
+	n, err := d.decodeFrame(packet, d.stageBuf)
+	if err != nil {
+		return 0, err
+	}
+
+	staged := d.stageBuf[:n]
+
+	if d.selection != nil {
+		dst := output
+		if d.outDepth != 0 {
+			dst = d.selectBuf
+		}
+
+		n = selectChannels(dst, staged, d.selection, int(d.config.NumChannels), d.frameBytes)
+		if d.outDepth == 0 {
+			return n, nil
+		}
+
+		staged = dst[:n]
+	}
+
+	alacint.ConvertBitDepth(output, staged, d.config.BitDepth, d.outDepth, d.dither)
+
+	return n / d.frameBytes * d.sampleBytes, nil
+}
+
+// decodeFrame decodes the elements of a single packet into output at the
+// stream's native sample layout. Returns the number of bytes written.
+func (d *PacketDecoder) decodeFrame(packet, output []byte) (int, error) {
+	if d.noCopy {
+		d.bits.ResetNoCopy(packet, len(packet)) // padding checked by DecodePaddedPacket
+	} else {
+		d.bits.Reset(packet)
+	}
+
 	bits := &d.bits
 	numSamples := d.config.FrameLength
 	numChan := int(d.config.NumChannels)
-	bps := alacint.BytesPerSample(d.config.BitDepth)
 	chanIdx := 0
-	offsets := &channelLayoutOffsets[numChan-1]
+	offsets := &d.layoutOffsets[numChan-1]
+	ended := false
 
 	for {
 		if bits.PastEnd() {
@@ -148,7 +311,8 @@ func (d *PacketDecoder) decodePacketInto(packet, output []byte) (int, error) {
 
 		switch tag {
 		case elemSCE, elemLFE:
-			// Map bitstream channel to output position (MPEG → SMPTE order).
+			// Map bitstream channel to output position (MPEG → SMPTE order,
+			// unless WithNativeChannelOrder).
 			outChanIdx := offsets[chanIdx]
 
 			ns, err := d.decodeSCE(bits, output, outChanIdx, numChan, numSamples)
@@ -180,11 +344,19 @@ func (d *PacketDecoder) decodePacketInto(packet, output []byte) (int, error) {
 			return 0, fmt.Errorf("%w: %w", ErrDecode, alacint.ErrUnsupportedElement)
 
 		case elemDSE:
+			if d.rejectAncillary {
+				return 0, fmt.Errorf("%w: DSE: %w", ErrDecode, ErrAncillaryElement)
+			}
+
 			if err := d.skipDSE(bits); err != nil {
 				return 0, fmt.Errorf("%w: DSE: %w", ErrDecode, err)
 			}
 
 		case elemFIL:
+			if d.rejectAncillary {
+				return 0, fmt.Errorf("%w: FIL: %w", ErrDecode, ErrAncillaryElement)
+			}
+
 			if err := d.skipFIL(bits); err != nil {
 				return 0, fmt.Errorf("%w: FIL: %w", ErrDecode, err)
 			}
@@ -192,6 +364,8 @@ func (d *PacketDecoder) decodePacketInto(packet, output []byte) (int, error) {
 		case elemEND:
 			bits.ByteAlign()
 
+			ended = true
+
 			goto done
 
 		default:
@@ -203,19 +377,34 @@ func (d *PacketDecoder) decodePacketInto(packet, output []byte) (int, error) {
 	}
 
 done:
-	return int(numSamples) * numChan * bps, nil
+	d.elementChannels = chanIdx
+
+	if err := d.checkChannelCount(output, chanIdx, numSamples); err != nil {
+		return 0, err
+	}
+
+	if d.sizeCheck {
+		d.checkPacketSize(len(packet), ended)
+	}
+
+	d.checkIntegrity(len(packet), ended)
+
+	return int(numSamples) * numChan * d.frameBytes, nil
 }
 
 // decodeSCE decodes a Single Channel Element (mono) or LFE element.
 func (d *PacketDecoder) decodeSCE(
 	bits *alacint.BitBuffer, output []byte, chanIdx, numChan int, numSamples uint32,
 ) (uint32, error) {
+	if err := needBits(bits, elementHeaderBits); err != nil {
+		return 0, err
+	}
+
 	_ = bits.ReadSmall(4) // element instance tag
 
 	// 12 unused header bits (must be 0).
-	unusedHeader := bits.Read(alacint.UnusedHeaderBits)
-	if unusedHeader != 0 {
-		return 0, alacint.ErrInvalidHeader
+	if err := d.checkUnusedHeader(bits.Read(alacint.UnusedHeaderBits), chanIdx); err != nil {
+		return 0, err
 	}
 
 	headerByte := bits.Read(4)
@@ -230,45 +419,90 @@ func (d *PacketDecoder) decodeSCE(
 	chanBits := uint32(d.config.BitDepth) - uint32(bytesShifted)*8
 
 	if partialFrame != 0 {
+		if err := needBits(bits, partialFrameBits); err != nil {
+			return 0, err
+		}
+
 		numSamples = bits.Read(16) << 16
 		numSamples |= bits.Read(16)
+
+		// Buffers hold FrameLength samples; a larger count can only come from corruption.
+		if numSamples > d.config.FrameLength {
+			return 0, alacint.ErrSampleOverrun
+		}
+	}
+
+	// Planar output keeps each channel in a buffer of its own.
+	mix := d.mixBufferU
+	if d.planar && chanIdx == 1 {
+		mix = d.mixBufferV
 	}
 
 	if escapeFlag == 0 {
-		if err := d.decodeSCECompressed(bits, chanBits, bytesShifted, int(numSamples)); err != nil {
+		if err := d.decodeSCECompressed(bits, mix, chanBits, bytesShifted, int(numSamples)); err != nil {
 			return 0, err
 		}
 	} else {
-		d.decodeSCEEscape(bits, chanBits, int(numSamples))
+		if err := needBits(bits, int(numSamples)*int(chanBits)); err != nil {
+			return 0, err
+		}
+
+		if d.validateOnly {
+			bits.Advance(numSamples * chanBits)
+		} else {
+			d.decodeSCEEscape(bits, mix, chanBits, int(numSamples))
+		}
 
 		bytesShifted = 0
 	}
 
+	if d.validateOnly {
+		return numSamples, nil
+	}
+
 	// Write output.
 	sampleCount := int(numSamples)
-
-	switch d.config.BitDepth {
-	case 16:
-		alacint.WriteMono16(output, d.mixBufferU, chanIdx, numChan, sampleCount)
-	case 20:
-		alacint.WriteMono20(output, d.mixBufferU, chanIdx, numChan, sampleCount)
-	case 24:
-		alacint.WriteMono24(output, d.mixBufferU, chanIdx, numChan, sampleCount, d.shiftBuffer, bytesShifted)
-	case 32:
-		alacint.WriteMono32(output, d.mixBufferU, chanIdx, numChan, sampleCount, d.shiftBuffer, bytesShifted)
+	bitDepth := int(d.config.BitDepth)
+
+	switch {
+	case d.planar:
+		alacint.UnshiftMono(mix, sampleCount, d.shiftBuffer, bytesShifted)
+	case d.format.SampleFormat == SampleFloat32:
+		alacint.WriteMonoFloat32(output, mix, chanIdx, numChan, sampleCount,
+			d.shiftBuffer, bytesShifted, bitDepth)
+	case d.format.SampleFormat == SampleFloat64:
+		alacint.WriteMonoFloat64(output, mix, chanIdx, numChan, sampleCount,
+			d.shiftBuffer, bytesShifted, bitDepth)
+	case bitDepth == 16:
+		alacint.WriteMono16(output, mix, chanIdx, numChan, sampleCount)
+	case bitDepth == 20:
+		alacint.WriteMono20(output, mix, chanIdx, numChan, sampleCount)
+	case bitDepth == 24:
+		alacint.WriteMono24(output, mix, chanIdx, numChan, sampleCount, d.shiftBuffer, bytesShifted)
+	case bitDepth == 32:
+		alacint.WriteMono32(output, mix, chanIdx, numChan, sampleCount, d.shiftBuffer, bytesShifted)
 
 	default:
 		panic(fmt.Sprintf("alac: decodeSCE called with unsupported bit depth %d", d.config.BitDepth))
 	}
 
+	if d.paramSink != nil {
+		d.emitFrameParams(chanIdx, false, escapeFlag != 0, numSamples, bytesShifted, 0, 0)
+	}
+
 	return numSamples, nil
 }
 
 func (d *PacketDecoder) decodeSCECompressed(
 	bits *alacint.BitBuffer,
+	mix []int32,
 	chanBits uint32,
 	bytesShifted, numSamples int,
 ) error {
+	if err := needBits(bits, predictorHeaderBits); err != nil {
+		return err
+	}
+
 	_ = bits.Read(8) // mixBits (unused for mono)
 	_ = bits.Read(8) // mixRes (unused for mono)
 
@@ -280,14 +514,26 @@ func (d *PacketDecoder) decodeSCECompressed(
 	pbFactorU := headerByte >> 5
 	numU := headerByte & 0x1f
 
+	if err := needBits(bits, int(numU)*16); err != nil {
+		return err
+	}
+
 	var coefsU [alacint.MaxCoefs]int16
 	for i := range numU {
 		coefsU[i] = int16(bits.Read(16))
 	}
 
+	if d.paramSink != nil {
+		d.recordChannelParams(0, modeU, denShiftU, pbFactorU, coefsU[:numU])
+	}
+
 	// Save shift bits position, skip past them.
 	var shiftBits alacint.BitBuffer
 	if bytesShifted != 0 {
+		if err := needBits(bits, bytesShifted*8*numSamples); err != nil {
+			return err
+		}
+
 		shiftBits = bits.Copy()
 		bits.Advance(uint32(bytesShifted) * 8 * uint32(numSamples))
 	}
@@ -303,12 +549,18 @@ func (d *PacketDecoder) decodeSCECompressed(
 		return fmt.Errorf("entropy decode: %w", err)
 	}
 
+	d.countEntropy(&agP, numSamples)
+
+	if d.validateOnly {
+		return nil
+	}
+
 	// Predictor.
 	if modeU != 0 {
 		alacint.UnpcBlock(d.predictor, d.predictor, numSamples, nil, alacint.NumActiveDelta, chanBits, 0)
 	}
 
-	alacint.UnpcBlock(d.predictor, d.mixBufferU, numSamples, coefsU[:numU], int32(numU), chanBits, denShiftU)
+	alacint.UnpcBlock(d.predictor, mix, numSamples, coefsU[:numU], int32(numU), chanBits, denShiftU)
 
 	// Read shift buffer from saved position.
 	if bytesShifted != 0 {
@@ -323,9 +575,9 @@ func (d *PacketDecoder) decodeSCECompressed(
 	return nil
 }
 
-func (d *PacketDecoder) decodeSCEEscape(bits *alacint.BitBuffer, chanBits uint32, numSamples int) {
+func (d *PacketDecoder) decodeSCEEscape(bits *alacint.BitBuffer, mix []int32, chanBits uint32, numSamples int) {
 	shift := uint32(32) - chanBits
-	mixU := d.mixBufferU[:numSamples:numSamples]
+	mixU := mix[:numSamples:numSamples]
 
 	if chanBits <= 16 {
 		for idx := range mixU {
@@ -351,11 +603,14 @@ func (d *PacketDecoder) decodeCPE(
 	chanIdx, numChan int,
 	numSamples uint32,
 ) (uint32, error) {
+	if err := needBits(bits, elementHeaderBits); err != nil {
+		return 0, err
+	}
+
 	_ = bits.ReadSmall(4) // element instance tag
 
-	unusedHeader := bits.Read(alacint.UnusedHeaderBits)
-	if unusedHeader != 0 {
-		return 0, alacint.ErrInvalidHeader
+	if err := d.checkUnusedHeader(bits.Read(alacint.UnusedHeaderBits), chanIdx); err != nil {
+		return 0, err
 	}
 
 	headerByte := bits.Read(4)
@@ -371,8 +626,17 @@ func (d *PacketDecoder) decodeCPE(
 	chanBits := uint32(d.config.BitDepth) - uint32(bytesShifted)*8 + 1
 
 	if partialFrame != 0 {
+		if err := needBits(bits, partialFrameBits); err != nil {
+			return 0, err
+		}
+
 		numSamples = bits.Read(16) << 16
 		numSamples |= bits.Read(16)
+
+		// Buffers hold FrameLength samples; a larger count can only come from corruption.
+		if numSamples > d.config.FrameLength {
+			return 0, alacint.ErrSampleOverrun
+		}
 	}
 
 	var mixBits, mixRes int32
@@ -386,23 +650,44 @@ func (d *PacketDecoder) decodeCPE(
 		}
 	} else {
 		chanBits = uint32(d.config.BitDepth) // Reset for escape.
-		d.decodeCPEEscape(bits, chanBits, int(numSamples))
+		if err := needBits(bits, 2*int(numSamples)*int(chanBits)); err != nil {
+			return 0, err
+		}
+
+		if d.validateOnly {
+			bits.Advance(2 * numSamples * chanBits)
+		} else {
+			d.decodeCPEEscape(bits, chanBits, int(numSamples))
+		}
 
 		bytesShifted = 0
 	}
 
+	if d.validateOnly {
+		return numSamples, nil
+	}
+
 	// Unmix and write output.
 	sampleCount := int(numSamples)
-
-	switch d.config.BitDepth {
-	case 16:
+	bitDepth := int(d.config.BitDepth)
+
+	switch {
+	case d.planar:
+		alacint.UnmixStereo(d.mixBufferU, d.mixBufferV, sampleCount, mixBits, mixRes, d.shiftBuffer, bytesShifted)
+	case d.format.SampleFormat == SampleFloat32:
+		alacint.WriteStereoFloat32(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount,
+			mixBits, mixRes, d.shiftBuffer, bytesShifted, bitDepth)
+	case d.format.SampleFormat == SampleFloat64:
+		alacint.WriteStereoFloat64(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount,
+			mixBits, mixRes, d.shiftBuffer, bytesShifted, bitDepth)
+	case bitDepth == 16:
 		alacint.WriteStereo16(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount, mixBits, mixRes)
-	case 20:
+	case bitDepth == 20:
 		alacint.WriteStereo20(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount, mixBits, mixRes)
-	case 24:
+	case bitDepth == 24:
 		alacint.WriteStereo24(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount,
 			mixBits, mixRes, d.shiftBuffer, bytesShifted)
-	case 32:
+	case bitDepth == 32:
 		alacint.WriteStereo32(output, d.mixBufferU, d.mixBufferV, chanIdx, numChan, sampleCount,
 			mixBits, mixRes, d.shiftBuffer, bytesShifted)
 
@@ -410,6 +695,10 @@ func (d *PacketDecoder) decodeCPE(
 		panic(fmt.Sprintf("alac: decodeCPE called with unsupported bit depth %d", d.config.BitDepth))
 	}
 
+	if d.paramSink != nil {
+		d.emitFrameParams(chanIdx, true, escapeFlag != 0, numSamples, bytesShifted, mixBits, mixRes)
+	}
+
 	return numSamples, nil
 }
 
@@ -418,6 +707,10 @@ func (d *PacketDecoder) decodeCPECompressed(
 	chanBits uint32,
 	bytesShifted, numSamples int,
 ) (int32, int32, error) { //revive:disable-line:confusing-results
+	if err := needBits(bits, predictorHeaderBits); err != nil {
+		return 0, 0, err
+	}
+
 	mixBits := int32(bits.Read(8))
 	mixRes := int32(int8(bits.Read(8)))
 
@@ -430,6 +723,11 @@ func (d *PacketDecoder) decodeCPECompressed(
 	pbFactorU := headerByte >> 5
 	numU := headerByte & 0x1f
 
+	// U coefficients plus the V channel's mode and predictor bytes.
+	if err := needBits(bits, int(numU)*16+16); err != nil {
+		return 0, 0, err
+	}
+
 	var coefsU [alacint.MaxCoefs]int16
 	for i := range numU {
 		coefsU[i] = int16(bits.Read(16))
@@ -444,14 +742,27 @@ func (d *PacketDecoder) decodeCPECompressed(
 	pbFactorV := headerByte >> 5
 	numV := headerByte & 0x1f
 
+	if err := needBits(bits, int(numV)*16); err != nil {
+		return 0, 0, err
+	}
+
 	var coefsV [alacint.MaxCoefs]int16
 	for i := range numV {
 		coefsV[i] = int16(bits.Read(16))
 	}
 
+	if d.paramSink != nil {
+		d.recordChannelParams(0, modeU, denShiftU, pbFactorU, coefsU[:numU])
+		d.recordChannelParams(1, modeV, denShiftV, pbFactorV, coefsV[:numV])
+	}
+
 	// Save shift bits position, skip past interleaved shift data.
 	var shiftBits alacint.BitBuffer
 	if bytesShifted != 0 {
+		if err := needBits(bits, bytesShifted*8*2*numSamples); err != nil {
+			return 0, 0, err
+		}
+
 		shiftBits = bits.Copy()
 		bits.Advance(uint32(bytesShifted) * 8 * 2 * uint32(numSamples))
 	}
@@ -468,11 +779,15 @@ func (d *PacketDecoder) decodeCPECompressed(
 		return 0, 0, fmt.Errorf("entropy decode U: %w", err)
 	}
 
-	if modeU != 0 {
-		alacint.UnpcBlock(d.predictor, d.predictor, numSamples, nil, alacint.NumActiveDelta, chanBits, 0)
-	}
+	d.countEntropy(&agP, numSamples)
+
+	if !d.validateOnly {
+		if modeU != 0 {
+			alacint.UnpcBlock(d.predictor, d.predictor, numSamples, nil, alacint.NumActiveDelta, chanBits, 0)
+		}
 
-	alacint.UnpcBlock(d.predictor, d.mixBufferU, numSamples, coefsU[:numU], int32(numU), chanBits, denShiftU)
+		alacint.UnpcBlock(d.predictor, d.mixBufferU, numSamples, coefsU[:numU], int32(numU), chanBits, denShiftU)
+	}
 
 	// Decompress and predict V channel.
 	alacint.SetAGParams(&agP, uint32(d.config.MB), (predBound*pbFactorV)/4, uint32(d.config.KB),
@@ -482,6 +797,12 @@ func (d *PacketDecoder) decodeCPECompressed(
 		return 0, 0, fmt.Errorf("entropy decode V: %w", err)
 	}
 
+	d.countEntropy(&agP, numSamples)
+
+	if d.validateOnly {
+		return mixBits, mixRes, nil
+	}
+
 	if modeV != 0 {
 		alacint.UnpcBlock(d.predictor, d.predictor, numSamples, nil, alacint.NumActiveDelta, chanBits, 0)
 	}
@@ -534,7 +855,20 @@ func (d *PacketDecoder) decodeCPEEscape(bits *alacint.BitBuffer, chanBits uint32
 	}
 }
 
-// skipFIL skips a Fill Element.
+// needBits returns ErrBitstreamOverrun unless at least n bits remain before the packet end.
+// Reads past the end would otherwise run off the BitBuffer padding on corrupt input.
+func needBits(bits *alacint.BitBuffer, n int) error {
+	if bits.Remaining() < n {
+		return alacint.ErrBitstreamOverrun
+	}
+
+	return nil
+}
+
+// skipFIL skips a Fill Element. The 4-bit count, with its 8-bit escape,
+// is at most 15+255-1 = 269 bytes, so the advance cannot overflow Pos: a
+// count beyond the packet end is caught by PastEnd as ErrBitstreamOverrun,
+// and nothing is read from the skipped range.
 func (*PacketDecoder) skipFIL(bits *alacint.BitBuffer) error {
 	count := int16(bits.ReadSmall(4))
 	if count == 15 { //revive:disable-line:add-constant
@@ -550,7 +884,10 @@ func (*PacketDecoder) skipFIL(bits *alacint.BitBuffer) error {
 	return nil
 }
 
-// skipDSE skips a Data Stream Element.
+// skipDSE skips a Data Stream Element. As in the reference decoder, the
+// byte alignment requested by the flag applies after the count is read and
+// before the data bytes. The count is at most 255+255 = 510 bytes and is
+// checked against the packet end like skipFIL's.
 func (*PacketDecoder) skipDSE(bits *alacint.BitBuffer) error {
 	_ = bits.ReadSmall(4) // element instance tag
 	dataByteAlignFlag := bits.ReadOne()
@@ -0,0 +1,147 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// wavHeaderSize is the size of the canonical WAV header DecodeToFile writes.
+const wavHeaderSize = 44
+
+// WAV format tags.
+const (
+	wavFormatPCM   = 1
+	wavFormatFloat = 3
+)
+
+// DecodeToFile decodes an ALAC stream into a new file at path, replacing any
+// existing one: a WAV file when path ends in ".wav", raw PCM in the Read
+// layout otherwise. The file is sized from OutputSize and preallocated before
+// decoding (with fallocate on Linux, so that multi-gigabyte decodes are not
+// fragmented by growth), then truncated to the bytes actually decoded should
+// the estimate be off. WAV sizes saturate at 4 GiB, as the format allows no
+// more. On error the partial file is removed.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func DecodeToFile(rs io.ReadSeeker, path string, opts ...Option) (PCMFormat, error) {
+	dec, err := NewDecoder(rs, opts...)
+	if err != nil {
+		return PCMFormat{}, err
+	}
+
+	format := dec.Format()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return PCMFormat{}, fmt.Errorf("creating output file: %w", err)
+	}
+
+	err = decodeToFile(dec, file, strings.EqualFold(filepath.Ext(path), ".wav"))
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("closing output file: %w", closeErr)
+	}
+
+	if err != nil {
+		return PCMFormat{}, errors.Join(err, os.Remove(path))
+	}
+
+	return format, nil
+}
+
+// decodeToFile preallocates file, writes the WAV header when wav is set, and
+// streams dec into it, fixing up the sizes once the decoded length is known.
+func decodeToFile(dec *Decoder, file *os.File, wav bool) error {
+	var headerSize int64
+	if wav {
+		headerSize = wavHeaderSize
+	}
+
+	size := max(dec.OutputSize(), 0) // unknown for fragmented streams
+
+	if size > 0 {
+		if err := preallocate(file, headerSize+size); err != nil {
+			return fmt.Errorf("preallocating output file: %w", err)
+		}
+	}
+
+	if wav {
+		if _, err := file.Write(wavHeader(dec.Format(), size)); err != nil {
+			return fmt.Errorf("writing WAV header: %w", err)
+		}
+	}
+
+	written, err := io.Copy(file, dec)
+	if err != nil {
+		return err
+	}
+
+	if written == size {
+		return nil
+	}
+
+	if err := file.Truncate(headerSize + written); err != nil {
+		return fmt.Errorf("truncating output file: %w", err)
+	}
+
+	if wav {
+		if _, err := file.WriteAt(wavHeader(dec.Format(), written), 0); err != nil {
+			return fmt.Errorf("writing WAV header: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// wavHeader returns a canonical WAV header for dataSize bytes of PCM in format.
+// 20-bit samples are left-aligned in 3 bytes, so they are written as 24-bit.
+//
+//nolint:gosec // Format fields are small; sizes saturate at MaxUint32.
+func wavHeader(format PCMFormat, dataSize int64) []byte {
+	formatTag := uint16(wavFormatPCM)
+	if format.SampleFormat != SampleInt {
+		formatTag = wavFormatFloat
+	}
+
+	blockAlign := format.BytesPerFrame()
+	hdr := make([]byte, wavHeaderSize)
+
+	copy(hdr[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(min(wavHeaderSize-8+dataSize, math.MaxUint32)))
+	copy(hdr[8:12], "WAVE")
+
+	copy(hdr[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(hdr[16:20], 16)
+	binary.LittleEndian.PutUint16(hdr[20:22], formatTag)
+	binary.LittleEndian.PutUint16(hdr[22:24], uint16(format.Channels))
+	binary.LittleEndian.PutUint32(hdr[24:28], uint32(format.SampleRate))
+	binary.LittleEndian.PutUint32(hdr[28:32], uint32(format.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(hdr[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(hdr[34:36], uint16(format.BytesPerSample()*8))
+
+	copy(hdr[36:40], "data")
+	binary.LittleEndian.PutUint32(hdr[40:44], uint32(min(dataSize, math.MaxUint32)))
+
+	return hdr
+}
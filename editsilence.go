@@ -0,0 +1,137 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions are bounded by MP4 atom sizes.
+package alac
+
+import (
+	"math"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// silenceGap is a run of silent frames inserted before media frame at.
+type silenceGap struct {
+	at     int64
+	frames int64
+}
+
+// WithEditListSilence makes Read insert silence for the empty edits
+// (media_time == -1) of the track's edit list, so that the output follows
+// the presentation timeline rather than the raw media. Media edits are
+// assumed to play the media contiguously; their start offsets are not applied.
+//
+// Only Read honors the inserted silence: Position, Duration, Seek, Skip and
+// Frames do not count it.
+func WithEditListSilence() Option {
+	return func(o *options) { o.editListSilence = true }
+}
+
+// silenceGaps converts the empty edits of an edit list into media frame positions.
+func silenceGaps(edits []mp4int.Edit, movieTimescale, sampleRate uint32) []silenceGap {
+	if movieTimescale == 0 {
+		return nil
+	}
+
+	var (
+		gaps     []silenceGap
+		mediaPos int64
+	)
+
+	for _, edit := range edits {
+		frames := int64(min(mulDiv(edit.SegmentDuration, uint64(sampleRate), uint64(movieTimescale)), math.MaxInt64))
+
+		if !edit.Empty() {
+			mediaPos += frames
+
+			continue
+		}
+
+		if frames > 0 {
+			gaps = append(gaps, silenceGap{at: mediaPos, frames: frames})
+		}
+	}
+
+	return gaps
+}
+
+// mediaFrame returns the media frame index of the next sample frame Read would return.
+func (s *Decoder) mediaFrame() int64 {
+	if s.bufOff < len(s.buf) {
+		bytesPerFrame := s.dec.format.Channels * s.dec.sampleBytes
+
+		return s.packetFrame(s.sampleIdx-1) + int64(s.bufOff/bytesPerFrame)
+	}
+
+	frame := s.packetFrame(s.sampleIdx)
+	if s.trimmed() {
+		end := s.streamEnd()
+		frame = min(max(frame, s.trimStart), end, s.trimLimit(end))
+	}
+
+	return frame
+}
+
+// bufLimit returns the end of the buffered bytes Read may return before the next silence gap.
+func (s *Decoder) bufLimit() int {
+	if s.gapIdx >= len(s.gaps) {
+		return len(s.buf)
+	}
+
+	bytesPerFrame := int64(s.dec.format.Channels * s.dec.sampleBytes)
+	packetStart := s.packetFrame(s.sampleIdx - 1)
+	limit := (s.gaps[s.gapIdx].at - packetStart) * bytesPerFrame
+
+	return int(max(int64(s.bufOff), min(limit, int64(len(s.buf)))))
+}
+
+// startGap activates the next silence gap if the stream has reached it, or
+// unconditionally when atEnd is set. It reports whether a gap was activated.
+func (s *Decoder) startGap(atEnd bool) bool {
+	if s.gapIdx >= len(s.gaps) || (!atEnd && s.gaps[s.gapIdx].at > s.mediaFrame()) {
+		return false
+	}
+
+	bytesPerFrame := int64(s.dec.format.Channels * s.dec.sampleBytes)
+	s.gapBytes = min(s.gaps[s.gapIdx].frames, math.MaxInt64/bytesPerFrame) * bytesPerFrame
+	s.gapIdx++
+
+	return true
+}
+
+// chargeGap counts up to n bytes of the active gap's silence against the
+// WithMaxOutputBytes budget, like decoded PCM, and returns how many may be
+// emitted. It fails once the budget is spent.
+func (s *Decoder) chargeGap(n int) (int, error) {
+	if err := s.dec.outputLimitReached(); err != nil {
+		return 0, err
+	}
+
+	n = s.dec.chargeOutput(n)
+	s.gapBytes -= int64(n)
+
+	return n, nil
+}
+
+// seekGaps skips the silence gaps that precede media frame.
+func (s *Decoder) seekGaps(frame int64) {
+	s.gapBytes = 0
+	s.gapIdx = 0
+
+	for s.gapIdx < len(s.gaps) && s.gaps[s.gapIdx].at < frame {
+		s.gapIdx++
+	}
+}
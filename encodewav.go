@@ -0,0 +1,69 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// EncodeWAV streams dec to w as a canonical WAV file and returns the number
+// of PCM bytes written. The header is sized from OutputSize before decoding,
+// so nothing is buffered and any writer will do, pipes included. Fragmented
+// streams, whose size is unknown, declare the maximum size, as streaming WAV
+// writers do. Should the decoded length differ from the header and w be
+// seekable, the header is rewritten; on a pipe it is left as written.
+func EncodeWAV(w io.Writer, dec *Decoder) (int64, error) {
+	size := dec.OutputSize()
+	if size < 0 {
+		size = math.MaxInt64 // saturates to the WAV maximum
+	}
+
+	if _, err := w.Write(wavHeader(dec.Format(), size)); err != nil {
+		return 0, fmt.Errorf("writing WAV header: %w", err)
+	}
+
+	written, err := io.Copy(w, dec)
+	if err != nil {
+		return written, err
+	}
+
+	if written == size {
+		return written, nil
+	}
+
+	seeker, ok := w.(io.WriteSeeker)
+	if !ok {
+		return written, nil
+	}
+
+	// Pipes fail the seek: their header stays as written.
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return written, nil //nolint:nilerr // An unseekable writer keeps the estimated header.
+	}
+
+	if _, err := seeker.Write(wavHeader(dec.Format(), written)); err != nil {
+		return written, fmt.Errorf("writing WAV header: %w", err)
+	}
+
+	if _, err := seeker.Seek(0, io.SeekEnd); err != nil {
+		return written, fmt.Errorf("seeking to end of WAV: %w", err)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,64 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+
+// entropyCounts accumulates EntropyStats.
+type entropyCounts struct {
+	literals       int64
+	zeroRuns       int64
+	zeroRunSamples int64
+}
+
+// WithEntropyStats makes the decoder count how the samples of compressed
+// elements were entropy coded, for EntropyStats: as Golomb-coded literals, or
+// filled by zero runs. A high share of zero-run samples marks silent or sparse
+// content. It is off by default; the counts come from values the entropy
+// decoder computes anyway.
+func WithEntropyStats() Option {
+	return func(o *options) { o.entropyStats = true }
+}
+
+// EntropyStats returns the entropy-coded samples decoded so far, summed over
+// channels: literals counts the Golomb-coded samples, zeroRuns the zero runs
+// and zeroRunSamples the samples they filled. Samples of verbatim (escaped)
+// elements are in neither. Without WithEntropyStats it returns zeros.
+func (d *PacketDecoder) EntropyStats() (literals, zeroRuns, zeroRunSamples int64) {
+	if d.entropy == nil {
+		return 0, 0, 0
+	}
+
+	return d.entropy.literals, d.entropy.zeroRuns, d.entropy.zeroRunSamples
+}
+
+// EntropyStats returns the entropy-coded samples decoded so far; see
+// PacketDecoder.EntropyStats. Packets decoded again after a Seek are counted again.
+func (s *Decoder) EntropyStats() (literals, zeroRuns, zeroRunSamples int64) {
+	return s.dec.EntropyStats()
+}
+
+// countEntropy adds the counts of a channel's entropy decode of numSamples samples.
+func (d *PacketDecoder) countEntropy(agP *alacint.AGParams, numSamples int) {
+	if d.entropy == nil {
+		return
+	}
+
+	d.entropy.literals += int64(numSamples) - int64(agP.ZeroRunSamples)
+	d.entropy.zeroRuns += int64(agP.ZeroRuns)
+	d.entropy.zeroRunSamples += int64(agP.ZeroRunSamples)
+}
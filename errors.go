@@ -31,4 +31,21 @@ var (
 	// ErrDecode indicates a failure during packet decoding
 	// (bitstream overrun, invalid headers, unsupported elements).
 	ErrDecode = errors.New("decode failed")
+
+	// ErrTruncatedStream indicates the source ended before a packet listed in
+	// the sample table was complete. It is always reported together with
+	// io.ErrUnexpectedEOF.
+	ErrTruncatedStream = errors.New("truncated stream")
+
+	// ErrOutputLimitExceeded indicates the decoder produced the number of
+	// bytes allowed by WithMaxOutputBytes.
+	ErrOutputLimitExceeded = errors.New("output limit exceeded")
+
+	// ErrAncillaryElement indicates a packet holds a DSE or FIL element,
+	// reported only with WithRejectAncillary.
+	ErrAncillaryElement = errors.New("ancillary element present")
+
+	// ErrUnsupportedContainer indicates a container format Remux cannot
+	// write, or a source RefreshSampleTable cannot re-read.
+	ErrUnsupportedContainer = errors.New("unsupported container")
 )
@@ -16,9 +16,124 @@
 
 package alac
 
+import (
+	"fmt"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// SampleFormat selects the encoding of decoded samples.
+type SampleFormat int
+
+// Sample formats.
+const (
+	// SampleInt is little-endian signed integer PCM at the stream's bit depth
+	// (see the README for byte layouts). This is the default.
+	SampleInt SampleFormat = iota
+	// SampleFloat32 is little-endian IEEE 754 float32, normalized to [-1, 1).
+	// It takes 4 bytes per sample regardless of bit depth.
+	SampleFloat32
+	// SampleFloat64 is little-endian IEEE 754 float64, normalized to [-1, 1).
+	// It takes 8 bytes per sample: twice the memory of SampleFloat32, for
+	// analysis code (FFT libraries) that works in double precision.
+	SampleFloat64
+)
+
+// String returns a short name for the sample format.
+func (f SampleFormat) String() string {
+	switch f {
+	case SampleInt:
+		return "int"
+	case SampleFloat32:
+		return "float32"
+	case SampleFloat64:
+		return "float64"
+	default:
+		return "unknown"
+	}
+}
+
+// BytesPerSampleChecked returns the bytes one integer sample occupies at
+// bitDepth in the decoder's output layout (see the README). Unsupported
+// depths, including 8 and 12-bit PCM, return an error wrapping ErrConfig
+// rather than panicking.
+func BytesPerSampleChecked(bitDepth int) (int, error) {
+	size, err := alacint.BytesPerSampleChecked(bitDepth)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+
+	return size, nil
+}
+
 // PCMFormat describes the format of decoded PCM audio output.
 type PCMFormat struct {
 	SampleRate int
-	BitDepth   int
-	Channels   int
+	// BitDepth is the bit depth of the source stream, or the requested depth
+	// under WithOutputBitDepth. It determines the integer layout for
+	// SampleInt and the normalization for float formats.
+	BitDepth int
+	Channels int
+	// SampleFormat is the encoding of the output samples.
+	SampleFormat SampleFormat
+}
+
+// String formats the format as "44100Hz/16bit/2ch", with the sample format
+// appended for float output (e.g. "44100Hz/16bit/2ch/float32").
+func (f PCMFormat) String() string {
+	out := fmt.Sprintf("%dHz/%dbit/%dch", f.SampleRate, f.BitDepth, f.Channels)
+	if f.SampleFormat != SampleInt {
+		out += "/" + f.SampleFormat.String()
+	}
+
+	return out
+}
+
+// BytesPerSample returns the bytes one output sample occupies: 4 or 8 for
+// float formats, and 2, 3 or 4 for integer formats (20-bit samples are
+// left-aligned in 3 bytes). It returns 0 for bit depths the decoder does not
+// produce.
+func (f PCMFormat) BytesPerSample() int {
+	switch f.SampleFormat {
+	case SampleFloat32:
+		return 4
+	case SampleFloat64:
+		return 8
+	default:
+		size, err := alacint.BytesPerSampleChecked(f.BitDepth)
+		if err != nil {
+			return 0
+		}
+
+		return size
+	}
+}
+
+// BytesPerFrame returns the bytes one interleaved frame (one sample per
+// channel) occupies.
+func (f PCMFormat) BytesPerFrame() int {
+	return f.BytesPerSample() * f.Channels
+}
+
+// IsPacked24 reports whether integer samples are packed in 3 bytes, as for
+// 20 and 24-bit streams.
+func (f PCMFormat) IsPacked24() bool {
+	return f.SampleFormat == SampleInt && f.BytesPerSample() == 3 //revive:disable-line:add-constant
+}
+
+// FullScale returns the magnitude of the most negative integer sample, as
+// stored: 1<<15 for 16-bit, 1<<23 for 20 and 24-bit (20-bit samples are
+// left-aligned) and 1<<31 for 32-bit. Float samples are normalized, so it
+// returns 1 for them. It returns 0 for bit depths the decoder does not produce.
+func (f PCMFormat) FullScale() int64 {
+	if f.SampleFormat != SampleInt {
+		return 1
+	}
+
+	size := f.BytesPerSample()
+	if size == 0 {
+		return 0
+	}
+
+	return 1 << (8*size - 1)
 }
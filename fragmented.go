@@ -0,0 +1,177 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// FragmentedDecoder decodes a live fragmented MP4 stream (as used by HLS and
+// DASH) whose fragments arrive over time: an initialization segment carrying
+// the moov box, then moof+mdat fragments passed to AppendFragment. Read
+// decodes the packets appended so far.
+type FragmentedDecoder struct {
+	dec     *PacketDecoder
+	trackID uint32
+	extends mp4int.TrackExtends
+
+	// Packets appended and not yet decoded, with their bytes copied out of the
+	// fragments they arrived in.
+	pending []mp4int.SampleInfo
+	data    []byte
+	decoded int // packets decoded so far
+
+	// Per-packet PCM buffer, drained by Read.
+	buf    []byte
+	bufOff int
+}
+
+// NewFragmentedDecoder parses the initialization segment of a fragmented MP4
+// stream (ftyp and moov, without media) and returns a decoder for its first
+// ALAC track. Packets listed in the moov's own sample table, whose data is
+// not part of init, are ignored.
+func NewFragmentedDecoder(init []byte, opts ...Option) (*FragmentedDecoder, error) {
+	settings := newOptions(opts)
+
+	track, err := mp4int.FindALACTrack(bytes.NewReader(init), settings.trace)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoTrack, err)
+	}
+
+	config, err := parseMagicCookie(track.Cookie, settings.lenient)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ALAC config: %w", err)
+	}
+
+	dec, err := NewPacketDecoder(config, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FragmentedDecoder{
+		dec:     dec,
+		trackID: track.ID,
+		extends: track.Extends,
+		buf:     make([]byte, 0, int(dec.config.FrameLength)*dec.format.Channels*dec.sampleBytes),
+	}, nil
+}
+
+// Format returns the PCM output format.
+func (f *FragmentedDecoder) Format() PCMFormat { return f.dec.Format() }
+
+// AppendFragment adds the packets of the decoder's track found in moofMdat,
+// one or more moof boxes with the mdat boxes they describe, after those
+// already appended. The packet bytes are copied: moofMdat may be reused once
+// AppendFragment returns. Fragments without the track add nothing.
+//
+// A malformed fragment returns an error wrapping ErrNoTrack and adds nothing.
+func (f *FragmentedDecoder) AppendFragment(moofMdat []byte) error {
+	samples, err := mp4int.ParseFragment(moofMdat, f.trackID, f.extends)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNoTrack, err)
+	}
+
+	f.compact()
+
+	for _, sample := range samples {
+		f.pending = append(f.pending, mp4int.SampleInfo{Offset: uint64(len(f.data)), Size: sample.Size})
+		f.data = append(f.data, moofMdat[sample.Offset:sample.Offset+uint64(sample.Size)]...)
+	}
+
+	return nil
+}
+
+// Read reads decoded PCM bytes from the packets appended so far. Once they
+// are exhausted it returns io.EOF; Read resumes after the next AppendFragment.
+//
+// A packet that fails to decode is dropped: Read returns the error, and the
+// next call continues with the following packet.
+func (f *FragmentedDecoder) Read(p []byte) (int, error) { //nolint:varnamelen // p is idiomatic for io.Reader.Read
+	total := 0
+
+	for len(p) > 0 {
+		if f.bufOff < len(f.buf) {
+			n := copy(p, f.buf[f.bufOff:])
+			f.bufOff += n
+			total += n
+			p = p[n:]
+
+			continue
+		}
+
+		if len(f.pending) == 0 {
+			if total > 0 {
+				return total, nil
+			}
+
+			return 0, io.EOF
+		}
+
+		if err := f.fill(); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// compact drops the bytes of decoded packets from data.
+func (f *FragmentedDecoder) compact() {
+	if len(f.pending) == 0 {
+		f.data = f.data[:0]
+
+		return
+	}
+
+	start := f.pending[0].Offset
+	if start == 0 {
+		return
+	}
+
+	f.data = f.data[:copy(f.data, f.data[start:])]
+
+	for idx := range f.pending {
+		f.pending[idx].Offset -= start
+	}
+}
+
+// fill decodes the next pending packet into buf.
+func (f *FragmentedDecoder) fill() error {
+	sample := f.pending[0]
+	packet := f.data[sample.Offset : sample.Offset+uint64(sample.Size)]
+
+	f.pending = f.pending[1:]
+	f.decoded++
+
+	f.buf = f.buf[:cap(f.buf)]
+	f.bufOff = 0
+
+	n, err := f.dec.decodePacketInto(packet, f.buf)
+	if err != nil {
+		f.buf = f.buf[:0]
+
+		return fmt.Errorf("decoding packet %d: %w", f.decoded-1, err)
+	}
+
+	f.buf = f.buf[:n]
+
+	return nil
+}
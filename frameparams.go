@@ -0,0 +1,97 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// ChannelParams holds the prediction parameters the encoder chose for one
+// coded channel of an element.
+type ChannelParams struct {
+	// Mode is the prediction mode (0: adaptive FIR, nonzero: first-order delta pre-pass).
+	Mode uint8
+	// DenShift is the predictor coefficient denominator shift.
+	DenShift uint8
+	// PBFactor scales the Rice parameter limit (pb * PBFactor / 4).
+	PBFactor uint8
+	// Coefs are the predictor coefficients; len(Coefs) is the predictor order.
+	// The slice is only valid for the duration of the sink callback.
+	Coefs []int16
+}
+
+// FrameParams describes how one channel element (SCE, LFE or CPE) of a packet
+// was coded. It is delivered to the sink installed with WithFrameParamSink.
+type FrameParams struct {
+	// Channel is the output position of the element's first channel.
+	Channel int
+	// Pair reports a channel pair element; Channels[1] is then populated.
+	Pair bool
+	// Escape reports an uncompressed element; Channels are then zero.
+	Escape bool
+	// NumSamples is the number of frames in the element.
+	NumSamples int
+	// BytesShifted is the number of low-order bytes stored uncompressed.
+	BytesShifted int
+	// MixBits and MixRes are the stereo decorrelation parameters (pairs only).
+	MixBits int32
+	MixRes  int32
+	// Channels holds per-channel prediction parameters (U, then V for pairs).
+	Channels [2]ChannelParams
+}
+
+// WithFrameParamSink installs a callback receiving the prediction parameters
+// of every channel element decoded, for encoder analysis. The callback runs
+// synchronously on the decoding goroutine. Without a sink, which is the
+// default, the parameters are not collected.
+func WithFrameParamSink(fn func(FrameParams)) Option {
+	return func(o *options) { o.frameParamSink = fn }
+}
+
+// recordChannelParams captures the predictor header of coded channel idx (0 for U, 1 for V).
+func (d *PacketDecoder) recordChannelParams(idx int, mode, denShift, pbFactor uint32, coefs []int16) {
+	buf := d.paramCoefs[idx][:len(coefs)]
+	copy(buf, coefs)
+
+	d.params.Channels[idx] = ChannelParams{
+		Mode:     uint8(mode),
+		DenShift: uint8(denShift),
+		PBFactor: uint8(pbFactor),
+		Coefs:    buf,
+	}
+}
+
+// emitFrameParams completes the captured element parameters and hands them to the sink.
+func (d *PacketDecoder) emitFrameParams(chanIdx int, pair, escape bool, numSamples uint32, bytesShifted int,
+	mixBits, mixRes int32,
+) {
+	params := d.params
+	params.Channel = chanIdx
+	params.Pair = pair
+	params.Escape = escape
+	params.NumSamples = int(numSamples)
+	params.BytesShifted = bytesShifted
+	params.MixBits = mixBits
+	params.MixRes = mixRes
+
+	if escape {
+		params.Channels = [2]ChannelParams{}
+	}
+
+	if !pair {
+		params.Channels[1] = ChannelParams{}
+	}
+
+	d.params = FrameParams{}
+	d.paramSink(params)
+}
@@ -0,0 +1,67 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"io"
+	"iter"
+	"time"
+)
+
+// Frames returns an iterator over the decoded packets of the stream, starting
+// at the current position. Each step yields the timestamp of the packet's
+// first sample frame, counted like Position, and its interleaved PCM. The
+// slice is reused and is only valid within the loop body.
+//
+// Iteration stops at the end of the stream or on the first error; Err then
+// reports the error, if any. Breaking out of the loop leaves the decoder
+// positioned after the last yielded packet.
+func (s *Decoder) Frames() iter.Seq2[time.Duration, []byte] {
+	return func(yield func(time.Duration, []byte) bool) {
+		s.framesErr = nil
+		s.packPlanar()
+		bytesPerFrame := s.dec.format.Channels * s.dec.sampleBytes
+
+		for {
+			if s.bufOff >= len(s.buf) {
+				if err := s.fill(); err != nil {
+					if err != io.EOF { //nolint:errorlint // fill returns io.EOF unwrapped.
+						s.framesErr = err
+					}
+
+					return
+				}
+
+				if s.bufOff >= len(s.buf) {
+					continue
+				}
+			}
+
+			start := s.packetFrame(s.sampleIdx-1) + int64(s.bufOff/bytesPerFrame) - s.presentationOffset
+			pcm := s.buf[s.bufOff:]
+			s.bufOff = len(s.buf)
+
+			if !yield(frameTime(start, int64(s.dec.config.SampleRate)), pcm) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the error that stopped the most recent Frames iteration,
+// or nil if it ran to the end of the stream or was stopped by the caller.
+func (s *Decoder) Err() error { return s.framesErr }
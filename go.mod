@@ -3,6 +3,7 @@ module github.com/mycophonic/saprobe-alac
 go 1.25.7
 
 require (
+	github.com/go-audio/audio v1.0.0
 	github.com/mycophonic/agar v0.1.5
 	github.com/mycophonic/primordium v0.1.0
 )
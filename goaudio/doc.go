@@ -0,0 +1,24 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package goaudio adapts decoded ALAC streams to the go-audio ecosystem
+// (github.com/go-audio/audio), for pipelines built on its buffer types.
+//
+// The adapter is only compiled with the goaudio build tag, so that the
+// dependency stays optional:
+//
+//	go build -tags goaudio
+package goaudio
@@ -0,0 +1,97 @@
+//go:build goaudio
+
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions reinterpret little-endian PCM bytes.
+package goaudio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-audio/audio"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+// readChunkFrames is the number of sample frames read per iteration.
+const readChunkFrames = 4096
+
+// ErrSampleFormat indicates a decoder producing floating-point samples, which
+// an IntBuffer cannot hold.
+var ErrSampleFormat = errors.New("goaudio: IntBuffer requires integer samples")
+
+// IntBuffer decodes dec from its current position to the end of the stream
+// into an audio.IntBuffer. Format carries the channel count and sample rate,
+// and SourceBitDepth the output bit depth, at whose scale the samples are
+// given: 20-bit samples are returned as 20-bit values, not left-aligned in 24
+// bits as in the byte output. The whole remainder is held in memory.
+func IntBuffer(dec *alac.Decoder) (*audio.IntBuffer, error) {
+	format := dec.Format()
+	if format.SampleFormat != alac.SampleInt {
+		return nil, fmt.Errorf("%w: got %v", ErrSampleFormat, format.SampleFormat)
+	}
+
+	readSample := sampleReader(format.BitDepth)
+	bps := format.BytesPerSample()
+	bytesPerFrame := format.BytesPerFrame()
+
+	out := &audio.IntBuffer{
+		Format:         &audio.Format{NumChannels: format.Channels, SampleRate: format.SampleRate},
+		SourceBitDepth: format.BitDepth,
+	}
+
+	buf := make([]byte, readChunkFrames*bytesPerFrame)
+	pending := 0
+
+	for {
+		n, err := dec.Read(buf[pending:])
+		avail := pending + n
+		whole := avail - avail%bytesPerFrame
+
+		for off := 0; off < whole; off += bps {
+			out.Data = append(out.Data, readSample(buf[off:]))
+		}
+
+		pending = copy(buf, buf[whole:avail])
+
+		if err == io.EOF { //nolint:errorlint // io.Reader contract: io.EOF is returned unwrapped.
+			return out, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sampleReader returns a function reading one little-endian sample of the
+// given bit depth at its own scale.
+func sampleReader(bitDepth int) func([]byte) int {
+	switch bitDepth {
+	case 16:
+		return func(b []byte) int { return int(int16(binary.LittleEndian.Uint16(b))) }
+	case 20:
+		return func(b []byte) int { return int(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 12) }
+	case 24:
+		return func(b []byte) int { return int(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8) }
+	default:
+		return func(b []byte) int { return int(int32(binary.LittleEndian.Uint32(b))) }
+	}
+}
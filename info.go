@@ -0,0 +1,66 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// ContainerType identifies the container a Decoder reads packets from.
+type ContainerType int
+
+// Supported containers.
+const (
+	ContainerUnknown ContainerType = iota
+	ContainerMP4
+	ContainerFragmentedMP4
+	ContainerMatroska
+	ContainerOgg
+)
+
+// String returns a short name for the container type.
+func (c ContainerType) String() string {
+	switch c {
+	case ContainerMP4:
+		return "mp4"
+	case ContainerFragmentedMP4:
+		return "fmp4"
+	case ContainerMatroska:
+		return "mkv"
+	case ContainerOgg:
+		return "ogg"
+	case ContainerUnknown:
+		return "unknown"
+	default:
+		return "unknown"
+	}
+}
+
+// StreamInfo reports the capabilities of a Decoder's source.
+type StreamInfo struct {
+	// Seekable reports whether Seek can reposition the stream. Decoders built
+	// from a complete sample table (NewDecoder, NewDecoderAt) are seekable.
+	Seekable bool
+	// Gapless reports whether the container carries gapless playback
+	// information (an MP4 edit list). It is always false for Matroska and Ogg.
+	Gapless bool
+	// Container identifies the source container format.
+	Container ContainerType
+}
+
+// Info returns the capabilities of the stream.
+func (s *Decoder) Info() StreamInfo { return s.info }
+
+// CanSeek reports whether Seek can reposition the stream.
+// It is a shorthand for Info().Seekable.
+func (s *Decoder) CanSeek() bool { return s.info.Seekable }
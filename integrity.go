@@ -0,0 +1,54 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// IntegrityHint reports whether every packet decoded so far stayed within the
+// cookie's MaxFrameBytes (when set) and ended with an END element that, once
+// byte-aligned, reaches exactly the end of the packet. ALAC carries no
+// checksum, so this is no proof of integrity, but a corrupt sample table or a
+// damaged bitstream rarely passes it. The check costs nothing measurable and
+// is always on; WithPacketSizeCheck names the packets whose size is off.
+func (d *PacketDecoder) IntegrityHint() bool {
+	return !d.irregular
+}
+
+// IntegrityHint reports whether every packet decoded so far stayed within the
+// cookie's MaxFrameBytes and ended cleanly at its END element. See
+// PacketDecoder.IntegrityHint.
+func (s *Decoder) IntegrityHint() bool {
+	return s.dec.IntegrityHint()
+}
+
+// checkIntegrity records a packet of size bytes whose elements do not fill
+// it exactly up to a byte-aligned END element, or that exceeds MaxFrameBytes.
+// Decoding stops after the last channel element, so an unread END tag is peeked.
+func (d *PacketDecoder) checkIntegrity(size int, ended bool) {
+	if maxBytes := d.config.MaxFrameBytes; maxBytes > 0 && uint64(size) > uint64(maxBytes) {
+		d.irregular = true
+	}
+
+	if !ended {
+		peek := d.bits.Copy()
+		if peek.Remaining() < endTagBits || peek.ReadSmall(endTagBits) != elemEND {
+			d.irregular = true
+		}
+	}
+
+	if d.bytesUsed(ended) != size {
+		d.irregular = true
+	}
+}
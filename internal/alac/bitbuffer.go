@@ -20,22 +20,28 @@ package alac
 // BitBuffer provides bit-level reading from a byte buffer.
 // Ported from ALACBitUtilities.c.
 //
-// The buffer is padded with 4 zero bytes to allow safe reads near the end
+// The buffer is padded with zero bytes to allow safe reads near the end
 // without bounds checking in hot paths.
 type BitBuffer struct {
-	Buf    []byte // padded data (original + 4 zero bytes)
+	Buf    []byte // padded data (original + BitBufferPadding zero bytes)
 	Pos    int    // current byte position within Buf
 	BitIdx uint32 // 0-7, bit offset within current byte
 	Size   int    // original (unpadded) byte size
+
+	borrowed bool // Buf belongs to a ResetNoCopy caller and must not be reused
 }
 
-const bitBufferPadding = 4
+// BitBufferPadding covers the furthest read past the end of the data: the
+// number of zero bytes ResetNoCopy callers must provide after it.
+// BitBuffer reads load at most 3 bytes from a position callers have checked
+// is inside the data; DynDecomp reads further (see dynDecompReadAhead).
+const BitBufferPadding = max(3, dynDecompReadAhead)
 
 // Reset reuses the BitBuffer's backing storage, growing it only if needed.
 // This avoids a fresh allocation per packet.
 func (b *BitBuffer) Reset(data []byte) {
-	needed := len(data) + bitBufferPadding
-	if cap(b.Buf) < needed {
+	needed := len(data) + BitBufferPadding
+	if b.borrowed || cap(b.Buf) < needed {
 		b.Buf = make([]byte, needed)
 	} else {
 		b.Buf = b.Buf[:needed]
@@ -48,13 +54,29 @@ func (b *BitBuffer) Reset(data []byte) {
 	b.Pos = 0
 	b.BitIdx = 0
 	b.Size = len(data)
+	b.borrowed = false
+}
+
+// ResetNoCopy points the BitBuffer at the first size bytes of padded, without
+// copying them, for data already in memory with BitBufferPadding zero bytes
+// after it. The padding must be zero, as Reset leaves it: DynDecomp relies on
+// it to rule out an escape past the end of the data, and reads past a nonzero
+// padding may go out of range. padded is never written, and the next Reset
+// allocates storage of its own rather than reuse it. It panics if padded is
+// shorter than size+BitBufferPadding.
+func (b *BitBuffer) ResetNoCopy(padded []byte, size int) {
+	b.Buf = padded[:size+BitBufferPadding]
+	b.Pos = 0
+	b.BitIdx = 0
+	b.Size = size
+	b.borrowed = true
 }
 
 // Read reads up to 16 bits and returns them right-aligned.
 // Equivalent to BitBufferRead in the Apple implementation.
 func (b *BitBuffer) Read(numBits uint8) uint32 {
 	// BCE: sub-slice with constant length 3; element indices 0,1,2 are provably in-bounds.
-	// Safety: 4-byte padding guarantees Pos+2 < len(Buf) for any valid position.
+	// Safety: padding guarantees Pos+2 < len(Buf) for any valid position.
 	w := b.Buf[b.Pos : b.Pos+3 : b.Pos+3]
 	// Load 3 bytes starting at current position (24 bits available).
 	returnBits := uint32(w[0])<<16 | uint32(w[1])<<8 | uint32(w[2])
@@ -116,6 +138,18 @@ func (b *BitBuffer) PastEnd() bool {
 	return b.Pos >= b.Size
 }
 
+// Remaining returns the number of unread bits before the original data end.
+// It is negative once reads have run into the padding.
+func (b *BitBuffer) Remaining() int {
+	return (b.Size-b.Pos)*8 - int(b.BitIdx)
+}
+
+// Position returns the read position as a byte offset and a bit offset
+// (0-7, from the most significant bit) within that byte.
+func (b *BitBuffer) Position() (int, int) {
+	return b.Pos, int(b.BitIdx)
+}
+
 // Copy returns a snapshot of the current BitBuffer state.
 // The copy shares the underlying data but has independent position tracking.
 func (b *BitBuffer) Copy() BitBuffer {
@@ -0,0 +1,128 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions reinterpret little-endian PCM bytes.
+package alac
+
+// Bit-depth conversion of interleaved little-endian integer PCM.
+//
+// Samples use the same layouts as the Write* functions: 16-bit in 2 bytes,
+// 20 and 24-bit in 3 bytes (20-bit left-aligned), 32-bit in 4 bytes. 8-bit
+// samples, which no stream carries but a conversion may target, take 1 byte
+// and are unsigned, as in WAV: offset by 128, so that silence is 0x80.
+
+// unsignedOffset8 is the bias of unsigned 8-bit PCM.
+const unsignedOffset8 = 128
+
+// ditherSeed is the initial xorshift32 state for TPDF dither noise.
+const ditherSeed = 0x9E3779B9
+
+// Dither generates TPDF dither noise with a xorshift32 generator. The zero
+// value is ready to use; the sequence is deterministic.
+type Dither struct {
+	state uint32
+}
+
+// NewDither returns a generator whose sequence is determined by seed. The two
+// halves of seed are folded into the 32-bit state; a seed folding to zero,
+// which xorshift cannot leave, selects the default sequence.
+func NewDither(seed uint64) *Dither {
+	return &Dither{state: uint32(seed) ^ uint32(seed>>32)}
+}
+
+// next returns the next 32 pseudo-random bits.
+func (d *Dither) next() uint32 {
+	if d.state == 0 {
+		d.state = ditherSeed
+	}
+
+	d.state ^= d.state << 13
+	d.state ^= d.state >> 17
+	d.state ^= d.state << 5
+
+	return d.state
+}
+
+// noise returns triangular noise spanning ±(2^bits - 1), i.e. ±1 LSB at a
+// precision reduced by bits.
+func (d *Dither) noise(bits uint) int64 {
+	mask := uint32(1)<<bits - 1
+
+	return int64(d.next()&mask) - int64(d.next()&mask)
+}
+
+// ConvertBitDepth rewrites the samples in src, stored at inDepth, into dst at
+// outDepth. dst must hold len(src)/BytesPerSample(inDepth) samples of outDepth.
+//
+// Widening shifts left and is exact. Narrowing truncates; with a non-nil
+// dither it adds TPDF noise, rounds and clamps to the output range instead.
+func ConvertBitDepth(dst, src []byte, inDepth, outDepth uint8, dither *Dither) {
+	inBytes := BytesPerSample(inDepth)
+	outBytes := BytesPerSample(outDepth)
+	inAlign := uint(inBytes*8) - uint(inDepth)
+	outAlign := uint(outBytes*8) - uint(outDepth)
+	count := len(src) / inBytes
+
+	for idx := range count {
+		val := int64(readContainer(src[idx*inBytes:], inBytes) >> inAlign)
+
+		switch {
+		case outDepth > inDepth:
+			val <<= uint(outDepth - inDepth)
+		case outDepth < inDepth:
+			shift := uint(inDepth - outDepth)
+
+			if dither != nil {
+				val += dither.noise(shift) + 1<<(shift-1)
+			}
+
+			val >>= shift
+			val = min(max(val, -1<<(outDepth-1)), 1<<(outDepth-1)-1)
+		default:
+		}
+
+		writeContainer(dst[idx*outBytes:], outBytes, int32(val)<<outAlign)
+	}
+}
+
+// readContainer returns the sign-extended value of a 1, 2, 3 or 4-byte
+// sample, removing the unsigned offset of 1-byte samples.
+func readContainer(b []byte, size int) int32 {
+	switch size {
+	case 1:
+		return int32(b[0]) - unsignedOffset8
+	case 2:
+		return int32(int16(uint16(b[0]) | uint16(b[1])<<8))
+	case 3:
+		return int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+	default:
+		return int32(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+	}
+}
+
+// writeContainer stores the low size bytes of val little-endian, adding the
+// unsigned offset to 1-byte samples.
+func writeContainer(b []byte, size int, val int32) {
+	if size == 1 {
+		b[0] = byte(val + unsignedOffset8)
+
+		return
+	}
+
+	for i := range size {
+		b[i] = byte(val >> (8 * i))
+	}
+}
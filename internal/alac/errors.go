@@ -30,4 +30,11 @@ var (
 	ErrBitstreamOverrun   = errors.New("alac: bitstream overrun")
 	ErrSampleOverrun      = errors.New("alac: sample count exceeds buffer")
 	ErrBitDepth           = errors.New("alac: unsupported bit depth")
+	ErrChannelCount       = errors.New("alac: unsupported channel count")
+	ErrSampleRate         = errors.New("alac: sample rate is zero")
+	ErrFrameLength        = errors.New("alac: frame length exceeds maximum")
+	ErrZeroFrameLength    = errors.New("alac: frame length is zero")
+	ErrSampleFormat       = errors.New("alac: unsupported sample format")
+	ErrScratchLength      = errors.New("alac: scratch buffer shorter than frame length")
+	ErrPacketSize         = errors.New("alac: decoded length differs from packet size")
 )
@@ -19,9 +19,12 @@ package alac
 import "fmt"
 
 // BytesPerSample returns the number of bytes needed to store one sample at
-// the given bit depth. Only ALAC-supported depths (16, 20, 24, 32) are valid.
+// the given bit depth. Only ALAC-supported depths (16, 20, 24, 32) and the
+// unsigned 8-bit layout of ConvertBitDepth are valid.
 func BytesPerSample(depth uint8) int {
 	switch depth {
+	case 8:
+		return 1
 	case 16:
 		return 2
 	case 20, 24:
@@ -32,3 +35,15 @@ func BytesPerSample(depth uint8) int {
 		panic(fmt.Sprintf("alac: BytesPerSample called with unsupported bit depth %d", depth))
 	}
 }
+
+// BytesPerSampleChecked is BytesPerSample for API boundaries: it returns
+// ErrBitDepth for unsupported depths (including 4, 8 and 12-bit PCM) instead
+// of panicking.
+func BytesPerSampleChecked(depth int) (int, error) {
+	switch depth {
+	case 16, 20, 24, 32:
+		return BytesPerSample(uint8(depth)), nil
+	default:
+		return 0, fmt.Errorf("%w: %d", ErrBitDepth, depth)
+	}
+}
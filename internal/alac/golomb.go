@@ -19,6 +19,7 @@ package alac
 
 import (
 	"encoding/binary"
+	"fmt"
 	"math/bits"
 )
 
@@ -38,8 +39,24 @@ const (
 	nMaxMeanClamp = 0xffff
 	nMeanClampVal = 0xffff
 	maxZeroRun    = 65535 // Maximum zero-run length before resetting zmode.
+	maxEscapeBits = 32    // Largest escaped residual (chanBits).
 )
 
+// dynDecompReadAhead is the number of bytes DynDecomp may read past the end of
+// its input. The loop only checks that each sample starts inside the input.
+//
+//   - A coded residual is a prefix of fewer than maxPrefix32 one bits and at
+//     most 23 suffix bits (k is capped by lg3a of a 23-bit mean), read by a
+//     single 4-byte load.
+//   - An escape needs maxPrefix32 one bits, which the zero padding cannot
+//     supply, so its value starts by the end of the input and ends at most
+//     maxEscapeBits past it. getStreamBits loads 5 bytes from there.
+//   - A zero-run count may follow either, loaded with 4 bytes from where the
+//     residual ended: at most maxEscapeBits/8 bytes past the end.
+//
+// The last case is the furthest: maxEscapeBits/8 + 4 bytes.
+const dynDecompReadAhead = maxEscapeBits/8 + 4
+
 // AGParams holds the adaptive Golomb-Rice codec state.
 type AGParams struct {
 	MB, MB0 uint32
@@ -49,6 +66,10 @@ type AGParams struct {
 	QB      uint32
 	FW, SW  uint32
 	MaxRun  uint32
+
+	// ZeroRuns and ZeroRunSamples are set by DynDecomp: the zero runs decoded
+	// and the samples they filled. Every other sample is Golomb-coded.
+	ZeroRuns, ZeroRunSamples uint32
 }
 
 // SetAGParams initialises the adaptive Golomb-Rice parameters.
@@ -61,6 +82,8 @@ func SetAGParams(params *AGParams, meanBase, partBound, kBase, frameWin, sampleW
 	params.QB = quantBits - partBound
 	params.FW = frameWin
 	params.SW = sampleWin
+	params.ZeroRuns = 0
+	params.ZeroRunSamples = 0
 	params.MaxRun = maxrun
 }
 
@@ -148,7 +171,8 @@ func dynGet(input []byte, bitPos, golombM, golombK uint32) (result, newBitPos ui
 func DynDecomp(params *AGParams, bitBuf *BitBuffer, predCoefs []int32, numSamples, maxSize int) error {
 	input := bitBuf.Buf[bitBuf.Pos:]
 	startPos := bitBuf.BitIdx
-	maxPos := uint32(bitBuf.Size-bitBuf.Pos) * 8
+	// A previous block may have overrun into the padding: nothing is left to decode.
+	maxPos := uint32(max(bitBuf.Size-bitBuf.Pos, 0)) * 8
 	bitPos := startPos
 
 	// BCE: reslice so compiler knows len(predCoefs) == numSamples.
@@ -162,11 +186,11 @@ func DynDecomp(params *AGParams, bitBuf *BitBuffer, predCoefs []int32, numSample
 	kbLocal := params.KB
 	wbLocal := params.WB
 
-	var residual uint32
+	var residual, runs, runSamples uint32
 
 	for count < numSamples {
 		if bitPos >= maxPos {
-			return ErrBitstreamOverrun
+			return overrunError(ErrBitstreamOverrun, count, numSamples, bitBuf.Pos, bitPos)
 		}
 
 		m := meanAccum >> qbShift                //nolint:varnamelen // standard Golomb-Rice parameter name
@@ -230,13 +254,15 @@ func DynDecomp(params *AGParams, bitBuf *BitBuffer, predCoefs []int32, numSample
 			residual, bitPos = dynGet(input, bitPos, mz, uint32(k32))
 
 			if count+int(residual) > numSamples {
-				return ErrSampleOverrun
+				return overrunError(ErrSampleOverrun, count, numSamples, bitBuf.Pos, bitPos)
 			}
 
 			// BCE: single slice check replaces per-element bounds checks.
 			end := count + int(residual)
 			clear(predCoefs[count:end])
 			count = end
+			runs++
+			runSamples += residual
 
 			if residual >= maxZeroRun {
 				zmode = 0
@@ -249,5 +275,14 @@ func DynDecomp(params *AGParams, bitBuf *BitBuffer, predCoefs []int32, numSample
 	bitsConsumed := bitPos - startPos
 	bitBuf.Advance(bitsConsumed)
 
+	params.ZeroRuns = runs
+	params.ZeroRunSamples = runSamples
+
 	return nil
 }
+
+// overrunError wraps a DynDecomp failure with the sample it stopped at and its
+// bit offset in the packet, given the block's starting byte and bit offset.
+func overrunError(err error, count, numSamples, startByte int, bitPos uint32) error {
+	return fmt.Errorf("%w: at sample %d/%d, bit %d", err, count, numSamples, startByte*8+int(bitPos))
+}
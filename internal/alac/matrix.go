@@ -17,12 +17,17 @@
 //nolint:gosec // Integer conversions match Apple reference C implementation's fixed-width arithmetic.
 package alac
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"math"
+)
 
 // Matrix unmix and output byte formatting.
 // Ported from matrix_dec.c.
 //
-// All output is interleaved little-endian signed PCM.
+// Integer output is interleaved little-endian signed PCM. Float output
+// (WriteStereoFloat32/64, WriteMonoFloat32/64) is interleaved little-endian
+// IEEE 754, normalized by 2^(bitDepth-1) to [-1, 1).
 
 // --- Stereo unmix (channel pair) ---
 
@@ -62,7 +67,9 @@ func WriteStereo16(out []byte, mixU, mixV []int32, chanIdx, numChan, numSamples
 	}
 }
 
-// WriteStereo20 unmixes and writes 20-bit stereo PCM.
+// WriteStereo20 unmixes and writes 20-bit stereo PCM, left-justified in 3
+// bytes (sample << 4) as Apple's copyPredictorTo20 does. ffmpeg's decoder
+// shifts 20-bit samples left by 12 into 32 bits, so its s24le output matches.
 func WriteStereo20(out []byte, mixU, mixV []int32, chanIdx, numChan, numSamples int, mixBits, mixRes int32) {
 	stride := numChan * 3
 	off := chanIdx * 3
@@ -162,6 +169,18 @@ func WriteStereo24(out []byte, mixU, mixV []int32, chanIdx, numChan, numSamples
 	}
 }
 
+// WriteStereo24In32 unmixes 24-bit stereo into 4-byte little-endian words,
+// sign-extended, trading a third more output bytes for aligned stores. The
+// unmixed samples are already sign-extended int32 values, so this is the
+// 32-bit writer applied to 24-bit streams.
+//
+//revive:disable-next-line:argument-limit
+func WriteStereo24In32(out []byte, mixU, mixV []int32, chanIdx, numChan, numSamples int,
+	mixBits, mixRes int32, shiftBuf []uint16, bytesShifted int,
+) {
+	WriteStereo32(out, mixU, mixV, chanIdx, numChan, numSamples, mixBits, mixRes, shiftBuf, bytesShifted)
+}
+
 // WriteStereo32 unmixes and writes 32-bit stereo PCM.
 //
 //revive:disable-next-line:argument-limit
@@ -214,6 +233,38 @@ func WriteStereo32(out []byte, mixU, mixV []int32, chanIdx, numChan, numSamples
 	}
 }
 
+// UnmixStereo unmixes a channel pair in place, leaving the left channel in
+// mixU and the right in mixV at the stream's bit depth, with the shifted-out
+// low bits restored: the samples WriteStereo* would pack, without packing them.
+//
+//revive:disable-next-line:argument-limit
+func UnmixStereo(mixU, mixV []int32, numSamples int, mixBits, mixRes int32, shiftBuf []uint16, bytesShifted int) {
+	shift := bytesShifted * 8
+
+	mixU = mixU[:numSamples:numSamples]
+	mixV = mixV[:numSamples:numSamples]
+
+	if bytesShifted != 0 {
+		shiftBuf = shiftBuf[: numSamples*2 : numSamples*2]
+	}
+
+	for idx := range mixU {
+		left, right := mixU[idx], mixV[idx]
+
+		if mixRes != 0 {
+			left = mixU[idx] + mixV[idx] - ((mixRes * mixV[idx]) >> mixBits)
+			right = left - mixV[idx]
+		}
+
+		if bytesShifted != 0 {
+			left = (left << shift) | int32(shiftBuf[idx*2+0])
+			right = (right << shift) | int32(shiftBuf[idx*2+1])
+		}
+
+		mixU[idx], mixV[idx] = left, right
+	}
+}
+
 // --- Mono output (single channel) ---
 
 // WriteMono16 writes 16-bit mono PCM.
@@ -231,7 +282,7 @@ func WriteMono16(out []byte, mixU []int32, chanIdx, numChan, numSamples int) {
 	}
 }
 
-// WriteMono20 writes 20-bit mono PCM.
+// WriteMono20 writes 20-bit mono PCM, laid out like WriteStereo20.
 func WriteMono20(out []byte, mixU []int32, chanIdx, numChan, numSamples int) {
 	stride := numChan * 3
 	off := chanIdx * 3
@@ -299,3 +350,166 @@ func WriteMono32(out []byte, mixU []int32, chanIdx, numChan, numSamples int, shi
 		off += stride
 	}
 }
+
+// UnshiftMono restores the shifted-out low bits of a single channel in place,
+// leaving the samples WriteMono* would pack.
+func UnshiftMono(mixU []int32, numSamples int, shiftBuf []uint16, bytesShifted int) {
+	if bytesShifted == 0 {
+		return
+	}
+
+	shift := bytesShifted * 8
+	mixU = mixU[:numSamples:numSamples]
+	shiftBuf = shiftBuf[:numSamples:numSamples]
+
+	for idx := range mixU {
+		mixU[idx] = (mixU[idx] << shift) | int32(shiftBuf[idx])
+	}
+}
+
+// --- Float output (any bit depth) ---
+
+// floatScale returns the normalization factor for samples of the given bit depth.
+func floatScale(bitDepth int) float64 {
+	return 1 / float64(int64(1)<<(bitDepth-1))
+}
+
+// WriteStereoFloat32 unmixes and writes stereo samples as normalized float32.
+//
+//revive:disable-next-line:argument-limit
+func WriteStereoFloat32(out []byte, mixU, mixV []int32, chanIdx, numChan, numSamples int,
+	mixBits, mixRes int32, shiftBuf []uint16, bytesShifted, bitDepth int,
+) {
+	stride := numChan * 4
+	shift := bytesShifted * 8
+	off := chanIdx * 4
+	scale := floatScale(bitDepth)
+
+	mixU = mixU[:numSamples:numSamples]
+	mixV = mixV[:numSamples:numSamples]
+
+	if bytesShifted != 0 {
+		shiftBuf = shiftBuf[: numSamples*2 : numSamples*2]
+	}
+
+	for idx := range mixU {
+		left := mixU[idx]
+		right := mixV[idx]
+
+		if mixRes != 0 {
+			left = mixU[idx] + mixV[idx] - ((mixRes * mixV[idx]) >> mixBits)
+			right = left - mixV[idx]
+		}
+
+		if bytesShifted != 0 {
+			left = (left << shift) | int32(shiftBuf[idx*2+0])
+			right = (right << shift) | int32(shiftBuf[idx*2+1])
+		}
+
+		dst := out[off : off+8 : off+8]
+		binary.LittleEndian.PutUint32(dst[:4], math.Float32bits(float32(float64(left)*scale)))
+		binary.LittleEndian.PutUint32(dst[4:], math.Float32bits(float32(float64(right)*scale)))
+
+		off += stride
+	}
+}
+
+// WriteStereoFloat64 unmixes and writes stereo samples as normalized float64.
+//
+//revive:disable-next-line:argument-limit
+func WriteStereoFloat64(out []byte, mixU, mixV []int32, chanIdx, numChan, numSamples int,
+	mixBits, mixRes int32, shiftBuf []uint16, bytesShifted, bitDepth int,
+) {
+	stride := numChan * 8
+	shift := bytesShifted * 8
+	off := chanIdx * 8
+	scale := floatScale(bitDepth)
+
+	mixU = mixU[:numSamples:numSamples]
+	mixV = mixV[:numSamples:numSamples]
+
+	if bytesShifted != 0 {
+		shiftBuf = shiftBuf[: numSamples*2 : numSamples*2]
+	}
+
+	for idx := range mixU {
+		left := mixU[idx]
+		right := mixV[idx]
+
+		if mixRes != 0 {
+			left = mixU[idx] + mixV[idx] - ((mixRes * mixV[idx]) >> mixBits)
+			right = left - mixV[idx]
+		}
+
+		if bytesShifted != 0 {
+			left = (left << shift) | int32(shiftBuf[idx*2+0])
+			right = (right << shift) | int32(shiftBuf[idx*2+1])
+		}
+
+		dst := out[off : off+16 : off+16]
+		binary.LittleEndian.PutUint64(dst[:8], math.Float64bits(float64(left)*scale))
+		binary.LittleEndian.PutUint64(dst[8:], math.Float64bits(float64(right)*scale))
+
+		off += stride
+	}
+}
+
+// WriteMonoFloat32 writes mono samples as normalized float32.
+//
+//revive:disable-next-line:argument-limit
+func WriteMonoFloat32(out []byte, mixU []int32, chanIdx, numChan, numSamples int,
+	shiftBuf []uint16, bytesShifted, bitDepth int,
+) {
+	stride := numChan * 4
+	shift := bytesShifted * 8
+	off := chanIdx * 4
+	scale := floatScale(bitDepth)
+
+	mixU = mixU[:numSamples:numSamples]
+
+	if bytesShifted != 0 {
+		shiftBuf = shiftBuf[:numSamples:numSamples]
+	}
+
+	for idx := range mixU {
+		val := mixU[idx]
+		if bytesShifted != 0 {
+			val = (val << shift) | int32(shiftBuf[idx])
+		}
+
+		dst := out[off : off+4 : off+4]
+		binary.LittleEndian.PutUint32(dst, math.Float32bits(float32(float64(val)*scale)))
+
+		off += stride
+	}
+}
+
+// WriteMonoFloat64 writes mono samples as normalized float64.
+//
+//revive:disable-next-line:argument-limit
+func WriteMonoFloat64(out []byte, mixU []int32, chanIdx, numChan, numSamples int,
+	shiftBuf []uint16, bytesShifted, bitDepth int,
+) {
+	stride := numChan * 8
+	shift := bytesShifted * 8
+	off := chanIdx * 8
+	scale := floatScale(bitDepth)
+
+	mixU = mixU[:numSamples:numSamples]
+
+	if bytesShifted != 0 {
+		shiftBuf = shiftBuf[:numSamples:numSamples]
+	}
+
+	for idx := range mixU {
+		val := mixU[idx]
+		if bytesShifted != 0 {
+			val = (val << shift) | int32(shiftBuf[idx])
+		}
+
+		dst := out[off : off+8 : off+8]
+		binary.LittleEndian.PutUint64(dst, math.Float64bits(float64(val)*scale))
+
+		off += stride
+	}
+}
@@ -49,3 +49,53 @@ func BenchmarkWriteStereo16(b *testing.B) {
 		}
 	})
 }
+
+// benchmarkStereo24 runs write over one second of 96 kHz 24-bit stereo, in
+// 4096-frame packets, reporting the cost per output sample.
+func benchmarkStereo24(b *testing.B, bytesPerSample int, write func(out []byte, mixU, mixV []int32, numSamples int)) {
+	b.Helper()
+
+	const (
+		rate       = 96000
+		numSamples = 4096
+		numChan    = 2
+	)
+
+	out := make([]byte, numSamples*numChan*bytesPerSample)
+	mixU := make([]int32, numSamples)
+	mixV := make([]int32, numSamples)
+
+	for i := range numSamples {
+		mixU[i] = int32(i*4099) - 1<<23
+		mixV[i] = int32(i * 1021)
+	}
+
+	packets := (rate + numSamples - 1) / numSamples
+
+	b.SetBytes(int64(packets * len(out)))
+	b.ReportAllocs()
+
+	for range b.N {
+		for range packets {
+			write(out, mixU, mixV, numSamples)
+		}
+	}
+
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*packets*numSamples*numChan), "ns/sample")
+}
+
+// BenchmarkWriteStereo24 compares the packed 3-byte output with 24-in-32
+// words: aligned stores, against a third more bytes written.
+func BenchmarkWriteStereo24(b *testing.B) {
+	b.Run("packed", func(b *testing.B) {
+		benchmarkStereo24(b, 3, func(out []byte, mixU, mixV []int32, numSamples int) {
+			alacint.WriteStereo24(out, mixU, mixV, 0, 2, numSamples, 2, 1, nil, 0)
+		})
+	})
+
+	b.Run("in32", func(b *testing.B) {
+		benchmarkStereo24(b, 4, func(out []byte, mixU, mixV []int32, numSamples int) {
+			alacint.WriteStereo24In32(out, mixU, mixV, 0, 2, numSamples, 2, 1, nil, 0)
+		})
+	})
+}
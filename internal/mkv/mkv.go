@@ -0,0 +1,606 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions are bounded by element sizes.
+package mkv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// Packet locates one ALAC packet in the stream.
+type Packet struct {
+	Offset uint64
+	Size   uint32
+}
+
+// Track describes the ALAC track located by FindALACTrack.
+type Track struct {
+	// Number is the TrackNumber that blocks refer to.
+	Number uint64
+	// Cookie is the CodecPrivate element: the ALACSpecificConfig.
+	Cookie []byte
+	// Packets lists the track's frames in stream order.
+	Packets []Packet
+}
+
+// TraceFunc receives container parsing events as an event name followed by
+// alternating key/value pairs (the log/slog convention).
+type TraceFunc func(event string, args ...any)
+
+// EBML element IDs, with their length marker bits (RFC 8794, RFC 9559).
+const (
+	idEBML         = 0x1A45DFA3
+	idSegment      = 0x18538067
+	idSeekHead     = 0x114D9B74
+	idInfo         = 0x1549A966
+	idTracks       = 0x1654AE6B
+	idCues         = 0x1C53BB6B
+	idChapters     = 0x1043A770
+	idTags         = 0x1254C367
+	idAttachments  = 0x1941A469
+	idCluster      = 0x1F43B675
+	idTrackEntry   = 0xAE
+	idTrackNumber  = 0xD7
+	idCodecID      = 0x86
+	idCodecPrivate = 0x63A2
+	idSimpleBlock  = 0xA3
+	idBlockGroup   = 0xA0
+	idBlock        = 0xA1
+)
+
+const (
+	// alacCodecID is the Matroska CodecID of ALAC tracks.
+	alacCodecID = "A_ALAC"
+
+	maxIDLength   = 4
+	maxSizeLength = 8
+
+	// maxCodecPrivate bounds the CodecPrivate read into memory; an ALAC
+	// cookie, with an optional channel layout, is under 100 bytes.
+	maxCodecPrivate = 1 << 16
+
+	// maxLacedBlock bounds a laced block, which is read whole to parse its lace sizes.
+	maxLacedBlock = 16 << 20
+
+	// blockHeaderMax is the largest block header: track number (8), timecode (2), flags (1).
+	blockHeaderMax = maxSizeLength + 3
+)
+
+// Block flag lacing modes (RFC 9559 10.3).
+const (
+	lacingMask  = 0x06
+	lacingNone  = 0x00
+	lacingXiph  = 0x02
+	lacingFixed = 0x04
+	lacingEBML  = 0x06
+)
+
+// element holds the position and size of a parsed EBML element.
+type element struct {
+	id     uint32
+	offset int64 // header start
+	data   int64 // data start
+	end    int64 // data end; for unknown sizes, the end of the parent
+	sized  bool  // the size was declared rather than unknown
+}
+
+// parser walks the element tree of a single Matroska stream.
+type parser struct {
+	reader io.ReadSeeker
+	size   int64 // total stream length
+	trace  TraceFunc
+}
+
+// IsMatroska reports whether header, the first bytes of a stream, starts
+// with an EBML header, as Matroska and WebM files do.
+func IsMatroska(header []byte) bool {
+	return len(header) >= maxIDLength && binary.BigEndian.Uint32(header) == idEBML
+}
+
+// FindALACTrack locates the first track with CodecID A_ALAC and lists the
+// packets of its SimpleBlock and Block frames. Clusters preceding the Tracks
+// element, which Matroska does not allow, are not searched.
+// trace may be nil.
+func FindALACTrack(reader io.ReadSeeker, trace TraceFunc) (Track, error) {
+	end, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Track{}, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	p := &parser{reader: reader, size: end, trace: trace} //nolint:varnamelen // p matches the parser receiver name.
+	root := element{end: end}
+
+	header, err := p.readElement(0, &root)
+	if err != nil || header.id != idEBML {
+		return Track{}, ErrNoALACTrack
+	}
+
+	var (
+		track Track
+		found bool
+	)
+
+	segment, hasSegment, err := p.findChild(&root, header.end, idSegment)
+	if err != nil {
+		return Track{}, err
+	}
+
+	if !hasSegment {
+		return Track{}, ErrNoALACTrack
+	}
+
+	for pos := segment.data; pos < segment.end; {
+		child, err := p.readElement(pos, &segment)
+		if err != nil {
+			return Track{}, err
+		}
+
+		p.traceElement(child)
+
+		switch {
+		case child.id == idTracks && !found:
+			track, found, err = p.readTracks(&child)
+		case child.id == idCluster && found:
+			child.end, err = p.readCluster(&child, &track)
+		case !child.sized:
+			err = fmt.Errorf("%w: unknown size for element 0x%X at offset %d", ErrInvalidElement, child.id, child.offset)
+		}
+
+		if err != nil {
+			return Track{}, err
+		}
+
+		pos = child.end
+	}
+
+	if !found {
+		return Track{}, ErrNoALACTrack
+	}
+
+	if p.trace != nil {
+		p.trace("sample table", "samples", len(track.Packets))
+	}
+
+	return track, nil
+}
+
+// readElement reads the header of the element at offset within parent.
+// An unknown size extends the element to the end of its parent.
+func (p *parser) readElement(offset int64, parent *element) (element, error) {
+	var buf [maxIDLength + maxSizeLength]byte
+
+	avail := min(int64(len(buf)), parent.end-offset)
+	if avail <= 0 {
+		return element{}, fmt.Errorf("%w: no element header at offset %d", ErrInvalidElement, offset)
+	}
+
+	if _, err := p.reader.Seek(offset, io.SeekStart); err != nil {
+		return element{}, fmt.Errorf("seeking to element: %w", err)
+	}
+
+	if _, err := io.ReadFull(p.reader, buf[:avail]); err != nil {
+		return element{}, fmt.Errorf("reading element header: %w", err)
+	}
+
+	idLen := vintLength(buf[0])
+	if idLen == 0 || idLen > maxIDLength || int64(idLen) >= avail {
+		return element{}, fmt.Errorf("%w: bad ID at offset %d", ErrInvalidElement, offset)
+	}
+
+	var id uint32
+	for _, b := range buf[:idLen] {
+		id = id<<8 | uint32(b)
+	}
+
+	size, sizeLen, known := readVint(buf[idLen:avail])
+	if sizeLen == 0 {
+		return element{}, fmt.Errorf("%w: bad size of element 0x%X at offset %d", ErrInvalidElement, id, offset)
+	}
+
+	el := element{id: id, offset: offset, data: offset + int64(idLen+sizeLen), end: parent.end, sized: known}
+
+	if known {
+		if size > uint64(parent.end-el.data) {
+			return element{}, fmt.Errorf("%w: element 0x%X at offset %d, size %d, overruns its parent",
+				ErrInvalidElement, id, offset, size)
+		}
+
+		el.end = el.data + int64(size)
+	}
+
+	return el, nil
+}
+
+// findChild returns the first element with the given ID among the children
+// of parent starting at offset.
+func (p *parser) findChild(parent *element, offset int64, id uint32) (element, bool, error) {
+	for pos := offset; pos < parent.end; {
+		child, err := p.readElement(pos, parent)
+		if err != nil {
+			return element{}, false, err
+		}
+
+		p.traceElement(child)
+
+		if child.id == id {
+			return child, true, nil
+		}
+
+		if !child.sized {
+			return element{}, false, fmt.Errorf("%w: unknown size for element 0x%X", ErrInvalidElement, child.id)
+		}
+
+		pos = child.end
+	}
+
+	return element{}, false, nil
+}
+
+// readTracks returns the first ALAC track entry of a Tracks element.
+func (p *parser) readTracks(tracks *element) (Track, bool, error) {
+	for pos := tracks.data; pos < tracks.end; {
+		entry, err := p.readElement(pos, tracks)
+		if err != nil {
+			return Track{}, false, err
+		}
+
+		pos = entry.end
+
+		if entry.id != idTrackEntry {
+			continue
+		}
+
+		track, isALAC, err := p.readTrackEntry(&entry)
+		if err != nil {
+			return Track{}, false, err
+		}
+
+		if isALAC {
+			if p.trace != nil {
+				p.trace("track selected", "offset", entry.offset, "number", track.Number, "cookie_length", len(track.Cookie))
+			}
+
+			return track, true, nil
+		}
+	}
+
+	return Track{}, false, nil
+}
+
+// readTrackEntry reads the number and cookie of a track entry, and reports
+// whether it is an ALAC track with a cookie.
+func (p *parser) readTrackEntry(entry *element) (Track, bool, error) {
+	var (
+		track   Track
+		codecID string
+	)
+
+	for pos := entry.data; pos < entry.end; {
+		child, err := p.readElement(pos, entry)
+		if err != nil {
+			return Track{}, false, err
+		}
+
+		pos = child.end
+
+		switch child.id {
+		case idTrackNumber:
+			data, err := p.readData(&child, maxSizeLength)
+			if err != nil {
+				return Track{}, false, err
+			}
+
+			for _, b := range data {
+				track.Number = track.Number<<8 | uint64(b)
+			}
+		case idCodecID:
+			data, err := p.readData(&child, len(alacCodecID)+1)
+			if err != nil && !errors.Is(err, ErrElementSize) {
+				return Track{}, false, err
+			}
+
+			// Strings may be zero-padded.
+			for len(data) > 0 && data[len(data)-1] == 0 {
+				data = data[:len(data)-1]
+			}
+
+			codecID = string(data)
+		case idCodecPrivate:
+			if track.Cookie, err = p.readData(&child, maxCodecPrivate); err != nil {
+				return Track{}, false, err
+			}
+		default:
+		}
+	}
+
+	isALAC := codecID == alacCodecID && track.Number != 0 && len(track.Cookie) > 0
+	if !isALAC && p.trace != nil && codecID == alacCodecID {
+		p.trace("track skipped", "offset", entry.offset, "reason", "no track number or CodecPrivate")
+	}
+
+	return track, isALAC, nil
+}
+
+// readData reads the data of an element of at most limit bytes.
+// Longer elements return ErrElementSize and no data.
+func (p *parser) readData(el *element, limit int) ([]byte, error) {
+	size := el.end - el.data
+	if size > int64(limit) {
+		return nil, fmt.Errorf("%w: element 0x%X holds %d bytes", ErrElementSize, el.id, size)
+	}
+
+	if _, err := p.reader.Seek(el.data, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to element data: %w", err)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(p.reader, data); err != nil {
+		return nil, fmt.Errorf("reading element 0x%X: %w", el.id, err)
+	}
+
+	return data, nil
+}
+
+// readCluster appends the packets of track found in a cluster's blocks, and
+// returns the end of the cluster. A cluster of unknown size ends at the
+// next top-level element.
+func (p *parser) readCluster(cluster *element, track *Track) (int64, error) {
+	pos := cluster.data
+
+	for pos < cluster.end {
+		child, err := p.readElement(pos, cluster)
+		if err != nil {
+			return 0, err
+		}
+
+		if !cluster.sized && isTopLevel(child.id) {
+			return pos, nil
+		}
+
+		if !child.sized {
+			return 0, fmt.Errorf("%w: unknown size for element 0x%X at offset %d", ErrInvalidElement, child.id, child.offset)
+		}
+
+		switch child.id {
+		case idSimpleBlock:
+			err = p.readBlock(&child, track)
+		case idBlockGroup:
+			block, found, findErr := p.findChild(&child, child.data, idBlock)
+			if findErr == nil && found {
+				findErr = p.readBlock(&block, track)
+			}
+
+			err = findErr
+		default:
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		pos = child.end
+	}
+
+	return cluster.end, nil
+}
+
+// isTopLevel reports whether id is a Segment child, which ends a cluster of unknown size.
+func isTopLevel(id uint32) bool {
+	switch id {
+	case idCluster, idSeekHead, idInfo, idTracks, idCues, idChapters, idTags, idAttachments:
+		return true
+	default:
+		return false
+	}
+}
+
+// readBlock appends the frames of a SimpleBlock or Block when it belongs to track.
+// Layout: trackNumber(vint) + timecode(2) + flags(1) + [lacing] + frames.
+func (p *parser) readBlock(block *element, track *Track) error {
+	size := block.end - block.data
+
+	var buf [blockHeaderMax]byte
+
+	if _, err := p.reader.Seek(block.data, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking to block: %w", err)
+	}
+
+	header := buf[:min(size, int64(len(buf)))]
+	if _, err := io.ReadFull(p.reader, header); err != nil {
+		return fmt.Errorf("reading block header: %w", err)
+	}
+
+	number, numberLen, _ := readVint(header)
+	if numberLen == 0 || len(header) < numberLen+3 {
+		return fmt.Errorf("%w: header at offset %d", ErrInvalidBlock, block.offset)
+	}
+
+	if number != track.Number {
+		return nil
+	}
+
+	headerLen := int64(numberLen + 3)
+	flags := header[numberLen+2]
+
+	if flags&lacingMask == lacingNone {
+		return appendPacket(track, block.data+headerLen, size-headerLen)
+	}
+
+	if size > maxLacedBlock {
+		return fmt.Errorf("%w: %d-byte laced block at offset %d", ErrInvalidBlock, size, block.offset)
+	}
+
+	data := make([]byte, size-headerLen)
+
+	if _, err := p.reader.Seek(block.data+headerLen, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking to block data: %w", err)
+	}
+
+	if _, err := io.ReadFull(p.reader, data); err != nil {
+		return fmt.Errorf("reading block: %w", err)
+	}
+
+	sizes, lacingLen, err := laceSizes(data, flags&lacingMask)
+	if err != nil {
+		return fmt.Errorf("%w: block at offset %d: %w", ErrInvalidBlock, block.offset, err)
+	}
+
+	offset := block.data + headerLen + int64(lacingLen)
+
+	for _, frameSize := range sizes {
+		if err := appendPacket(track, offset, frameSize); err != nil {
+			return err
+		}
+
+		offset += frameSize
+	}
+
+	return nil
+}
+
+// appendPacket adds a frame of size bytes at offset to the track's packets.
+func appendPacket(track *Track, offset, size int64) error {
+	if size < 0 || size > math.MaxUint32 {
+		return fmt.Errorf("%w: frame of %d bytes at offset %d", ErrInvalidBlock, size, offset)
+	}
+
+	track.Packets = append(track.Packets, Packet{Offset: uint64(offset), Size: uint32(size)})
+
+	return nil
+}
+
+// laceSizes returns the frame sizes of a laced block from its data following
+// the block header, and the length of the lacing header.
+func laceSizes(data []byte, lacing byte) ([]int64, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errors.New("missing lace count")
+	}
+
+	count := int(data[0]) + 1
+	pos := 1
+	sizes := make([]int64, count)
+	known := int64(0) // total size of the frames with explicit sizes
+
+	switch lacing {
+	case lacingXiph:
+		for idx := range count - 1 {
+			for {
+				if pos >= len(data) {
+					return nil, 0, errors.New("truncated Xiph lace sizes")
+				}
+
+				b := data[pos]
+				pos++
+				sizes[idx] += int64(b)
+
+				if b != math.MaxUint8 {
+					break
+				}
+			}
+
+			known += sizes[idx]
+		}
+	case lacingEBML:
+		for idx := range count - 1 {
+			value, length, ok := readVint(data[pos:])
+			if length == 0 || !ok {
+				return nil, 0, errors.New("bad EBML lace size")
+			}
+
+			pos += length
+
+			if idx == 0 {
+				sizes[idx] = int64(value & math.MaxInt64)
+			} else {
+				// Later sizes are signed differences, biased by half the vint range.
+				bias := int64(1)<<(7*length-1) - 1
+				sizes[idx] = sizes[idx-1] + int64(value) - bias
+			}
+
+			if sizes[idx] < 0 || sizes[idx] > int64(len(data)) {
+				return nil, 0, fmt.Errorf("EBML lace size %d out of range", sizes[idx])
+			}
+
+			known += sizes[idx]
+		}
+	case lacingFixed:
+		if (len(data)-pos)%count != 0 {
+			return nil, 0, fmt.Errorf("%d bytes do not split into %d equal frames", len(data)-pos, count)
+		}
+
+		for idx := range sizes {
+			sizes[idx] = int64((len(data) - pos) / count)
+		}
+
+		return sizes, pos, nil
+	default:
+	}
+
+	last := int64(len(data)-pos) - known
+	if last < 0 {
+		return nil, 0, fmt.Errorf("lace sizes total %d bytes, %d available", known, len(data)-pos)
+	}
+
+	sizes[count-1] = last
+
+	return sizes, pos, nil
+}
+
+// vintLength returns the length of a variable-length integer from its first
+// byte, or 0 if the byte has no length marker.
+func vintLength(first byte) int {
+	if first == 0 {
+		return 0
+	}
+
+	return bits.LeadingZeros8(first) + 1
+}
+
+// readVint decodes a variable-length integer with its length marker removed.
+// It returns the value, its length (0 if malformed), and false when every
+// value bit is set, which marks an unknown element size.
+func readVint(buf []byte) (uint64, int, bool) {
+	if len(buf) == 0 {
+		return 0, 0, false
+	}
+
+	length := vintLength(buf[0])
+	if length == 0 || length > len(buf) {
+		return 0, 0, false
+	}
+
+	value := uint64(buf[0]) & (0xFF >> length)
+	for _, b := range buf[1:length] {
+		value = value<<8 | uint64(b)
+	}
+
+	allOnes := uint64(1)<<(7*length) - 1
+
+	return value, length, value != allOnes
+}
+
+// traceElement reports an element visited.
+func (p *parser) traceElement(el element) {
+	if p.trace != nil {
+		p.trace("element", "id", fmt.Sprintf("0x%X", el.id), "offset", el.offset, "size", el.end-el.data)
+	}
+}
@@ -0,0 +1,345 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions are bounded by MP4 atom sizes.
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// TrackExtends holds the fragment defaults a trex box declares for a track.
+type TrackExtends struct {
+	SampleDuration uint32
+	SampleSize     uint32
+}
+
+// tfhd flags (ISO 14496-12 8.8.7).
+const (
+	tfhdBaseDataOffset         = 0x000001
+	tfhdSampleDescriptionIndex = 0x000002
+	tfhdDefaultSampleDuration  = 0x000008
+	tfhdDefaultSampleSize      = 0x000010
+	tfhdDefaultSampleFlags     = 0x000020
+)
+
+// trun flags (ISO 14496-12 8.8.8).
+const (
+	trunDataOffset            = 0x000001
+	trunFirstSampleFlags      = 0x000004
+	trunSampleDuration        = 0x000100
+	trunSampleSize            = 0x000200
+	trunSampleFlags           = 0x000400
+	trunSampleCompositionTime = 0x000800
+)
+
+// trackFragment holds the tfhd fields that locate a track fragment's samples.
+type trackFragment struct {
+	trackID     uint32
+	baseOffset  bool // explicit base_data_offset, an absolute file position
+	defaultSize uint32
+}
+
+// readTrex reads the trex boxes of an mvex box, keyed by track ID.
+// Layout: trackID(4) + sampleDescriptionIndex(4) + duration(4) + size(4) + flags(4).
+func (p *parser) readTrex(mvex *boxInfo) (map[uint32]TrackExtends, error) {
+	fccTrex := [4]byte{'t', 'r', 'e', 'x'}
+	extends := map[uint32]TrackExtends{}
+
+	err := p.iterChildren(mvex, func(child boxInfo) (bool, error) {
+		if child.fourCC != fccTrex {
+			return false, nil
+		}
+
+		_, payload, err := p.readFullBoxPayload(&child, ErrInvalidFragment)
+		if err != nil {
+			return false, err
+		}
+
+		if len(payload) < 16 {
+			return false, fmt.Errorf("%w: trex too short", ErrInvalidFragment)
+		}
+
+		extends[binary.BigEndian.Uint32(payload)] = TrackExtends{
+			SampleDuration: binary.BigEndian.Uint32(payload[8:]),
+			SampleSize:     binary.BigEndian.Uint32(payload[12:]),
+		}
+
+		return false, nil
+	})
+
+	return extends, err
+}
+
+// ParseFragment locates the samples of track trackID in data, one or more
+// moof boxes followed by the mdat boxes they describe, as delivered by a live
+// fragmented MP4 stream. Sample offsets are relative to the start of data;
+// FirstFrame is not set. Sample sizes missing from trun and tfhd fall back to
+// extends. Track fragments addressed by an absolute base_data_offset cannot
+// be located in a standalone fragment and are rejected.
+func ParseFragment(data []byte, trackID uint32, extends TrackExtends) ([]SampleInfo, error) {
+	p := &parser{reader: bytes.NewReader(data), size: int64(len(data))} //nolint:varnamelen // p matches the parser receiver name.
+	root := boxInfo{size: int64(len(data))}
+	fccMoof := [4]byte{'m', 'o', 'o', 'f'}
+
+	var samples []SampleInfo
+
+	err := p.iterChildren(&root, func(moof boxInfo) (bool, error) {
+		if moof.fourCC != fccMoof {
+			return false, nil
+		}
+
+		moofSamples, err := p.readMoof(&moof, trackID, extends)
+		samples = append(samples, moofSamples...)
+
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, sample := range samples {
+		if sample.Offset+uint64(sample.Size) > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: sample %d at offset %d, size %d, exceeds the %d-byte fragment",
+				ErrInvalidFragment, idx, sample.Offset, sample.Size, len(data))
+		}
+	}
+
+	return samples, nil
+}
+
+// FindFragmentSamples locates the samples of track trackID in the moof boxes
+// at the top level of reader, a whole fragmented MP4 stream, in stream order.
+// Sample offsets are absolute; FirstFrame is not set. Scanning stops at the
+// first box that runs past the end of the stream, and samples whose data does
+// not fit in the stream are left out, so that a fragment still being written
+// is skipped rather than rejected.
+func FindFragmentSamples(reader io.ReadSeeker, trackID uint32, extends TrackExtends) ([]SampleInfo, error) {
+	fileEnd, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	p := &parser{reader: reader, size: fileEnd} //nolint:varnamelen // p matches the parser receiver name.
+	root := boxInfo{size: fileEnd}
+	fccMoof := [4]byte{'m', 'o', 'o', 'f'}
+
+	var samples []SampleInfo
+
+	err = p.iterChildren(&root, func(box boxInfo) (bool, error) {
+		if box.offset+box.size > fileEnd {
+			return true, nil
+		}
+
+		if box.fourCC != fccMoof {
+			return false, nil
+		}
+
+		moofSamples, err := p.readMoof(&box, trackID, extends)
+		samples = append(samples, moofSamples...)
+
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, sample := range samples {
+		if sample.Offset+uint64(sample.Size) > uint64(fileEnd) {
+			return samples[:idx], nil
+		}
+	}
+
+	return samples, nil
+}
+
+// readMoof collects the samples of track trackID from the track fragments of one moof.
+func (p *parser) readMoof(moof *boxInfo, trackID uint32, extends TrackExtends) ([]SampleInfo, error) {
+	fccTraf := [4]byte{'t', 'r', 'a', 'f'}
+	fccTfhd := [4]byte{'t', 'f', 'h', 'd'}
+	fccTrun := [4]byte{'t', 'r', 'u', 'n'}
+
+	var samples []SampleInfo
+
+	// Track fragment data is taken to be addressed from the moof
+	// (default-base-is-moof), as CMAF requires. Without that flag this only
+	// holds for the first track fragment, which is the ALAC one in the
+	// single-track fragments live streams use.
+	base := uint64(moof.offset)
+
+	err := p.iterChildren(moof, func(traf boxInfo) (bool, error) {
+		if traf.fourCC != fccTraf {
+			return false, nil
+		}
+
+		tfhd, found, err := p.findChild(&traf, fccTfhd)
+		if err != nil || !found {
+			return false, fmt.Errorf("%w: traf without tfhd", ErrInvalidFragment)
+		}
+
+		header, err := p.readTfhd(&tfhd)
+		if err != nil || header.trackID != trackID {
+			return false, err
+		}
+
+		if header.baseOffset {
+			return false, fmt.Errorf("%w: absolute base_data_offset", ErrInvalidFragment)
+		}
+
+		defaultSize := extends.SampleSize
+		if header.defaultSize != 0 {
+			defaultSize = header.defaultSize
+		}
+
+		pos := base
+
+		return false, p.iterChildren(&traf, func(trun boxInfo) (bool, error) {
+			if trun.fourCC != fccTrun {
+				return false, nil
+			}
+
+			var trunErr error
+
+			samples, pos, trunErr = p.readTrun(&trun, samples, base, pos, defaultSize)
+
+			return false, trunErr
+		})
+	})
+
+	return samples, err
+}
+
+// readTfhd reads a track fragment header.
+// Layout: trackID(4) + optional fields selected by the flags, in flag order.
+func (p *parser) readTfhd(box *boxInfo) (trackFragment, error) {
+	_, flags, payload, err := p.readFullBox(box, ErrInvalidFragment)
+	if err != nil {
+		return trackFragment{}, err
+	}
+
+	if len(payload) < 4 {
+		return trackFragment{}, fmt.Errorf("%w: tfhd too short", ErrInvalidFragment)
+	}
+
+	header := trackFragment{
+		trackID:    binary.BigEndian.Uint32(payload),
+		baseOffset: flags&tfhdBaseDataOffset != 0,
+	}
+
+	off := 4
+
+	for _, field := range []struct {
+		flag  uint32
+		bytes int
+	}{
+		{tfhdBaseDataOffset, 8},
+		{tfhdSampleDescriptionIndex, 4},
+		{tfhdDefaultSampleDuration, 4},
+		{tfhdDefaultSampleSize, 4},
+		{tfhdDefaultSampleFlags, 4},
+	} {
+		if flags&field.flag == 0 {
+			continue
+		}
+
+		if len(payload) < off+field.bytes {
+			return trackFragment{}, fmt.Errorf("%w: tfhd too short for flags 0x%06x", ErrInvalidFragment, flags)
+		}
+
+		if field.flag == tfhdDefaultSampleSize {
+			header.defaultSize = binary.BigEndian.Uint32(payload[off:])
+		}
+
+		off += field.bytes
+	}
+
+	return header, nil
+}
+
+// readTrun appends the samples of a track run and returns the position
+// following its data, where a next run without data_offset continues.
+// Layout: sampleCount(4) + dataOffset(4)? + firstSampleFlags(4)? + per-sample
+// duration, size, flags and composition offset, each present per the flags.
+func (p *parser) readTrun(
+	box *boxInfo, samples []SampleInfo, base, pos uint64, defaultSize uint32,
+) ([]SampleInfo, uint64, error) {
+	_, flags, payload, err := p.readFullBox(box, ErrInvalidFragment)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(payload) < 4 {
+		return nil, 0, fmt.Errorf("%w: trun too short", ErrInvalidFragment)
+	}
+
+	count := binary.BigEndian.Uint32(payload)
+	off := 4
+
+	if flags&trunDataOffset != 0 {
+		if len(payload) < off+4 {
+			return nil, 0, fmt.Errorf("%w: trun too short", ErrInvalidFragment)
+		}
+
+		pos = base + uint64(int64(int32(binary.BigEndian.Uint32(payload[off:]))))
+		off += 4
+	}
+
+	if flags&trunFirstSampleFlags != 0 {
+		off += 4
+	}
+
+	entryBytes, sizeAt := 0, -1
+
+	for _, field := range []uint32{trunSampleDuration, trunSampleSize, trunSampleFlags, trunSampleCompositionTime} {
+		if flags&field == 0 {
+			continue
+		}
+
+		if field == trunSampleSize {
+			sizeAt = entryBytes
+		}
+
+		entryBytes += 4
+	}
+
+	if off > len(payload) || (entryBytes > 0 && uint64(count) > uint64((len(payload)-off)/entryBytes)) {
+		return nil, 0, fmt.Errorf("%w: %d trun entries overflow the box", ErrInvalidFragment, count)
+	}
+
+	if sizeAt < 0 && defaultSize == 0 {
+		return nil, 0, fmt.Errorf("%w: no sample size in trun, tfhd or trex", ErrInvalidFragment)
+	}
+
+	// Without per-sample entries, the count is only bounded by the data it describes.
+	if entryBytes == 0 && uint64(count) > uint64(p.size) {
+		return nil, 0, fmt.Errorf("%w: %d samples in a %d-byte fragment", ErrInvalidFragment, count, p.size)
+	}
+
+	for idx := range int(count) {
+		size := defaultSize
+		if sizeAt >= 0 {
+			size = binary.BigEndian.Uint32(payload[off+idx*entryBytes+sizeAt:])
+		}
+
+		samples = append(samples, SampleInfo{Offset: pos, Size: size})
+		pos += uint64(size)
+	}
+
+	return samples, pos, nil
+}
@@ -22,19 +22,93 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 )
 
 // SampleInfo holds the byte offset and size of a single encoded ALAC packet
-// within the MP4 container.
+// within the MP4 container, and the media time at which it starts.
 type SampleInfo struct {
 	Offset uint64
 	Size   uint32
+	// FirstFrame is the cumulative duration of all earlier packets. FindALACTrack
+	// fills it from stts in media timescale units when Track.HasTimeToSample is set.
+	FirstFrame uint64
+
+	// tableIndex is the sample's 0-based position in stsz and stts, which
+	// differs from its position in Track.Samples when chunks of other sample
+	// descriptions are left out.
+	tableIndex uint32
+}
+
+// Track describes the ALAC track located by FindALACTrack.
+type Track struct {
+	// ID is the tkhd track_ID (0 if unreadable).
+	ID uint32
+	// Cookie is the raw magic cookie, possibly still wrapped in frma/alac atoms.
+	Cookie []byte
+	// Samples is the flat sample table, in decode order.
+	Samples []SampleInfo
+	// HasEditList reports whether the track carries an edit list (edts/elst),
+	// which is where encoders record gapless priming and padding.
+	HasEditList bool
+	// Edits is the track's edit list, empty without one.
+	Edits []Edit
+	// MovieTimescale is the mvhd timescale, the unit of Edit.SegmentDuration (0 if absent).
+	MovieTimescale uint32
+	// MovieDuration is the mvhd duration in MovieTimescale units (0 if absent or unknown).
+	MovieDuration uint64
+	// MediaTimescale is the mdhd timescale, the unit of media time values (0 if absent).
+	MediaTimescale uint32
+	// Fragmented reports whether the movie declares fragments (moov/mvex).
+	Fragmented bool
+	// Extends holds the track's fragment defaults from mvex/trex, zero when absent.
+	Extends TrackExtends
+	// HasTimeToSample reports whether Samples[i].FirstFrame was read from stts.
+	HasTimeToSample bool
+	// TimeToSampleEnd is the end of the last sample from stts, in
+	// MediaTimescale units, when HasTimeToSample is set.
+	TimeToSampleEnd uint64
+	// BitRate is the sample entry's btrt box, zero when absent.
+	BitRate BitRate
+	// ChannelLayout is the Core Audio channel layout of the chan box, in the
+	// sample entry or following the ALAC config, zero when absent.
+	ChannelLayout ChannelLayout
+	// PrerollSamples lists the samples that roll or pre-roll sample groups
+	// (sbgp/sgpd) mark as needing earlier samples decoded first, sorted and
+	// non-overlapping. It is empty for ALAC, whose packets are all independent.
+	PrerollSamples []SampleRange
+}
+
+// Core Audio layout tags selecting the other representations of a chan box.
+const (
+	ChannelLayoutUseDescriptions = 0       // kAudioChannelLayoutTag_UseChannelDescriptions
+	ChannelLayoutUseBitmap       = 1 << 16 // kAudioChannelLayoutTag_UseChannelBitmap
+)
+
+// ChannelLayout mirrors the Core Audio AudioChannelLayout of a chan box. Tag
+// is a predefined layout, or selects Bitmap (ChannelLayoutUseBitmap) or the
+// channel description labels (ChannelLayoutUseDescriptions) instead.
+type ChannelLayout struct {
+	Tag    uint32
+	Bitmap uint32
+	// Labels holds the AudioChannelLabel of each channel description, in
+	// channel order. Flags and coordinates are not kept.
+	Labels []uint32
+}
+
+// BitRate mirrors the ISO 14496-12 BitRateBox (btrt), in bits per second
+// except for the decoding buffer size, in bytes.
+type BitRate struct {
+	BufferSizeDB uint32
+	Max          uint32
+	Avg          uint32
 }
 
 // stscEntry mirrors the ISO 14496-12 sample-to-chunk table entry.
 type stscEntry struct {
-	FirstChunk      uint32
-	SamplesPerChunk uint32
+	FirstChunk             uint32
+	SamplesPerChunk        uint32
+	SampleDescriptionIndex uint32
 }
 
 // boxInfo holds the position and size of a parsed box.
@@ -55,9 +129,22 @@ const (
 	fullBoxSize     = 4 // version(1) + flags(3)
 )
 
+// TraceFunc receives container parsing events as an event name followed by
+// alternating key/value pairs (the log/slog convention).
+type TraceFunc func(event string, args ...any)
+
+// parser walks the box tree of a single MP4 stream.
+type parser struct {
+	reader io.ReadSeeker
+	size   int64 // total stream length
+	trace  TraceFunc
+}
+
 // readBoxInfo reads a single box header from the current position.
 // Returns io.EOF if there are no more bytes to read.
-func readBoxInfo(reader io.ReadSeeker) (boxInfo, error) {
+func (p *parser) readBoxInfo() (boxInfo, error) {
+	reader := p.reader
+
 	offset, err := reader.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return boxInfo{}, fmt.Errorf("seeking current position: %w", err)
@@ -98,7 +185,16 @@ func readBoxInfo(reader io.ReadSeeker) (boxInfo, error) {
 		}
 
 		info.headerSize = largeHeaderSize
-		info.size = int64(binary.BigEndian.Uint64(header[smallHeaderSize:largeHeaderSize]))
+
+		// Reject sizes that would wrap int64 or reach past the end of the stream,
+		// so that no downstream offset arithmetic can overflow.
+		extended := binary.BigEndian.Uint64(header[smallHeaderSize:largeHeaderSize])
+		if extended > math.MaxInt64 || int64(extended) > p.size-offset {
+			return boxInfo{}, fmt.Errorf("%w: extended size %d at offset %d exceeds stream length %d",
+				ErrInvalidBoxSize, extended, offset, p.size)
+		}
+
+		info.size = int64(extended)
 
 	default:
 		info.size = int64(rawSize)
@@ -143,11 +239,9 @@ func (info *boxInfo) payloadSize() int64 {
 
 // iterChildren calls callback for each direct child box within parent's payload.
 // callback returns true to stop iteration early.
-func iterChildren(
-	reader io.ReadSeeker,
-	parent *boxInfo,
-	callback func(child boxInfo) (stop bool, err error),
-) error {
+func (p *parser) iterChildren(parent *boxInfo, callback func(child boxInfo) (stop bool, err error)) error {
+	reader := p.reader
+
 	if err := parent.seekToPayload(reader); err != nil {
 		return err
 	}
@@ -164,7 +258,7 @@ func iterChildren(
 			return nil
 		}
 
-		child, err := readBoxInfo(reader)
+		child, err := p.readBoxInfo()
 		if err != nil {
 			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
 				return nil
@@ -173,6 +267,10 @@ func iterChildren(
 			return err
 		}
 
+		if p.trace != nil {
+			p.trace("box", "type", string(child.fourCC[:]), "offset", child.offset, "size", child.size)
+		}
+
 		stop, err := callback(child)
 		if err != nil {
 			return err
@@ -189,12 +287,12 @@ func iterChildren(
 }
 
 // findChild finds the first child box with the given fourCC inside parent.
-func findChild(reader io.ReadSeeker, parent *boxInfo, target [4]byte) (boxInfo, bool, error) {
+func (p *parser) findChild(parent *boxInfo, target [4]byte) (boxInfo, bool, error) {
 	var found boxInfo
 
 	var matched bool
 
-	err := iterChildren(reader, parent, func(child boxInfo) (bool, error) {
+	err := p.iterChildren(parent, func(child boxInfo) (bool, error) {
 		if child.fourCC == target {
 			found = child
 			matched = true
@@ -209,11 +307,11 @@ func findChild(reader io.ReadSeeker, parent *boxInfo, target [4]byte) (boxInfo,
 }
 
 // findDescendant walks a path of fourCCs from parent, descending one level per element.
-func findDescendant(reader io.ReadSeeker, parent *boxInfo, path [][4]byte) (boxInfo, bool, error) {
+func (p *parser) findDescendant(parent *boxInfo, path [][4]byte) (boxInfo, bool, error) {
 	current := *parent
 
 	for _, target := range path {
-		child, found, err := findChild(reader, &current, target)
+		child, found, err := p.findChild(&current, target)
 		if err != nil {
 			return boxInfo{}, false, err
 		}
@@ -229,114 +327,364 @@ func findDescendant(reader io.ReadSeeker, parent *boxInfo, path [][4]byte) (boxI
 }
 
 // FindALACTrack walks the MP4 box tree to locate the first track containing
-// an ALAC sample entry. It returns the magic cookie and a flat sample table.
-func FindALACTrack(reader io.ReadSeeker) ([]byte, []SampleInfo, error) {
-	if _, err := reader.Seek(0, io.SeekStart); err != nil {
-		return nil, nil, fmt.Errorf("seeking to start: %w", err)
+// an ALAC sample entry. It returns the magic cookie and a flat sample table,
+// along with the track properties callers report as stream capabilities.
+// trace may be nil.
+func FindALACTrack(reader io.ReadSeeker, trace TraceFunc) (Track, error) {
+	tracks, err := findALACTracks(reader, trace, true)
+	if err != nil {
+		return Track{}, err
 	}
 
-	// Find the moov box.
-	fileEnd, err := reader.Seek(0, io.SeekEnd)
+	return tracks[0], nil
+}
+
+// FindALACTracks is FindALACTrack for every ALAC track of the movie, in file order.
+func FindALACTracks(reader io.ReadSeeker, trace TraceFunc) ([]Track, error) {
+	return findALACTracks(reader, trace, false)
+}
+
+// findALACTracks parses the ALAC tracks of the movie, stopping after the
+// first one when firstOnly is set. It returns ErrNoALACTrack if there are none.
+func findALACTracks(reader io.ReadSeeker, trace TraceFunc, firstOnly bool) ([]Track, error) {
+	p := &parser{reader: reader, trace: trace} //nolint:varnamelen // p matches the parser receiver name.
+
+	moov, err := p.findMoov()
 	if err != nil {
-		return nil, nil, fmt.Errorf("seeking to end: %w", err)
+		return nil, err
 	}
 
-	root := boxInfo{offset: 0, size: fileEnd, headerSize: 0}
-	fccMoov := [4]byte{'m', 'o', 'o', 'v'}
+	// Iterate trak boxes within moov, descend to stbl in each.
+	var (
+		tracks         []Track
+		fragmented     bool
+		movieTimescale uint32
+		movieDuration  uint64
+		extends        map[uint32]TrackExtends
+		damaged        error // first non-ALAC-looking track too damaged to read
+	)
+
+	fccTrak := [4]byte{'t', 'r', 'a', 'k'}
+	fccMvex := [4]byte{'m', 'v', 'e', 'x'}
+	fccMvhd := [4]byte{'m', 'v', 'h', 'd'}
+
+	err = p.iterChildren(&moov, func(child boxInfo) (bool, error) {
+		switch child.fourCC {
+		case fccMvex:
+			fragmented = true
+
+			// Fragment defaults only matter to ParseFragment: a damaged trex must not prevent decoding.
+			var trexErr error
+			if extends, trexErr = p.readTrex(&child); trexErr != nil && p.trace != nil {
+				p.trace("box ignored", "type", "trex", "reason", trexErr)
+			}
 
-	moov, found, err := findChild(reader, &root, fccMoov)
+			return false, nil
+		case fccMvhd:
+			// Timing metadata is advisory: a damaged header must not prevent decoding.
+			timescale, duration, mvhdErr := p.readHeaderTiming(&child)
+			if mvhdErr != nil && p.trace != nil {
+				p.trace("box ignored", "type", "mvhd", "reason", mvhdErr)
+			}
+
+			movieTimescale, movieDuration = timescale, duration
+
+			return false, nil
+		default:
+		}
+
+		if child.fourCC != fccTrak || (firstOnly && len(tracks) > 0) {
+			return false, nil
+		}
+
+		track, isALAC, trakErr := p.readTrak(&child)
+		if trakErr != nil && !isALAC && errors.Is(trakErr, ErrInvalidBoxSize) {
+			// A malformed video or timecode track must not hide the audio
+			// track after it: only report it if no ALAC track is found.
+			if damaged == nil {
+				damaged = trakErr
+			}
+
+			return false, nil
+		}
+
+		if trakErr != nil || !isALAC {
+			return false, trakErr
+		}
+
+		tracks = append(tracks, track)
+
+		// Keep scanning moov: an mvex box may follow the track.
+		return false, nil
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("reading container structure: %w", err)
+		return nil, err
 	}
 
-	if !found {
-		return nil, nil, ErrNoALACTrack
+	if len(tracks) == 0 && damaged != nil {
+		return nil, damaged
 	}
 
-	// Iterate trak boxes within moov, descend to stbl in each.
-	var cookie []byte
+	if len(tracks) == 0 {
+		return nil, ErrNoALACTrack
+	}
+
+	for idx := range tracks {
+		tracks[idx].Fragmented = fragmented
+		tracks[idx].MovieTimescale = movieTimescale
+		tracks[idx].MovieDuration = movieDuration
+		tracks[idx].Extends = extends[tracks[idx].ID]
+	}
+
+	return tracks, nil
+}
+
+// FindALACCookie returns the magic cookie of the first ALAC track without
+// building its sample table, for callers that only need the stream format.
+// trace may be nil.
+func FindALACCookie(reader io.ReadSeeker, trace TraceFunc) ([]byte, error) {
+	p := &parser{reader: reader, trace: trace} //nolint:varnamelen // p matches the parser receiver name.
+
+	moov, err := p.findMoov()
+	if err != nil {
+		return nil, err
+	}
 
-	var samples []SampleInfo
+	var (
+		cookie  []byte
+		damaged error // first track too damaged to reach its stbl
+	)
 
 	fccTrak := [4]byte{'t', 'r', 'a', 'k'}
-	fccMdia := [4]byte{'m', 'd', 'i', 'a'}
-	fccMinf := [4]byte{'m', 'i', 'n', 'f'}
-	fccStbl := [4]byte{'s', 't', 'b', 'l'}
+	stblPath := [][4]byte{{'m', 'd', 'i', 'a'}, {'m', 'i', 'n', 'f'}, {'s', 't', 'b', 'l'}}
+
+	err = p.iterChildren(&moov, func(child boxInfo) (bool, error) {
+		if child.fourCC != fccTrak {
+			return false, nil
+		}
+
+		stbl, found, findErr := p.findDescendant(&child, stblPath)
+		if errors.Is(findErr, ErrInvalidBoxSize) {
+			if damaged == nil {
+				damaged = findErr
+			}
 
-	err = iterChildren(reader, &moov, func(trak boxInfo) (bool, error) {
-		if trak.fourCC != fccTrak {
 			return false, nil
 		}
 
-		stbl, stblFound, findErr := findDescendant(reader, &trak, [][4]byte{fccMdia, fccMinf, fccStbl})
-		if findErr != nil || !stblFound {
+		if findErr != nil || !found {
 			return false, findErr
 		}
 
-		trackCookie, cookieErr := extractCookie(reader, &stbl)
+		trackCookie, _, _, cookieErr := p.extractCookie(&stbl)
 		if cookieErr != nil {
 			return false, nil //nolint:nilerr // cookieErr means "not an ALAC track"; continue to next trak
 		}
 
-		trackSamples, tableErr := buildSampleTable(reader, &stbl)
-		if tableErr != nil {
-			return false, fmt.Errorf("building sample table: %w", tableErr)
-		}
-
 		cookie = trackCookie
-		samples = trackSamples
 
-		return true, nil // found it, stop
+		return true, nil
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	if cookie == nil && damaged != nil {
+		return nil, damaged
 	}
 
 	if cookie == nil {
-		return nil, nil, ErrNoALACTrack
+		return nil, ErrNoALACTrack
 	}
 
-	return cookie, samples, nil
+	return cookie, nil
+}
+
+// findMoov locates the moov box, recording the stream size.
+func (p *parser) findMoov() (boxInfo, error) {
+	if _, err := p.reader.Seek(0, io.SeekStart); err != nil {
+		return boxInfo{}, fmt.Errorf("seeking to start: %w", err)
+	}
+
+	fileEnd, err := p.reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return boxInfo{}, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	p.size = fileEnd
+	root := boxInfo{offset: 0, size: fileEnd, headerSize: 0}
+	fccMoov := [4]byte{'m', 'o', 'o', 'v'}
+
+	moov, found, err := p.findChild(&root, fccMoov)
+	if err != nil {
+		return boxInfo{}, fmt.Errorf("reading container structure: %w", err)
+	}
+
+	if !found {
+		return boxInfo{}, ErrNoALACTrack
+	}
+
+	return moov, nil
+}
+
+// readTrak parses one trak box. It reports false, without error, for tracks
+// that do not carry ALAC audio, and false with the error for tracks whose
+// boxes are too damaged to reach the sample description. Errors in a track
+// known to carry ALAC report true.
+func (p *parser) readTrak(trak *boxInfo) (Track, bool, error) {
+	fccMdia := [4]byte{'m', 'd', 'i', 'a'}
+	fccMinf := [4]byte{'m', 'i', 'n', 'f'}
+	fccStbl := [4]byte{'s', 't', 'b', 'l'}
+	fccEdts := [4]byte{'e', 'd', 't', 's'}
+	fccElst := [4]byte{'e', 'l', 's', 't'}
+	fccMdhd := [4]byte{'m', 'd', 'h', 'd'}
+	fccStts := [4]byte{'s', 't', 't', 's'}
+	fccTkhd := [4]byte{'t', 'k', 'h', 'd'}
+
+	var track Track
+
+	stbl, stblFound, findErr := p.findDescendant(trak, [][4]byte{fccMdia, fccMinf, fccStbl})
+	if findErr != nil || !stblFound {
+		if p.trace != nil {
+			var reason any = "no stbl"
+			if findErr != nil {
+				reason = findErr
+			}
+
+			p.trace("track skipped", "offset", trak.offset, "reason", reason)
+		}
+
+		return Track{}, false, findErr
+	}
+
+	trackCookie, entryBoxes, descIdx, cookieErr := p.extractCookie(&stbl)
+	if cookieErr != nil {
+		if p.trace != nil {
+			p.trace("track skipped", "offset", trak.offset, "reason", cookieErr)
+		}
+
+		return Track{}, false, nil //nolint:nilerr // cookieErr means "not an ALAC track"; continue to next trak
+	}
+
+	if p.trace != nil {
+		p.trace("track selected", "offset", trak.offset, "cookie_length", len(trackCookie))
+	}
+
+	trackSamples, tableErr := p.buildSampleTable(&stbl, descIdx)
+	if tableErr != nil {
+		return Track{}, true, fmt.Errorf("building sample table: %w", tableErr)
+	}
+
+	if p.trace != nil {
+		p.trace("sample table", "samples", len(trackSamples))
+	}
+
+	// Without a usable stts, packets are assumed to hold FrameLength frames each.
+	if stts, hasStts, sttsErr := p.findChild(&stbl, fccStts); sttsErr == nil && hasStts {
+		end, readErr := p.readStts(&stts, trackSamples)
+		if readErr != nil {
+			if p.trace != nil {
+				p.trace("box ignored", "type", "stts", "reason", readErr)
+			}
+		} else {
+			track.HasTimeToSample = true
+			track.TimeToSampleEnd = end
+		}
+	}
+
+	// Sample groups are advisory: a damaged table leaves every sample independent.
+	preroll, groupErr := p.readRollGroups(&stbl)
+	if groupErr != nil && p.trace != nil {
+		p.trace("box ignored", "type", "sbgp/sgpd", "reason", groupErr)
+	}
+
+	elst, hasElst, elstErr := p.findDescendant(trak, [][4]byte{fccEdts, fccElst})
+	if elstErr != nil {
+		return Track{}, true, fmt.Errorf("reading edit list: %w", elstErr)
+	}
+
+	if hasElst {
+		if track.Edits, elstErr = p.readElst(&elst); elstErr != nil && p.trace != nil {
+			p.trace("box ignored", "type", "elst", "reason", elstErr)
+		}
+	}
+
+	if mdhd, hasMdhd, mdhdErr := p.findDescendant(trak, [][4]byte{fccMdia, fccMdhd}); mdhdErr == nil && hasMdhd {
+		if track.MediaTimescale, mdhdErr = p.readTimescale(&mdhd); mdhdErr != nil && p.trace != nil {
+			p.trace("box ignored", "type", "mdhd", "reason", mdhdErr)
+		}
+	}
+
+	if tkhd, hasTkhd, tkhdErr := p.findChild(trak, fccTkhd); tkhdErr == nil && hasTkhd {
+		if track.ID, tkhdErr = p.readTrackID(&tkhd); tkhdErr != nil && p.trace != nil {
+			p.trace("box ignored", "type", "tkhd", "reason", tkhdErr)
+		}
+	}
+
+	track.Cookie = trackCookie
+	track.BitRate = findBitRate(entryBoxes)
+	track.ChannelLayout = findChannelLayout(entryBoxes)
+	track.Samples = trackSamples
+	track.HasEditList = hasElst
+	track.PrerollSamples = preroll
+
+	return track, true, nil
 }
 
 const (
 	alacFourCC            = "alac"
+	mp4aFourCC            = "mp4a"
+	waveFourCC            = "wave"
+	chanFourCC            = "chan"
 	sampleEntryHeaderSize = 8  // box header: size(4) + type(4)
 	sampleEntryBaseSize   = 28 // standard AudioSampleEntry fields
 	sampleEntryV1Extra    = 16 // QuickTime version 1 extra fields
 	stsdPayloadHeader     = 8  // version(1) + flags(3) + entryCount(4)
 )
 
-// extractCookie reads the stsd box from stbl, finds an 'alac' sample entry,
+// extractCookie reads the stsd box from stbl, finds an 'alac' sample entry
+// (or an 'mp4a' entry wrapping an 'alac' box, as some QuickTime muxers write),
 // and extracts the raw magic cookie (ALACSpecificConfig, possibly wrapped in
-// 'frma'+'alac' atoms which ParseMagicCookie handles).
-func extractCookie(reader io.ReadSeeker, stbl *boxInfo) ([]byte, error) {
+// 'frma'+'alac' atoms which ParseMagicCookie handles), along with the boxes
+// following the entry's fixed fields and its 1-based sample description index.
+// For an 'alac' entry the cookie is those boxes; for an 'mp4a' entry it is the
+// nested 'alac' box alone.
+//
+//revive:disable-next-line:function-result-limit
+func (p *parser) extractCookie(stbl *boxInfo) ([]byte, []byte, uint32, error) {
+	reader := p.reader
 	fccStsd := [4]byte{'s', 't', 's', 'd'}
 
-	stsd, found, err := findChild(reader, stbl, fccStsd)
+	stsd, found, err := p.findChild(stbl, fccStsd)
 	if err != nil || !found {
-		return nil, ErrNoALACTrack
+		return nil, nil, 0, ErrNoALACTrack
+	}
+
+	// The payload is read whole: refuse a size that runs past the end of the stream.
+	if stsd.offset+stsd.size > p.size {
+		return nil, nil, 0, fmt.Errorf("%w: stsd at offset %d runs past stream end %d",
+			ErrInvalidBoxSize, stsd.offset, p.size)
 	}
 
 	payloadLen := int(stsd.payloadSize())
 	data := make([]byte, payloadLen)
 
 	if err := stsd.seekToPayload(reader); err != nil {
-		return nil, fmt.Errorf("seeking to stsd payload: %w", err)
+		return nil, nil, 0, fmt.Errorf("seeking to stsd payload: %w", err)
 	}
 
 	if _, err := io.ReadFull(reader, data); err != nil {
-		return nil, fmt.Errorf("reading stsd payload: %w", err)
+		return nil, nil, 0, fmt.Errorf("reading stsd payload: %w", err)
 	}
 
 	if len(data) < stsdPayloadHeader {
-		return nil, ErrNoALACTrack
+		return nil, nil, 0, ErrNoALACTrack
 	}
 
 	entryCount := binary.BigEndian.Uint32(data[4:8])
 	pos := stsdPayloadHeader
 
-	for range entryCount {
+	for entryIdx := range entryCount {
 		if pos+sampleEntryHeaderSize > len(data) {
 			break
 		}
@@ -348,13 +696,14 @@ func extractCookie(reader io.ReadSeeker, stbl *boxInfo) ([]byte, error) {
 			continue
 		}
 
-		if string(data[pos+4:pos+8]) != alacFourCC {
+		entryType := string(data[pos+4 : pos+8])
+		if entryType != alacFourCC && entryType != mp4aFourCC {
 			pos += entrySize
 
 			continue
 		}
 
-		// Found ALAC sample entry. Determine cookie start from QT version field.
+		// Found a candidate sample entry. Determine cookie start from QT version field.
 		// Layout after 8-byte box header: reserved(6) + dataRefIdx(2) + version(2) + ...
 		// Version is at offset 8 within the payload (i.e., pos + headerSize + 8).
 		version := binary.BigEndian.Uint16(data[pos+sampleEntryHeaderSize+8 : pos+sampleEntryHeaderSize+10])
@@ -368,39 +717,181 @@ func extractCookie(reader io.ReadSeeker, stbl *boxInfo) ([]byte, error) {
 		cookieEnd := pos + entrySize
 
 		if cookieStart >= cookieEnd {
-			return nil, ErrInvalidEntry
+			if entryType == mp4aFourCC {
+				pos += entrySize
+
+				continue
+			}
+
+			return nil, nil, 0, ErrInvalidEntry
+		}
+
+		if entryType == mp4aFourCC {
+			// QuickTime muxers may store ALAC under mp4a, with the cookie in a
+			// wave/alac child; anything else is AAC.
+			cookie := quickTimeALACCookie(data[cookieStart:cookieEnd])
+			if cookie == nil {
+				pos += entrySize
+
+				continue
+			}
+
+			return cookie, data[cookieStart:cookieEnd], entryIdx + 1, nil
 		}
 
-		return data[cookieStart:cookieEnd], nil
+		return data[cookieStart:cookieEnd], data[cookieStart:cookieEnd], entryIdx + 1, nil
+	}
+
+	return nil, nil, 0, ErrNoALACTrack
+}
+
+// quickTimeALACCookie returns the alac box found among the children of an
+// mp4a sample entry, directly or inside a wave box, or nil if there is none.
+func quickTimeALACCookie(children []byte) []byte {
+	if wave := findEntryChild(children, waveFourCC); wave != nil {
+		children = wave[smallHeaderSize:]
 	}
 
-	return nil, ErrNoALACTrack
+	return findEntryChild(children, alacFourCC)
+}
+
+// findEntryChild returns the first box of type fourCC, header included, in a
+// run of boxes, or nil if there is none.
+func findEntryChild(boxes []byte, fourCC string) []byte {
+	for len(boxes) >= smallHeaderSize {
+		size := int(binary.BigEndian.Uint32(boxes))
+		if size < smallHeaderSize || size > len(boxes) {
+			break
+		}
+
+		if string(boxes[4:8]) == fourCC {
+			return boxes[:size]
+		}
+
+		boxes = boxes[size:]
+	}
+
+	return nil
+}
+
+// sampleEntryRuns returns the runs of boxes that may hold a sample entry's
+// optional boxes: those following its fixed fields (as returned by
+// extractCookie), then the children of a QuickTime wave box among them.
+func sampleEntryRuns(entry []byte) [][]byte {
+	runs := [][]byte{entry}
+	if wave := findEntryChild(entry, waveFourCC); wave != nil {
+		runs = append(runs, wave[smallHeaderSize:])
+	}
+
+	return runs
+}
+
+// findBitRate returns the bit rates of the btrt box among the boxes of a
+// sample entry (see sampleEntryRuns), or zero if there is none.
+// Layout: bufferSizeDB(4) + maxBitrate(4) + avgBitrate(4).
+func findBitRate(entry []byte) BitRate {
+	const btrtPayload = 12
+
+	for _, run := range sampleEntryRuns(entry) {
+		btrt := findEntryChild(run, "btrt")
+		if len(btrt) < smallHeaderSize+btrtPayload {
+			continue
+		}
+
+		payload := btrt[smallHeaderSize:]
+
+		return BitRate{
+			BufferSizeDB: binary.BigEndian.Uint32(payload),
+			Max:          binary.BigEndian.Uint32(payload[4:]),
+			Avg:          binary.BigEndian.Uint32(payload[8:]),
+		}
+	}
+
+	return BitRate{}
+}
+
+// findChannelLayout returns the layout of the chan box among the boxes of a
+// sample entry (see sampleEntryRuns), or of the one that may follow the ALAC
+// config inside the alac box, or the zero layout if there is none.
+// Both share a layout: FullBox(4) + channelLayoutTag(4) + channelBitmap(4) +
+// numberChannelDescriptions(4), then per description: channelLabel(4) +
+// channelFlags(4) + coordinates(12). Descriptions past the box end are dropped.
+func findChannelLayout(entry []byte) ChannelLayout {
+	const (
+		alacConfigSize  = fullBoxSize + 24
+		descriptionSize = 20
+	)
+
+	var chanBox []byte
+
+	for _, run := range sampleEntryRuns(entry) {
+		if chanBox = findEntryChild(run, chanFourCC); chanBox != nil {
+			break
+		}
+
+		if alac := findEntryChild(run, alacFourCC); len(alac) > smallHeaderSize+alacConfigSize {
+			if chanBox = findEntryChild(alac[smallHeaderSize+alacConfigSize:], chanFourCC); chanBox != nil {
+				break
+			}
+		}
+	}
+
+	if len(chanBox) < smallHeaderSize+fullBoxSize+4 {
+		return ChannelLayout{}
+	}
+
+	payload := chanBox[smallHeaderSize+fullBoxSize:]
+	layout := ChannelLayout{Tag: binary.BigEndian.Uint32(payload)}
+
+	if len(payload) < 12 { //revive:disable-line:add-constant
+		return layout
+	}
+
+	layout.Bitmap = binary.BigEndian.Uint32(payload[4:])
+	count := int(binary.BigEndian.Uint32(payload[8:]))
+	descriptions := payload[12:]
+
+	for idx := 0; idx < count && len(descriptions) >= descriptionSize; idx++ {
+		layout.Labels = append(layout.Labels, binary.BigEndian.Uint32(descriptions))
+		descriptions = descriptions[descriptionSize:]
+	}
+
+	return layout
 }
 
 // buildSampleTable constructs a flat list of sample offsets and sizes from
-// the stco/co64, stsc, and stsz boxes within the given stbl box.
-func buildSampleTable(reader io.ReadSeeker, stbl *boxInfo) ([]SampleInfo, error) {
-	chunkOffsets, err := readChunkOffsets(reader, stbl)
+// the stco/co64, stsc, and stsz boxes within the given stbl box. Chunks that
+// stsc assigns to a sample description other than descIdx (1-based) hold
+// another codec's samples and are left out; index 0 in stsc, written by some
+// single-entry muxers, is taken to mean descIdx.
+func (p *parser) buildSampleTable(stbl *boxInfo, descIdx uint32) ([]SampleInfo, error) {
+	chunkOffsets, err := p.readChunkOffsets(stbl)
 	if err != nil {
 		return nil, err
 	}
 
-	stscEntries, err := readStsc(reader, stbl)
+	stscEntries, err := p.readStsc(stbl)
 	if err != nil {
 		return nil, err
 	}
 
-	entrySizes, constantSize, sampleCount, err := readStsz(reader, stbl)
+	entrySizes, constantSize, sampleCount, err := p.readStsz(stbl)
 	if err != nil {
 		return nil, err
 	}
 
+	// Sample data must lie within the mdat boxes, as declared: a truncated
+	// file keeps its mdat header, so this only rejects inconsistent tables.
+	dataEnd := p.mdatEnd()
+
 	samples := make([]SampleInfo, 0, sampleCount)
 	sampleIdx := 0
 
 	for chunkIdx := range chunkOffsets {
-		samplesInChunk := lookupSamplesPerChunk(stscEntries, uint32(chunkIdx+1)) // stsc uses 1-based chunk numbers
+		run := lookupChunkRun(stscEntries, uint32(chunkIdx+1)) // stsc uses 1-based chunk numbers
+		samplesInChunk := run.SamplesPerChunk
 		chunkOffset := chunkOffsets[chunkIdx]
+		described := run.SampleDescriptionIndex == descIdx || run.SampleDescriptionIndex == 0
 
 		for iter := uint32(0); iter < samplesInChunk && sampleIdx < int(sampleCount); iter++ {
 			var size uint32
@@ -410,7 +901,21 @@ func buildSampleTable(reader io.ReadSeeker, stbl *boxInfo) ([]SampleInfo, error)
 				size = entrySizes[sampleIdx]
 			}
 
-			samples = append(samples, SampleInfo{Offset: chunkOffset, Size: size})
+			// Packets are read into memory whole: bound them by the stream length.
+			if int64(size) > p.size {
+				return nil, fmt.Errorf("%w: sample %d size %d exceeds stream length %d",
+					ErrInvalidStsz, sampleIdx, size, p.size)
+			}
+
+			if dataEnd > 0 && chunkOffset+uint64(size) > uint64(dataEnd) {
+				return nil, fmt.Errorf("%w: sample %d at offset %d, size %d, ends past the mdat data ending at %d",
+					ErrInvalidStsz, sampleIdx, chunkOffset, size, dataEnd)
+			}
+
+			if described {
+				samples = append(samples, SampleInfo{Offset: chunkOffset, Size: size, tableIndex: uint32(sampleIdx)})
+			}
+
 			chunkOffset += uint64(size)
 			sampleIdx++
 		}
@@ -419,22 +924,43 @@ func buildSampleTable(reader io.ReadSeeker, stbl *boxInfo) ([]SampleInfo, error)
 	return samples, nil
 }
 
-func readChunkOffsets(reader io.ReadSeeker, stbl *boxInfo) ([]uint64, error) {
+// mdatEnd returns the end of the last top-level mdat box as its header
+// declares it, which may lie past the end of a truncated stream, or 0 when
+// no mdat is found.
+func (p *parser) mdatEnd() int64 {
+	fccMdat := [4]byte{'m', 'd', 'a', 't'}
+	root := boxInfo{size: p.size}
+
+	var end int64
+
+	// A damaged box after the mdat only ends the scan.
+	_ = p.iterChildren(&root, func(box boxInfo) (bool, error) {
+		if box.fourCC == fccMdat {
+			end = max(end, box.offset+box.size)
+		}
+
+		return false, nil
+	})
+
+	return end
+}
+
+func (p *parser) readChunkOffsets(stbl *boxInfo) ([]uint64, error) {
 	fccStco := [4]byte{'s', 't', 'c', 'o'}
 	fccCo64 := [4]byte{'c', 'o', '6', '4'}
 
 	// Try 32-bit stco first.
-	if stco, stcoFound, err := findChild(reader, stbl, fccStco); err == nil && stcoFound {
-		return readStco(reader, &stco)
+	if stco, stcoFound, err := p.findChild(stbl, fccStco); err == nil && stcoFound {
+		return readStco(p.reader, &stco)
 	}
 
 	// Fall back to 64-bit co64.
-	co64, found, err := findChild(reader, stbl, fccCo64)
+	co64, found, err := p.findChild(stbl, fccCo64)
 	if err != nil || !found {
 		return nil, ErrNoChunkOffset
 	}
 
-	return readCo64(reader, &co64)
+	return readCo64(p.reader, &co64)
 }
 
 // readStco reads a 32-bit chunk offset box.
@@ -450,6 +976,9 @@ func readStco(reader io.ReadSeeker, box *boxInfo) ([]uint64, error) {
 	}
 
 	count := binary.BigEndian.Uint32(header[fullBoxSize:])
+	if !entriesFit(box, len(header), count, 4) {
+		return nil, fmt.Errorf("%w: %d entries overflow the box", ErrNoChunkOffset, count)
+	}
 
 	buf := make([]byte, int(count)*4)
 	if _, err := io.ReadFull(reader, buf); err != nil {
@@ -477,6 +1006,9 @@ func readCo64(reader io.ReadSeeker, box *boxInfo) ([]uint64, error) {
 	}
 
 	count := binary.BigEndian.Uint32(header[fullBoxSize:])
+	if !entriesFit(box, len(header), count, 8) {
+		return nil, fmt.Errorf("%w: %d entries overflow the box", ErrInvalidCo64, count)
+	}
 
 	buf := make([]byte, int(count)*8)
 	if _, err := io.ReadFull(reader, buf); err != nil {
@@ -493,10 +1025,11 @@ func readCo64(reader io.ReadSeeker, box *boxInfo) ([]uint64, error) {
 
 // readStsc reads the sample-to-chunk box.
 // Layout: FullBox(4) + entryCount(4) + entryCount × (firstChunk(4) + samplesPerChunk(4) + sampleDescIdx(4)).
-func readStsc(reader io.ReadSeeker, stbl *boxInfo) ([]stscEntry, error) {
+func (p *parser) readStsc(stbl *boxInfo) ([]stscEntry, error) {
+	reader := p.reader
 	fccStsc := [4]byte{'s', 't', 's', 'c'}
 
-	box, found, err := findChild(reader, stbl, fccStsc)
+	box, found, err := p.findChild(stbl, fccStsc)
 	if err != nil || !found {
 		return nil, ErrNoStsc
 	}
@@ -514,6 +1047,10 @@ func readStsc(reader io.ReadSeeker, stbl *boxInfo) ([]stscEntry, error) {
 
 	const entryBytes = 12 // 3 × uint32
 
+	if !entriesFit(&box, len(header), count, entryBytes) {
+		return nil, fmt.Errorf("%w: %d entries overflow the box", ErrInvalidStsc, count)
+	}
+
 	buf := make([]byte, int(count)*entryBytes)
 	if _, err := io.ReadFull(reader, buf); err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrInvalidStsc, err)
@@ -523,23 +1060,83 @@ func readStsc(reader io.ReadSeeker, stbl *boxInfo) ([]stscEntry, error) {
 	for idx := range count {
 		off := int(idx) * entryBytes
 		entries[idx] = stscEntry{
-			FirstChunk:      binary.BigEndian.Uint32(buf[off:]),
-			SamplesPerChunk: binary.BigEndian.Uint32(buf[off+4:]),
-			// sampleDescriptionIndex at buf[off+8:] is unused.
+			FirstChunk:             binary.BigEndian.Uint32(buf[off:]),
+			SamplesPerChunk:        binary.BigEndian.Uint32(buf[off+4:]),
+			SampleDescriptionIndex: binary.BigEndian.Uint32(buf[off+8:]),
 		}
 	}
 
 	return entries, nil
 }
 
+// readStts reads the time-to-sample box, sets FirstFrame on samples and
+// returns the end of the last sample.
+// Samples beyond the table keep the last entry's duration.
+// Layout: FullBox(4) + entryCount(4) + entryCount × (sampleCount(4) + sampleDelta(4)).
+func (p *parser) readStts(box *boxInfo, samples []SampleInfo) (uint64, error) {
+	reader := p.reader
+
+	if err := box.seekToPayload(reader); err != nil {
+		return 0, err
+	}
+
+	var header [fullBoxSize + 4]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidStts, err)
+	}
+
+	count := binary.BigEndian.Uint32(header[fullBoxSize:])
+
+	const entryBytes = 8 // 2 × uint32
+
+	if count == 0 || !entriesFit(box, len(header), count, entryBytes) {
+		return 0, fmt.Errorf("%w: %d entries", ErrInvalidStts, count)
+	}
+
+	buf := make([]byte, int(count)*entryBytes)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidStts, err)
+	}
+
+	var (
+		frame    uint64
+		delta    uint32
+		runStart uint64 // table index of the run's first sample
+	)
+
+	sampleIdx := 0
+
+	// Runs count every sample in the table, including those of other sample
+	// descriptions that buildSampleTable left out: only listed samples take time.
+	for off := 0; off < len(buf) && sampleIdx < len(samples); off += entryBytes {
+		runEnd := runStart + uint64(binary.BigEndian.Uint32(buf[off:]))
+		delta = binary.BigEndian.Uint32(buf[off+4:])
+
+		for ; sampleIdx < len(samples) && uint64(samples[sampleIdx].tableIndex) < runEnd; sampleIdx++ {
+			samples[sampleIdx].FirstFrame = frame
+			frame += uint64(delta)
+		}
+
+		runStart = runEnd
+	}
+
+	for ; sampleIdx < len(samples); sampleIdx++ {
+		samples[sampleIdx].FirstFrame = frame
+		frame += uint64(delta)
+	}
+
+	return frame, nil
+}
+
 // readStsz reads the sample size box.
 // Layout: FullBox(4) + sampleSize(4) + sampleCount(4) + [sampleCount × uint32 if sampleSize == 0].
 //
 //revive:disable:function-result-limit,confusing-results
-func readStsz(reader io.ReadSeeker, stbl *boxInfo) ([]uint32, uint32, uint32, error) {
+func (p *parser) readStsz(stbl *boxInfo) ([]uint32, uint32, uint32, error) {
+	reader := p.reader
 	fccStsz := [4]byte{'s', 't', 's', 'z'}
 
-	box, found, err := findChild(reader, stbl, fccStsz)
+	box, found, err := p.findChild(stbl, fccStsz)
 	if err != nil || !found {
 		return nil, 0, 0, ErrNoStsz
 	}
@@ -557,10 +1154,19 @@ func readStsz(reader io.ReadSeeker, stbl *boxInfo) ([]uint32, uint32, uint32, er
 	sampleCount := binary.BigEndian.Uint32(header[fullBoxSize+4:])
 
 	if sampleSize != 0 {
-		// Constant size: no per-sample entries.
+		// Constant size: no per-sample entries. Every sample occupies at least
+		// one byte, so a count above the stream length cannot be genuine.
+		if int64(sampleCount) > p.size {
+			return nil, 0, 0, fmt.Errorf("%w: %d samples in a %d-byte stream", ErrInvalidStsz, sampleCount, p.size)
+		}
+
 		return nil, sampleSize, sampleCount, nil
 	}
 
+	if !entriesFit(&box, len(header), sampleCount, 4) {
+		return nil, 0, 0, fmt.Errorf("%w: %d entries overflow the box", ErrInvalidStsz, sampleCount)
+	}
+
 	buf := make([]byte, int(sampleCount)*4)
 	if _, err := io.ReadFull(reader, buf); err != nil {
 		return nil, 0, 0, fmt.Errorf("%w: %w", ErrInvalidStsz, err)
@@ -574,18 +1180,34 @@ func readStsz(reader io.ReadSeeker, stbl *boxInfo) ([]uint32, uint32, uint32, er
 	return sizes, 0, sampleCount, nil
 }
 
-// lookupSamplesPerChunk finds the samples-per-chunk count for a 1-based
-// chunk number from the stsc run-length table.
-func lookupSamplesPerChunk(entries []stscEntry, chunkNumber uint32) uint32 {
-	var samplesPerChunk uint32
+// entriesFit reports whether count table entries of entryBytes each fit in
+// the payload of box after a header of headerBytes. Checking before
+// allocating keeps a corrupt count from requesting gigabytes.
+func entriesFit(box *boxInfo, headerBytes int, count uint32, entryBytes int) bool {
+	return int64(count)*int64(entryBytes) <= box.payloadSize()-int64(headerBytes)
+}
+
+// lookupChunkRun finds the stsc entry governing a 1-based chunk number: its
+// samples-per-chunk count and sample description index.
+//
+// Each entry starts a run that extends up to the next entry's FirstChunk.
+// Chunks numbered before the first entry's FirstChunk (a table that does not
+// start at chunk 1) are treated as part of the first run, as ffmpeg does, so
+// that every chunk holds samples and the sample table stays aligned with stsz.
+func lookupChunkRun(entries []stscEntry, chunkNumber uint32) stscEntry {
+	if len(entries) == 0 {
+		return stscEntry{}
+	}
+
+	run := entries[0]
 
-	for _, entry := range entries {
+	for _, entry := range entries[1:] {
 		if entry.FirstChunk > chunkNumber {
 			break
 		}
 
-		samplesPerChunk = entry.SamplesPerChunk
+		run = entry
 	}
 
-	return samplesPerChunk
+	return run
 }
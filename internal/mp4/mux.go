@@ -0,0 +1,256 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions are bounded by MP4 field widths.
+package mp4
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// MuxTrack describes the ALAC track a Muxer writes.
+type MuxTrack struct {
+	// Config is the 24-byte ALACSpecificConfig, stored in the alac box.
+	Config      []byte
+	SampleRate  uint32 // media and movie timescale
+	Channels    uint16
+	BitDepth    uint16
+	AvgBitRate  uint32
+	EditStart   uint64 // first presented frame; an edit list is written when non-zero
+	EditFrames  uint64 // presented frame count; an edit list is written when non-zero
+	MaxBitRate  uint32 // btrt maxBitrate, or 0 for no btrt box
+	BufferBytes uint32 // btrt bufferSizeDB
+	// Layout is the Core Audio channel layout for a chan box, zero for none.
+	Layout ChannelLayout
+}
+
+// Muxer writes an M4A file holding one ALAC track: ftyp, then an mdat
+// receiving the packets as they are written, then moov.
+type Muxer struct {
+	writer    io.WriteSeeker
+	mdatStart int64  // position of the mdat header
+	dataSize  uint64 // packet bytes written to mdat
+	sizes     []uint32
+	durations []uint32
+}
+
+// NewMuxer writes the file header at the current position of writer.
+func NewMuxer(writer io.WriteSeeker) (*Muxer, error) {
+	start, err := writer.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("locating output: %w", err)
+	}
+
+	ftyp := muxBox("ftyp", []byte("M4A "), u32(0), []byte("M4A mp42isom"))
+
+	// A 64-bit mdat header, whose size is patched by Finish.
+	mdat := append(u32(1), "mdat"...)
+	mdat = append(mdat, u64(0)...)
+
+	if _, err := writer.Write(append(ftyp, mdat...)); err != nil {
+		return nil, fmt.Errorf("writing file header: %w", err)
+	}
+
+	return &Muxer{writer: writer, mdatStart: start + int64(len(ftyp))}, nil
+}
+
+// WritePacket appends one packet, lasting frames sample frames, to mdat.
+func (m *Muxer) WritePacket(packet []byte, frames uint32) error {
+	if _, err := m.writer.Write(packet); err != nil {
+		return fmt.Errorf("writing packet %d: %w", len(m.sizes), err)
+	}
+
+	m.dataSize += uint64(len(packet))
+	m.sizes = append(m.sizes, uint32(len(packet)))
+	m.durations = append(m.durations, frames)
+
+	return nil
+}
+
+// Finish completes mdat and writes the moov box describing track.
+func (m *Muxer) Finish(track MuxTrack) error {
+	end, err := m.writer.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("locating output: %w", err)
+	}
+
+	if _, err := m.writer.Seek(m.mdatStart+smallHeaderSize, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking to mdat header: %w", err)
+	}
+
+	if _, err := m.writer.Write(u64(largeHeaderSize + m.dataSize)); err != nil {
+		return fmt.Errorf("writing mdat size: %w", err)
+	}
+
+	if _, err := m.writer.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking to end of mdat: %w", err)
+	}
+
+	if _, err := m.writer.Write(m.moov(track)); err != nil {
+		return fmt.Errorf("writing moov: %w", err)
+	}
+
+	return nil
+}
+
+// moov builds the movie box. Header boxes use version 1, whose 64-bit
+// durations cannot overflow.
+func (m *Muxer) moov(track MuxTrack) []byte {
+	var mediaFrames uint64
+	for _, frames := range m.durations {
+		mediaFrames += uint64(frames)
+	}
+
+	hasEdit := track.EditStart != 0 || track.EditFrames != 0
+
+	presented := mediaFrames
+	if hasEdit {
+		presented = cmp.Or(track.EditFrames, mediaFrames-min(track.EditStart, mediaFrames))
+	}
+
+	identity := []byte{
+		0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0,
+	}
+
+	mvhd := muxFullBox("mvhd", 1, 0, u64(0), u64(0), u32(track.SampleRate), u64(presented),
+		u32(0x00010000), u16(0x0100), make([]byte, 10), identity, make([]byte, 24), u32(2))
+	tkhd := muxFullBox("tkhd", 1, 0x7, u64(0), u64(0), u32(1), u32(0), u64(presented),
+		make([]byte, 8), u16(0), u16(0), u16(0x0100), u16(0), identity, u32(0), u32(0))
+
+	trak := [][]byte{tkhd}
+
+	if hasEdit {
+		elst := muxFullBox("elst", 1, 0, u32(1), u64(presented), u64(track.EditStart), u32(0x00010000))
+		trak = append(trak, muxBox("edts", elst))
+	}
+
+	mdhd := muxFullBox("mdhd", 1, 0, u64(0), u64(0), u32(track.SampleRate), u64(mediaFrames),
+		u16(0x55C4), u16(0))
+	hdlr := muxFullBox("hdlr", 0, 0, u32(0), []byte("soun"), make([]byte, 12), []byte("SoundHandler\x00"))
+	dinf := muxBox("dinf", muxFullBox("dref", 0, 0, u32(1), muxFullBox("url ", 0, 1)))
+	minf := muxBox("minf", muxFullBox("smhd", 0, 0, make([]byte, 4)), dinf, m.stbl(track))
+
+	trak = append(trak, muxBox("mdia", mdhd, hdlr, minf))
+
+	return muxBox("moov", mvhd, muxBox("trak", trak...))
+}
+
+// stbl builds the sample table, with every packet in a single chunk.
+func (m *Muxer) stbl(track MuxTrack) []byte {
+	// The 16.16 sample rate field cannot hold rates above 65535; the cookie has the real one.
+	var rate uint32
+	if track.SampleRate <= math.MaxUint16 {
+		rate = track.SampleRate << 16
+	}
+
+	entry := [][]byte{
+		make([]byte, 6), u16(1), u16(0), u16(0), u32(0),
+		u16(track.Channels), u16(track.BitDepth), u16(0), u16(0), u32(rate),
+		muxFullBox("alac", 0, 0, track.Config),
+	}
+
+	if layout := track.Layout; layout.Tag != 0 || len(layout.Labels) != 0 {
+		chanBox := [][]byte{u32(layout.Tag), u32(layout.Bitmap), u32(uint32(len(layout.Labels)))}
+
+		for _, label := range layout.Labels {
+			chanBox = append(chanBox, u32(label), make([]byte, 16)) // no flags, no coordinates
+		}
+
+		entry = append(entry, muxFullBox("chan", 0, 0, chanBox...))
+	}
+
+	if track.MaxBitRate != 0 {
+		entry = append(entry, muxBox("btrt",
+			u32(track.BufferBytes), u32(track.MaxBitRate), u32(track.AvgBitRate)))
+	}
+
+	stsd := muxFullBox("stsd", 0, 0, u32(1), muxBox("alac", entry...))
+
+	var (
+		stts []byte
+		runs int
+	)
+
+	for idx := 0; idx < len(m.durations); {
+		end := idx
+		for end < len(m.durations) && m.durations[end] == m.durations[idx] {
+			end++
+		}
+
+		stts = append(stts, u32(uint32(end-idx))...)
+		stts = append(stts, u32(m.durations[idx])...)
+		runs++
+		idx = end
+	}
+
+	stsc := u32(0)
+	if len(m.sizes) > 0 {
+		stsc = append(u32(1), u32(1)...)
+		stsc = append(stsc, u32(uint32(len(m.sizes)))...)
+		stsc = append(stsc, u32(1)...)
+	}
+
+	stsz := append(u32(0), u32(uint32(len(m.sizes)))...)
+	for _, size := range m.sizes {
+		stsz = append(stsz, u32(size)...)
+	}
+
+	dataStart := uint64(m.mdatStart) + largeHeaderSize
+
+	chunks := muxFullBox("stco", 0, 0, u32(1), u32(uint32(dataStart)))
+	if dataStart > math.MaxUint32 {
+		chunks = muxFullBox("co64", 0, 0, u32(1), u64(dataStart))
+	}
+
+	return muxBox("stbl", stsd,
+		muxFullBox("stts", 0, 0, u32(uint32(runs)), stts),
+		muxFullBox("stsc", 0, 0, stsc),
+		muxFullBox("stsz", 0, 0, stsz),
+		chunks)
+}
+
+// muxBox assembles a box from its type and payload parts.
+func muxBox(fourCC string, parts ...[]byte) []byte {
+	size := smallHeaderSize
+	for _, part := range parts {
+		size += len(part)
+	}
+
+	out := make([]byte, 0, size)
+	out = append(out, u32(uint32(size))...)
+	out = append(out, fourCC...)
+
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+
+	return out
+}
+
+// muxFullBox assembles a full box, prefixing the payload with version and flags.
+func muxFullBox(fourCC string, version uint8, flags uint32, parts ...[]byte) []byte {
+	return muxBox(fourCC, append([][]byte{u32(uint32(version)<<24 | flags)}, parts...)...)
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
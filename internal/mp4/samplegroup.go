@@ -0,0 +1,235 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions are bounded by MP4 atom sizes.
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// SampleRange is a run of consecutive samples, by 0-based index.
+type SampleRange struct {
+	First uint32
+	Count uint32
+}
+
+// groupingRoll and groupingPreroll are the sample group types (ISO 14496-12
+// §10.1) whose entries carry a roll distance: the number of samples that must
+// be decoded before a member sample decodes correctly.
+var (
+	groupingRoll    = [4]byte{'r', 'o', 'l', 'l'}
+	groupingPreroll = [4]byte{'p', 'r', 'o', 'l'}
+)
+
+// maxSgpdLocalIndex is the largest group description index referring to the
+// sample table's own sgpd; larger values address movie fragment groups.
+const maxSgpdLocalIndex = 0x10000
+
+// readRollGroups reads the roll and pre-roll sample groups of stbl and returns
+// the samples that need pre-roll, sorted and merged. Samples outside such
+// groups, or in groups with a zero roll distance, decode independently.
+func (p *parser) readRollGroups(stbl *boxInfo) ([]SampleRange, error) {
+	descriptions := map[[4]byte][]int16{}
+	var mappings []*boxInfo
+
+	err := p.iterChildren(stbl, func(child boxInfo) (bool, error) {
+		switch child.fourCC {
+		case [4]byte{'s', 'g', 'p', 'd'}:
+			grouping, distances, err := p.readSgpd(&child)
+			if err != nil {
+				return true, err
+			}
+
+			if distances != nil {
+				descriptions[grouping] = distances
+			}
+		case [4]byte{'s', 'b', 'g', 'p'}:
+			mappings = append(mappings, &child)
+		default:
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []SampleRange
+
+	for _, sbgp := range mappings {
+		found, err := p.readSbgp(sbgp, descriptions, ranges)
+		if err != nil {
+			return nil, err
+		}
+
+		ranges = found
+	}
+
+	return mergeRanges(ranges), nil
+}
+
+// mergeRanges sorts ranges by first sample and coalesces overlapping or
+// adjacent runs, so that every sample is covered by at most one range.
+func mergeRanges(ranges []SampleRange) []SampleRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].First < ranges[j].First })
+
+	merged := ranges[:0]
+
+	for _, run := range ranges {
+		if last := len(merged) - 1; last >= 0 {
+			end := uint64(merged[last].First) + uint64(merged[last].Count)
+			if uint64(run.First) <= end {
+				merged[last].Count = uint32(min(max(end, uint64(run.First)+uint64(run.Count))-uint64(merged[last].First),
+					uint64(^uint32(0))))
+
+				continue
+			}
+		}
+
+		merged = append(merged, run)
+	}
+
+	return merged
+}
+
+// readSgpd reads a sample group description box. It returns the roll distance
+// of each entry for roll and pre-roll groups, and nil distances for any other
+// grouping type.
+// Layout: FullBox(4) + groupingType(4) + [v1: defaultLength(4)] +
+// [v2+: defaultDescriptionIndex(4)] + entryCount(4) + entries.
+func (p *parser) readSgpd(box *boxInfo) ([4]byte, []int16, error) {
+	version, payload, err := p.readFullBoxPayload(box, ErrInvalidSampleGroup)
+	if err != nil {
+		return [4]byte{}, nil, err
+	}
+
+	if len(payload) < 8 {
+		return [4]byte{}, nil, fmt.Errorf("%w: sgpd too short", ErrInvalidSampleGroup)
+	}
+
+	grouping := [4]byte(payload[:4])
+	payload = payload[4:]
+
+	if grouping != groupingRoll && grouping != groupingPreroll {
+		return grouping, nil, nil
+	}
+
+	var defaultLength uint32
+
+	if version == 1 {
+		defaultLength = binary.BigEndian.Uint32(payload)
+		payload = payload[4:]
+	}
+
+	if version >= 2 {
+		payload = payload[4:]
+	}
+
+	if len(payload) < 4 {
+		return grouping, nil, fmt.Errorf("%w: sgpd missing entry count", ErrInvalidSampleGroup)
+	}
+
+	count := int(binary.BigEndian.Uint32(payload))
+	payload = payload[4:]
+
+	// Each entry is at least a 2-byte roll distance.
+	if count > len(payload)/2 {
+		return grouping, nil, fmt.Errorf("%w: %d sgpd entries in %d bytes", ErrInvalidSampleGroup, count, len(payload))
+	}
+
+	distances := make([]int16, count)
+
+	for idx := range distances {
+		length := 2
+
+		if version == 1 {
+			length = int(defaultLength)
+
+			if length == 0 {
+				if len(payload) < 4 {
+					return grouping, nil, fmt.Errorf("%w: truncated sgpd entry", ErrInvalidSampleGroup)
+				}
+
+				length = int(binary.BigEndian.Uint32(payload))
+				payload = payload[4:]
+			}
+		}
+
+		if length < 2 || length > len(payload) {
+			return grouping, nil, fmt.Errorf("%w: sgpd entry of %d bytes", ErrInvalidSampleGroup, length)
+		}
+
+		distances[idx] = int16(binary.BigEndian.Uint16(payload))
+		payload = payload[length:]
+	}
+
+	return grouping, distances, nil
+}
+
+// readSbgp reads a sample-to-group box and appends to ranges the runs mapped
+// to roll or pre-roll descriptions with a non-zero distance.
+// Layout: FullBox(4) + groupingType(4) + [v1: groupingTypeParameter(4)] +
+// entryCount(4) + entryCount × (sampleCount(4) + groupDescriptionIndex(4)).
+func (p *parser) readSbgp(box *boxInfo, descriptions map[[4]byte][]int16, ranges []SampleRange) ([]SampleRange, error) {
+	version, payload, err := p.readFullBoxPayload(box, ErrInvalidSampleGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	header := 8
+	if version == 1 {
+		header = 12
+	}
+
+	if len(payload) < header {
+		return nil, fmt.Errorf("%w: sbgp too short", ErrInvalidSampleGroup)
+	}
+
+	distances, ok := descriptions[[4]byte(payload[:4])]
+	if !ok {
+		return ranges, nil
+	}
+
+	count := int(binary.BigEndian.Uint32(payload[header-4:]))
+	payload = payload[header:]
+
+	const entryBytes = 8
+
+	if count > len(payload)/entryBytes {
+		return nil, fmt.Errorf("%w: %d sbgp entries in %d bytes", ErrInvalidSampleGroup, count, len(payload))
+	}
+
+	var first uint64
+
+	for idx := range count {
+		entry := payload[idx*entryBytes:]
+		run := binary.BigEndian.Uint32(entry)
+		group := binary.BigEndian.Uint32(entry[4:])
+
+		// Index 0 means no group; indices past the local table belong to fragments.
+		if group != 0 && group <= maxSgpdLocalIndex && int(group) <= len(distances) &&
+			distances[group-1] != 0 && run != 0 && first <= uint64(^uint32(0)) {
+			ranges = append(ranges, SampleRange{First: uint32(first), Count: run})
+		}
+
+		first += uint64(run)
+	}
+
+	return ranges, nil
+}
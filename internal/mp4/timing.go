@@ -0,0 +1,177 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions are bounded by MP4 atom sizes.
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxTimingPayload bounds the payload read for mvhd, mdhd, elst and fragment
+// boxes. An elst entry is at most 20 bytes, so this allows over 50000 edits.
+const maxTimingPayload = 1 << 20
+
+// Edit is one entry of an edit list (elst).
+type Edit struct {
+	// SegmentDuration is the presentation length of the edit, in movie timescale units.
+	SegmentDuration uint64
+	// MediaTime is where the edit starts in the media, in media timescale units.
+	// -1 marks an empty edit: SegmentDuration of silence.
+	MediaTime int64
+	// MediaRate is the playback rate as 16.16 fixed point (0x10000 is normal speed).
+	MediaRate int32
+}
+
+// Empty reports whether the edit inserts silence rather than media.
+func (e Edit) Empty() bool { return e.MediaTime == -1 }
+
+// readFullBoxPayload reads a full box payload and returns its version and the
+// bytes following the version and flags.
+func (p *parser) readFullBoxPayload(box *boxInfo, errInvalid error) (uint8, []byte, error) {
+	version, _, payload, err := p.readFullBox(box, errInvalid)
+
+	return version, payload, err
+}
+
+// readFullBox is readFullBoxPayload, also returning the 24-bit flags.
+func (p *parser) readFullBox(box *boxInfo, errInvalid error) (uint8, uint32, []byte, error) {
+	size := box.payloadSize()
+	if size < fullBoxSize || size > maxTimingPayload {
+		return 0, 0, nil, fmt.Errorf("%w: payload size %d", errInvalid, size)
+	}
+
+	if err := box.seekToPayload(p.reader); err != nil {
+		return 0, 0, nil, err
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(p.reader, buf); err != nil {
+		return 0, 0, nil, fmt.Errorf("%w: %w", errInvalid, err)
+	}
+
+	return buf[0], binary.BigEndian.Uint32(buf) & 0xFFFFFF, buf[fullBoxSize:], nil
+}
+
+// readTimescale reads the timescale of an mvhd or mdhd box, which share their leading layout.
+// Layout v0: creation(4) + modification(4) + timescale(4) + duration(4).
+// Layout v1: creation(8) + modification(8) + timescale(4) + duration(8).
+func (p *parser) readTimescale(box *boxInfo) (uint32, error) {
+	version, payload, err := p.readFullBoxPayload(box, ErrInvalidHeader)
+	if err != nil {
+		return 0, err
+	}
+
+	off := 8
+	if version == 1 {
+		off = 16
+	}
+
+	if len(payload) < off+4 {
+		return 0, fmt.Errorf("%w: %s too short", ErrInvalidHeader, box.fourCC[:])
+	}
+
+	return binary.BigEndian.Uint32(payload[off:]), nil
+}
+
+// readHeaderTiming reads the timescale and duration of an mvhd or mdhd box.
+// An all-ones duration, meaning unknown, is reported as 0.
+func (p *parser) readHeaderTiming(box *boxInfo) (uint32, uint64, error) {
+	version, payload, err := p.readFullBoxPayload(box, ErrInvalidHeader)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	off, durationBytes := 8, 4
+	if version == 1 {
+		off, durationBytes = 16, 8
+	}
+
+	if len(payload) < off+4+durationBytes {
+		return 0, 0, fmt.Errorf("%w: %s too short", ErrInvalidHeader, box.fourCC[:])
+	}
+
+	timescale := binary.BigEndian.Uint32(payload[off:])
+
+	var duration uint64
+
+	if version == 1 {
+		duration = binary.BigEndian.Uint64(payload[off+4:])
+	} else if d32 := binary.BigEndian.Uint32(payload[off+4:]); d32 != math.MaxUint32 {
+		duration = uint64(d32)
+	}
+
+	if duration == math.MaxUint64 {
+		duration = 0
+	}
+
+	return timescale, duration, nil
+}
+
+// readTrackID reads the track_ID of a tkhd box, which sits where mvhd and mdhd keep their timescale.
+// Layout v0: creation(4) + modification(4) + trackID(4).
+// Layout v1: creation(8) + modification(8) + trackID(4).
+func (p *parser) readTrackID(box *boxInfo) (uint32, error) {
+	return p.readTimescale(box)
+}
+
+// readElst reads an edit list box.
+// Layout: entryCount(4) + entries of segmentDuration(4|8) + mediaTime(4|8) + mediaRate(4).
+func (p *parser) readElst(box *boxInfo) ([]Edit, error) {
+	version, payload, err := p.readFullBoxPayload(box, ErrInvalidElst)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) < 4 {
+		return nil, fmt.Errorf("%w: missing entry count", ErrInvalidElst)
+	}
+
+	count := int(binary.BigEndian.Uint32(payload))
+	payload = payload[4:]
+
+	entryBytes := 12
+	if version == 1 {
+		entryBytes = 20
+	}
+
+	if count > len(payload)/entryBytes {
+		return nil, fmt.Errorf("%w: %d entries in %d bytes", ErrInvalidElst, count, len(payload))
+	}
+
+	edits := make([]Edit, count)
+
+	for idx := range edits {
+		entry := payload[idx*entryBytes:]
+
+		if version == 1 {
+			edits[idx].SegmentDuration = binary.BigEndian.Uint64(entry)
+			edits[idx].MediaTime = int64(binary.BigEndian.Uint64(entry[8:]))
+			entry = entry[16:]
+		} else {
+			edits[idx].SegmentDuration = uint64(binary.BigEndian.Uint32(entry))
+			edits[idx].MediaTime = int64(int32(binary.BigEndian.Uint32(entry[4:])))
+			entry = entry[8:]
+		}
+
+		edits[idx].MediaRate = int32(binary.BigEndian.Uint32(entry))
+	}
+
+	return edits, nil
+}
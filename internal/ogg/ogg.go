@@ -0,0 +1,366 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions are bounded by page sizes.
+package ogg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Packet locates one ALAC packet in the packet data of a Track (see Reader).
+type Packet struct {
+	Offset uint64
+	Size   uint32
+}
+
+// Span maps a run of packet data onto the stream.
+type Span struct {
+	Data   uint64 // position in the packet data
+	Offset uint64 // position in the stream
+	Size   uint32
+}
+
+// Track describes the ALAC logical stream located by FindALACTrack.
+type Track struct {
+	// Serial is the bitstream serial number of the logical stream.
+	Serial uint32
+	// Cookie is the identification packet: the ALAC magic cookie.
+	Cookie []byte
+	// Packets lists the audio packets that follow it, in stream order.
+	Packets []Packet
+	// Spans locates the packet data in the stream, in order.
+	Spans []Span
+}
+
+// TraceFunc receives container parsing events as an event name followed by
+// alternating key/value pairs (the log/slog convention).
+type TraceFunc func(event string, args ...any)
+
+// Page layout (RFC 3533 section 6).
+const (
+	capturePattern = "OggS"
+	pageHeaderSize = 27 // up to and including the segment count
+	segmentsOffset = 26
+	serialOffset   = 14
+	flagsOffset    = 5
+
+	flagContinued = 0x01 // the page opens with the rest of a packet
+	flagBOS       = 0x02 // first page of a logical stream
+
+	// segmentMax is the lacing value of a segment the packet continues past.
+	segmentMax = 255
+)
+
+// Magic cookie layout, for recognizing the identification packet.
+const (
+	atomHeaderSize = 12 // size (4) + type (4) + format or version (4)
+	configSize     = 24 // ALACSpecificConfig
+	maxChannels    = 8
+)
+
+// page holds the header of one Ogg page.
+type page struct {
+	offset int64
+	flags  byte
+	serial uint32
+	lacing []byte
+	body   int64 // body start
+	end    int64 // body end
+}
+
+// IsOgg reports whether header, the first bytes of a stream, starts with
+// the capture pattern of an Ogg page.
+func IsOgg(header []byte) bool {
+	return len(header) >= len(capturePattern) && string(header[:len(capturePattern)]) == capturePattern
+}
+
+// FindALACTrack locates the first logical stream whose identification packet
+// (the first packet, alone on its beginning-of-stream page) is an ALAC magic
+// cookie, and lists the packets that follow it. A cookie is recognized in the
+// MP4 form, inside an 'alac' atom optionally preceded by a 'frma' atom, or as
+// a bare ALACSpecificConfig. Packets are reassembled across pages; page CRCs
+// are not checked. A page cut short at the end of the stream ends it, along
+// with the packet it held.
+// trace may be nil.
+func FindALACTrack(reader io.ReadSeeker, trace TraceFunc) (Track, error) {
+	end, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Track{}, fmt.Errorf("seeking to end: %w", err)
+	}
+
+	var (
+		asm   assembler
+		found bool
+		buf   [pageHeaderSize + segmentMax]byte
+	)
+
+	for pos := int64(0); pos < end; {
+		pg, err := readPage(reader, pos, end, &buf)
+		if errors.Is(err, errTruncatedPage) {
+			if trace != nil {
+				trace("page truncated", "offset", pos, "available", end-pos)
+			}
+
+			break
+		}
+
+		if err != nil {
+			return Track{}, err
+		}
+
+		if trace != nil {
+			trace("page", "offset", pg.offset, "serial", pg.serial, "flags", pg.flags, "segments", len(pg.lacing))
+		}
+
+		pos = pg.end
+
+		switch {
+		case !found && pg.flags&flagBOS != 0:
+			cookie, err := readIdentification(reader, &pg)
+			if err != nil {
+				return Track{}, err
+			}
+
+			if cookie == nil {
+				continue
+			}
+
+			found = true
+			asm.track.Serial = pg.serial
+			asm.track.Cookie = cookie
+			asm.skip = 1
+
+			if trace != nil {
+				trace("track selected", "offset", pg.offset, "serial", pg.serial, "cookie_length", len(cookie))
+			}
+
+			asm.addPage(&pg)
+		case found && pg.serial == asm.track.Serial:
+			asm.addPage(&pg)
+		}
+	}
+
+	if !found {
+		return Track{}, ErrNoALACTrack
+	}
+
+	if trace != nil {
+		trace("sample table", "samples", len(asm.track.Packets))
+	}
+
+	return asm.track, nil
+}
+
+// errTruncatedPage reports a page running past the end of the stream.
+var errTruncatedPage = errors.New("ogg: page truncated")
+
+// readPage reads the header of the page at offset, using buf for the fixed
+// fields and the segment table. The returned lacing values alias buf.
+func readPage(reader io.ReadSeeker, offset, end int64, buf *[pageHeaderSize + segmentMax]byte) (page, error) {
+	if end-offset < pageHeaderSize {
+		return page{}, errTruncatedPage
+	}
+
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		return page{}, fmt.Errorf("seeking to page: %w", err)
+	}
+
+	if _, err := io.ReadFull(reader, buf[:pageHeaderSize]); err != nil {
+		return page{}, fmt.Errorf("reading page header: %w", err)
+	}
+
+	if !IsOgg(buf[:]) || buf[4] != 0 {
+		return page{}, fmt.Errorf("%w: no version 0 capture pattern at offset %d", ErrInvalidPage, offset)
+	}
+
+	segments := int(buf[segmentsOffset])
+	lacing := buf[pageHeaderSize : pageHeaderSize+segments]
+
+	if end-offset < int64(pageHeaderSize+segments) {
+		return page{}, errTruncatedPage
+	}
+
+	if _, err := io.ReadFull(reader, lacing); err != nil {
+		return page{}, fmt.Errorf("reading segment table: %w", err)
+	}
+
+	body := offset + int64(pageHeaderSize+segments)
+	size := int64(0)
+
+	for _, lace := range lacing {
+		size += int64(lace)
+	}
+
+	if body+size > end {
+		return page{}, errTruncatedPage
+	}
+
+	return page{
+		offset: offset,
+		flags:  buf[flagsOffset],
+		serial: binary.LittleEndian.Uint32(buf[serialOffset:]),
+		lacing: lacing,
+		body:   body,
+		end:    body + size,
+	}, nil
+}
+
+// readIdentification returns the first packet of a beginning-of-stream page
+// if it is an ALAC magic cookie, or nil.
+func readIdentification(reader io.ReadSeeker, pg *page) ([]byte, error) {
+	size := 0
+
+	for idx, lace := range pg.lacing {
+		size += int(lace)
+
+		if lace < segmentMax {
+			break
+		}
+
+		if idx == len(pg.lacing)-1 {
+			return nil, nil // continues on a later page: not a valid identification packet
+		}
+	}
+
+	packet := make([]byte, size)
+
+	if _, err := reader.Seek(pg.body, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to identification packet: %w", err)
+	}
+
+	if _, err := io.ReadFull(reader, packet); err != nil {
+		return nil, fmt.Errorf("reading identification packet: %w", err)
+	}
+
+	if !isALACCookie(packet) {
+		return nil, nil
+	}
+
+	return packet, nil
+}
+
+// isALACCookie reports whether packet is an ALAC magic cookie: one holding an
+// 'alac' atom, after an optional 'frma' atom, or a bare ALACSpecificConfig of
+// compatible version 0 with a valid bit depth and channel count. Identification
+// packets of other codecs carry their own magic and match neither form.
+func isALACCookie(packet []byte) bool {
+	if len(packet) >= atomHeaderSize && string(packet[4:8]) == "frma" {
+		packet = packet[atomHeaderSize:]
+	}
+
+	if len(packet) >= atomHeaderSize && string(packet[4:8]) == "alac" {
+		return true
+	}
+
+	if len(packet) < configSize || packet[4] != 0 {
+		return false
+	}
+
+	switch packet[5] { // bit depth
+	case 16, 20, 24, 32:
+		return packet[9] >= 1 && packet[9] <= maxChannels
+	default:
+		return false
+	}
+}
+
+// assembler gathers the packets of the selected logical stream, page by page.
+type assembler struct {
+	track Track
+	size  uint64 // packet data gathered so far
+	open  bool   // a packet continues on the next page
+	start uint64 // packet data offset of the open packet
+	skip  int    // packets still to leave out: the identification packet, or one begun before the stream was joined
+}
+
+// addPage adds the packets, whole or in part, that page holds.
+func (a *assembler) addPage(pg *page) {
+	continued := pg.flags&flagContinued != 0
+
+	switch {
+	case continued && !a.open && a.skip == 0:
+		a.skip = 1 // the rest of a packet whose start was never seen
+	case !continued && a.open:
+		a.open = false // the packet was abandoned: drop it
+	}
+
+	pos := pg.body
+	size := 0
+
+	for idx, lace := range pg.lacing {
+		size += int(lace)
+
+		if lace == segmentMax && idx < len(pg.lacing)-1 {
+			continue
+		}
+
+		a.addPiece(pos, size, lace < segmentMax)
+		pos += int64(size)
+		size = 0
+	}
+}
+
+// addPiece adds size bytes of packet data at offset in the stream, which
+// complete the current packet when complete is set.
+func (a *assembler) addPiece(offset int64, size int, complete bool) {
+	if a.skip > 0 {
+		if complete {
+			a.skip--
+		}
+
+		return
+	}
+
+	if !a.open {
+		a.open = true
+		a.start = a.size
+	}
+
+	if size > 0 {
+		a.addSpan(uint64(offset), uint32(size))
+	}
+
+	if !complete {
+		return
+	}
+
+	a.open = false
+
+	// Empty packets carry nothing to decode; ALAC packets are far below 4 GiB.
+	if packetSize := a.size - a.start; packetSize > 0 && packetSize <= math.MaxUint32 {
+		a.track.Packets = append(a.track.Packets, Packet{Offset: a.start, Size: uint32(packetSize)})
+	}
+}
+
+// addSpan maps the next size bytes of packet data to offset in the stream,
+// extending the last span when the two are contiguous.
+func (a *assembler) addSpan(offset uint64, size uint32) {
+	spans := a.track.Spans
+
+	if n := len(spans); n > 0 && spans[n-1].Offset+uint64(spans[n-1].Size) == offset &&
+		uint64(spans[n-1].Size)+uint64(size) <= math.MaxUint32 {
+		spans[n-1].Size += size
+	} else {
+		a.track.Spans = append(spans, Span{Data: a.size, Offset: offset, Size: size})
+	}
+
+	a.size += uint64(size)
+}
@@ -0,0 +1,129 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package ogg
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Reader reads the packet data of a Track: its packets laid end to end,
+// without the page headers that split them in the stream. Track packet
+// offsets are positions in this data. ReadAt goes through the stream's own
+// ReadAt when it has one, so that it is safe for concurrent use if that is;
+// otherwise it seeks and reads, like Read.
+type Reader struct {
+	src   io.ReadSeeker
+	spans []Span
+	size  int64 // packet data length
+	pos   int64 // Read position
+}
+
+// NewReader returns a Reader over the packet data that spans locate in src.
+func NewReader(src io.ReadSeeker, spans []Span) *Reader {
+	var size int64
+	if n := len(spans); n > 0 {
+		size = int64(spans[n-1].Data) + int64(spans[n-1].Size)
+	}
+
+	return &Reader{src: src, spans: spans, size: size}
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+
+	if n > 0 && err == io.EOF { //nolint:errorlint // ReadAt returns io.EOF unwrapped.
+		err = nil // reported by the next Read
+	}
+
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return r.pos, fmt.Errorf("%w: %d", ErrUnsupportedSeek, whence)
+	}
+
+	if offset < 0 {
+		return r.pos, ErrNegativeOffset
+	}
+
+	r.pos = offset
+
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	// First span ending past off.
+	idx := sort.Search(len(r.spans), func(i int) bool {
+		return int64(r.spans[i].Data)+int64(r.spans[i].Size) > off
+	})
+
+	total := 0
+
+	for ; total < len(p) && idx < len(r.spans); idx++ {
+		span := r.spans[idx]
+		skip := off + int64(total) - int64(span.Data)
+		chunk := p[total:min(len(p), total+int(int64(span.Size)-skip))]
+
+		n, err := r.readStream(chunk, int64(span.Offset)+skip)
+		total += n
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if total < len(p) {
+		return total, io.EOF
+	}
+
+	return total, nil
+}
+
+// readStream fills p from the stream at off.
+func (r *Reader) readStream(p []byte, off int64) (int, error) {
+	if at, ok := r.src.(io.ReaderAt); ok {
+		n, err := at.ReadAt(p, off)
+		if n == len(p) {
+			return n, nil // io.ReaderAt may report io.EOF alongside a full read
+		}
+
+		return n, err //nolint:wrapcheck // io.ReaderAt contract: io.EOF is returned unwrapped.
+	}
+
+	if _, err := r.src.Seek(off, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seeking to packet data: %w", err)
+	}
+
+	return io.ReadFull(r.src, p) //nolint:wrapcheck // io.ReaderAt contract: io.EOF is returned unwrapped.
+}
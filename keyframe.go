@@ -0,0 +1,44 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import "sort"
+
+// IsKeyFrame reports whether packet i (0-based, in decode order) can be
+// decoded without decoding earlier packets first, so that seeking to it
+// produces correct output immediately.
+//
+// Every ALAC packet is independently decodable, so this is true for every
+// packet of a well-formed file. It is false only where the container's roll or
+// pre-roll sample groups (sbgp/sgpd) declare a non-zero roll distance, and for
+// out-of-range indices. Generic seeking layers can rely on it rather than
+// assuming codec behavior.
+func (s *Decoder) IsKeyFrame(i int) bool {
+	if i < 0 || i >= len(s.samples) {
+		return false
+	}
+
+	// Last range starting at or before i.
+	idx := sort.Search(len(s.preroll), func(n int) bool { return int64(s.preroll[n].First) > int64(i) }) - 1
+	if idx < 0 {
+		return true
+	}
+
+	run := s.preroll[idx]
+
+	return int64(i) >= int64(run.First)+int64(run.Count)
+}
@@ -0,0 +1,133 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"slices"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// MaxWarnings is the number of warnings a decoder keeps; later ones are dropped.
+const MaxWarnings = 64
+
+// Warning describes a bitstream or configuration irregularity tolerated under
+// WithLenient, a packet size mismatch found under WithPacketSizeCheck, the
+// packet failure that ended the stream under WithStopOnError, or a container
+// inconsistency found when a Decoder is opened.
+type Warning struct {
+	// Packet is the index of the offending packet: its sample table index for
+	// a Decoder, or the number of packets decoded before it for a PacketDecoder.
+	// It is -1 for container and configuration warnings.
+	Packet int
+	// Channel is the output position of the element's first channel, or -1
+	// for packet size, stop, container and configuration warnings.
+	Channel int
+	// Err is the error strict decoding would have returned.
+	Err error
+}
+
+// WithLenient tolerates bitstream irregularities that do not affect the audio,
+// such as nonzero unused element header bits set by some encoders. Each one is
+// recorded as a Warning, available from Warnings, instead of failing the
+// packet. Channels a packet's elements end before are output as silence. A
+// configuration with a zero FrameLength is likewise accepted as
+// DefaultFrameLength. Strict decoding is the default.
+func WithLenient() Option {
+	return func(o *options) { o.lenient = true }
+}
+
+// Warnings returns the irregularities tolerated so far under WithLenient and
+// the mismatches found under WithPacketSizeCheck, oldest first, up to MaxWarnings.
+func (d *PacketDecoder) Warnings() []Warning {
+	return slices.Clone(d.warnings)
+}
+
+// Warnings returns the container warnings, then the packet warnings recorded
+// so far under WithLenient, WithPacketSizeCheck and WithStopOnError, oldest
+// first, up to MaxWarnings of the latter.
+func (s *Decoder) Warnings() []Warning {
+	return append(slices.Clone(s.warnings), s.dec.warnings...)
+}
+
+// checkUnusedHeader validates the unused element header bits, which must be
+// zero. In lenient mode a violation is recorded as a warning instead.
+func (d *PacketDecoder) checkUnusedHeader(unused uint32, chanIdx int) error {
+	if unused == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: unused header bits 0x%03x", alacint.ErrInvalidHeader, unused)
+	if !d.lenient {
+		return err
+	}
+
+	d.warn(chanIdx, err)
+
+	return nil
+}
+
+// checkChannelCount checks that the elements of a packet carried every
+// configured channel: an END element or a channel pair arriving early leaves
+// the remaining ones undecoded, still holding an earlier packet's samples.
+// Under WithLenient, those channels are zeroed in output and a warning is
+// recorded against the first of them.
+func (d *PacketDecoder) checkChannelCount(output []byte, decoded int, numSamples uint32) error {
+	numChan := int(d.config.NumChannels)
+	if decoded >= numChan {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %w: elements carry %d channels, configuration %d",
+		ErrDecode, alacint.ErrChannelCount, decoded, numChan)
+	if !d.lenient {
+		return err
+	}
+
+	missing := d.layoutOffsets[numChan-1][decoded:numChan]
+	d.warn(missing[0], err)
+
+	if d.validateOnly {
+		return nil
+	}
+
+	if d.planar {
+		for _, ch := range missing {
+			clear([2][]int32{d.mixBufferU, d.mixBufferV}[ch][:numSamples])
+		}
+
+		return nil
+	}
+
+	for frame := range int(numSamples) {
+		for _, ch := range missing {
+			off := (frame*numChan + ch) * d.frameBytes
+			clear(output[off : off+d.frameBytes])
+		}
+	}
+
+	return nil
+}
+
+// warn records a warning against the current packet, dropping it once
+// MaxWarnings are held.
+func (d *PacketDecoder) warn(chanIdx int, err error) {
+	if len(d.warnings) < MaxWarnings {
+		d.warnings = append(d.warnings, Warning{Packet: d.packet, Channel: chanIdx, Err: err})
+	}
+}
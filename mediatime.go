@@ -0,0 +1,74 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions are bounded by MP4 atom sizes.
+package alac
+
+import (
+	"math"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// WithMediaTime makes Seek and Position use raw media time, where zero is the
+// first frame of the first packet, instead of presentation time.
+//
+// By default, when the track's edit list starts presentation partway into
+// the media (a non-zero media_time, typically skipping encoder priming),
+// Seek and Position are expressed in presentation time: Seek(0) lands on the
+// first presented frame, and Position is negative while priming frames are
+// being read. Empty edits do not shift presentation time.
+func WithMediaTime() Option {
+	return func(o *options) { o.mediaTime = true }
+}
+
+// presentationOffset returns the media frame presented at time zero: the
+// start of the first media edit, converted from the media timescale to frames.
+func presentationOffset(edits []mp4int.Edit, mediaTimescale, sampleRate uint32) int64 {
+	for _, edit := range edits {
+		if edit.Empty() {
+			continue
+		}
+
+		if edit.MediaTime <= 0 {
+			return 0
+		}
+
+		if mediaTimescale == 0 || mediaTimescale == sampleRate {
+			return edit.MediaTime
+		}
+
+		return int64(min(mulDiv(uint64(edit.MediaTime), uint64(sampleRate), uint64(mediaTimescale)), math.MaxInt64))
+	}
+
+	return 0
+}
+
+// presentedFrames returns the length of the first media edit in frames, the
+// part of the media a gapless player presents, or 0 without a media edit.
+func presentedFrames(edits []mp4int.Edit, movieTimescale, sampleRate uint32) int64 {
+	if movieTimescale == 0 {
+		return 0
+	}
+
+	for _, edit := range edits {
+		if !edit.Empty() {
+			return int64(mulDiv(edit.SegmentDuration, uint64(sampleRate), uint64(movieTimescale)) & math.MaxInt64)
+		}
+	}
+
+	return 0
+}
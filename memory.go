@@ -0,0 +1,49 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"reflect"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// MemoryFootprint returns the bytes held by the decoder's buffers: the
+// per-channel mixing, predictor and shift buffers, the bit reader and
+// conversion buffers, the decoded PCM buffer, the packet read buffer, and the
+// sample table. It counts capacity, not length, so it reflects what the
+// decoder actually retains. The packet buffer grows to the largest packet
+// read; every other buffer is sized when the decoder is created.
+func (s *Decoder) MemoryFootprint() int {
+	return s.dec.memoryFootprint() +
+		cap(s.buf) +
+		cap(s.packetBuf) +
+		cap(s.samples)*int(reflect.TypeFor[mp4int.SampleInfo]().Size())
+}
+
+// memoryFootprint returns the bytes held by the packet decoder's buffers.
+func (d *PacketDecoder) memoryFootprint() int {
+	const (
+		int32Bytes  = 4
+		uint16Bytes = 2
+	)
+
+	return (cap(d.mixBufferU)+cap(d.mixBufferV)+cap(d.predictor))*int32Bytes +
+		cap(d.shiftBuffer)*uint16Bytes +
+		cap(d.bits.Buf) +
+		cap(d.stageBuf) + cap(d.selectBuf)
+}
@@ -0,0 +1,223 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+// MultiDecoder plays a sequence of ALAC files back to back as one continuous
+// stream, such as an album for gapless playback.
+type MultiDecoder struct {
+	files      []multiFile
+	format     PCMFormat
+	frameBytes int64
+	frames     int64 // presented frames across all files
+
+	current int   // index of the file being read; len(files) at the end
+	left    int64 // bytes of the current file's presented range not yet read
+}
+
+// multiFile is one file of a MultiDecoder and the range of it that is presented.
+type multiFile struct {
+	dec    *Decoder
+	offset int64 // first frame of the file on the combined timeline
+	start  int64 // first presented media frame
+	frames int64 // presented frames
+}
+
+// NewMultiDecoder opens readers as consecutive parts of a single stream. All
+// must decode to the same PCMFormat; ErrConfig is returned otherwise.
+//
+// Each file is trimmed to the media its edit list presents, dropping encoder
+// priming and final padding, so the files join without gaps. A file without
+// an edit list is played whole. The options apply to every file;
+// WithMediaTime and WithEditListSilence have no effect.
+//
+// Every file is opened and its final packet decoded upfront, to measure it.
+func NewMultiDecoder(readers []io.ReadSeeker, opts ...Option) (*MultiDecoder, error) {
+	if len(readers) == 0 {
+		return nil, fmt.Errorf("%w: no files", ErrConfig)
+	}
+
+	opts = append(slices.Clip(opts), WithMediaTime(), func(o *options) { o.editListSilence = false })
+
+	multi := &MultiDecoder{files: make([]multiFile, len(readers))}
+
+	for idx, reader := range readers {
+		dec, err := NewDecoder(reader, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: %w", idx, err)
+		}
+
+		if idx == 0 {
+			multi.format = dec.Format()
+			multi.frameBytes = int64(multi.format.BytesPerFrame())
+		} else if format := dec.Format(); format != multi.format {
+			return nil, fmt.Errorf("%w: file %d is %v, file 0 is %v", ErrConfig, idx, format, multi.format)
+		}
+
+		// Skipping to the end decodes only the final packet.
+		total, err := dec.Skip(math.MaxInt64)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("file %d: %w", idx, err)
+		}
+
+		start := min(dec.editStart, total)
+		frames := total - start
+
+		if dec.editFrames > 0 {
+			frames = min(frames, dec.editFrames)
+		}
+
+		multi.files[idx] = multiFile{dec: dec, offset: multi.frames, start: start, frames: frames}
+		multi.frames += frames
+	}
+
+	if err := multi.position(0, 0); err != nil {
+		return nil, err
+	}
+
+	return multi, nil
+}
+
+// Format returns the PCM output format shared by all files.
+func (m *MultiDecoder) Format() PCMFormat { return m.format }
+
+// Duration returns the combined presented duration of all files.
+func (m *MultiDecoder) Duration() time.Duration {
+	return m.frameTime(m.frames)
+}
+
+// Position returns the time of the next frame Read returns.
+func (m *MultiDecoder) Position() time.Duration {
+	return m.frameTime(m.frame())
+}
+
+// Read reads decoded PCM bytes, continuing into the next file at the end of
+// each one. It returns io.EOF after the last file.
+func (m *MultiDecoder) Read(p []byte) (int, error) { //nolint:varnamelen // p is idiomatic for io.Reader.Read
+	total := 0
+
+	for len(p) > 0 {
+		if m.left == 0 {
+			if m.current >= len(m.files)-1 {
+				m.current = len(m.files)
+
+				break
+			}
+
+			if err := m.position(m.current+1, 0); err != nil {
+				return total, err
+			}
+
+			continue
+		}
+
+		n, err := m.files[m.current].dec.Read(p[:min(int64(len(p)), m.left)])
+		m.left -= int64(n)
+		total += n
+		p = p[n:]
+
+		if err == io.EOF { //nolint:errorlint // io.Reader contract: io.EOF is returned unwrapped.
+			// The file ended short of its measured length: move on.
+			m.left = 0
+
+			continue
+		}
+
+		// The file's decoder reports the error again on the next call.
+		if err != nil && total > 0 {
+			return total, nil
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("file %d: %w", m.current, err)
+		}
+	}
+
+	if total == 0 && m.current >= len(m.files) {
+		return 0, io.EOF
+	}
+
+	return total, nil
+}
+
+// Seek positions the stream at time t on the combined timeline, in the file
+// presenting it, and returns the time of the frame it landed on. Seeking past
+// the end positions at the end; seeking to a negative time positions at the start.
+func (m *MultiDecoder) Seek(t time.Duration) (time.Duration, error) {
+	target := min(timeFrame(t, int64(m.format.SampleRate)), m.frames)
+
+	if target == m.frames {
+		m.current = len(m.files)
+		m.left = 0
+
+		return m.Duration(), nil
+	}
+
+	// The last file starting at or before target; empty files are passed over.
+	idx := sort.Search(len(m.files), func(idx int) bool { return m.files[idx].offset > target }) - 1
+
+	if err := m.position(idx, target-m.files[idx].offset); err != nil {
+		return m.Position(), err
+	}
+
+	return m.frameTime(target), nil
+}
+
+// position makes file idx current, at frame within its presented range.
+func (m *MultiDecoder) position(idx int, frame int64) error {
+	file := &m.files[idx]
+
+	m.current = idx
+	m.left = 0
+
+	if _, err := file.dec.Seek(0); err != nil {
+		return fmt.Errorf("file %d: %w", idx, err)
+	}
+
+	if _, err := file.dec.Skip(file.start + frame); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("file %d: %w", idx, err)
+	}
+
+	m.left = (file.frames - frame) * m.frameBytes
+
+	return nil
+}
+
+// frame returns the combined timeline frame of the next frame Read returns.
+func (m *MultiDecoder) frame() int64 {
+	if m.current >= len(m.files) {
+		return m.frames
+	}
+
+	file := m.files[m.current]
+
+	return file.offset + file.frames - (m.left+m.frameBytes-1)/m.frameBytes
+}
+
+// frameTime converts a frame count to a duration.
+func (m *MultiDecoder) frameTime(frames int64) time.Duration {
+	return time.Duration(frames * int64(time.Second) / int64(m.format.SampleRate))
+}
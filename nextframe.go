@@ -0,0 +1,71 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// NextFrameSize returns the number of bytes the next packet-aligned read
+// produces: the unread remainder of the current packet, or else the whole
+// next packet, as ReadZeroCopy and Frames return it. A Read into a buffer of
+// exactly that size then consumes one packet, which lets block processors
+// follow the codec's frames without re-chunking. It returns 0 at the end of
+// the stream or once decoding has failed.
+//
+// The next packet is sized from the sample table without decoding it, after
+// WithTrimStart, WithTrimEnd and WithPaddedFinalFrame. The final packet is
+// taken from stts, or as FrameLength frames without it; a packet decoding to
+// another length than the table gives still reports the decoded length once
+// buffered. Silence from WithEditListSilence is not counted.
+func (s *Decoder) NextFrameSize() int {
+	if s.bufOff < len(s.buf) {
+		return len(s.buf) - s.bufOff
+	}
+
+	if s.err != nil || s.eof {
+		return 0
+	}
+
+	idx := s.sampleIdx
+	last := len(s.samples) - 1
+
+	for s.trimmed() && idx < last && s.packetFrame(idx+1) <= s.trimStart {
+		idx++
+	}
+
+	if idx > last {
+		return 0
+	}
+
+	first := s.packetFrame(idx)
+
+	end := s.packetFrame(idx + 1)
+	if idx == last {
+		end = s.streamEnd()
+
+		if s.padFinal {
+			end = first + int64(s.dec.config.FrameLength)
+		}
+	}
+
+	if s.trimmed() {
+		first = max(first, s.trimStart)
+
+		if !s.padFinal || idx < last {
+			end = min(end, s.trimLimit(s.streamEnd()))
+		}
+	}
+
+	return int(max(end-first, 0)) * s.dec.format.Channels * s.dec.sampleBytes
+}
@@ -0,0 +1,88 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// Option configures a Decoder or PacketDecoder.
+type Option func(*options)
+
+// DefaultMaxFrameLength is the largest FrameLength accepted unless overridden
+// with WithMaxFrameLength. Encoders use 4096; the margin covers unusual but
+// legitimate streams while keeping per-decoder buffers to a few hundred KiB.
+const DefaultMaxFrameLength = 16384
+
+// options holds the settings collected from Option values.
+type options struct {
+	trace           func(event string, args ...any)
+	frameParamSink  func(FrameParams)
+	maxFrameLength  uint32
+	sampleFormat    SampleFormat
+	outputBitDepth  int
+	dither          bool
+	ditherSeed      uint64
+	lenient         bool
+	packetSizeCheck bool
+	maxOutputBytes  int64
+	stats           bool
+	entropyStats    bool
+	packetTiming    bool
+	rejectAncillary bool
+	stopOnError     bool
+	trimStart       int64
+	trimEnd         int64
+	scratch         *scratchBuffers
+
+	channelSelection   []int
+	nativeChannelOrder bool
+
+	editListSilence  bool
+	mediaTime        bool
+	paddedFinalFrame bool
+}
+
+// WithTrace installs a callback receiving container parsing events: every box
+// visited, skipped and selected tracks, the magic cookie length and the sample
+// count. Arguments follow the log/slog key/value convention, so a
+// (*slog.Logger).Debug method value can be passed directly.
+// A nil callback disables tracing, which is the default.
+func WithTrace(fn func(event string, args ...any)) Option {
+	return func(o *options) { o.trace = fn }
+}
+
+// WithMaxFrameLength sets the largest FrameLength a configuration may declare.
+// Decoder buffers are sized from FrameLength, so this bounds the memory a
+// hostile magic cookie can make the decoder allocate.
+// The default is DefaultMaxFrameLength.
+func WithMaxFrameLength(frames uint32) Option {
+	return func(o *options) { o.maxFrameLength = frames }
+}
+
+// WithSampleFormat selects the encoding of decoded samples.
+// The default is SampleInt.
+func WithSampleFormat(format SampleFormat) Option {
+	return func(o *options) { o.sampleFormat = format }
+}
+
+// newOptions applies opts over the defaults.
+func newOptions(opts []Option) options {
+	resolved := options{maxFrameLength: DefaultMaxFrameLength}
+
+	for _, opt := range opts {
+		opt(&resolved)
+	}
+
+	return resolved
+}
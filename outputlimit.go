@@ -0,0 +1,61 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import "fmt"
+
+// WithMaxOutputBytes caps the PCM a decoder produces over its lifetime at n
+// bytes, a guard against decompression bombs: a small file whose sample table
+// claims billions of samples. Once n bytes have been produced, Read (and
+// DecodePacket on a PacketDecoder) returns ErrOutputLimitExceeded instead of
+// decoding further packets; the packet that reaches the cap is cut to it, on
+// a sample frame boundary. Silence inserted by WithEditListSilence counts
+// too. Seek does not reset the count. Zero or a negative n means no limit,
+// which is the default.
+func WithMaxOutputBytes(n int64) Option {
+	return func(o *options) { o.maxOutputBytes = n }
+}
+
+// outputLimitReached reports whether the WithMaxOutputBytes budget is spent,
+// in which case no further packet is decoded.
+func (d *PacketDecoder) outputLimitReached() error {
+	if d.maxOutput <= 0 || d.produced < d.maxOutput {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d bytes", ErrOutputLimitExceeded, d.maxOutput)
+}
+
+// chargeOutput counts n decoded bytes against the WithMaxOutputBytes budget
+// and returns how many of them may be delivered.
+func (d *PacketDecoder) chargeOutput(n int) int {
+	if d.maxOutput <= 0 || d.validateOnly {
+		return n
+	}
+
+	if left := d.maxOutput - d.produced; int64(n) > left {
+		frameBytes := int64(d.format.BytesPerFrame())
+		n = int(left / frameBytes * frameBytes)
+		d.produced = d.maxOutput
+
+		return n
+	}
+
+	d.produced += int64(n)
+
+	return n
+}
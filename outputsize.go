@@ -0,0 +1,77 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"math"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// OutputSize returns the number of PCM bytes Read produces over the whole
+// stream: every media frame, plus the silence of WithEditListSilence and the
+// padding of WithPaddedFinalFrame when set. It lets callers preallocate, or
+// reject a file whose sample table claims more than WithMaxOutputBytes would
+// let through, before decoding anything.
+//
+// The size is exact for non-fragmented files: the final packet's length is
+// taken from stts. Without stts, the final packet is counted as a full
+// FrameLength frames. Fragmented streams carry their samples in fragments the
+// moov does not list, so their size is unknown and OutputSize returns -1.
+func (s *Decoder) OutputSize() int64 {
+	if s.info.Container == ContainerFragmentedMP4 {
+		return -1
+	}
+
+	var frames int64
+
+	switch {
+	case len(s.samples) == 0:
+	case s.endFrame > 0 && !s.padFinal:
+		frames = s.endFrame
+	default:
+		frames = s.packetFrame(len(s.samples))
+	}
+
+	frames = max(frames-s.trimStart-s.trimEnd, 0)
+
+	for _, gap := range s.gaps {
+		frames += gap.frames
+	}
+
+	bytesPerFrame := int64(s.dec.format.BytesPerFrame())
+	if frames > math.MaxInt64/bytesPerFrame {
+		return math.MaxInt64
+	}
+
+	return frames * bytesPerFrame
+}
+
+// timeToSampleEnd returns the end of the final packet from stts, converted to
+// frames, or 0 without stts.
+func timeToSampleEnd(track mp4int.Track, sampleRate uint32) int64 {
+	if !track.HasTimeToSample {
+		return 0
+	}
+
+	end := track.TimeToSampleEnd
+	if timescale := uint64(track.MediaTimescale); timescale != 0 && timescale != uint64(sampleRate) {
+		end = mulDiv(end, uint64(sampleRate), timescale)
+	}
+
+	return int64(min(end, math.MaxInt64))
+}
@@ -0,0 +1,58 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// endTagBits is the size of the END element tag.
+const endTagBits = 3
+
+// WithPacketSizeCheck compares the bits each packet's elements consume with
+// the packet's size. Encoders end every packet with an END element and pad it
+// to a byte boundary, so anything else means the sample table and the
+// bitstream disagree: a corrupt stsz entry, or a decoder that has lost sync.
+// Mismatches are recorded as Warnings, available from Warnings; the decoded
+// audio is returned as usual. The check is off by default.
+func WithPacketSizeCheck() Option {
+	return func(o *options) { o.packetSizeCheck = true }
+}
+
+// checkPacketSize records a warning when the bits consumed by the packet's
+// elements, rounded up to a byte, do not cover exactly size bytes. Decoding
+// stops after the last channel element, so an unread END tag is counted.
+func (d *PacketDecoder) checkPacketSize(size int, ended bool) {
+	if used := d.bytesUsed(ended); used != size {
+		d.warn(-1, fmt.Errorf("%w: %d bytes decoded, %d bytes in packet", alacint.ErrPacketSize, used, size))
+	}
+}
+
+// bytesUsed returns the bytes the packet's elements consumed, counting an
+// unread END tag and rounding up to a byte.
+func (d *PacketDecoder) bytesUsed(ended bool) int {
+	pos, bitIdx := d.bits.Position()
+	consumed := pos*8 + bitIdx
+
+	if !ended {
+		consumed += endTagBits
+	}
+
+	return (consumed + 7) / 8
+}
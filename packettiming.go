@@ -0,0 +1,41 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"slices"
+	"time"
+)
+
+// WithPacketTiming makes the Decoder record how long each packet takes to
+// decode, for PacketTimings. It costs one time.Duration per packet in the
+// sample table and is off by default.
+func WithPacketTiming() Option {
+	return func(o *options) { o.packetTiming = true }
+}
+
+// PacketTimings returns the decode time of every packet, indexed like the
+// sample table, so slow packets can be matched to their size and position.
+// Packets not decoded yet, or passed over whole by Skip, report zero; a
+// packet decoded again after a Seek reports its latest time. Time spent
+// reading from the source is not included.
+//
+// Without WithPacketTiming it returns nil. Unlike Stats, it must not be
+// called concurrently with Read.
+func (s *Decoder) PacketTimings() []time.Duration {
+	return slices.Clone(s.timings)
+}
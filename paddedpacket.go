@@ -0,0 +1,51 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// PacketPadding is the number of zero bytes DecodePaddedPacket needs after a
+// packet: the furthest the bit reader may look past its end.
+const PacketPadding = alacint.BitBufferPadding
+
+// DecodePaddedPacket is DecodePacket for a packet already in memory with
+// PacketPadding zero bytes after it, such as a whole mdat read with tail
+// padding: it decodes the first size bytes of padded in place, without the
+// per-packet copy DecodePacket makes. padded is never written. A padded
+// shorter than size+PacketPadding, or padding that is not zero, returns
+// ErrDecode.
+func (d *PacketDecoder) DecodePaddedPacket(padded []byte, size int) ([]byte, error) {
+	if size < 0 || len(padded)-size < PacketPadding {
+		return nil, fmt.Errorf("%w: %d-byte buffer cannot hold a %d-byte packet and %d bytes of padding",
+			ErrDecode, len(padded), size, PacketPadding)
+	}
+
+	for _, b := range padded[size : size+PacketPadding] {
+		if b != 0 {
+			return nil, fmt.Errorf("%w: packet padding is not zero", ErrDecode)
+		}
+	}
+
+	d.noCopy = true
+	defer func() { d.noCopy = false }()
+
+	return d.DecodePacket(padded[:size])
+}
@@ -0,0 +1,52 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// WithPaddedFinalFrame zero-pads the final decoded packet up to FrameLength
+// frames, for fixed-block consumers that need every buffer to be a full frame.
+// FinalPadding reports the number of frames added. By default the final
+// packet is returned at its true, possibly partial, length.
+func WithPaddedFinalFrame() Option {
+	return func(o *options) { o.paddedFinalFrame = true }
+}
+
+// FinalPadding returns the number of silent frames WithPaddedFinalFrame
+// appended to the final packet. It is zero without the option, and until the
+// final packet has been decoded.
+func (s *Decoder) FinalPadding() int {
+	return s.finalPadding
+}
+
+// padFinalFrame extends the freshly decoded final packet in buf to a full frame.
+func (s *Decoder) padFinalFrame() {
+	bytesPerFrame := s.dec.format.Channels * s.dec.sampleBytes
+	full := int(s.dec.config.FrameLength) * bytesPerFrame
+
+	if len(s.buf) >= full {
+		return
+	}
+
+	decoded := len(s.buf)
+	s.finalPadding = (full - decoded) / bytesPerFrame
+	s.buf = s.buf[:full]
+	clear(s.buf[decoded:])
+
+	if s.planarBuf {
+		clear(s.dec.mixBufferU[decoded/bytesPerFrame:])
+		clear(s.dec.mixBufferV[decoded/bytesPerFrame:])
+	}
+}
@@ -0,0 +1,115 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"io"
+
+	mkvint "github.com/mycophonic/saprobe-alac/internal/mkv"
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// SampleInfo locates one ALAC packet in a stream.
+type SampleInfo struct {
+	Offset uint64 // position of the packet's first byte in the stream
+	Size   uint32 // packet length in bytes
+}
+
+// ContainerParser demuxes an ALAC track out of a container, for use with
+// NewDecoderWithParser. Parse returns the track's magic cookie, in any form
+// ParseMagicCookie accepts, and its packets in decode order. Offsets are
+// positions in rs, from which the Decoder then reads the packets.
+type ContainerParser interface {
+	Parse(rs io.ReadSeeker) (cookie []byte, samples []SampleInfo, err error)
+}
+
+// MP4Parser is the built-in M4A/MP4 ContainerParser. Through this interface
+// only the cookie and packets are exposed; NewDecoder also uses the track's
+// timing and edit list.
+type MP4Parser struct{}
+
+// MatroskaParser is the built-in Matroska ContainerParser.
+type MatroskaParser struct{}
+
+// Parse implements ContainerParser.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func (MP4Parser) Parse(rs io.ReadSeeker) ([]byte, []SampleInfo, error) {
+	track, err := mp4int.FindALACTrack(rs, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	samples := make([]SampleInfo, len(track.Samples))
+	for idx, sample := range track.Samples {
+		samples[idx] = SampleInfo{Offset: sample.Offset, Size: sample.Size}
+	}
+
+	return track.Cookie, samples, nil
+}
+
+// Parse implements ContainerParser.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func (MatroskaParser) Parse(rs io.ReadSeeker) ([]byte, []SampleInfo, error) {
+	track, err := mkvint.FindALACTrack(rs, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	samples := make([]SampleInfo, len(track.Packets))
+	for idx, packet := range track.Packets {
+		samples[idx] = SampleInfo{Offset: packet.Offset, Size: packet.Size}
+	}
+
+	return track.Cookie, samples, nil
+}
+
+// NewDecoderWithParser is like NewDecoder, but leaves demuxing to parser, so
+// that containers this package does not support can reuse its decoder.
+// Every packet but the last is taken to hold FrameLength frames; the stream
+// has no edit list, and Info reports ContainerUnknown. Parse errors are
+// wrapped with ErrNoTrack.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func NewDecoderWithParser(rs io.ReadSeeker, parser ContainerParser, opts ...Option) (*Decoder, error) {
+	cookie, samples, err := parser.Parse(rs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoTrack, err)
+	}
+
+	config, err := parseMagicCookie(cookie, newOptions(opts).lenient)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ALAC config: %w", err)
+	}
+
+	track := mp4int.Track{Cookie: cookie, Samples: make([]mp4int.SampleInfo, len(samples))}
+	for idx, sample := range samples {
+		track.Samples[idx] = mp4int.SampleInfo{Offset: sample.Offset, Size: sample.Size}
+	}
+
+	decoder, err := newDecoder(track, config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder.reader = rs
+	decoder.info.Container = ContainerUnknown
+
+	return decoder, nil
+}
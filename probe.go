@@ -0,0 +1,110 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// ProbeFormat returns the PCM format NewDecoder would report for an M4A/MP4
+// stream, parsing only enough of the container to read the magic cookie.
+// The sample table is not built, so this is much cheaper than NewDecoder on
+// long files: it is meant for library scanners and metadata indexers.
+// The format is that of the default options (SampleInt at the stream's depth).
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func ProbeFormat(rs io.ReadSeeker) (PCMFormat, error) {
+	cookie, err := mp4int.FindALACCookie(rs, nil)
+	if err != nil {
+		return PCMFormat{}, fmt.Errorf("%w: %w", ErrNoTrack, err)
+	}
+
+	config, err := ParseMagicCookie(cookie)
+	if err != nil {
+		return PCMFormat{}, fmt.Errorf("parsing ALAC config: %w", err)
+	}
+
+	return PCMFormat{
+		SampleRate: int(config.SampleRate),
+		BitDepth:   int(config.BitDepth),
+		Channels:   int(config.NumChannels),
+	}, nil
+}
+
+// ProbeResult is the outcome of Probe.
+type ProbeResult struct {
+	Format PCMFormat
+	// Decodable reports whether the first packet parses and its elements
+	// carry the channel count of the configuration.
+	Decodable bool
+	// Reason explains why the stream is not decodable; it is empty when it is.
+	Reason string
+}
+
+// Probe is a stricter ProbeFormat: it also checks the first packet's element
+// structure (element tags, headers and entropy-coded data, without producing
+// PCM), so that a file whose configuration reads fine but whose audio cannot
+// be decoded, such as one with unsupported CCE/PCE elements or a channel
+// count the elements contradict, is reported as not decodable. It builds the
+// sample table, like NewDecoder, but checks a single packet where Validate
+// checks them all.
+//
+// The error is reserved for streams without a usable track or configuration,
+// and for read failures.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func Probe(rs io.ReadSeeker) (ProbeResult, error) {
+	dec, err := NewDecoder(rs)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+
+	result := ProbeResult{Format: dec.Format()}
+
+	if len(dec.samples) == 0 {
+		result.Reason = "no audio packets"
+
+		return result, nil
+	}
+
+	packet, err := dec.loadPacket(0)
+	if err != nil {
+		if !errors.Is(err, ErrTruncatedStream) {
+			return ProbeResult{}, err
+		}
+
+		result.Reason = err.Error()
+
+		return result, nil
+	}
+
+	dec.dec.validateOnly = true
+
+	if err := dec.dec.checkPacket(packet); err != nil {
+		result.Reason = err.Error()
+
+		return result, nil
+	}
+
+	result.Decodable = true
+
+	return result, nil
+}
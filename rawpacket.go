@@ -0,0 +1,44 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+// EachRawPacket calls fn with the index and bytes of every encoded packet of
+// the track, in sample table order, without decoding them. It is meant for
+// remuxing into another container.
+//
+// Packets are read into a single buffer the Decoder reuses: packet is only
+// valid until fn returns, and must be copied to be retained. fn must not
+// modify it, nor call the Decoder's reading methods (Read, ReadZeroCopy,
+// Skip, Seek, Frames), which reuse the same buffer.
+//
+// EachRawPacket stops at the first error returned by fn and returns it
+// unchanged. A packet cut short by the end of the source stops it with an
+// error matching ErrTruncatedStream. The decoding position is not affected.
+func (s *Decoder) EachRawPacket(fn func(i int, packet []byte) error) error {
+	for idx := range s.samples {
+		packet, err := s.loadPacket(idx)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(idx, packet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"io"
+)
+
+// ReaderOffset returns the current position of the underlying reader, for
+// diagnosing a failed decode. Packets are read whole before they are decoded,
+// so after Read it is the end of the last packet fetched: together with the
+// packet index named in the error and the file's sample table, it tells a
+// container problem, such as a packet cut short, from a bitstream one.
+// Calling it between Reads is safe: every packet is fetched after an explicit
+// seek, so the query does not disturb decoding. Decoders opened with
+// NewDecoderAt keep no cursor; they report the end of the last packet read,
+// or 0 before the first. For Ogg, whose pages may split packets, offsets are
+// positions in the packet data, page headers left out.
+func (s *Decoder) ReaderOffset() (int64, error) {
+	if s.readerAt != nil {
+		return s.readAtEnd, nil
+	}
+
+	offset, err := s.reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, fmt.Errorf("querying reader offset: %w", err)
+	}
+
+	return offset, nil
+}
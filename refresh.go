@@ -0,0 +1,122 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"time"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// RefreshSampleTable re-reads the container to pick up the packets a writer
+// has added since the Decoder was opened or last refreshed, so that a
+// recording still in progress can be tailed: once Read returns io.EOF,
+// refresh and read on. The read position is kept.
+//
+// Only decoders opened with NewDecoder or NewDecoderWithConfig on an MP4
+// stream can refresh; others return ErrUnsupportedContainer. What a refresh
+// finds depends on how the file is written:
+//
+//   - A regular MP4 only gains packets when the writer rewrites its moov with
+//     a longer sample table. A moov written once, at the start (faststart) or
+//     at the end, never grows, and refreshing finds nothing new.
+//   - A fragmented MP4 gains the packets of every complete moof at the top
+//     level of the file, after those its moov lists. NewDecoder does not read
+//     fragments, so the first refresh finds all those already written. A
+//     fragment still being written is left for a later refresh. Fragment
+//     packets are counted as FrameLength frames each.
+//
+// The first ALAC track must keep its ID, and the packets already read must
+// keep their place in the table. Duration, OutputSize, Seek and the trims
+// follow the refreshed table; the edit list and ContainerDuration keep the
+// values read when the Decoder was opened. A sticky ErrTruncatedStream is
+// cleared, as the missing bytes may since have been written.
+func (s *Decoder) RefreshSampleTable() error {
+	if s.reader == nil || (s.info.Container != ContainerMP4 && s.info.Container != ContainerFragmentedMP4) {
+		return fmt.Errorf("%w: RefreshSampleTable needs an MP4 stream opened with NewDecoder", ErrUnsupportedContainer)
+	}
+
+	track, _, _, err := findTrack(s.reader, nil)
+	if err != nil {
+		return err
+	}
+
+	if track.ID != s.trackID {
+		return fmt.Errorf("%w: first ALAC track is now %d, was %d", ErrNoTrack, track.ID, s.trackID)
+	}
+
+	config := s.dec.config
+	indexFrames(track, config)
+
+	samples := track.Samples
+	endFrame := timeToSampleEnd(track, config.SampleRate)
+
+	if track.Fragmented {
+		fragments, err := mp4int.FindFragmentSamples(s.reader, track.ID, track.Extends)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrNoTrack, err)
+		}
+
+		if len(fragments) > 0 {
+			samples = appendFragments(samples, fragments, endFrame, config.FrameLength)
+			endFrame = 0
+		}
+	}
+
+	if len(samples) < s.sampleIdx {
+		return fmt.Errorf("%w: sample table shrank to %d packets, %d already read", ErrNoTrack, len(samples), s.sampleIdx)
+	}
+
+	s.samples = samples
+	s.endFrame = endFrame
+	s.constantFrames = constantFrameLength(samples, config.FrameLength)
+	s.preroll = track.PrerollSamples
+	s.err = nil
+
+	if s.timings != nil {
+		s.timings = append(s.timings[:min(len(s.timings), len(samples))],
+			make([]time.Duration, max(len(samples)-len(s.timings), 0))...)
+	}
+
+	if s.bufOff >= len(s.buf) {
+		s.eof = s.sampleIdx >= len(samples)
+	}
+
+	return nil
+}
+
+// appendFragments appends the fragment packets to the moov's samples, which
+// end at endFrame (0 when stts is missing), FrameLength frames each.
+func appendFragments(
+	samples, fragments []mp4int.SampleInfo, endFrame int64, frameLength uint32,
+) []mp4int.SampleInfo {
+	next := uint64(len(samples)) * uint64(frameLength)
+	if len(samples) > 0 {
+		next = samples[len(samples)-1].FirstFrame + uint64(frameLength)
+	}
+
+	if endFrame > 0 {
+		next = uint64(endFrame) //nolint:gosec // endFrame is positive here.
+	}
+
+	for idx := range fragments {
+		fragments[idx].FirstFrame = next + uint64(idx)*uint64(frameLength)
+	}
+
+	return append(samples, fragments...)
+}
@@ -0,0 +1,106 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Frame counts are non-negative and clamped to the MP4 field widths.
+package alac
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	mp4int "github.com/mycophonic/saprobe-alac/internal/mp4"
+)
+
+// Remux copies the ALAC track of src, in any container NewDecoder reads, into
+// a new dstFormat file written to dst, without re-encoding. Packets are copied
+// verbatim, along with the stream configuration, packet durations, edit list
+// and channel layout. Only ContainerMP4 can be written; other formats return
+// ErrUnsupportedContainer.
+//
+// The output is written from the current position of dst, which must be
+// seekable so the mdat size can be patched once every packet is written.
+//
+//nolint:varnamelen // src and dst are idiomatic
+func Remux(src io.ReadSeeker, dst io.WriteSeeker, dstFormat ContainerType) error {
+	if dstFormat != ContainerMP4 {
+		return fmt.Errorf("%w: %v", ErrUnsupportedContainer, dstFormat)
+	}
+
+	dec, err := NewDecoder(src)
+	if err != nil {
+		return err
+	}
+
+	mux, err := mp4int.NewMuxer(dst)
+	if err != nil {
+		return err
+	}
+
+	err = dec.EachRawPacket(func(idx int, packet []byte) error {
+		frames, durationErr := dec.packetDuration(idx, packet)
+		if durationErr != nil {
+			return durationErr
+		}
+
+		return mux.WritePacket(packet, frames)
+	})
+	if err != nil {
+		return err
+	}
+
+	config := dec.dec.config
+	track := mp4int.MuxTrack{
+		Config:     config.MarshalCookie(),
+		SampleRate: config.SampleRate,
+		Channels:   uint16(config.NumChannels),
+		BitDepth:   uint16(config.BitDepth),
+		AvgBitRate: config.AvgBitRate,
+		EditStart:  uint64(dec.editStart),
+		EditFrames: uint64(dec.editFrames),
+		Layout:     dec.layout,
+	}
+
+	// maxBitRate differs from the cookie's average only when it came from a btrt box.
+	if dec.maxBitRate != config.AvgBitRate {
+		track.MaxBitRate = dec.maxBitRate
+		track.BufferBytes = config.MaxFrameBytes
+	}
+
+	return mux.Finish(track)
+}
+
+// packetDuration returns the frame count of packet idx: the distance to the
+// next packet's start, or for the final packet the end of the stream from
+// stts, or else the frames it decodes to.
+func (s *Decoder) packetDuration(idx int, packet []byte) (uint32, error) {
+	start := s.packetFrame(idx)
+
+	if idx+1 < len(s.samples) {
+		return uint32(min(s.packetFrame(idx+1)-start, math.MaxUint32)), nil
+	}
+
+	if s.endFrame > start {
+		return uint32(min(s.endFrame-start, math.MaxUint32)), nil
+	}
+
+	pcm, err := s.dec.DecodePacket(packet)
+	if err != nil {
+		return 0, fmt.Errorf("decoding final packet %d: %w", idx, err)
+	}
+
+	return uint32(len(pcm) / s.dec.format.BytesPerFrame()), nil
+}
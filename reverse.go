@@ -0,0 +1,132 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import "io"
+
+// ReverseDecoder decodes an ALAC stream backward, for reverse playback: Read
+// returns the last frame first and the first frame last, each frame keeping
+// its channels in the usual interleaved order.
+//
+// ALAC packets decode independently, so packets are decoded from the last to
+// the first and the frames of each reversed. The source is read from its end
+// first and then backward, so it must be seekable: a pipe or a network stream
+// without range requests will not do.
+type ReverseDecoder struct {
+	dec  *Decoder
+	next int // packet to decode next; -1 once the first has been decoded
+}
+
+// NewReverseDecoder opens a stream like NewDecoder for backward decoding.
+// Options that shape the samples (bit depth, sample format, channel
+// selection) and the trims of WithTrimStart and WithTrimEnd apply; the
+// silence of WithEditListSilence and the padding of WithPaddedFinalFrame do not.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func NewReverseDecoder(rs io.ReadSeeker, opts ...Option) (*ReverseDecoder, error) {
+	dec, err := NewDecoder(rs, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	dec.padFinal = false
+
+	return &ReverseDecoder{dec: dec, next: len(dec.samples) - 1}, nil
+}
+
+// Format returns the PCM output format.
+func (r *ReverseDecoder) Format() PCMFormat { return r.dec.Format() }
+
+// Read reads decoded PCM bytes, from the end of the stream backward. It
+// returns io.EOF once the first frame has been returned.
+func (r *ReverseDecoder) Read(p []byte) (int, error) { //nolint:varnamelen // p is idiomatic for io.Reader.Read
+	s := r.dec
+	total := 0
+
+	for len(p) > 0 {
+		if s.bufOff < len(s.buf) {
+			n := copy(p, s.buf[s.bufOff:])
+			s.bufOff += n
+			total += n
+			p = p[n:]
+
+			continue
+		}
+
+		if r.next < 0 {
+			if total > 0 {
+				return total, nil
+			}
+
+			return 0, io.EOF
+		}
+
+		// fill fails again on the next call: return the audio already copied first.
+		if err := r.fill(); err != nil {
+			if total > 0 {
+				return total, nil
+			}
+
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// fill decodes packet r.next into the Decoder's buffer, keeping the frames
+// the trims leave, and reverses their order.
+func (r *ReverseDecoder) fill() error {
+	s := r.dec
+	idx := r.next
+	r.next--
+
+	s.sampleIdx = idx
+	s.eof = false
+
+	// fill skips forward past packets the start trim drops whole: those and
+	// every earlier packet hold nothing to return.
+	if err := s.fill(); err != nil || s.sampleIdx != idx+1 {
+		s.buf = s.buf[:0]
+		s.bufOff = 0
+
+		if err == io.EOF { //nolint:errorlint // fill returns io.EOF unwrapped.
+			return nil // dropped by the end trim
+		}
+
+		if err == nil {
+			r.next = -1
+		} else {
+			r.next = idx // retried by the next Read
+		}
+
+		return err
+	}
+
+	reverseFrames(s.buf[s.bufOff:], s.dec.format.BytesPerFrame())
+
+	return nil
+}
+
+// reverseFrames reverses the order of the frames of frameBytes each in pcm, in place.
+func reverseFrames(pcm []byte, frameBytes int) {
+	for lo, hi := 0, len(pcm)-frameBytes; lo < hi; lo, hi = lo+frameBytes, hi-frameBytes {
+		for idx := range frameBytes {
+			pcm[lo+idx], pcm[hi+idx] = pcm[hi+idx], pcm[lo+idx]
+		}
+	}
+}
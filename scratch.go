@@ -0,0 +1,68 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// scratchBuffers holds caller-supplied working buffers (WithScratch).
+type scratchBuffers struct {
+	mixU, mixV, predictor []int32
+	shift                 []uint16
+}
+
+// WithScratch makes a PacketDecoder work in caller-owned buffers instead of
+// allocating its own, so embedders with custom decode loops control those
+// allocations. mixU, mixV and predictor need at least FrameLength elements and
+// shift at least twice that; shorter buffers make NewPacketDecoder return
+// ErrConfig. The buffers must not be shared with another decoder in use.
+func WithScratch(mixU, mixV, predictor []int32, shift []uint16) Option {
+	return func(o *options) {
+		o.scratch = &scratchBuffers{mixU: mixU, mixV: mixV, predictor: predictor, shift: shift}
+	}
+}
+
+// setScratch installs the working buffers, allocating them unless supplied.
+func (d *PacketDecoder) setScratch(scratch *scratchBuffers) error {
+	frameLen := int(d.config.FrameLength)
+
+	if scratch == nil {
+		d.mixBufferU = make([]int32, frameLen)
+		d.mixBufferV = make([]int32, frameLen)
+		d.predictor = make([]int32, frameLen)
+		d.shiftBuffer = make([]uint16, frameLen*2) // stereo worst case
+
+		return nil
+	}
+
+	if len(scratch.mixU) < frameLen || len(scratch.mixV) < frameLen || len(scratch.predictor) < frameLen ||
+		len(scratch.shift) < frameLen*2 {
+		return fmt.Errorf("%w: %w: mix %d/%d, predictor %d, shift %d for frame length %d", ErrConfig,
+			alacint.ErrScratchLength, len(scratch.mixU), len(scratch.mixV), len(scratch.predictor),
+			len(scratch.shift), frameLen)
+	}
+
+	d.mixBufferU = scratch.mixU[:frameLen]
+	d.mixBufferV = scratch.mixV[:frameLen]
+	d.predictor = scratch.predictor[:frameLen]
+	d.shiftBuffer = scratch.shift[:frameLen*2]
+
+	return nil
+}
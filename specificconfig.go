@@ -0,0 +1,99 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import "encoding/binary"
+
+// ALACSpecificConfig is the magic cookie exactly as stored, field for field,
+// following the ALACSpecificConfig structure of Apple's ALAC specification.
+// Unlike PacketConfig it keeps the compatible version, and it is never
+// validated: a format-inspection tool sees what the encoder wrote.
+type ALACSpecificConfig struct {
+	// FrameLength is the number of sample frames per packet; every packet but
+	// the last holds exactly this many (4096 from Apple's encoder).
+	FrameLength uint32
+	// CompatibleVersion is the bitstream version; only 0 is defined, and
+	// decoders reject later ones.
+	CompatibleVersion uint8
+	// BitDepth is the sample size in bits: 16, 20, 24 or 32.
+	BitDepth uint8
+	// PB is the Rice history multiplier, a tuning parameter (40).
+	PB uint8
+	// MB is the initial Rice history, a tuning parameter (10).
+	MB uint8
+	// KB is the Rice parameter limit, a tuning parameter (14).
+	KB uint8
+	// NumChannels is the channel count, 1 to 8.
+	NumChannels uint8
+	// MaxRun is unused by the format; encoders write 255.
+	MaxRun uint16
+	// MaxFrameBytes is the largest packet size in bytes, or 0 when unknown.
+	MaxFrameBytes uint32
+	// AvgBitRate is the average bit rate in bits per second, or 0 when unknown.
+	AvgBitRate uint32
+	// SampleRate is the sample rate in hertz.
+	SampleRate uint32
+}
+
+// SpecificConfig returns the track's magic cookie as stored, without atom
+// wrappers. It is the zero value when the cookie is too short to hold one,
+// which only a decoder from NewDecoderWithConfig can have.
+func (s *Decoder) SpecificConfig() ALACSpecificConfig { return s.specific }
+
+// parseSpecificConfig reads the fields of the configSize bytes at the start of data.
+func parseSpecificConfig(data []byte) ALACSpecificConfig {
+	return ALACSpecificConfig{
+		FrameLength:       binary.BigEndian.Uint32(data[0:4]),
+		CompatibleVersion: data[4],
+		BitDepth:          data[5],
+		PB:                data[6],
+		MB:                data[7],
+		KB:                data[8],
+		NumChannels:       data[9],
+		MaxRun:            binary.BigEndian.Uint16(data[10:12]),
+		MaxFrameBytes:     binary.BigEndian.Uint32(data[12:16]),
+		AvgBitRate:        binary.BigEndian.Uint32(data[16:20]),
+		SampleRate:        binary.BigEndian.Uint32(data[20:24]),
+	}
+}
+
+// specificConfig returns the unwrapped ALACSpecificConfig in cookie, or the
+// zero value if it is too short to hold one.
+func specificConfig(cookie []byte) ALACSpecificConfig {
+	data := skipAtomHeader(skipAtomHeader(cookie, "frma"), "alac")
+	if len(data) < configSize {
+		return ALACSpecificConfig{}
+	}
+
+	return parseSpecificConfig(data)
+}
+
+// packetConfig returns the fields the decoder uses.
+func (c ALACSpecificConfig) packetConfig() PacketConfig {
+	return PacketConfig{
+		FrameLength:   c.FrameLength,
+		BitDepth:      c.BitDepth,
+		PB:            c.PB,
+		MB:            c.MB,
+		KB:            c.KB,
+		NumChannels:   c.NumChannels,
+		MaxRun:        c.MaxRun,
+		MaxFrameBytes: c.MaxFrameBytes,
+		AvgBitRate:    c.AvgBitRate,
+		SampleRate:    c.SampleRate,
+	}
+}
@@ -0,0 +1,75 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DecodeStats reports the work a Decoder has done since it was opened.
+type DecodeStats struct {
+	// PacketsDecoded counts the packets decoded, excluding those that failed.
+	PacketsDecoded int64
+	// FramesDecoded counts the sample frames those packets produced.
+	FramesDecoded int64
+	// BytesOut counts the PCM bytes those packets produced, in the output format.
+	BytesOut int64
+	// DecodeTime is the wall time spent decoding them, excluding reads from the source.
+	DecodeTime time.Duration
+}
+
+// decodeStats accumulates DecodeStats. Counters are atomic so that Stats may
+// be called from a monitoring goroutine while another one reads.
+type decodeStats struct {
+	packets atomic.Int64
+	frames  atomic.Int64
+	bytes   atomic.Int64
+	nanos   atomic.Int64
+}
+
+// WithStats makes the Decoder count the packets, frames and bytes it decodes
+// and the time spent decoding them, for Stats. It is off by default, which
+// keeps timing and atomic updates out of the decode path.
+func WithStats() Option {
+	return func(o *options) { o.stats = true }
+}
+
+// Stats returns the decoding work done so far. It is safe to call
+// concurrently with Read. Without WithStats it returns zero values.
+// Packets decoded again after a Seek are counted again; whole packets that
+// Skip passes over are not decoded and not counted.
+func (s *Decoder) Stats() DecodeStats {
+	if s.stats == nil {
+		return DecodeStats{}
+	}
+
+	return DecodeStats{
+		PacketsDecoded: s.stats.packets.Load(),
+		FramesDecoded:  s.stats.frames.Load(),
+		BytesOut:       s.stats.bytes.Load(),
+		DecodeTime:     time.Duration(s.stats.nanos.Load()),
+	}
+}
+
+// record counts one packet decoded into n bytes in elapsed.
+func (st *decodeStats) record(n, bytesPerFrame int, elapsed time.Duration) {
+	st.packets.Add(1)
+	st.frames.Add(int64(n / bytesPerFrame))
+	st.bytes.Add(int64(n))
+	st.nanos.Add(int64(elapsed))
+}
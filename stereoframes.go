@@ -0,0 +1,154 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"fmt"
+	"io"
+
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+// ReadStereoFrames decodes up to len(dst) frames of a 2-channel stream as
+// left/right pairs, with the sample scale of DecodeAllInt32, and returns the
+// number of frames read. Like Read, it returns io.EOF once the stream is
+// exhausted, and includes the silence of WithEditListSilence. Streams with
+// other channel counts, or float output from WithSampleFormat, return ErrConfig.
+//
+// Samples are copied from the decoder's mixing buffers as unmixed, skipping
+// the byte packing Read does, unless WithOutputBitDepth or
+// WithChannelSelection reshape them.
+func (s *Decoder) ReadStereoFrames(dst [][2]int32) (int, error) {
+	format := s.dec.format
+	if format.Channels != 2 || format.SampleFormat != SampleInt {
+		return 0, fmt.Errorf("%w: ReadStereoFrames requires 2-channel integer output, got %s", ErrConfig, format)
+	}
+
+	// Only frames decoded at the stream's own layout stay in the mixing buffers.
+	s.dec.planar = s.dec.stageBuf == nil
+	defer func() { s.dec.planar = false }()
+
+	bytesPerFrame := format.BytesPerFrame()
+	total := 0
+
+	for total < len(dst) {
+		// Packets and silence gaps are frame-aligned.
+		if s.gapBytes > 0 {
+			charged, err := s.chargeGap(int(min(int64(len(dst)-total), s.gapBytes/int64(bytesPerFrame))) * bytesPerFrame)
+			if err != nil {
+				if total > 0 {
+					return total, nil
+				}
+
+				return 0, err
+			}
+
+			n := charged / bytesPerFrame
+			clear(dst[total : total+n])
+			total += n
+
+			continue
+		}
+
+		if s.startGap(false) {
+			continue
+		}
+
+		if s.bufOff < len(s.buf) {
+			first := s.bufOff / bytesPerFrame
+			n := min(len(dst)-total, s.bufLimit()/bytesPerFrame-first)
+			s.copyStereoFrames(dst[total:total+n], first)
+			s.bufOff += n * bytesPerFrame
+			total += n
+
+			continue
+		}
+
+		if err := s.fill(); err != nil {
+			if err == io.EOF && s.startGap(true) { //nolint:errorlint // fill returns io.EOF unwrapped.
+				continue
+			}
+
+			if total > 0 {
+				return total, nil
+			}
+
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// copyStereoFrames copies the buffered packet's frames from first on into
+// dst, from the mixing buffers or, for a packet decoded by Read, from buf.
+func (s *Decoder) copyStereoFrames(dst [][2]int32, first int) {
+	if !s.planarBuf {
+		bps := s.dec.format.BytesPerSample()
+		readSample := int32SampleReader(s.dec.format)
+
+		for idx := range dst {
+			off := (first + idx) * 2 * bps
+			dst[idx] = [2]int32{readSample(s.buf[off:]), readSample(s.buf[off+bps:])}
+		}
+
+		return
+	}
+
+	// Sign-extend from the stream's bit depth, left-aligning 20-bit samples
+	// in 24 bits as the byte output does.
+	up := 32 - int(s.dec.config.BitDepth)
+	down := up
+
+	if s.dec.config.BitDepth == 20 { //revive:disable-line:add-constant
+		down -= 4
+	}
+
+	left := s.dec.mixBufferU[first : first+len(dst)]
+	right := s.dec.mixBufferV[first : first+len(dst)]
+
+	for idx := range dst {
+		dst[idx] = [2]int32{left[idx] << up >> down, right[idx] << up >> down}
+	}
+}
+
+// packPlanar packs the unread frames of a packet ReadStereoFrames left in
+// the mixing buffers into buf, for the methods that return bytes.
+func (s *Decoder) packPlanar() {
+	if !s.planarBuf {
+		return
+	}
+
+	s.planarBuf = false
+
+	bytesPerFrame := s.dec.format.BytesPerFrame()
+	first := s.bufOff / bytesPerFrame
+	count := len(s.buf)/bytesPerFrame - first
+	out := s.buf[s.bufOff:]
+	left, right := s.dec.mixBufferU[first:], s.dec.mixBufferV[first:]
+
+	switch s.dec.config.BitDepth {
+	case 16:
+		alacint.WriteStereo16(out, left, right, 0, 2, count, 0, 0)
+	case 20:
+		alacint.WriteStereo20(out, left, right, 0, 2, count, 0, 0)
+	case 24:
+		alacint.WriteStereo24(out, left, right, 0, 2, count, 0, 0, nil, 0)
+	default:
+		alacint.WriteStereo32(out, left, right, 0, 2, count, 0, 0, nil, 0)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import "io"
+
+// WithStopOnError ends the stream at the first packet that fails to decode,
+// for best-effort playback of damaged files: Read returns the PCM decoded
+// before it, then io.EOF, instead of an ErrDecode error. The failure is
+// recorded as a Warning, available from Warnings. Edit-list silence due after
+// the failed packet is dropped with the rest of the stream.
+//
+// This sits between strict decoding, the default, which fails on a bad
+// packet, and WithLenient, which decodes through the irregularities it
+// tolerates. Container errors, such as ErrTruncatedStream, still fail.
+func WithStopOnError() Option {
+	return func(o *options) { o.stopOnError = true }
+}
+
+// decodeFailed handles the failure of the current packet: it returns err, or
+// under WithStopOnError records it and ends the stream.
+func (s *Decoder) decodeFailed(err error) error {
+	if !s.stopOnError {
+		return err
+	}
+
+	s.dec.packet = s.sampleIdx // decodePacketInto has moved on
+	s.dec.warn(-1, err)
+	s.eof = true
+	s.gapIdx = len(s.gaps)
+
+	return io.EOF
+}
@@ -0,0 +1,130 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAlacServe_Range verifies that alac-serve answers byte-range requests
+// with the matching slice of the decoded WAV.
+func TestAlacServe_Range(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	serveBin := filepath.Join(tmpDir, "alac-serve")
+
+	build := exec.CommandContext(context.Background(), "go", "build", "-o", serveBin, "./cmd/alac-serve")
+	build.Dir = ".."
+
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build alac-serve: %v\n%s", err, out)
+	}
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, 2)
+	m4aPath := filepath.Join(tmpDir, "input.m4a")
+
+	if err := os.WriteFile(m4aPath, m4a, 0o600); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	serve := exec.CommandContext(ctx, serveBin, "-addr", "127.0.0.1:0", m4aPath)
+
+	stderr, err := serve.StderrPipe()
+	if err != nil {
+		t.Fatalf("stderr pipe: %v", err)
+	}
+
+	if err := serve.Start(); err != nil {
+		t.Fatalf("start alac-serve: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cancel()
+
+		_ = serve.Wait()
+	})
+
+	line, err := bufio.NewReader(stderr).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read listen address: %v", err)
+	}
+
+	url := line[strings.Index(line, "http://") : len(line)-1]
+
+	get := func(rangeHeader string) (*http.Response, []byte) {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", rangeHeader, err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+
+		return resp, body
+	}
+
+	const wavHeaderSize = 44
+
+	_, full := get("")
+	if len(full) != wavHeaderSize+len(pcm) {
+		t.Fatalf("full body: got %d bytes, want %d", len(full), wavHeaderSize+len(pcm))
+	}
+
+	if string(full[:4]) != "RIFF" || !bytes.Equal(full[wavHeaderSize:], pcm) {
+		t.Fatal("full body does not match header plus source PCM")
+	}
+
+	// Odd offsets land mid-frame; the last range straddles the header.
+	for _, span := range [][2]int{{1001, 2999}, {wavHeaderSize + len(pcm) - 7, wavHeaderSize + len(pcm) - 1}, {10, 100}} {
+		resp, body := get(fmt.Sprintf("bytes=%d-%d", span[0], span[1]))
+
+		if resp.StatusCode != http.StatusPartialContent {
+			t.Fatalf("range %v: status %d", span, resp.StatusCode)
+		}
+
+		if !bytes.Equal(body, full[span[0]:span[1]+1]) {
+			t.Fatalf("range %v: body mismatch", span)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac/alactest"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestCompareDecoders(t *testing.T) {
+	t.Parallel()
+
+	const (
+		channels      = 2
+		bytesPerFrame = 3 * channels
+	)
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, channels)
+	frames := len(pcm) / bytesPerFrame
+
+	build := func(pcm []byte) []byte {
+		return testutil.BuildM4A(testutil.SyntheticM4A{SampleRate: 8000, BitDepth: 24, Channels: channels, PCM: pcm})
+	}
+
+	t.Run("identical", func(t *testing.T) {
+		t.Parallel()
+
+		diffs, err := alactest.CompareDecoders(bytes.NewReader(m4a), bytes.NewReader(m4a), 24, channels)
+		if err != nil || len(diffs) != 0 {
+			t.Fatalf("CompareDecoders = %v, %v; want no differences", diffs, err)
+		}
+	})
+
+	t.Run("changed sample", func(t *testing.T) {
+		t.Parallel()
+
+		changed := bytes.Clone(pcm)
+		changed[5000*bytesPerFrame+3] ^= 0x01 // channel 1, low byte
+
+		diffs, err := alactest.CompareDecoders(bytes.NewReader(m4a), bytes.NewReader(build(changed)), 24, channels)
+		if err != nil {
+			t.Fatalf("CompareDecoders: %v", err)
+		}
+
+		if len(diffs) != 1 || diffs[0].Frame != 5000 {
+			t.Fatalf("got %v, want one difference at frame 5000", diffs)
+		}
+
+		if diffs[0].A[0] != diffs[0].B[0] || diffs[0].A[1]^diffs[0].B[1] != 1 {
+			t.Fatalf("unexpected sample detail: %v", diffs[0])
+		}
+	})
+
+	t.Run("shorter stream", func(t *testing.T) {
+		t.Parallel()
+
+		short := build(pcm[:(frames-2)*bytesPerFrame])
+
+		diffs, err := alactest.CompareDecoders(bytes.NewReader(m4a), bytes.NewReader(short), 24, channels)
+		if err != nil {
+			t.Fatalf("CompareDecoders: %v", err)
+		}
+
+		if len(diffs) != 2 || diffs[0].Frame != int64(frames-2) || diffs[0].B != nil || diffs[1].A == nil {
+			t.Fatalf("got %v, want the last two frames missing from b", diffs)
+		}
+	})
+
+	t.Run("many differences", func(t *testing.T) {
+		t.Parallel()
+
+		diffs, err := alactest.CompareDecoders(bytes.NewReader(m4a), bytes.NewReader(build(make([]byte, len(pcm)))), 24, channels)
+		if err != nil {
+			t.Fatalf("CompareDecoders: %v", err)
+		}
+
+		if len(diffs) != alactest.MaxFrameDiffs {
+			t.Fatalf("got %d differences, want %d", len(diffs), alactest.MaxFrameDiffs)
+		}
+	})
+
+	t.Run("format mismatch", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := alactest.CompareDecoders(bytes.NewReader(m4a), bytes.NewReader(m4a), 16, channels)
+		if !errors.Is(err, alactest.ErrFormatMismatch) {
+			t.Fatalf("expected ErrFormatMismatch, got: %v", err)
+		}
+	})
+}
+
+func TestVerifyAgainst(t *testing.T) {
+	t.Parallel()
+
+	const bytesPerFrame = 3 * 2
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, 2)
+
+	// chunks serves reference PCM in pieces that do not align with packets.
+	chunks := func(ref []byte) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			if len(ref) == 0 {
+				return nil, io.EOF
+			}
+
+			n := min(1001, len(ref))
+			chunk := ref[:n]
+			ref = ref[n:]
+
+			return chunk, nil
+		}
+	}
+
+	if err := alactest.VerifyAgainst(bytes.NewReader(m4a), chunks(pcm)); err != nil {
+		t.Fatalf("identical reference: %v", err)
+	}
+
+	changed := bytes.Clone(pcm)
+	changed[5000*bytesPerFrame+4] ^= 0x01
+
+	errRef := errors.New("reference failed")
+
+	for _, tc := range []struct {
+		name string
+		ref  func() ([]byte, error)
+		want error
+		at   string
+	}{
+		{"changed sample", chunks(changed), alactest.ErrMismatch, fmt.Sprintf("frame 5000, byte %d", 5000*bytesPerFrame+4)},
+		{"short reference", chunks(pcm[:len(pcm)-bytesPerFrame]), alactest.ErrMismatch, "reference ends first"},
+		{"long reference", chunks(append(bytes.Clone(pcm), 0)), alactest.ErrMismatch, "decoder ends first"},
+		{"reference error", func() ([]byte, error) { return nil, errRef }, errRef, "reference"},
+	} {
+		err := alactest.VerifyAgainst(bytes.NewReader(m4a), tc.ref)
+		if !errors.Is(err, tc.want) || !strings.Contains(err.Error(), tc.at) {
+			t.Errorf("%s: got %v, want %v at %q", tc.name, err, tc.want, tc.at)
+		}
+	}
+}
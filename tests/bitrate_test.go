@@ -0,0 +1,71 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecoder_MaxBitRate(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+
+	cookie := testutil.Cookie(4096, 16, 2, 8000)
+	binary.BigEndian.PutUint32(cookie[16:20], 256000) // avgBitRate
+
+	btrt := testutil.Box("btrt", testutil.U32(6144), testutil.U32(512000), testutil.U32(300000))
+
+	for _, tc := range []struct {
+		name   string
+		cookie []byte
+		boxes  [][]byte
+		want   uint32
+	}{
+		{"btrt", cookie, [][]byte{btrt}, 512000},
+		{"after another box", cookie, [][]byte{testutil.Box("chan", make([]byte, 12)), btrt}, 512000},
+		{"cookie fallback", cookie, nil, 256000},
+		{"neither", nil, nil, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewDecoder(bytes.NewReader(testutil.BuildM4A(testutil.SyntheticM4A{
+				SampleRate:       8000,
+				BitDepth:         16,
+				Channels:         2,
+				PCM:              pcm,
+				Cookie:           tc.cookie,
+				SampleEntryBoxes: tc.boxes,
+			})))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			if got := dec.MaxBitRate(); got != tc.want {
+				t.Fatalf("MaxBitRate() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
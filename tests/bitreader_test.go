@@ -0,0 +1,134 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac/bitreader"
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+)
+
+func TestBitReader(t *testing.T) {
+	t.Parallel()
+
+	bits := bitreader.New([]byte{0b1010_1100, 0xFF, 0x12, 0x34})
+
+	if got := bits.ReadOne(); got != 1 {
+		t.Fatalf("ReadOne = %d, want 1", got)
+	}
+
+	if got := bits.Read(3); got != 0b010 {
+		t.Fatalf("Read(3) = %#b, want 0b10", got)
+	}
+
+	if byteOff, bitOff := bits.Position(); byteOff != 0 || bitOff != 4 {
+		t.Fatalf("Position = (%d, %d), want (0, 4)", byteOff, bitOff)
+	}
+
+	bits.ByteAlign()
+
+	if byteOff, bitOff := bits.Position(); byteOff != 1 || bitOff != 0 {
+		t.Fatalf("Position after ByteAlign = (%d, %d), want (1, 0)", byteOff, bitOff)
+	}
+
+	if got := bits.Read(4); got != 0xF {
+		t.Fatalf("Read(4) = %#x, want 0xf", got)
+	}
+
+	if got := bits.Read(16); got != 0xF123 {
+		t.Fatalf("Read(16) = %#x, want 0xf123", got)
+	}
+}
+
+func TestBitReader_DSEPayload(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte{0xA5, 0x5A, 0xFF}
+	packet := dsePacket(make([]byte, 16), 8, 1, payload)
+	bits := bitreader.New(packet)
+
+	// FIL element: tag and empty count.
+	if tag := bits.Read(3); tag != tagFIL {
+		t.Fatalf("first element tag %d, want FIL", tag)
+	}
+
+	_ = bits.Read(4)
+
+	if tag := bits.Read(3); tag != tagDSE {
+		t.Fatalf("second element tag %d, want DSE", tag)
+	}
+
+	_ = bits.Read(4) // element instance tag
+	align := bits.ReadOne()
+	count := int(bits.Read(8))
+
+	if align != 0 {
+		bits.ByteAlign()
+	}
+
+	got := make([]byte, count)
+	for idx := range got {
+		got[idx] = byte(bits.Read(8))
+	}
+
+	if string(got) != string(payload) {
+		t.Fatalf("DSE payload %x, want %x", got, payload)
+	}
+}
+
+func TestBitBuffer_ResetNoCopy(t *testing.T) {
+	t.Parallel()
+
+	data := []byte{0xDE, 0xAD, 0xBE, 0xEF, 0x42}
+
+	// The data sits mid-buffer, followed by its zero padding.
+	padded := append([]byte{0x99}, data...)
+	padded = append(padded, make([]byte, alacint.BitBufferPadding)...)
+	original := bytes.Clone(padded)
+
+	var copied, borrowed alacint.BitBuffer
+
+	copied.Reset(data)
+	borrowed.ResetNoCopy(padded[1:], len(data))
+
+	if &borrowed.Buf[0] != &padded[1] {
+		t.Fatal("ResetNoCopy copied the data")
+	}
+
+	for range 2 {
+		if want, got := copied.Read(16), borrowed.Read(16); got != want {
+			t.Fatalf("Read(16) = %#x, want %#x", got, want)
+		}
+	}
+
+	if borrowed.PastEnd() {
+		t.Fatal("PastEnd within the data")
+	}
+
+	// Reusing the reader must leave the caller's buffer alone.
+	borrowed.Reset([]byte{1, 2})
+
+	if !bytes.Equal(padded, original) {
+		t.Fatalf("Reset after ResetNoCopy wrote into the caller's buffer: %x", padded)
+	}
+
+	if got := borrowed.Read(16); got != 0x0102 {
+		t.Fatalf("Read(16) after Reset = %#x, want 0x102", got)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+var errCanceled = errors.New("canceled")
+
+// cancelableReader fails every Read and Seek once canceled is set.
+type cancelableReader struct {
+	*bytes.Reader
+
+	canceled bool
+}
+
+func (c *cancelableReader) Read(p []byte) (int, error) {
+	if c.canceled {
+		return 0, errCanceled
+	}
+
+	return c.Reader.Read(p)
+}
+
+func (c *cancelableReader) Seek(offset int64, whence int) (int64, error) {
+	if c.canceled {
+		return 0, errCanceled
+	}
+
+	return c.Reader.Seek(offset, whence)
+}
+
+func TestRead_DrainsBufferBeforeSourceError(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, 2)
+	src := &cancelableReader{Reader: bytes.NewReader(m4a)}
+
+	dec, err := alac.NewDecoder(src)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// The first Read decodes the whole first packet.
+	got := make([]byte, 1000)
+	if _, err := io.ReadFull(dec, got); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	src.canceled = true
+
+	rest := make([]byte, len(pcm))
+
+	n, err := dec.Read(rest)
+	if err != nil {
+		t.Fatalf("Read after cancel: got error %v, want the buffered packet first", err)
+	}
+
+	const packetBytes = 4096 * 2 * 2
+	if n != packetBytes-len(got) {
+		t.Fatalf("Read after cancel returned %d bytes, want %d", n, packetBytes-len(got))
+	}
+
+	got = append(got, rest[:n]...)
+	if !bytes.Equal(got, pcm[:packetBytes]) {
+		t.Fatal("drained PCM differs from the first packet")
+	}
+
+	for range 2 {
+		if n, err := dec.Read(rest); n != 0 || !errors.Is(err, errCanceled) {
+			t.Fatalf("Read: got (%d, %v), want (0, %v)", n, err, errCanceled)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Parallel()
+
+	if got := alac.SupportedBitDepths(); !slices.Equal(got, []int{16, 20, 24, 32}) {
+		t.Fatalf("SupportedBitDepths() = %v", got)
+	}
+
+	if got := alac.MaxChannels(); got != 8 {
+		t.Fatalf("MaxChannels() = %d, want 8", got)
+	}
+
+	for _, depth := range []int{8, 12, 16, 20, 24, 32, 64} {
+		for channels := range 10 {
+			want := alac.PacketConfig{
+				FrameLength: 4096,
+				BitDepth:    uint8(depth),
+				NumChannels: uint8(channels),
+				SampleRate:  44100,
+			}.Validate() == nil
+
+			if got := alac.IsSupported(depth, channels); got != want {
+				t.Errorf("IsSupported(%d, %d) = %t, want %t", depth, channels, got, want)
+			}
+		}
+	}
+}
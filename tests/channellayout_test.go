@@ -0,0 +1,188 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// Core Audio tags selecting the bitmap and description forms of a chan box.
+const (
+	layoutUseDescriptions = 0
+	layoutUseBitmap       = 1 << 16
+)
+
+// descriptionsChan builds a chan box describing each channel by label, with
+// no flags and zero coordinates.
+func descriptionsChan(labels ...alac.ChannelLabel) []byte {
+	parts := [][]byte{testutil.U32(layoutUseDescriptions), testutil.U32(0), testutil.U32(len(labels))}
+	for _, label := range labels {
+		parts = append(parts, testutil.U32(int(label)), make([]byte, 16))
+	}
+
+	return testutil.FullBox("chan", parts...)
+}
+
+// layoutM4A builds a verbatim-coded M4A with the given chan box.
+func layoutM4A(channels int, chanBox []byte) ([]byte, []byte) {
+	pcm := make([]byte, 1024*2*channels)
+	for idx := range pcm {
+		pcm[idx] = byte(idx * 7)
+	}
+
+	spec := testutil.SyntheticM4A{SampleRate: 8000, BitDepth: 16, Channels: channels, PCM: pcm}
+	if chanBox != nil {
+		spec.SampleEntryBoxes = [][]byte{chanBox}
+	}
+
+	return testutil.BuildM4A(spec), pcm
+}
+
+func TestDecoder_ChannelLayout(t *testing.T) {
+	t.Parallel()
+
+	const (
+		L, R, C, LFE = alac.LabelLeft, alac.LabelRight, alac.LabelCenter, alac.LabelLFE
+		Ls, Rs, Cs   = alac.LabelLeftSurround, alac.LabelRightSurround, alac.LabelCenterSurround
+	)
+
+	tagChan := func(id, channels int) []byte {
+		return testutil.FullBox("chan", testutil.U32(id<<16|channels), testutil.U32(0), testutil.U32(0))
+	}
+
+	bitmapChan := func(bitmap int) []byte {
+		return testutil.FullBox("chan", testutil.U32(layoutUseBitmap), testutil.U32(bitmap), testutil.U32(0))
+	}
+
+	for _, tc := range []struct {
+		name     string
+		channels int
+		chanBox  []byte
+		opts     []alac.Option
+		want     []alac.ChannelLabel
+	}{
+		{name: "default mono", channels: 1, want: []alac.ChannelLabel{C}},
+		{name: "default 5.1", channels: 6, want: []alac.ChannelLabel{L, R, C, LFE, Ls, Rs}},
+		{
+			name: "default 5.1 native", channels: 6, opts: []alac.Option{alac.WithNativeChannelOrder()},
+			want: []alac.ChannelLabel{C, L, R, Ls, Rs, LFE},
+		},
+		{
+			name: "default 5.1 selection", channels: 6, opts: []alac.Option{alac.WithChannelSelection([]int{3, 0})},
+			want: []alac.ChannelLabel{LFE, L},
+		},
+		{name: "tag AAC 6.0", channels: 6, chanBox: tagChan(141, 6), want: []alac.ChannelLabel{L, R, C, Cs, Ls, Rs}},
+		{name: "tag count mismatch", channels: 6, chanBox: tagChan(141, 5), want: []alac.ChannelLabel{L, R, C, LFE, Ls, Rs}},
+		{name: "bitmap quad", channels: 4, chanBox: bitmapChan(0x33), want: []alac.ChannelLabel{L, R, Ls, Rs}},
+		{
+			name: "bitmap quad native", channels: 4, chanBox: bitmapChan(0x33),
+			opts: []alac.Option{alac.WithNativeChannelOrder()}, want: []alac.ChannelLabel{Ls, L, R, Rs},
+		},
+		{name: "bitmap count mismatch", channels: 4, chanBox: bitmapChan(0x3), want: []alac.ChannelLabel{L, R, C, Cs}},
+		{name: "descriptions", channels: 3, chanBox: descriptionsChan(R, L, C), want: []alac.ChannelLabel{L, C, R}},
+		{name: "descriptions repeated", channels: 2, chanBox: descriptionsChan(L, L), want: []alac.ChannelLabel{L, L}},
+		{name: "descriptions count mismatch", channels: 2, chanBox: descriptionsChan(C), want: []alac.ChannelLabel{L, R}},
+	} {
+		m4a, _ := layoutM4A(tc.channels, tc.chanBox)
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a), tc.opts...)
+		if err != nil {
+			t.Fatalf("%s: NewDecoder: %v", tc.name, err)
+		}
+
+		if got := dec.ChannelLayout(); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: ChannelLayout() = %v, want %v", tc.name, got, tc.want)
+		}
+
+		if got, want := dec.HasLFE(), slices.Contains(tc.want, LFE); got != want {
+			t.Errorf("%s: HasLFE() = %t, want %t", tc.name, got, want)
+		}
+	}
+}
+
+// TestDecoder_ChannelLayoutDescriptionsOrder checks that channel descriptions
+// override the default output order: 5.1 described in standard order is
+// output as stored in the bitstream.
+func TestDecoder_ChannelLayoutDescriptionsOrder(t *testing.T) {
+	t.Parallel()
+
+	standard := []alac.ChannelLabel{
+		alac.LabelLeft, alac.LabelRight, alac.LabelCenter,
+		alac.LabelLFE, alac.LabelLeftSurround, alac.LabelRightSurround,
+	}
+
+	m4a, _ := layoutM4A(6, descriptionsChan(standard...))
+
+	described, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	native, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithNativeChannelOrder())
+	if err != nil {
+		t.Fatalf("NewDecoder (native): %v", err)
+	}
+
+	if got := described.ChannelLayout(); !slices.Equal(got, standard) {
+		t.Fatalf("ChannelLayout() = %v, want %v", got, standard)
+	}
+
+	got, err := io.ReadAll(described)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	want, err := io.ReadAll(native)
+	if err != nil {
+		t.Fatalf("ReadAll (native): %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatal("described layout did not keep the bitstream order")
+	}
+
+	// Remuxing keeps the descriptions.
+	remuxed, err := alac.NewDecoder(bytes.NewReader(remux(t, m4a)))
+	if err != nil {
+		t.Fatalf("NewDecoder (remuxed): %v", err)
+	}
+
+	if got := remuxed.ChannelLayout(); !slices.Equal(got, standard) {
+		t.Fatalf("remuxed ChannelLayout() = %v, want %v", got, standard)
+	}
+}
+
+func TestChannelLabel_String(t *testing.T) {
+	t.Parallel()
+
+	for label, want := range map[alac.ChannelLabel]string{
+		alac.LabelLeft:             "L",
+		alac.LabelLFE:              "LFE",
+		alac.LabelRearSurroundLeft: "Rls",
+		alac.ChannelLabel(200):     "label 200",
+	} {
+		if got := label.String(); got != want {
+			t.Errorf("ChannelLabel(%d).String() = %q, want %q", uint32(label), got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecoder_ChannelMode(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		channels  int
+		selection []int
+		native    bool
+		mode      alac.ChannelMode
+		lfe       bool
+	}{
+		{channels: 1, mode: alac.Mono},
+		{channels: 2, mode: alac.Stereo},
+		{channels: 3, mode: alac.Multichannel},
+		{channels: 5, mode: alac.Multichannel},
+		{channels: 6, mode: alac.Multichannel, lfe: true},
+		{channels: 8, mode: alac.Multichannel, lfe: true},
+		{channels: 6, selection: []int{0, 1}, mode: alac.Stereo},
+		{channels: 6, selection: []int{3}, mode: alac.Mono, lfe: true},
+		{channels: 6, selection: []int{3}, native: true, mode: alac.Mono},
+		{channels: 7, selection: []int{6}, native: true, mode: alac.Mono, lfe: true},
+	} {
+		m4a, _ := syntheticM4A(t, 8000, 16, tc.channels)
+
+		opts := []alac.Option{alac.WithChannelSelection(tc.selection)}
+		if tc.native {
+			opts = append(opts, alac.WithNativeChannelOrder())
+		}
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a), opts...)
+		if err != nil {
+			t.Fatalf("%dch %v: NewDecoder: %v", tc.channels, tc.selection, err)
+		}
+
+		if got := dec.ChannelMode(); got != tc.mode {
+			t.Errorf("%dch %v: ChannelMode() = %v, want %v", tc.channels, tc.selection, got, tc.mode)
+		}
+
+		if got := dec.HasLFE(); got != tc.lfe {
+			t.Errorf("%dch %v: HasLFE() = %t, want %t", tc.channels, tc.selection, got, tc.lfe)
+		}
+	}
+}
+
+func TestDecoder_ChannelLayoutName(t *testing.T) {
+	t.Parallel()
+
+	// chan box: FullBox + layoutTag(4) + bitmap(4) + numDescriptions(4).
+	chanBox := func(id, channels int) []byte {
+		return testutil.FullBox("chan", testutil.U32(id<<16|channels), testutil.U32(0), testutil.U32(0))
+	}
+
+	for _, tc := range []struct {
+		channels int
+		chanBox  []byte
+		inCookie bool
+		want     string
+	}{
+		{channels: 1, want: "mono"},
+		{channels: 2, want: "stereo"},
+		{channels: 3, want: "3.0"},
+		{channels: 4, want: "4.0"},
+		{channels: 5, want: "5.0"},
+		{channels: 6, want: "5.1"},
+		{channels: 7, want: "6.1"},
+		{channels: 8, want: "7.1"},
+		{channels: 4, chanBox: chanBox(108, 4), want: "quad"},
+		{channels: 4, chanBox: chanBox(108, 4), inCookie: true, want: "quad"},
+		{channels: 6, chanBox: chanBox(141, 6), want: "6.0"},
+		{channels: 6, chanBox: chanBox(108, 4), want: "5.1"}, // channel count mismatch
+		{channels: 2, chanBox: chanBox(999, 2), want: "stereo"},
+	} {
+		spec := testutil.SyntheticM4A{SampleRate: 8000, BitDepth: 16, Channels: tc.channels}
+		spec.PCM = make([]byte, 1024*2*tc.channels)
+
+		switch {
+		case tc.inCookie:
+			spec.Cookie = append(testutil.Cookie(4096, 16, tc.channels, 8000), tc.chanBox...)
+		case tc.chanBox != nil:
+			spec.SampleEntryBoxes = [][]byte{tc.chanBox}
+		}
+
+		dec, err := alac.NewDecoder(bytes.NewReader(testutil.BuildM4A(spec)))
+		if err != nil {
+			t.Fatalf("%dch: NewDecoder: %v", tc.channels, err)
+		}
+
+		if got := dec.ChannelLayoutName(); got != tc.want {
+			t.Errorf("%dch, chan %x (in cookie: %t): ChannelLayoutName() = %q, want %q",
+				tc.channels, tc.chanBox, tc.inCookie, got, tc.want)
+		}
+	}
+}
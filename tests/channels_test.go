@@ -0,0 +1,295 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestWithChannelSelection(t *testing.T) {
+	t.Parallel()
+
+	const channels = 6
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, channels)
+	frames := len(pcm) / (3 * channels)
+
+	for _, tc := range []struct {
+		name      string
+		selection []int
+		outDepth  int
+	}{
+		{"center only", []int{2}, 0},
+		{"swapped stereo", []int{1, 0}, 0},
+		{"duplicate", []int{3, 3}, 0},
+		{"center to 32 bits", []int{2}, 32},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := []alac.Option{alac.WithChannelSelection(tc.selection)}
+			if tc.outDepth != 0 {
+				opts = append(opts, alac.WithOutputBitDepth(tc.outDepth, false))
+			}
+
+			dec, err := alac.NewDecoder(bytes.NewReader(m4a), opts...)
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			if dec.Format().Channels != len(tc.selection) {
+				t.Fatalf("Format().Channels = %d, want %d", dec.Format().Channels, len(tc.selection))
+			}
+
+			got, err := io.ReadAll(dec)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			sampleBytes := 3
+			if tc.outDepth == 32 {
+				sampleBytes = 4
+			}
+
+			if len(got) != frames*len(tc.selection)*sampleBytes {
+				t.Fatalf("got %d bytes, want %d", len(got), frames*len(tc.selection)*sampleBytes)
+			}
+
+			for frame := range frames {
+				for pos, ch := range tc.selection {
+					src := pcm[(frame*channels+ch)*3:]
+					want := []byte{src[0], src[1], src[2]}
+
+					if sampleBytes == 4 {
+						want = []byte{0, src[0], src[1], src[2]}
+					}
+
+					off := (frame*len(tc.selection) + pos) * sampleBytes
+					if !bytes.Equal(got[off:off+sampleBytes], want) {
+						t.Fatalf("frame %d channel %d: got %x, want %x", frame, ch, got[off:off+sampleBytes], want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWithChannelSelection_OutOfRange(t *testing.T) {
+	t.Parallel()
+
+	config := alac.PacketConfig{FrameLength: 4096, BitDepth: 16, NumChannels: 2, SampleRate: 44100}
+
+	for _, selection := range [][]int{{2}, {0, -1}} {
+		if _, err := alac.NewPacketDecoder(config, alac.WithChannelSelection(selection)); !errors.Is(err, alac.ErrConfig) {
+			t.Errorf("selection %v: expected ErrConfig, got: %v", selection, err)
+		}
+	}
+}
+
+func TestDecode_DualMonoStereo(t *testing.T) {
+	t.Parallel()
+
+	for _, bitDepth := range []int{16, 24} {
+		bps := testutil.BytesPerSample(bitDepth)
+		frameBytes := 2 * bps
+
+		// Distinct channels: left is noise, right is its negation, so a swap
+		// or a duplicated channel shows up.
+		pcm := agar.GenerateWhiteNoise(8000, bitDepth, 2, 1)
+		for off := 0; off < len(pcm); off += frameBytes {
+			right := -testutil.PCMSample(pcm[off:], bitDepth) / 2
+			for idx := range bps {
+				pcm[off+bps+idx] = byte(right >> (8 * idx))
+			}
+		}
+
+		var packets [][]byte
+		for off := 0; off < len(pcm); off += 4096 * frameBytes {
+			chunk := pcm[off:min(off+4096*frameBytes, len(pcm))]
+			packets = append(packets, testutil.EncodeDualMonoPacket(chunk, bitDepth, 4096))
+		}
+
+		got := decodeSynthetic(t, testutil.SyntheticM4A{
+			SampleRate: 8000,
+			BitDepth:   bitDepth,
+			Channels:   2,
+			Packets:    packets,
+			PCM:        pcm,
+		})
+
+		if !bytes.Equal(got, pcm) {
+			t.Fatalf("%d-bit: dual-SCE stereo decoded to %d bytes differing from the %d-byte source",
+				bitDepth, len(got), len(pcm))
+		}
+	}
+}
+
+// TestDecode_EightChannelLayout builds the 7.1 elements by hand, each channel
+// holding a constant naming it, so the check does not rely on the encoder
+// helpers sharing the decoder's mapping table. WithNativeChannelOrder keeps
+// the bitstream order.
+func TestDecode_EightChannelLayout(t *testing.T) {
+	t.Parallel()
+
+	const (
+		tagSCE      = 0
+		tagCPE      = 1
+		tagLFE      = 3
+		tagEND      = 7
+		frameLength = 16
+	)
+
+	// Bitstream order C, Lc, Rc, L, R, Ls, Rs, LFE.
+	const (
+		center = iota + 1
+		leftCenter
+		rightCenter
+		left
+		right
+		leftSurround
+		rightSurround
+		lfe
+	)
+
+	var bw testutil.BitWriter
+
+	for _, element := range []struct {
+		tag    int
+		values []int32
+	}{
+		{tagSCE, []int32{center}},
+		{tagCPE, []int32{leftCenter, rightCenter}},
+		{tagCPE, []int32{left, right}},
+		{tagCPE, []int32{leftSurround, rightSurround}},
+		{tagLFE, []int32{lfe}},
+	} {
+		testutil.WriteElementHeader(&bw, element.tag, frameLength, frameLength)
+
+		for range frameLength {
+			for _, value := range element.values {
+				testutil.WriteEscapeSamples(&bw, value, 16)
+			}
+		}
+	}
+
+	bw.Write(tagEND, 3)
+	bw.ByteAlign()
+
+	for _, tc := range []struct {
+		name string
+		opts []alac.Option
+		want []int32
+	}{
+		// SMPTE 7.1 (wide): L, R, C, LFE, Ls, Rs, Lc, Rc.
+		{"smpte", nil, []int32{left, right, center, lfe, leftSurround, rightSurround, leftCenter, rightCenter}},
+		{
+			"native", []alac.Option{alac.WithNativeChannelOrder()},
+			[]int32{center, leftCenter, rightCenter, left, right, leftSurround, rightSurround, lfe},
+		},
+	} {
+		dec, err := alac.NewPacketDecoder(alac.PacketConfig{
+			FrameLength: frameLength,
+			BitDepth:    16,
+			NumChannels: 8,
+			SampleRate:  8000,
+		}, tc.opts...)
+		if err != nil {
+			t.Fatalf("%s: NewPacketDecoder: %v", tc.name, err)
+		}
+
+		pcm, err := dec.DecodePacket(bw.Bytes())
+		if err != nil {
+			t.Fatalf("%s: DecodePacket: %v", tc.name, err)
+		}
+
+		for frame := range frameLength {
+			for ch, value := range tc.want {
+				if got := testutil.PCMSample(pcm[(frame*8+ch)*2:], 16); got != value {
+					t.Fatalf("%s: frame %d position %d: got channel %d, want %d", tc.name, frame, ch, got, value)
+				}
+			}
+		}
+	}
+}
+
+// TestDecode_EightChannelMatchesFFmpeg encodes SMPTE-ordered 7.1 with ffmpeg
+// and checks that saprobe returns it in the same order, byte for byte.
+func TestDecode_EightChannelMatchesFFmpeg(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not found")
+	}
+
+	const channels = 8
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, channels, 1)
+	dir := t.TempDir()
+
+	srcPath := filepath.Join(dir, "source.raw")
+	if err := os.WriteFile(srcPath, pcm, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	encPath := filepath.Join(dir, "encoded.m4a")
+
+	agar.FFmpegEncode(t, agar.FFmpegEncodeOptions{
+		Src:        srcPath,
+		Dst:        encPath,
+		BitDepth:   16,
+		SampleRate: 8000,
+		Channels:   channels,
+		CodecArgs:  []string{"-c:a", "alac", "-sample_fmt", "s16p"},
+		InputArgs:  []string{"-channel_layout", "7.1(wide)"},
+	})
+
+	m4a, err := os.ReadFile(encPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	want := agar.FFmpegDecode(t, agar.FFmpegDecodeOptions{Src: encPath, BitDepth: 16, Channels: channels})
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("saprobe's %d bytes differ from ffmpeg's %d", len(got), len(want))
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+}
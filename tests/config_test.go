@@ -0,0 +1,159 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// randomConfig returns a random valid PacketConfig.
+func randomConfig(rng *rand.Rand) alac.PacketConfig {
+	depths := []uint8{16, 20, 24, 32}
+
+	return alac.PacketConfig{
+		FrameLength:   1 + rng.Uint32N(alac.DefaultMaxFrameLength),
+		BitDepth:      depths[rng.IntN(len(depths))],
+		NumChannels:   uint8(1 + rng.IntN(8)),
+		PB:            uint8(rng.Uint32()),
+		MB:            uint8(rng.Uint32()),
+		KB:            uint8(rng.Uint32()),
+		MaxRun:        uint16(rng.Uint32()),
+		MaxFrameBytes: rng.Uint32(),
+		AvgBitRate:    rng.Uint32(),
+		SampleRate:    1 + rng.Uint32N(768000),
+	}
+}
+
+func TestMarshalCookie_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // Deterministic test input, not security sensitive.
+
+	for range 1000 {
+		want := randomConfig(rng)
+
+		cookie := want.MarshalCookie()
+		if len(cookie) != 24 {
+			t.Fatalf("MarshalCookie returned %d bytes, want 24", len(cookie))
+		}
+
+		got, err := alac.ParseMagicCookie(cookie)
+		if err != nil {
+			t.Fatalf("ParseMagicCookie(%v): %v", want, err)
+		}
+
+		if got != want {
+			t.Fatalf("round trip: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPacketConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	valid := alac.PacketConfig{FrameLength: 4096, BitDepth: 16, NumChannels: 2, SampleRate: 44100}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate(%v): %v", valid, err)
+	}
+
+	for _, mutate := range []func(*alac.PacketConfig){
+		func(c *alac.PacketConfig) { c.BitDepth = 8 },
+		func(c *alac.PacketConfig) { c.NumChannels = 0 },
+		func(c *alac.PacketConfig) { c.NumChannels = 9 },
+		func(c *alac.PacketConfig) { c.FrameLength = 0 },
+		func(c *alac.PacketConfig) { c.SampleRate = 0 },
+	} {
+		config := valid
+		mutate(&config)
+
+		if err := config.Validate(); !errors.Is(err, alac.ErrConfig) {
+			t.Errorf("Validate(%v): got %v, want ErrConfig", config, err)
+		}
+
+		if _, err := alac.ParseMagicCookie(config.MarshalCookie()); !errors.Is(err, alac.ErrConfig) {
+			t.Errorf("ParseMagicCookie(%v): got %v, want ErrConfig", config, err)
+		}
+	}
+}
+
+func TestDecoder_SpecificConfig(t *testing.T) {
+	t.Parallel()
+
+	want := alac.ALACSpecificConfig{
+		FrameLength:   4096,
+		BitDepth:      24,
+		PB:            40,
+		MB:            10,
+		KB:            14,
+		NumChannels:   2,
+		MaxRun:        255,
+		MaxFrameBytes: 24613,
+		AvgBitRate:    1411200,
+		SampleRate:    8000,
+	}
+
+	config := alac.PacketConfig{
+		FrameLength:   want.FrameLength,
+		BitDepth:      want.BitDepth,
+		NumChannels:   want.NumChannels,
+		PB:            want.PB,
+		MB:            want.MB,
+		KB:            want.KB,
+		MaxRun:        want.MaxRun,
+		MaxFrameBytes: want.MaxFrameBytes,
+		AvgBitRate:    want.AvgBitRate,
+		SampleRate:    want.SampleRate,
+	}
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 8000,
+		BitDepth:   24,
+		Channels:   2,
+		PCM:        agar.GenerateWhiteNoise(8000, 24, 2, 1),
+		Cookie:     config.MarshalCookie(),
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got := dec.SpecificConfig(); got != want {
+		t.Fatalf("SpecificConfig = %+v, want %+v", got, want)
+	}
+
+	// An override config does not change what the file stores.
+	override := config
+	override.MaxFrameBytes = 0
+
+	dec, err = alac.NewDecoderWithConfig(bytes.NewReader(m4a), override)
+	if err != nil {
+		t.Fatalf("NewDecoderWithConfig: %v", err)
+	}
+
+	if got := dec.SpecificConfig(); got != want {
+		t.Fatalf("SpecificConfig with override = %+v, want %+v", got, want)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -54,6 +55,7 @@ const (
 	decoderFFmpeg
 	decoderCoreAudio
 	decoderAlacconvert
+	decoderSaprobeNative // saprobe with WithNativeChannelOrder, to match CoreAudio.
 )
 
 func encoderName(enc encoderType) string {
@@ -81,6 +83,8 @@ func decoderName(dec decoderType) string {
 		return "coreaudio"
 	case decoderAlacconvert:
 		return "alacconvert"
+	case decoderSaprobeNative:
+		return "saprobe-native"
 	default:
 		return "unknown"
 	}
@@ -130,10 +134,18 @@ func channelLayout(channels int) string {
 //
 // This difference means CoreAudio-encoded or CoreAudio-decoded output
 // cannot be byte-compared against source or ffmpeg/saprobe-alac for multichannel.
+// saprobe-alac decoding with WithNativeChannelOrder follows element order and
+// is compared against them instead.
 func coreAudioUsesElementOrder(channels int) bool {
 	return channels > 2
 }
 
+// decodesInElementOrder reports whether a decoder's multichannel output
+// follows MPEG element order rather than SMPTE order.
+func decodesInElementOrder(dec decoderType, channels int) bool {
+	return coreAudioUsesElementOrder(channels) && (dec == decoderCoreAudio || dec == decoderSaprobeNative)
+}
+
 // =============================================================================
 // Encoder/Decoder Capabilities
 // =============================================================================
@@ -185,6 +197,8 @@ func availableEncoders(bitDepth, channels int, hasCoreAudio, hasAlacconvert bool
 //	coreaudio   → M4A: saprobe, ffmpeg, coreaudio
 //	natural     → M4A: saprobe, ffmpeg, coreaudio (within supported matrix)
 //	alacconvert → CAF: ffmpeg, alacconvert
+//
+// Whenever CoreAudio output is in element order, saprobe-native is added to verify it.
 func decodersForEncoder(enc encoderType, bitDepth, channels int, hasCoreAudio, hasAlacconvert bool) []decoderType {
 	switch enc {
 	case encoderFFmpeg, encoderCoreAudio, encoderNone:
@@ -193,6 +207,12 @@ func decodersForEncoder(enc encoderType, bitDepth, channels int, hasCoreAudio, h
 			decs = append(decs, decoderCoreAudio)
 		}
 
+		if enc == encoderCoreAudio || slices.Contains(decs, decoderCoreAudio) {
+			if coreAudioUsesElementOrder(channels) {
+				decs = append(decs, decoderSaprobeNative)
+			}
+		}
+
 		return decs
 	case encoderAlacconvert:
 		decs := []decoderType{decoderFFmpeg}
@@ -236,9 +256,10 @@ type conformanceInput struct {
 //  3. If source PCM provided: bit-for-bit comparison against source
 //  4. Cross-decoder comparison: bit-for-bit between all decoder pairs
 //
-// CoreAudio exception: When CoreAudio is involved (as encoder or decoder)
-// in multichannel mode, byte comparison is skipped due to different channel
-// ordering conventions. Length verification is still performed.
+// Channel order: When CoreAudio is involved (as encoder or decoder) in
+// multichannel mode, outputs are only byte-compared against those in the same
+// channel order: MPEG element order for CoreAudio and saprobe-native, SMPTE
+// for the others. Length verification is performed across orders.
 //
 //nolint:cyclop // Test orchestration requires many steps.
 func verifyConformance(t *testing.T, input conformanceInput) {
@@ -250,10 +271,9 @@ func verifyConformance(t *testing.T, input conformanceInput) {
 
 	decoders := decodersForEncoder(input.Encoder, input.BitDepth, input.Channels, hasCoreAudio, hasAlacconvert)
 
-	// CoreAudio uses MPEG element order for multichannel.
-	// Skip byte comparison when CoreAudio is involved.
-	coreAudioSkipByteCompare := coreAudioUsesElementOrder(input.Channels)
-	skipSourceCompare := coreAudioSkipByteCompare && input.Encoder == encoderCoreAudio
+	// CoreAudio reads and writes MPEG element order for multichannel: so is
+	// the source PCM it encoded.
+	sourceElementOrder := coreAudioUsesElementOrder(input.Channels) && input.Encoder == encoderCoreAudio
 
 	// Decode with every compatible decoder.
 	decoded := make(map[string][]byte, len(decoders))
@@ -264,7 +284,7 @@ func verifyConformance(t *testing.T, input conformanceInput) {
 		pcm, format := runDecode(t, dec, input.EncPath, tmpDir, input.BitDepth, input.Channels)
 
 		// Verify format metadata (saprobe decoder only — others return nil format).
-		if dec == decoderSaprobe && format != nil {
+		if (dec == decoderSaprobe || dec == decoderSaprobeNative) && format != nil {
 			if format.SampleRate != input.SampleRate {
 				t.Errorf("sample rate: got %d, want %d", format.SampleRate, input.SampleRate)
 			}
@@ -280,12 +300,12 @@ func verifyConformance(t *testing.T, input conformanceInput) {
 
 		// Compare decoded PCM vs original source (if provided).
 		if input.SourcePCM != nil {
-			skipDecoderByteCompare := coreAudioSkipByteCompare && dec == decoderCoreAudio
+			sameOrder := decodesInElementOrder(dec, input.Channels) == sourceElementOrder
 
 			if len(input.SourcePCM) != len(pcm) {
 				t.Errorf("decode(%s) vs source length mismatch: source=%d, decoded=%d",
 					decName, len(input.SourcePCM), len(pcm))
-			} else if !skipSourceCompare && !skipDecoderByteCompare {
+			} else if sameOrder {
 				label := fmt.Sprintf("decode(%s) vs source", decName)
 				agar.CompareLosslessSamples(t, label, input.SourcePCM, pcm, input.BitDepth, input.Channels)
 			}
@@ -306,9 +326,9 @@ func verifyConformance(t *testing.T, input conformanceInput) {
 			nameA := decoderNames[idx]
 			nameB := decoderNames[jdx]
 
-			// Skip byte comparison if either decoder is CoreAudio in multichannel mode.
-			if coreAudioSkipByteCompare &&
-				(decoderTypes[nameA] == decoderCoreAudio || decoderTypes[nameB] == decoderCoreAudio) {
+			// Skip byte comparison across channel orders.
+			if decodesInElementOrder(decoderTypes[nameA], input.Channels) !=
+				decodesInElementOrder(decoderTypes[nameB], input.Channels) {
 				// Still verify length.
 				if len(decoded[nameA]) != len(decoded[nameB]) {
 					t.Errorf("decode(%s) vs decode(%s) length mismatch: %d vs %d",
@@ -515,6 +535,14 @@ func runDecode(
 
 		return pcm, &format
 
+	case decoderSaprobeNative:
+		pcm, format, err := decodeSaprobe(encPath, alac.WithNativeChannelOrder())
+		if err != nil {
+			t.Fatalf("saprobe native-order decode: %v", err)
+		}
+
+		return pcm, &format
+
 	case decoderFFmpeg:
 		var args []string
 		if layout := channelLayout(channels); layout != "" {
@@ -0,0 +1,96 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+// setMovieDuration patches the mvhd (version 0) duration of a synthetic file.
+func setMovieDuration(m4a []byte, duration uint32) {
+	// Box header(8) + version/flags(4) + creation(4) + modification(4) + timescale(4).
+	binary.BigEndian.PutUint32(m4a[findFourCC(m4a, "mvhd")+24:], duration)
+}
+
+func TestContainerDuration(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, 2)
+	frames := len(pcm) / 4
+
+	for _, tc := range []struct {
+		name     string
+		declared int
+		warn     bool
+	}{
+		{"matching", frames, false},
+		{"one frame over", frames + 1, false},
+		{"longer than media", frames + 4096, true},
+		{"shorter than media", frames - 4096, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := bytes.Clone(m4a)
+			setMovieDuration(data, uint32(tc.declared))
+
+			dec, err := alac.NewDecoder(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			want := time.Duration(tc.declared) * time.Second / 8000
+			if got := dec.ContainerDuration(); got != want {
+				t.Errorf("ContainerDuration = %v, want %v", got, want)
+			}
+
+			warnings := dec.Warnings()
+			if !tc.warn {
+				if len(warnings) != 0 {
+					t.Fatalf("unexpected warnings: %v", warnings)
+				}
+
+				return
+			}
+
+			if len(warnings) != 1 || warnings[0].Packet != -1 || warnings[0].Err == nil {
+				t.Fatalf("got warnings %+v, want one container warning", warnings)
+			}
+		})
+	}
+}
+
+func TestContainerDuration_Absent(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 2)
+	setMovieDuration(m4a, 0)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if dec.ContainerDuration() != 0 || len(dec.Warnings()) != 0 {
+		t.Fatalf("ContainerDuration = %v, warnings %v; want 0 and none", dec.ContainerDuration(), dec.Warnings())
+	}
+}
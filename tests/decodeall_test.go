@@ -0,0 +1,117 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// syntheticM4A builds a verbatim-coded M4A from one second of white noise.
+func syntheticM4A(t *testing.T, sampleRate, bitDepth, channels int) ([]byte, []byte) {
+	t.Helper()
+
+	pcm := agar.GenerateWhiteNoise(sampleRate, bitDepth, channels, 1)
+
+	return testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: sampleRate,
+		BitDepth:   bitDepth,
+		Channels:   channels,
+		PCM:        pcm,
+	}), pcm
+}
+
+func TestDecodeAllInt16(t *testing.T) {
+	t.Parallel()
+
+	const channels = 2
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, channels)
+
+	samples, format, err := alac.DecodeAllInt16(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("DecodeAllInt16: %v", err)
+	}
+
+	if format.BitDepth != 16 || format.Channels != channels {
+		t.Fatalf("unexpected format: %+v", format)
+	}
+
+	if len(samples) != channels {
+		t.Fatalf("got %d channels, want %d", len(samples), channels)
+	}
+
+	frames := len(pcm) / (2 * channels)
+
+	for ch := range channels {
+		if len(samples[ch]) != frames {
+			t.Fatalf("channel %d: got %d samples, want %d", ch, len(samples[ch]), frames)
+		}
+
+		for idx, got := range samples[ch] {
+			want := int16(testutil.PCMSample(pcm[(idx*channels+ch)*2:], 16))
+			if got != want {
+				t.Fatalf("channel %d sample %d: got %d, want %d", ch, idx, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeAllInt32(t *testing.T) {
+	t.Parallel()
+
+	const channels = 3
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, channels)
+
+	samples, _, err := alac.DecodeAllInt32(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("DecodeAllInt32: %v", err)
+	}
+
+	frames := len(pcm) / (3 * channels)
+
+	for ch := range channels {
+		if len(samples[ch]) != frames {
+			t.Fatalf("channel %d: got %d samples, want %d", ch, len(samples[ch]), frames)
+		}
+
+		for idx, got := range samples[ch] {
+			want := testutil.PCMSample(pcm[(idx*channels+ch)*3:], 24)
+			if got != want {
+				t.Fatalf("channel %d sample %d: got %d, want %d", ch, idx, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeAllInt16_RejectsOtherDepths(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 24, 1)
+
+	_, _, err := alac.DecodeAllInt16(bytes.NewReader(m4a))
+	if !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("expected ErrConfig, got: %v", err)
+	}
+}
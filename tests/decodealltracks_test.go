@@ -0,0 +1,81 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecodeAllTracks(t *testing.T) {
+	t.Parallel()
+
+	stereo := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+	mono := agar.GenerateWhiteNoise(11025, 24, 1, 1)
+
+	m4a := testutil.BuildMultiTrackM4A(
+		testutil.SyntheticM4A{SampleRate: 8000, BitDepth: 16, Channels: 2, PCM: stereo},
+		testutil.SyntheticM4A{SampleRate: 11025, BitDepth: 24, Channels: 1, PCM: mono},
+	)
+
+	tracks, err := alac.DecodeAllTracks(bytes.NewReader(m4a), int64(len(m4a)))
+	if err != nil {
+		t.Fatalf("DecodeAllTracks: %v", err)
+	}
+
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+
+	for id, want := range map[uint32]struct {
+		format alac.PCMFormat
+		pcm    []byte
+	}{
+		1: {alac.PCMFormat{SampleRate: 8000, BitDepth: 16, Channels: 2}, stereo},
+		2: {alac.PCMFormat{SampleRate: 11025, BitDepth: 24, Channels: 1}, mono},
+	} {
+		got, ok := tracks[id]
+		if !ok {
+			t.Fatalf("track %d missing", id)
+		}
+
+		if got.Format != want.format {
+			t.Errorf("track %d: format %+v, want %+v", id, got.Format, want.format)
+		}
+
+		if !bytes.Equal(got.PCM, want.pcm) {
+			t.Errorf("track %d: PCM mismatch (%d bytes, want %d)", id, len(got.PCM), len(want.pcm))
+		}
+	}
+}
+
+func TestDecodeAllTracks_NoALAC(t *testing.T) {
+	t.Parallel()
+
+	data := testutil.Box("ftyp", []byte("M4A "))
+
+	_, err := alac.DecodeAllTracks(bytes.NewReader(data), int64(len(data)))
+	if !errors.Is(err, alac.ErrNoTrack) {
+		t.Fatalf("expected ErrNoTrack, got: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecodeToFile(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, 2)
+	dir := t.TempDir()
+
+	rawPath := filepath.Join(dir, "out.pcm")
+
+	format, err := alac.DecodeToFile(bytes.NewReader(m4a), rawPath)
+	if err != nil {
+		t.Fatalf("DecodeToFile: %v", err)
+	}
+
+	if format.BitDepth != 24 || format.Channels != 2 {
+		t.Fatalf("unexpected format: %+v", format)
+	}
+
+	raw, err := os.ReadFile(rawPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(raw, pcm) {
+		t.Fatalf("raw output: got %d bytes, want %d", len(raw), len(pcm))
+	}
+
+	wavPath := filepath.Join(dir, "out.WAV")
+	if _, err := alac.DecodeToFile(bytes.NewReader(m4a), wavPath); err != nil {
+		t.Fatalf("DecodeToFile: %v", err)
+	}
+
+	wav, err := os.ReadFile(wavPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(wav) != 44+len(pcm) || string(wav[0:4]) != "RIFF" || string(wav[36:40]) != "data" {
+		t.Fatalf("malformed WAV: %d bytes, header %q", len(wav), wav[:44])
+	}
+
+	if got := binary.LittleEndian.Uint32(wav[40:44]); int(got) != len(pcm) {
+		t.Fatalf("data size = %d, want %d", got, len(pcm))
+	}
+
+	if got := binary.LittleEndian.Uint16(wav[34:36]); got != 24 {
+		t.Fatalf("bits per sample = %d, want 24", got)
+	}
+
+	if !bytes.Equal(wav[44:], pcm) {
+		t.Fatal("WAV data differs from the source PCM")
+	}
+}
+
+// A final packet shorter than the cookie's FrameLength, with no stts to say
+// so, makes OutputSize overestimate: the file is truncated to the real length.
+func TestDecodeToFile_Overestimate(t *testing.T) {
+	t.Parallel()
+
+	packets, pcm := mkvSource()
+	packets = append(packets, testutil.EncodeVerbatimPacket(pcm[:100*mkvFrameBytes], 16, mkvChannels, mkvFrameLength))
+	pcm = append(pcm, pcm[:100*mkvFrameBytes]...)
+
+	mkv := testutil.BuildMKV(testutil.Cookie(mkvFrameLength, 16, mkvChannels, mkvRate), packets)
+	path := filepath.Join(t.TempDir(), "out.wav")
+
+	if _, err := alac.DecodeToFile(bytes.NewReader(mkv), path); err != nil {
+		t.Fatalf("DecodeToFile: %v", err)
+	}
+
+	wav, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := binary.LittleEndian.Uint32(wav[40:44]); int(got) != len(pcm) {
+		t.Fatalf("data size = %d, want %d", got, len(pcm))
+	}
+
+	if !bytes.Equal(wav[44:], pcm) {
+		t.Fatalf("WAV data: got %d bytes, want %d", len(wav)-44, len(pcm))
+	}
+}
+
+func TestDecodeToFile_RemovesOnError(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 2)
+	path := filepath.Join(t.TempDir(), "out.pcm")
+
+	if _, err := alac.DecodeToFile(bytes.NewReader(m4a), path, alac.WithMaxOutputBytes(1000)); err == nil {
+		t.Fatal("DecodeToFile succeeded past WithMaxOutputBytes")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("partial output left behind: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// Element tags used to build DSE packets.
+const (
+	tagSCE = 0
+	tagDSE = 4
+	tagFIL = 6
+	tagEND = 7
+)
+
+// dsePacket builds a mono 16-bit packet holding an empty FIL element (so the
+// DSE starts off a byte boundary), a DSE with the given align flag and
+// payload, then a verbatim SCE carrying pcm.
+func dsePacket(pcm []byte, frameLength int, alignFlag uint32, payload []byte) []byte {
+	var bw testutil.BitWriter
+
+	bw.Write(tagFIL, 3)
+	bw.Write(0, 4) // fill count
+
+	// Reference order: tag, instance tag, align flag, count, then align, then data.
+	bw.Write(tagDSE, 3)
+	bw.Write(0, 4)
+	bw.Write(alignFlag, 1)
+
+	if len(payload) >= 255 {
+		bw.Write(255, 8)
+		bw.Write(uint32(len(payload)-255), 8)
+	} else {
+		bw.Write(uint32(len(payload)), 8)
+	}
+
+	if alignFlag != 0 {
+		bw.ByteAlign()
+	}
+
+	for _, b := range payload {
+		bw.Write(uint32(b), 8)
+	}
+
+	numSamples := len(pcm) / 2
+	testutil.WriteElementHeader(&bw, tagSCE, numSamples, frameLength)
+
+	for idx := range numSamples {
+		testutil.WriteEscapeSamples(&bw, testutil.PCMSample(pcm[idx*2:], 16), 16)
+	}
+
+	bw.Write(tagEND, 3)
+	bw.ByteAlign()
+
+	return bw.Bytes()
+}
+
+func TestDecode_DataStreamElement(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 256
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 1, 1)[:frameLength*2]
+
+	config, err := alac.ParseMagicCookie(testutil.Cookie(frameLength, 16, 1, 8000))
+	if err != nil {
+		t.Fatalf("ParseMagicCookie: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		alignFlag uint32
+		payload   []byte
+	}{
+		// The DSE header ends at bit 23: aligning moves the data to bit 24.
+		{"aligned odd count", 1, []byte{0xA5, 0x5A, 0xFF}},
+		{"unaligned odd count", 0, []byte{0xA5, 0x5A, 0xFF}},
+		{"aligned empty", 1, nil},
+		{"extended count", 1, bytes.Repeat([]byte{0xC3}, 300)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewPacketDecoder(config)
+			if err != nil {
+				t.Fatalf("NewPacketDecoder: %v", err)
+			}
+
+			got, err := dec.DecodePacket(dsePacket(pcm, frameLength, tc.alignFlag, tc.payload))
+			if err != nil {
+				t.Fatalf("DecodePacket: %v", err)
+			}
+
+			if !bytes.Equal(got, pcm) {
+				t.Fatal("decoded PCM differs: DSE skipped to the wrong bit position")
+			}
+		})
+	}
+}
+
+func TestWithRejectAncillary(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 256
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 1, 1)[:frameLength*2]
+
+	config, err := alac.ParseMagicCookie(testutil.Cookie(frameLength, 16, 1, 8000))
+	if err != nil {
+		t.Fatalf("ParseMagicCookie: %v", err)
+	}
+
+	dec, err := alac.NewPacketDecoder(config, alac.WithRejectAncillary())
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	// Packets without ancillary elements decode as usual.
+	if got, err := dec.DecodePacket(testutil.EncodeVerbatimPacket(pcm, 16, 1, frameLength)); err != nil ||
+		!bytes.Equal(got, pcm) {
+		t.Fatalf("plain packet: DecodePacket returned %d bytes, %v", len(got), err)
+	}
+
+	_, err = dec.DecodePacket(dsePacket(pcm, frameLength, 1, []byte{0xA5}))
+	if !errors.Is(err, alac.ErrDecode) || !errors.Is(err, alac.ErrAncillaryElement) {
+		t.Fatalf("expected ErrDecode and ErrAncillaryElement, got: %v", err)
+	}
+
+	// dsePacket leads with a FIL element, reported before the DSE.
+	if !strings.Contains(err.Error(), "FIL") {
+		t.Fatalf("error does not name the FIL element: %v", err)
+	}
+}
+
+// TestDecode_OversizedSkipCount checks that FIL and DSE counts running past
+// the packet end are rejected rather than skipped into the padding.
+func TestDecode_OversizedSkipCount(t *testing.T) {
+	t.Parallel()
+
+	config, err := alac.ParseMagicCookie(testutil.Cookie(256, 16, 1, 8000))
+	if err != nil {
+		t.Fatalf("ParseMagicCookie: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		write func(bw *testutil.BitWriter)
+	}{
+		{"FIL maximum count", func(bw *testutil.BitWriter) {
+			bw.Write(tagFIL, 3)
+			bw.Write(15, 4)
+			bw.Write(255, 8) // 15+255-1 = 269 bytes
+		}},
+		{"DSE maximum count", func(bw *testutil.BitWriter) {
+			bw.Write(tagDSE, 3)
+			bw.Write(0, 4)
+			bw.Write(1, 1)
+			bw.Write(255, 8)
+			bw.Write(255, 8) // 255+255 = 510 bytes
+		}},
+		{"DSE count one past the end", func(bw *testutil.BitWriter) {
+			bw.Write(tagDSE, 3)
+			bw.Write(0, 4)
+			bw.Write(1, 1)
+			bw.Write(4, 8) // 4 bytes announced, 3 present
+			bw.ByteAlign()
+			bw.Write(0, 24)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var bw testutil.BitWriter
+
+			tc.write(&bw)
+			bw.ByteAlign()
+
+			dec, err := alac.NewPacketDecoder(config)
+			if err != nil {
+				t.Fatalf("NewPacketDecoder: %v", err)
+			}
+
+			_, err = dec.DecodePacket(bw.Bytes())
+			if !errors.Is(err, alac.ErrDecode) || !errors.Is(err, alacint.ErrBitstreamOverrun) {
+				t.Fatalf("expected ErrDecode and ErrBitstreamOverrun, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDurationPrecise_Rounds(t *testing.T) {
+	t.Parallel()
+
+	// One 4096-frame packet at 44100 Hz lasts 92879818.594 ns.
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 44100,
+		BitDepth:   16,
+		Channels:   1,
+		PCM:        agar.GenerateWhiteNoise(44100, 16, 1, 1)[:4096*2],
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got, want := dec.Duration(), 92879818*time.Nanosecond; got != want {
+		t.Errorf("Duration: got %d, want %d", got, want)
+	}
+
+	if got, want := dec.DurationPrecise(), 92879819*time.Nanosecond; got != want {
+		t.Errorf("DurationPrecise: got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// editListBox builds an edts/elst (version 0) from (segmentDuration, mediaTime) pairs.
+func editListBox(edits ...[2]int) []byte {
+	entries := testutil.U32(len(edits))
+	for _, edit := range edits {
+		entries = append(entries, testutil.U32(edit[0])...)
+		entries = append(entries, testutil.U32(edit[1])...)
+		entries = append(entries, testutil.U32(0x00010000)...)
+	}
+
+	return testutil.Box("edts", testutil.FullBox("elst", entries))
+}
+
+func TestWithEditListSilence(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleRate    = 8000
+		bytesPerFrame = 4 // 16-bit stereo
+		emptyEdit     = 0xFFFFFFFF
+	)
+
+	pcm := agar.GenerateWhiteNoise(sampleRate, 16, 2, 1)
+	silence := func(frames int) []byte { return make([]byte, frames*bytesPerFrame) }
+
+	// The synthetic mvhd timescale is the sample rate, so edit durations are in frames.
+	for _, tc := range []struct {
+		name  string
+		edits [][2]int
+		want  [][]byte
+	}{
+		{
+			"leading and trailing",
+			[][2]int{{4000, emptyEdit}, {8000, 0}, {2000, emptyEdit}},
+			[][]byte{silence(4000), pcm, silence(2000)},
+		},
+		{
+			"mid packet",
+			[][2]int{{2400, 0}, {800, emptyEdit}, {5600, 2400}},
+			[][]byte{pcm[:2400*bytesPerFrame], silence(800), pcm[2400*bytesPerFrame:]},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+				SampleRate:     sampleRate,
+				BitDepth:       16,
+				Channels:       2,
+				PCM:            pcm,
+				ExtraTrakBoxes: [][]byte{editListBox(tc.edits...)},
+			})
+
+			dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithEditListSilence())
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			// Odd-sized reads exercise gap boundaries that fall inside a read.
+			got, err := io.ReadAll(iotest.OneByteReader(dec))
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			if want := bytes.Join(tc.want, nil); !bytes.Equal(got, want) {
+				t.Fatalf("got %d bytes, want %d matching bytes", len(got), len(want))
+			}
+
+			// Without the option the edit list is ignored.
+			plain, err := alac.NewDecoder(bytes.NewReader(m4a))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			if got, _ := io.ReadAll(plain); !bytes.Equal(got, pcm) {
+				t.Fatal("decoding without WithEditListSilence altered the output")
+			}
+		})
+	}
+}
+
+func TestWithEditListSilence_OutputLimit(t *testing.T) {
+	t.Parallel()
+
+	const limit = 1 << 16
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+
+	// A version 1 empty edit of 2^61 frames: times the sample rate it wraps
+	// around 64 bits to zero, so it must be scaled without overflow.
+	entries := testutil.U32(2)
+	entries = append(entries, testutil.U64(1<<61)...)
+	entries = append(entries, testutil.U64(^uint64(0))...)
+	entries = append(entries, testutil.U32(0x00010000)...)
+	entries = append(entries, testutil.U64(8000)...)
+	entries = append(entries, testutil.U64(0)...)
+	entries = append(entries, testutil.U32(0x00010000)...)
+
+	elst := testutil.Box("elst", []byte{1, 0, 0, 0}, entries)
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:     8000,
+		BitDepth:       16,
+		Channels:       2,
+		PCM:            pcm,
+		ExtraTrakBoxes: [][]byte{testutil.Box("edts", elst)},
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithEditListSilence(), alac.WithMaxOutputBytes(limit))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// The silence counts against the output budget like decoded PCM.
+	got, err := io.ReadAll(dec)
+	if !errors.Is(err, alac.ErrOutputLimitExceeded) {
+		t.Fatalf("expected ErrOutputLimitExceeded, got: %v", err)
+	}
+
+	if !bytes.Equal(got, make([]byte, limit)) {
+		t.Fatalf("got %d bytes, want %d bytes of silence", len(got), limit)
+	}
+
+	dec, err = alac.NewDecoder(bytes.NewReader(m4a), alac.WithEditListSilence(), alac.WithMaxOutputBytes(limit))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	frames := 0
+	dst := make([][2]int32, 1000)
+
+	for {
+		n, err := dec.ReadStereoFrames(dst)
+		frames += n
+
+		if err != nil {
+			if !errors.Is(err, alac.ErrOutputLimitExceeded) {
+				t.Fatalf("ReadStereoFrames: expected ErrOutputLimitExceeded, got: %v", err)
+			}
+
+			break
+		}
+	}
+
+	if frames != limit/4 {
+		t.Fatalf("ReadStereoFrames returned %d frames, want %d", frames, limit/4)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestEncodeWAV(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	var out bytes.Buffer
+
+	written, err := alac.EncodeWAV(&out, dec)
+	if err != nil {
+		t.Fatalf("EncodeWAV: %v", err)
+	}
+
+	if written != int64(len(pcm)) || out.Len() != 44+len(pcm) {
+		t.Fatalf("wrote %d PCM bytes (%d total), want %d", written, out.Len(), len(pcm))
+	}
+
+	wav := out.Bytes()
+	if string(wav[0:4]) != "RIFF" || string(wav[36:40]) != "data" {
+		t.Fatalf("not a canonical WAV header: %q", wav[:44])
+	}
+
+	if size := binary.LittleEndian.Uint32(wav[40:44]); size != uint32(len(pcm)) {
+		t.Fatalf("data chunk declares %d bytes, want %d", size, len(pcm))
+	}
+
+	if !bytes.Equal(wav[44:], pcm) {
+		t.Fatal("WAV data differs from the source PCM")
+	}
+}
+
+// TestEncodeWAV_Streams checks that a long stream is converted without
+// holding its PCM in memory.
+//
+//nolint:paralleltest // Allocation totals are process-wide.
+func TestEncodeWAV_Streams(t *testing.T) {
+	const (
+		sampleRate = 44100
+		seconds    = 30
+	)
+
+	pcm := agar.GenerateWhiteNoise(sampleRate, 16, 2, seconds)
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: sampleRate,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        pcm,
+	})
+
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	written, err := alac.EncodeWAV(io.Discard, dec)
+	if err != nil {
+		t.Fatalf("EncodeWAV: %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if written != int64(len(pcm)) {
+		t.Fatalf("wrote %d PCM bytes, want %d", written, len(pcm))
+	}
+
+	// Decoder buffers and the sample table only: a small fraction of the PCM.
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(len(pcm)/16) {
+		t.Fatalf("converting %d PCM bytes allocated %d bytes", len(pcm), allocated)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// compressedEscapeDecoder returns a 32-bit mono decoder for frameLength-sample packets.
+func compressedEscapeDecoder(t *testing.T, frameLength int) *alac.PacketDecoder {
+	t.Helper()
+
+	config, err := alac.ParseMagicCookie(testutil.Cookie(frameLength, 32, 1, 8000))
+	if err != nil {
+		t.Fatalf("ParseMagicCookie: %v", err)
+	}
+
+	dec, err := alac.NewPacketDecoder(config)
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	return dec
+}
+
+// writeCompressedHeader writes a compressed SCE header for a full frame with
+// no prediction: residuals decode straight to samples.
+func writeCompressedHeader(bw *testutil.BitWriter) {
+	bw.Write(0, 3)    // SCE
+	bw.Write(0, 4)    // element instance tag
+	bw.Write(0, 12)   // unused header bits
+	bw.Write(0, 4)    // partialFrame, bytesShifted=0, escapeFlag=0
+	bw.Write(0, 16)   // mixBits, mixRes
+	bw.Write(0, 8)    // mode, denShift
+	bw.Write(4<<5, 8) // pbFactor=4, numCoefs=0
+}
+
+// TestDynDecomp_EscapeEndsAtPacketEnd decodes a packet of 32-bit escaped
+// residuals whose last bit is the packet's last bit. Most residuals straddle
+// five bytes, exercising the widest getStreamBits load.
+func TestDynDecomp_EscapeEndsAtPacketEnd(t *testing.T) {
+	t.Parallel()
+
+	// 55 header bits + 9*41 entropy bits = 53 bytes exactly, with no END tag.
+	const numSamples = 9
+
+	var bw testutil.BitWriter
+
+	writeCompressedHeader(&bw)
+
+	want := make([]int32, numSamples)
+
+	for idx := range numSamples {
+		residual := uint32(1_000_003 + idx*77_777)
+
+		bw.Write(0x1FF, 9) // escape prefix
+		bw.Write(residual, 32)
+
+		// Residuals are zigzag coded: odd values are negative.
+		want[idx] = int32((residual + 1) >> 1)
+		if residual&1 != 0 {
+			want[idx] = -want[idx]
+		}
+	}
+
+	packet := bw.Bytes()
+	if len(packet) != 53 {
+		t.Fatalf("packet is %d bytes, want 53", len(packet))
+	}
+
+	out, err := compressedEscapeDecoder(t, numSamples).DecodePacket(packet)
+	if err != nil {
+		t.Fatalf("DecodePacket: %v", err)
+	}
+
+	for idx, sample := range want {
+		if got := int32(binary.LittleEndian.Uint32(out[idx*4:])); got != sample {
+			t.Fatalf("sample %d: got %d, want %d", idx, got, sample)
+		}
+	}
+}
+
+// TestDynDecomp_EscapePrefixAtPacketEnd ends a packet on an escape prefix:
+// the escaped value and the zero-run count that follows are read entirely
+// from the padding, the furthest the entropy decoder reads past a packet.
+func TestDynDecomp_EscapePrefixAtPacketEnd(t *testing.T) {
+	t.Parallel()
+
+	var bw testutil.BitWriter
+
+	writeCompressedHeader(&bw)
+	bw.Write(0x1FF, 9) // escape prefix ending on the packet's last bit
+
+	packet := bw.Bytes()
+	if len(packet) != 8 {
+		t.Fatalf("packet is %d bytes, want 8", len(packet))
+	}
+
+	_, err := compressedEscapeDecoder(t, 2).DecodePacket(packet)
+	if !errors.Is(err, alac.ErrDecode) || !errors.Is(err, alacint.ErrBitstreamOverrun) {
+		t.Fatalf("expected ErrDecode and ErrBitstreamOverrun, got: %v", err)
+	}
+
+	// The escaped zero and an empty zero run end 36 bits past the packet.
+	if want := "at sample 1/2, bit 100"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("error %q does not locate the overrun (%q)", err, want)
+	}
+}
+
+func TestEntropyStats(t *testing.T) {
+	t.Parallel()
+
+	// One literal zero drops the mean low enough to enter zero-run mode; an
+	// escaped run count of 100 follows, then one last literal.
+	const (
+		run         = 100
+		frameLength = run + 2
+	)
+
+	var bw testutil.BitWriter
+
+	writeCompressedHeader(&bw)
+	bw.Write(0, 1)     // literal: empty prefix, k=1
+	bw.Write(0x1FF, 9) // zero-run count escape prefix
+	bw.Write(run, 16)
+	bw.Write(0, 1) // literal
+	bw.Write(7, 3) // END
+	bw.ByteAlign()
+
+	packet := bw.Bytes()
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:   8000,
+		BitDepth:     16,
+		Channels:     1,
+		FrameLength:  frameLength,
+		Packets:      [][]byte{packet, packet},
+		PacketFrames: []int{frameLength, frameLength},
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithEntropyStats())
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := io.ReadAll(dec); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if literals, runs, runSamples := dec.EntropyStats(); literals != 4 || runs != 2 || runSamples != 2*run {
+		t.Fatalf("EntropyStats() = %d, %d, %d; want 4, 2, %d", literals, runs, runSamples, 2*run)
+	}
+
+	plain, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := io.ReadAll(plain); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if literals, runs, runSamples := plain.EntropyStats(); literals != 0 || runs != 0 || runSamples != 0 {
+		t.Fatalf("without WithEntropyStats: got %d, %d, %d; want zeros", literals, runs, runSamples)
+	}
+}
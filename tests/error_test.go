@@ -20,13 +20,16 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/mycophonic/agar/pkg/agar"
 
 	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
 )
 
 // encodeTestM4A generates a short M4A file for corruption tests.
@@ -121,6 +124,38 @@ func TestParseMagicCookie_BadVersion(t *testing.T) {
 	}
 }
 
+func TestParseMagicCookie_WrapperOnly(t *testing.T) {
+	t.Parallel()
+
+	frma := append(testutil.U32(12), "frmaalac"...)
+	alacHeader := append(testutil.U32(36), "alac\x00\x00\x00\x00"...)
+	config := testutil.Cookie(4096, 16, 2, 44100)
+	short := config[:len(config)-1]
+
+	for _, tc := range []struct {
+		name   string
+		cookie []byte
+	}{
+		{"frma only", frma},
+		{"alac header only", alacHeader},
+		{"frma and alac headers", append(bytes.Clone(frma), alacHeader...)},
+		{"frma then short config", append(bytes.Clone(frma), short...)},
+		{"both wrappers then short config", append(append(bytes.Clone(frma), alacHeader...), short...)},
+		{"truncated frma header", frma[:11]},
+		{"frma then truncated alac header", append(bytes.Clone(frma), alacHeader[:8]...)},
+	} {
+		_, err := alac.ParseMagicCookie(tc.cookie)
+		if !errors.Is(err, alac.ErrConfig) {
+			t.Errorf("%s: expected ErrConfig, got: %v", tc.name, err)
+		}
+	}
+
+	// The same wrappers around a whole config parse.
+	if _, err := alac.ParseMagicCookie(append(append(bytes.Clone(frma), alacHeader...), config...)); err != nil {
+		t.Fatalf("wrapped config: %v", err)
+	}
+}
+
 // --- NewPacketDecoder error tests ---
 
 func TestNewPacketDecoder_InvalidBitDepth(t *testing.T) {
@@ -141,6 +176,75 @@ func TestNewPacketDecoder_InvalidBitDepth(t *testing.T) {
 	}
 }
 
+//nolint:paralleltest // testing.AllocsPerRun refuses to run in parallel tests.
+func TestNewPacketDecoder_OversizedFrameLength(t *testing.T) {
+	config := alac.PacketConfig{
+		FrameLength: 0xFFFFFFFF,
+		BitDepth:    16,
+		NumChannels: 2,
+		SampleRate:  44100,
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := alac.NewPacketDecoder(config); !errors.Is(err, alac.ErrConfig) {
+			t.Fatalf("expected ErrConfig, got: %v", err)
+		}
+	})
+
+	// Only the error values are allocated, never the frame-sized buffers.
+	if allocs > 8 {
+		t.Fatalf("rejecting an oversized frame length took %.0f allocations", allocs)
+	}
+
+	// The bound is configurable.
+	config.FrameLength = 2 * alac.DefaultMaxFrameLength
+
+	if _, err := alac.NewPacketDecoder(config, alac.WithMaxFrameLength(config.FrameLength)); err != nil {
+		t.Fatalf("NewPacketDecoder with raised bound: %v", err)
+	}
+}
+
+func TestNewDecoder_OversizedFrameLength(t *testing.T) {
+	t.Parallel()
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 44100,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        make([]byte, 4096*4),
+		Cookie:     testutil.Cookie(1<<30, 16, 2, 44100),
+	})
+
+	if _, err := alac.NewDecoder(bytes.NewReader(m4a)); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("expected ErrConfig, got: %v", err)
+	}
+}
+
+func TestNewDecoder_ZeroSampleRate(t *testing.T) {
+	t.Parallel()
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 44100,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        make([]byte, 4096*4),
+		Cookie:     testutil.Cookie(4096, 16, 2, 0),
+	})
+
+	if _, err := alac.NewDecoder(bytes.NewReader(m4a)); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("NewDecoder: expected ErrConfig, got: %v", err)
+	}
+
+	if _, err := alac.ProbeFormat(bytes.NewReader(m4a)); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("ProbeFormat: expected ErrConfig, got: %v", err)
+	}
+
+	config := alac.PacketConfig{FrameLength: 4096, BitDepth: 16, NumChannels: 2}
+	if _, err := alac.NewDecoderWithConfig(bytes.NewReader(m4a), config); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("NewDecoderWithConfig: expected ErrConfig, got: %v", err)
+	}
+}
+
 // --- NewDecoder / Decode error tests on corrupt M4A ---
 
 func TestDecode_EmptyReader(t *testing.T) {
@@ -365,6 +469,84 @@ func TestDecode_ZeroedStsz(t *testing.T) {
 	}
 }
 
+func TestDecode_NoAudioFrames(t *testing.T) {
+	t.Parallel()
+
+	// Valid cookie, empty stts/stsz/stco, empty mdat.
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 44100,
+		BitDepth:   16,
+		Channels:   2,
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if dur := dec.Duration(); dur != 0 {
+		t.Fatalf("expected zero duration, got %v", dur)
+	}
+
+	n, err := dec.Read(make([]byte, 64))
+	if n != 0 || !errors.Is(err, io.EOF) {
+		t.Fatalf("expected (0, io.EOF), got (%d, %v)", n, err)
+	}
+
+	pos, err := dec.Seek(time.Second)
+	if err != nil || pos != 0 {
+		t.Fatalf("expected seek to 0, got (%v, %v)", pos, err)
+	}
+
+	if pos := dec.Position(); pos != 0 {
+		t.Fatalf("expected zero position, got %v", pos)
+	}
+}
+
+func TestNewDecoderWithConfig_RecoversBadCookie(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(44100, 16, 2, 1)
+
+	cookie := testutil.Cookie(4096, 16, 2, 44100)
+	cookie[4] = 99 // unsupported compatible version
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 44100,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        pcm,
+		Cookie:     cookie,
+	})
+
+	if _, err := alac.NewDecoder(bytes.NewReader(m4a)); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("expected ErrConfig from the embedded cookie, got: %v", err)
+	}
+
+	dec, err := alac.NewDecoderWithConfig(bytes.NewReader(m4a), alac.PacketConfig{
+		FrameLength: 4096,
+		BitDepth:    16,
+		NumChannels: 2,
+		PB:          40,
+		MB:          10,
+		KB:          14,
+		MaxRun:      255,
+		SampleRate:  44100,
+	})
+	if err != nil {
+		t.Fatalf("NewDecoderWithConfig: %v", err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded PCM mismatch: got %d bytes, want %d", len(got), len(pcm))
+	}
+}
+
 func TestDecode_CorruptedMdat(t *testing.T) {
 	t.Parallel()
 
@@ -413,30 +595,71 @@ func TestNewDecoder_EmptyReader(t *testing.T) {
 func TestDecode_TruncatedPacket(t *testing.T) {
 	t.Parallel()
 
-	data := encodeTestM4A(t)
+	// A faststart file: the moov precedes the mdat, so cutting into the mdat
+	// leaves the sample table whole. TestDecode_TruncatedFinalPacket covers a
+	// cut inside the last packet; this one drops half the packets.
+	data, _ := syntheticM4A(t, 8000, 16, 2)
 
 	mdatOff := findFourCC(data, "mdat")
 	if mdatOff < 0 {
 		t.Fatal("mdat not found in test M4A")
 	}
 
-	// Truncate the file partway through mdat.
 	mdatSize := binary.BigEndian.Uint32(data[mdatOff : mdatOff+4])
-	cutPoint := mdatOff + int(mdatSize)/2
+	truncated := data[:mdatOff+int(mdatSize)/2]
 
-	if cutPoint >= len(data) {
-		cutPoint = mdatOff + 16
+	_, _, err := decode(bytes.NewReader(truncated))
+	if !errors.Is(err, alac.ErrTruncatedStream) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrTruncatedStream and io.ErrUnexpectedEOF, got: %v", err)
 	}
+}
 
-	truncated := data[:cutPoint]
+func TestDecode_TruncatedFinalPacket(t *testing.T) {
+	t.Parallel()
 
-	_, _, err := decode(bytes.NewReader(truncated))
-	if err == nil {
-		// Might succeed if the sample table entries before the cut point
-		// happen to be complete. Not guaranteed to fail.
-		return
+	const (
+		channels    = 2
+		frameLength = 4096
+		frameBytes  = frameLength * channels * 2
+	)
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, channels)
+
+	// The mdat is the last box: cutting a few bytes only damages the final packet.
+	truncated := m4a[:len(m4a)-5]
+
+	for _, tc := range []struct {
+		name string
+		open func() (*alac.Decoder, error)
+	}{
+		{"ReadSeeker", func() (*alac.Decoder, error) { return alac.NewDecoder(bytes.NewReader(truncated)) }},
+		{"ReaderAt", func() (*alac.Decoder, error) {
+			return alac.NewDecoderAt(bytes.NewReader(truncated), int64(len(truncated)))
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := tc.open()
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+
+			got, err := io.ReadAll(dec)
+			if !errors.Is(err, alac.ErrTruncatedStream) || !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("expected ErrTruncatedStream and io.ErrUnexpectedEOF, got: %v", err)
+			}
+
+			// Every complete packet before the damaged one is delivered intact.
+			complete := (len(pcm) - 1) / frameBytes * frameBytes
+			if !bytes.Equal(got, pcm[:complete]) {
+				t.Fatalf("got %d bytes of leading PCM, want %d matching bytes", len(got), complete)
+			}
+
+			// The error is sticky.
+			if n, again := dec.Read(make([]byte, 16)); n != 0 || !errors.Is(again, alac.ErrTruncatedStream) {
+				t.Fatalf("second Read = (%d, %v), want (0, ErrTruncatedStream)", n, again)
+			}
+		})
 	}
-
-	// Any error is acceptable — the decoder hit truncated data.
-	t.Logf("got expected error: %v", err)
 }
@@ -129,3 +129,55 @@ func TestExampleDecoder(t *testing.T) {
 
 	agar.CompareLosslessSamples(t, "example-decoder(wav) vs coreaudio", refPCM, wavPCM, bitDepth, channels)
 }
+
+// TestExampleDecoder_WAVStream verifies WAV mode on a long synthetic file,
+// piped to stdout without a reference decoder.
+func TestExampleDecoder_WAVStream(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	decoderBin := filepath.Join(tmpDir, "alac-example-decoder")
+
+	build := exec.CommandContext(context.Background(), "go", "build", "-o", decoderBin, "./cmd/alac-example-decoder")
+	build.Dir = ".."
+
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build alac-example-decoder: %v\n%s", err, out)
+	}
+
+	pcm := agar.GenerateWhiteNoise(44100, 16, 2, 30)
+	m4aPath := filepath.Join(tmpDir, "long.m4a")
+
+	if err := os.WriteFile(m4aPath, testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 44100,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        pcm,
+	}), 0o600); err != nil {
+		t.Fatalf("write M4A: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(context.Background(), decoderBin, "-format", "wav", m4aPath)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("example decoder (wav): %v\n%s", err, stderr.String())
+	}
+
+	wavPath := filepath.Join(tmpDir, "long.wav")
+
+	if err := os.WriteFile(wavPath, stdout.Bytes(), 0o600); err != nil {
+		t.Fatalf("write WAV output: %v", err)
+	}
+
+	if stdout.Len() != 44+len(pcm) {
+		t.Fatalf("WAV output size: got %d, want %d", stdout.Len(), 44+len(pcm))
+	}
+
+	if !bytes.Equal(testutil.ReadWAVPCMData(t, wavPath), pcm) {
+		t.Fatal("WAV data differs from the source PCM")
+	}
+}
@@ -0,0 +1,112 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestBytesPerSampleChecked(t *testing.T) {
+	t.Parallel()
+
+	for depth, want := range map[int]int{16: 2, 20: 3, 24: 3, 32: 4} {
+		got, err := alac.BytesPerSampleChecked(depth)
+		if err != nil || got != want {
+			t.Fatalf("BytesPerSampleChecked(%d) = %d, %v; want %d, nil", depth, got, err, want)
+		}
+	}
+
+	for _, depth := range []int{-16, 0, 4, 8, 12, 17, 64, 272} {
+		got, err := alac.BytesPerSampleChecked(depth)
+		if !errors.Is(err, alac.ErrConfig) || got != 0 {
+			t.Fatalf("BytesPerSampleChecked(%d) = %d, %v; want 0, ErrConfig", depth, got, err)
+		}
+	}
+}
+
+func TestPCMFormat_String(t *testing.T) {
+	t.Parallel()
+
+	for format, want := range map[alac.PCMFormat]string{
+		{SampleRate: 44100, BitDepth: 16, Channels: 2}:                                   "44100Hz/16bit/2ch",
+		{SampleRate: 96000, BitDepth: 24, Channels: 6, SampleFormat: alac.SampleFloat32}: "96000Hz/24bit/6ch/float32",
+	} {
+		if got := format.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestPCMFormat_Layout(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		format      alac.PCMFormat
+		sampleBytes int
+		packed24    bool
+		fullScale   int64
+	}{
+		{alac.PCMFormat{BitDepth: 16, Channels: 2}, 2, false, 1 << 15},
+		{alac.PCMFormat{BitDepth: 20, Channels: 2}, 3, true, 1 << 23},
+		{alac.PCMFormat{BitDepth: 24, Channels: 6}, 3, true, 1 << 23},
+		{alac.PCMFormat{BitDepth: 32, Channels: 1}, 4, false, 1 << 31},
+		{alac.PCMFormat{BitDepth: 24, Channels: 2, SampleFormat: alac.SampleFloat32}, 4, false, 1},
+		{alac.PCMFormat{BitDepth: 20, Channels: 3, SampleFormat: alac.SampleFloat64}, 8, false, 1},
+		{alac.PCMFormat{BitDepth: 8, Channels: 2}, 0, false, 0},
+	} {
+		format := tc.format
+
+		if got := format.BytesPerSample(); got != tc.sampleBytes {
+			t.Errorf("%v: BytesPerSample() = %d, want %d", format, got, tc.sampleBytes)
+		}
+
+		if got := format.BytesPerFrame(); got != tc.sampleBytes*format.Channels {
+			t.Errorf("%v: BytesPerFrame() = %d, want %d", format, got, tc.sampleBytes*format.Channels)
+		}
+
+		if got := format.IsPacked24(); got != tc.packed24 {
+			t.Errorf("%v: IsPacked24() = %t, want %t", format, got, tc.packed24)
+		}
+
+		if got := format.FullScale(); got != tc.fullScale {
+			t.Errorf("%v: FullScale() = %d, want %d", format, got, tc.fullScale)
+		}
+	}
+}
+
+func TestPacketConfig_String(t *testing.T) {
+	t.Parallel()
+
+	config := alac.PacketConfig{
+		FrameLength: 4096,
+		BitDepth:    16,
+		NumChannels: 2,
+		PB:          40,
+		MB:          10,
+		KB:          14,
+		MaxRun:      255,
+		SampleRate:  44100,
+	}
+
+	want := "44100Hz/16bit/2ch frame=4096 pb=40 mb=10 kb=14 maxRun=255"
+	if got := config.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
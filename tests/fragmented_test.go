@@ -0,0 +1,242 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+const (
+	fragSampleRate  = 8000
+	fragBitDepth    = 16
+	fragChannels    = 2
+	fragFrameLength = 256
+	fragTrackID     = 1
+)
+
+// fragmentedInit builds an initialization segment: a moov without samples
+// whose mvex declares trexSize as the track's default sample size.
+func fragmentedInit(trexSize int) []byte {
+	trex := testutil.FullBox("trex", testutil.U32(fragTrackID), testutil.U32(1),
+		testutil.U32(fragFrameLength), testutil.U32(trexSize), testutil.U32(0))
+
+	return testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:     fragSampleRate,
+		BitDepth:       fragBitDepth,
+		Channels:       fragChannels,
+		FrameLength:    fragFrameLength,
+		ExtraMoovBoxes: [][]byte{testutil.Box("mvex", trex)},
+	})
+}
+
+// fragmentPackets encodes pcm into equally sized verbatim packets.
+func fragmentPackets(pcm []byte) [][]byte {
+	bytesPerFrame := fragChannels * fragBitDepth / 8
+	chunk := fragFrameLength * bytesPerFrame
+
+	var packets [][]byte
+
+	for off := 0; off < len(pcm); off += chunk {
+		packets = append(packets, testutil.EncodeVerbatimPacket(
+			pcm[off:min(off+chunk, len(pcm))], fragBitDepth, fragChannels, fragFrameLength))
+	}
+
+	return packets
+}
+
+// buildFragment assembles a moof+mdat pair for one track. With tfhdSize or
+// perSample unset, sizes are left to the trex default.
+func buildFragment(trackID, tfhdFlags, tfhdSize int, perSample bool, packets [][]byte) []byte {
+	tfhdPayload := testutil.U32(trackID)
+	if tfhdFlags&0x000001 != 0 {
+		tfhdPayload = append(tfhdPayload, testutil.U64(0)...)
+	}
+
+	if tfhdSize != 0 {
+		tfhdFlags |= 0x000010
+		tfhdPayload = append(tfhdPayload, testutil.U32(tfhdSize)...)
+	}
+
+	tfhd := testutil.Box("tfhd", testutil.U32(tfhdFlags), tfhdPayload)
+
+	trunFlags := 0x000001
+	if perSample {
+		trunFlags |= 0x000200
+	}
+
+	build := func(dataOffset int) []byte {
+		trunPayload := append(testutil.U32(len(packets)), testutil.U32(dataOffset)...)
+
+		if perSample {
+			for _, packet := range packets {
+				trunPayload = append(trunPayload, testutil.U32(len(packet))...)
+			}
+		}
+
+		trun := testutil.Box("trun", testutil.U32(trunFlags), trunPayload)
+
+		return testutil.Box("moof", testutil.FullBox("mfhd", testutil.U32(1)), testutil.Box("traf", tfhd, trun))
+	}
+
+	// The data offset does not change the moof size: size it first.
+	moof := build(len(build(0)) + 8)
+
+	return append(moof, testutil.Box("mdat", bytes.Join(packets, nil))...)
+}
+
+func TestFragmentedDecoder_AppendAcrossReads(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(fragSampleRate, fragBitDepth, fragChannels, 1)
+	packets := fragmentPackets(pcm)
+	half := len(packets) / 2
+
+	dec, err := alac.NewFragmentedDecoder(fragmentedInit(0))
+	if err != nil {
+		t.Fatalf("NewFragmentedDecoder: %v", err)
+	}
+
+	if format := dec.Format(); format.SampleRate != fragSampleRate || format.Channels != fragChannels {
+		t.Fatalf("unexpected format: %+v", format)
+	}
+
+	if _, err := dec.Read(make([]byte, 16)); !errors.Is(err, io.EOF) {
+		t.Fatalf("Read before any fragment: got %v, want io.EOF", err)
+	}
+
+	var got []byte
+
+	for _, part := range [][][]byte{packets[:half], packets[half:]} {
+		if err := dec.AppendFragment(buildFragment(fragTrackID, 0x020000, 0, true, part)); err != nil {
+			t.Fatalf("AppendFragment: %v", err)
+		}
+
+		out, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+
+		got = append(got, out...)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestFragmentedDecoder_DefaultSampleSizes(t *testing.T) {
+	t.Parallel()
+
+	// Only full packets share one size.
+	pcm := agar.GenerateWhiteNoise(fragSampleRate, fragBitDepth, fragChannels, 1)
+	pcm = pcm[:len(pcm)-len(pcm)%(fragFrameLength*fragChannels*2)]
+	packets := fragmentPackets(pcm)
+	size := len(packets[0])
+
+	for _, tc := range []struct {
+		name     string
+		trexSize int
+		tfhdSize int
+	}{
+		{"trex", size, 0},
+		{"tfhd", 0, size},
+		{"tfhd over trex", size + 1, size},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewFragmentedDecoder(fragmentedInit(tc.trexSize))
+			if err != nil {
+				t.Fatalf("NewFragmentedDecoder: %v", err)
+			}
+
+			if err := dec.AppendFragment(buildFragment(fragTrackID, 0x020000, tc.tfhdSize, false, packets)); err != nil {
+				t.Fatalf("AppendFragment: %v", err)
+			}
+
+			got, err := io.ReadAll(dec)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			if !bytes.Equal(got, pcm) {
+				t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+			}
+		})
+	}
+}
+
+func TestFragmentedDecoder_OtherTrack(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(fragSampleRate, fragBitDepth, fragChannels, 1)
+
+	dec, err := alac.NewFragmentedDecoder(fragmentedInit(0))
+	if err != nil {
+		t.Fatalf("NewFragmentedDecoder: %v", err)
+	}
+
+	if err := dec.AppendFragment(buildFragment(fragTrackID+1, 0x020000, 0, true, fragmentPackets(pcm))); err != nil {
+		t.Fatalf("AppendFragment: %v", err)
+	}
+
+	if _, err := dec.Read(make([]byte, 16)); !errors.Is(err, io.EOF) {
+		t.Fatalf("Read: got %v, want io.EOF", err)
+	}
+}
+
+func TestFragmentedDecoder_Malformed(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(fragSampleRate, fragBitDepth, fragChannels, 1)
+	packets := fragmentPackets(pcm)
+	good := buildFragment(fragTrackID, 0x020000, 0, true, packets)
+
+	for _, tc := range []struct {
+		name     string
+		fragment []byte
+	}{
+		{"absolute base offset", buildFragment(fragTrackID, 0x000001, 0, true, packets)},
+		{"no sample size", buildFragment(fragTrackID, 0x020000, 0, false, packets)},
+		{"truncated mdat", good[:len(good)-1]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewFragmentedDecoder(fragmentedInit(0))
+			if err != nil {
+				t.Fatalf("NewFragmentedDecoder: %v", err)
+			}
+
+			if err := dec.AppendFragment(tc.fragment); !errors.Is(err, alac.ErrNoTrack) {
+				t.Fatalf("AppendFragment: got %v, want ErrNoTrack", err)
+			}
+
+			if _, err := dec.Read(make([]byte, 16)); !errors.Is(err, io.EOF) {
+				t.Fatalf("Read after a rejected fragment: got %v, want io.EOF", err)
+			}
+		})
+	}
+}
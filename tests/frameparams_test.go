@@ -0,0 +1,66 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestWithFrameParamSink(t *testing.T) {
+	t.Parallel()
+
+	// 3 channels are coded as SCE (C) then CPE (L, R).
+	m4a, pcm := syntheticM4A(t, 8000, 16, 3)
+
+	var got []alac.FrameParams
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithFrameParamSink(func(p alac.FrameParams) {
+		got = append(got, p)
+	}))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := io.ReadAll(dec); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	packets := (len(pcm)/6 + 4095) / 4096
+	if len(got) != packets*2 {
+		t.Fatalf("got %d element callbacks, want %d", len(got), packets*2)
+	}
+
+	for idx, params := range got {
+		wantPair := idx%2 == 1
+		wantChannel := 2
+		if wantPair {
+			wantChannel = 0
+		}
+
+		if params.Pair != wantPair || params.Channel != wantChannel || !params.Escape {
+			t.Fatalf("element %d: got %+v, want Pair=%v Channel=%d Escape=true", idx, params, wantPair, wantChannel)
+		}
+
+		if params.NumSamples == 0 || params.NumSamples > 4096 {
+			t.Fatalf("element %d: NumSamples = %d", idx, params.NumSamples)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecoder_Frames(t *testing.T) {
+	t.Parallel()
+
+	const sampleRate = 8000
+
+	m4a, pcm := syntheticM4A(t, sampleRate, 16, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	var (
+		got   []byte
+		count int
+	)
+
+	for ts, frame := range dec.Frames() {
+		want := time.Duration(count) * 4096 * time.Second / sampleRate
+		if ts != want {
+			t.Fatalf("packet %d: timestamp %v, want %v", count, ts, want)
+		}
+
+		got = append(got, frame...)
+		count++
+	}
+
+	if err := dec.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("iterated PCM mismatch: got %d bytes, want %d", len(got), len(pcm))
+	}
+}
+
+func TestDecoder_FramesPresentationTime(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleRate = 8000
+		priming    = 2112
+	)
+
+	pcm := agar.GenerateWhiteNoise(sampleRate, 16, 2, 1)
+	frames := len(pcm) / 4
+
+	// A single edit presenting everything after the priming frames.
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:     sampleRate,
+		BitDepth:       16,
+		Channels:       2,
+		PCM:            pcm,
+		ExtraTrakBoxes: [][]byte{editListBox([2]int{frames - priming, priming})},
+	})
+
+	frameTime := func(frame int) time.Duration { return time.Duration(frame) * time.Second / sampleRate }
+
+	firstTimestamp := func(t *testing.T, dec *alac.Decoder) time.Duration {
+		t.Helper()
+
+		for ts := range dec.Frames() {
+			return ts
+		}
+
+		t.Fatalf("Frames yielded nothing: %v", dec.Err())
+
+		return 0
+	}
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// The priming frames come first, before presentation time zero.
+	if pos, ts := dec.Position(), firstTimestamp(t, dec); ts != pos || ts != -frameTime(priming) {
+		t.Fatalf("first timestamp %v, Position() %v, want %v", ts, pos, -frameTime(priming))
+	}
+
+	if _, err := dec.Seek(0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	if pos, ts := dec.Position(), firstTimestamp(t, dec); ts != pos || ts != 0 {
+		t.Fatalf("after Seek(0): first timestamp %v, Position() %v, want 0", ts, pos)
+	}
+}
+
+func TestDecoder_FramesBreakAndError(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 1)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a[:len(m4a)-5]))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// Breaking out leaves the decoder after the yielded packet.
+	for range dec.Frames() {
+		break
+	}
+
+	if got, want := dec.Position(), 4096*time.Second/8000; got != want {
+		t.Fatalf("Position after break = %v, want %v", got, want)
+	}
+
+	for range dec.Frames() { //nolint:revive // drain the iterator
+	}
+
+	if !errors.Is(dec.Err(), alac.ErrTruncatedStream) {
+		t.Fatalf("Err = %v, want ErrTruncatedStream", dec.Err())
+	}
+}
+
+func TestDecoder_ReadZeroCopy(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// Mix a copying Read with zero-copy reads: the first zero-copy slice is
+	// the remainder of the packet Read started.
+	got := make([]byte, 7)
+	if _, err := io.ReadFull(dec, got); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+
+	for {
+		chunk, err := dec.ReadZeroCopy()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("ReadZeroCopy: %v", err)
+		}
+
+		got = append(got, chunk...)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("zero-copy PCM mismatch: got %d bytes, want %d", len(got), len(pcm))
+	}
+}
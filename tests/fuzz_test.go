@@ -0,0 +1,126 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// FuzzFindALACTrack feeds arbitrary bytes through container parsing and
+// decoding. Any outcome but a panic, a hang or a runaway allocation is fine.
+func FuzzFindALACTrack(f *testing.F) {
+	valid := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 8000,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        make([]byte, 5000*4),
+	})
+
+	f.Add(valid)
+	f.Add(testutil.BuildMKV(testutil.Cookie(4096, 16, 2, 8000),
+		[][]byte{testutil.EncodeVerbatimPacket(make([]byte, 4096*4), 16, 2, 4096)}))
+
+	// Extended 64-bit box sizes near math.MaxUint64, at the root and inside moov.
+	huge := testutil.U64(math.MaxUint64 - 7)
+	f.Add(append(append(testutil.U32(1), "moov"...), huge...))
+	f.Add(append(append(testutil.U32(1), "free"...), testutil.U64(math.MaxInt64)...))
+
+	nested := bytes.Clone(valid)
+	if moov := bytes.Index(nested, []byte("moov")); moov >= 4 {
+		nested = append(nested[:moov+4:moov+4], append(testutil.U32(1), append([]byte("trak"), huge...)...)...)
+		f.Add(nested)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dec, err := alac.NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			if !errors.Is(err, alac.ErrNoTrack) && !errors.Is(err, alac.ErrConfig) {
+				t.Fatalf("unexpected error class: %v", err)
+			}
+
+			return
+		}
+
+		// Bound the work per input: the declared stream may be long.
+		_, _ = io.CopyN(io.Discard, dec, 1<<20)
+	})
+}
+
+func TestFindALACTrack_ExtendedSizeOverflow(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []uint64{math.MaxUint64, math.MaxInt64 + 1, math.MaxInt64, 1 << 40} {
+		data := append(append(testutil.U32(1), "moov"...), testutil.U64(size)...)
+
+		_, err := alac.NewDecoder(bytes.NewReader(data))
+		if !errors.Is(err, alac.ErrNoTrack) {
+			t.Fatalf("size %d: expected ErrNoTrack, got: %v", size, err)
+		}
+	}
+}
+
+// FuzzParseMagicCookie feeds arbitrary bytes to ParseMagicCookie, bare and
+// behind the legacy frma/alac wrappers. It must not panic, and any
+// configuration it accepts must pass Validate and survive a MarshalCookie
+// round trip.
+func FuzzParseMagicCookie(f *testing.F) {
+	for _, cfg := range []struct{ frameLength, bitDepth, channels, sampleRate int }{
+		{4096, 16, 2, 44100},
+		{4096, 24, 6, 96000},
+		{352, 20, 1, 8000},
+		{4096, 32, 8, 192000},
+	} {
+		cookie := testutil.Cookie(cfg.frameLength, cfg.bitDepth, cfg.channels, cfg.sampleRate)
+		alacAtom := testutil.FullBox("alac", cookie)
+		frma := testutil.Box("frma", []byte("alac"))
+
+		f.Add(cookie)
+		f.Add(alacAtom)
+		f.Add(append(bytes.Clone(frma), alacAtom...))
+		f.Add(append(bytes.Clone(frma), alacAtom[:14]...))
+	}
+
+	f.Add([]byte("\x00\x00\x00\x0cfrma"))
+	f.Add([]byte("\x00\x00\x00\x0calac\x00\x00\x00\x00"))
+
+	f.Fuzz(func(t *testing.T, cookie []byte) {
+		config, err := alac.ParseMagicCookie(cookie)
+		if err != nil {
+			if !errors.Is(err, alac.ErrConfig) {
+				t.Fatalf("unexpected error class: %v", err)
+			}
+
+			return
+		}
+
+		if err := config.Validate(); err != nil {
+			t.Fatalf("accepted config %v fails Validate: %v", config, err)
+		}
+
+		again, err := alac.ParseMagicCookie(config.MarshalCookie())
+		if err != nil || again != config {
+			t.Fatalf("round trip of %v: got %v, %v", config, again, err)
+		}
+	})
+}
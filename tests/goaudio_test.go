@@ -0,0 +1,79 @@
+//go:build goaudio
+
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/goaudio"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestGoAudio_IntBuffer(t *testing.T) {
+	t.Parallel()
+
+	for _, depth := range []int{16, 20, 24, 32} {
+		const channels = 2
+
+		m4a, pcm := syntheticM4A(t, 8000, depth, channels)
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+		if err != nil {
+			t.Fatalf("%d-bit: NewDecoder: %v", depth, err)
+		}
+
+		buf, err := goaudio.IntBuffer(dec)
+		if err != nil {
+			t.Fatalf("%d-bit: IntBuffer: %v", depth, err)
+		}
+
+		if buf.Format.NumChannels != channels || buf.Format.SampleRate != 8000 || buf.SourceBitDepth != depth {
+			t.Fatalf("%d-bit: unexpected format %+v, source depth %d", depth, *buf.Format, buf.SourceBitDepth)
+		}
+
+		bps := testutil.BytesPerSample(depth)
+		if len(buf.Data) != len(pcm)/bps {
+			t.Fatalf("%d-bit: got %d samples, want %d", depth, len(buf.Data), len(pcm)/bps)
+		}
+
+		for idx, got := range buf.Data {
+			if want := int(testutil.PCMSample(pcm[idx*bps:], depth)); got != want {
+				t.Fatalf("%d-bit sample %d: got %d, want %d", depth, idx, got, want)
+			}
+		}
+	}
+}
+
+func TestGoAudio_RejectsFloat(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 1)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithSampleFormat(alac.SampleFloat32))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := goaudio.IntBuffer(dec); !errors.Is(err, goaudio.ErrSampleFormat) {
+		t.Fatalf("expected ErrSampleFormat, got: %v", err)
+	}
+}
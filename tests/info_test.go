@@ -0,0 +1,72 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecoder_Info(t *testing.T) {
+	t.Parallel()
+
+	elst := testutil.Box("edts", testutil.FullBox("elst", testutil.U32(1), testutil.U32(8000), testutil.U32(0),
+		testutil.U32(0x00010000)))
+	mvex := testutil.Box("mvex", testutil.FullBox("trex", make([]byte, 20)))
+
+	for _, tc := range []struct {
+		name string
+		trak [][]byte
+		moov [][]byte
+		want alac.StreamInfo
+	}{
+		{"plain", nil, nil, alac.StreamInfo{Seekable: true, Container: alac.ContainerMP4}},
+		{"edit list", [][]byte{elst}, nil, alac.StreamInfo{Seekable: true, Gapless: true, Container: alac.ContainerMP4}},
+		{"fragmented", nil, [][]byte{mvex}, alac.StreamInfo{Seekable: true, Container: alac.ContainerFragmentedMP4}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+				SampleRate:     8000,
+				BitDepth:       16,
+				Channels:       1,
+				PCM:            agar.GenerateWhiteNoise(8000, 16, 1, 1),
+				ExtraTrakBoxes: tc.trak,
+				ExtraMoovBoxes: tc.moov,
+			})
+
+			dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			if got := dec.Info(); got != tc.want {
+				t.Fatalf("Info() = %+v, want %+v", got, tc.want)
+			}
+
+			if dec.CanSeek() != tc.want.Seekable {
+				t.Fatalf("CanSeek() = %v, want %v", dec.CanSeek(), tc.want.Seekable)
+			}
+		})
+	}
+}
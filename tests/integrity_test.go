@@ -0,0 +1,77 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestIntegrityHint(t *testing.T) {
+	t.Parallel()
+
+	clean, _ := syntheticM4A(t, 8000, 16, 2)
+
+	// A cookie bound smaller than the verbatim packets.
+	cookie := testutil.Cookie(4096, 16, 2, 8000)
+	binary.BigEndian.PutUint32(cookie[12:16], 1024)
+
+	_, pcm := syntheticM4A(t, 8000, 16, 2)
+	oversized := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 8000,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        pcm,
+		Cookie:     cookie,
+	})
+
+	for _, tc := range []struct {
+		name string
+		m4a  []byte
+		want bool
+	}{
+		{"clean", clean, true},
+		{"trailing bytes", paddedPacketM4A(t), false},
+		{"over MaxFrameBytes", oversized, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewDecoder(bytes.NewReader(tc.m4a))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			if !dec.IntegrityHint() {
+				t.Fatal("IntegrityHint = false before decoding")
+			}
+
+			if _, err := io.ReadAll(dec); err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			if got := dec.IntegrityHint(); got != tc.want {
+				t.Fatalf("IntegrityHint = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecoder_IsKeyFrame(t *testing.T) {
+	t.Parallel()
+
+	// sgpd v1 'roll' with two 2-byte entries: distance 0 and distance -1.
+	sgpd := testutil.Box("sgpd", testutil.U32(1<<24), []byte("roll"), testutil.U32(2), testutil.U32(2),
+		testutil.U16(0), testutil.U16(0xFFFF))
+
+	// sbgp: 2 ungrouped, 1 in entry 2, 1 in entry 1, 3 in entry 2, rest ungrouped.
+	sbgp := testutil.FullBox("sbgp", []byte("roll"), testutil.U32(4),
+		testutil.U32(2), testutil.U32(0),
+		testutil.U32(1), testutil.U32(2),
+		testutil.U32(1), testutil.U32(1),
+		testutil.U32(3), testutil.U32(2),
+	)
+
+	// A grouping type other than roll/prol is ignored.
+	other := testutil.FullBox("sbgp", []byte("rap "), testutil.U32(1), testutil.U32(8), testutil.U32(1))
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+
+	for _, tc := range []struct {
+		name  string
+		boxes [][]byte
+		key   []bool
+	}{
+		{"no sample groups", nil, []bool{true, true, true, true, true, true, true, true}},
+		{"roll groups", [][]byte{sgpd, sbgp, other}, []bool{true, true, false, true, false, false, false, true}},
+		{"mapping without description", [][]byte{sbgp}, []bool{true, true, true, true, true, true, true, true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewDecoder(bytes.NewReader(testutil.BuildM4A(testutil.SyntheticM4A{
+				SampleRate:     8000,
+				BitDepth:       16,
+				Channels:       2,
+				FrameLength:    1000,
+				PCM:            pcm,
+				ExtraStblBoxes: tc.boxes,
+			})))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			for idx, want := range tc.key {
+				if got := dec.IsKeyFrame(idx); got != want {
+					t.Fatalf("IsKeyFrame(%d) = %v, want %v", idx, got, want)
+				}
+			}
+
+			if dec.IsKeyFrame(-1) || dec.IsKeyFrame(len(tc.key)) {
+				t.Fatal("IsKeyFrame reported an out-of-range packet as a key frame")
+			}
+
+			got, err := io.ReadAll(dec)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			if !bytes.Equal(got, pcm) {
+				t.Fatal("decoded PCM mismatch")
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// strayBitsPacket returns a stereo verbatim packet and a copy whose first
+// element sets the most significant unused header bit.
+func strayBitsPacket(t *testing.T) ([]byte, []byte, alac.PacketConfig) {
+	t.Helper()
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:1024*4]
+	clean := testutil.EncodeVerbatimPacket(pcm, 16, 2, 1024)
+
+	// Header: tag(3) + instance(4) + unused(12); bit 7 is the first unused bit.
+	stray := bytes.Clone(clean)
+	stray[0] |= 0x01
+
+	config, err := alac.ParseMagicCookie(testutil.Cookie(1024, 16, 2, 8000))
+	if err != nil {
+		t.Fatalf("ParseMagicCookie: %v", err)
+	}
+
+	return clean, stray, config
+}
+
+func TestLenient_StrictRejectsUnusedBits(t *testing.T) {
+	t.Parallel()
+
+	_, stray, config := strayBitsPacket(t)
+
+	dec, err := alac.NewPacketDecoder(config)
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	if _, err = dec.DecodePacket(stray); !errors.Is(err, alac.ErrDecode) {
+		t.Fatalf("expected ErrDecode, got: %v", err)
+	}
+
+	if warnings := dec.Warnings(); len(warnings) != 0 {
+		t.Fatalf("strict decoder recorded warnings: %v", warnings)
+	}
+}
+
+func TestLenient_MatchesStrictAudio(t *testing.T) {
+	t.Parallel()
+
+	clean, stray, config := strayBitsPacket(t)
+
+	strict, err := alac.NewPacketDecoder(config)
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	want, err := strict.DecodePacket(clean)
+	if err != nil {
+		t.Fatalf("strict DecodePacket: %v", err)
+	}
+
+	lenient, err := alac.NewPacketDecoder(config, alac.WithLenient())
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	if _, err = lenient.DecodePacket(clean); err != nil {
+		t.Fatalf("lenient DecodePacket: %v", err)
+	}
+
+	got, err := lenient.DecodePacket(stray)
+	if err != nil {
+		t.Fatalf("lenient DecodePacket: %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatal("lenient output differs from strict decode of the clean packet")
+	}
+
+	warnings := lenient.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("got %d warnings, want 1", len(warnings))
+	}
+
+	if warnings[0].Packet != 1 || warnings[0].Channel != 0 {
+		t.Errorf("warning at packet %d channel %d, want packet 1 channel 0", warnings[0].Packet, warnings[0].Channel)
+	}
+
+	if warnings[0].Err == nil {
+		t.Error("warning carries no error")
+	}
+}
+
+func TestLenient_DecoderWarnings(t *testing.T) {
+	t.Parallel()
+
+	clean, stray, _ := strayBitsPacket(t)
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    2,
+		FrameLength: 1024,
+		Packets:     [][]byte{clean, clean, stray},
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithLenient())
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	pcm, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if len(pcm) != 3*1024*4 {
+		t.Fatalf("got %d bytes, want %d", len(pcm), 3*1024*4)
+	}
+
+	warnings := dec.Warnings()
+	if len(warnings) != 1 || warnings[0].Packet != 2 {
+		t.Fatalf("got warnings %+v, want one for packet 2", warnings)
+	}
+}
+
+func TestLenient_ZeroFrameLength(t *testing.T) {
+	t.Parallel()
+
+	// A cookie whose frame length field is zero, over packets of the default 4096 frames.
+	cookie := testutil.Cookie(4096, 16, 2, 8000)
+	clear(cookie[0:4])
+
+	if _, err := alac.ParseMagicCookie(cookie); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("ParseMagicCookie: expected ErrConfig, got: %v", err)
+	}
+
+	_, pcm := syntheticM4A(t, 8000, 16, 2)
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 8000,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        pcm,
+		Cookie:     cookie,
+	})
+
+	if _, err := alac.NewDecoder(bytes.NewReader(m4a)); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("strict NewDecoder: expected ErrConfig, got: %v", err)
+	}
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithLenient())
+	if err != nil {
+		t.Fatalf("lenient NewDecoder: %v", err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil || !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes (%v), want the %d-byte source", len(got), err, len(pcm))
+	}
+
+	warnings := dec.Warnings()
+	if len(warnings) != 1 || warnings[0].Packet != -1 || !errors.Is(warnings[0].Err, alacint.ErrZeroFrameLength) {
+		t.Fatalf("unexpected warnings: %+v", warnings)
+	}
+
+	// The raw configuration is still rejected by Validate and strict decoders.
+	config := alac.PacketConfig{BitDepth: 16, NumChannels: 2, SampleRate: 8000}
+	if err := config.Validate(); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("Validate: expected ErrConfig, got: %v", err)
+	}
+
+	if _, err := alac.NewPacketDecoder(config); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("strict NewPacketDecoder: expected ErrConfig, got: %v", err)
+	}
+
+	packetDec, err := alac.NewPacketDecoder(config, alac.WithLenient())
+	if err != nil {
+		t.Fatalf("lenient NewPacketDecoder: %v", err)
+	}
+
+	out, err := packetDec.DecodePacket(testutil.EncodeVerbatimPacket(pcm[:4096*4], 16, 2, 4096))
+	if err != nil || !bytes.Equal(out, pcm[:4096*4]) {
+		t.Fatalf("DecodePacket: %d bytes (%v), want the first packet", len(out), err)
+	}
+}
+
+// TestLenient_MissingChannels decodes a 3.0 packet whose END element follows
+// the center channel, after a complete one: strict decoding rejects it, and
+// lenient decoding zeroes the left and right channels rather than returning
+// the previous packet's samples.
+func TestLenient_MissingChannels(t *testing.T) {
+	t.Parallel()
+
+	const (
+		tagSCE      = 0
+		tagCPE      = 1
+		tagEND      = 7
+		frameLength = 16
+	)
+
+	packet := func(elements ...[]int32) []byte {
+		var bw testutil.BitWriter
+
+		for _, values := range elements {
+			tag := tagSCE
+			if len(values) == 2 {
+				tag = tagCPE
+			}
+
+			testutil.WriteElementHeader(&bw, tag, frameLength, frameLength)
+
+			for range frameLength {
+				for _, value := range values {
+					testutil.WriteEscapeSamples(&bw, value, 16)
+				}
+			}
+		}
+
+		bw.Write(tagEND, 3)
+		bw.ByteAlign()
+
+		return bw.Bytes()
+	}
+
+	full := packet([]int32{100}, []int32{200, 300})
+	short := packet([]int32{7})
+	config := alac.PacketConfig{FrameLength: frameLength, BitDepth: 16, NumChannels: 3, SampleRate: 8000}
+
+	strict, err := alac.NewPacketDecoder(config)
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	if _, err := strict.DecodePacket(short); !errors.Is(err, alac.ErrDecode) {
+		t.Fatalf("strict: expected ErrDecode, got: %v", err)
+	}
+
+	lenient, err := alac.NewPacketDecoder(config, alac.WithLenient())
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	if _, err := lenient.DecodePacket(full); err != nil {
+		t.Fatalf("full packet: %v", err)
+	}
+
+	pcm, err := lenient.DecodePacket(short)
+	if err != nil {
+		t.Fatalf("short packet: %v", err)
+	}
+
+	// SMPTE 3.0: L, R, C.
+	want := []int32{0, 0, 7}
+
+	for frame := range frameLength {
+		for ch, value := range want {
+			if got := testutil.PCMSample(pcm[(frame*3+ch)*2:], 16); got != value {
+				t.Fatalf("frame %d position %d: got %d, want %d", frame, ch, got, value)
+			}
+		}
+	}
+
+	warnings := lenient.Warnings()
+	if len(warnings) != 1 || warnings[0].Packet != 1 || warnings[0].Channel != 0 {
+		t.Fatalf("unexpected warnings: %+v", warnings)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+const (
+	mkvRate        = 8000
+	mkvChannels    = 2
+	mkvFrameLength = 1024
+	mkvFrameBytes  = mkvChannels * 2
+)
+
+// mkvSource returns whole 16-bit stereo packets of white noise, all the same
+// size, with the PCM they carry.
+func mkvSource() ([][]byte, []byte) {
+	pcm := agar.GenerateWhiteNoise(mkvRate, 16, mkvChannels, 1)
+	chunk := mkvFrameLength * mkvFrameBytes
+	pcm = pcm[:len(pcm)-len(pcm)%chunk]
+
+	var packets [][]byte
+	for off := 0; off < len(pcm); off += chunk {
+		packets = append(packets, testutil.EncodeVerbatimPacket(pcm[off:off+chunk], 16, mkvChannels, mkvFrameLength))
+	}
+
+	return packets, pcm
+}
+
+// mkvTracks returns a Tracks element with the ALAC track as number 1 and an
+// unrelated track 2.
+func mkvTracks() []byte {
+	cookie := testutil.Cookie(mkvFrameLength, 16, mkvChannels, mkvRate)
+
+	return testutil.MKVElement(testutil.MKVTracksID,
+		testutil.MKVTrackEntry(2, "A_OPUS", []byte("OpusHead")),
+		testutil.MKVTrackEntry(1, "A_ALAC", cookie),
+	)
+}
+
+// decodeMKV decodes a whole Matroska stream.
+func decodeMKV(t *testing.T, mkv []byte) []byte {
+	t.Helper()
+
+	dec, err := alac.NewDecoder(bytes.NewReader(mkv))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got := dec.Info().Container; got != alac.ContainerMatroska {
+		t.Fatalf("Info().Container = %v, want mkv", got)
+	}
+
+	out, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	return out
+}
+
+func TestMatroska_Decode(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(mkvRate, 24, 1, 1)
+
+	var packets [][]byte
+	for off := 0; off < len(pcm); off += 4096 * 3 {
+		packets = append(packets, testutil.EncodeVerbatimPacket(pcm[off:min(off+4096*3, len(pcm))], 24, 1, 4096))
+	}
+
+	mkv := testutil.BuildMKV(testutil.Cookie(4096, 24, 1, mkvRate), packets)
+
+	if got := decodeMKV(t, mkv); !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+
+	dec, err := alac.NewDecoderAt(bytes.NewReader(mkv), int64(len(mkv)))
+	if err != nil {
+		t.Fatalf("NewDecoderAt: %v", err)
+	}
+
+	if _, err := dec.Seek(0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	if got, err := io.ReadAll(dec); err != nil || !bytes.Equal(got, pcm) {
+		t.Fatalf("NewDecoderAt: ReadAll returned %d bytes, %v", len(got), err)
+	}
+}
+
+func TestMatroska_Layouts(t *testing.T) {
+	t.Parallel()
+
+	packets, pcm := mkvSource()
+	half := len(packets) / 2
+
+	simpleBlocks := func(packets [][]byte) [][]byte {
+		var out [][]byte
+
+		for _, packet := range packets {
+			out = append(out,
+				testutil.MKVElement(testutil.MKVSimpleBlockID, testutil.MKVBlock(2, 0x80, []byte("not alac"))),
+				testutil.MKVElement(testutil.MKVSimpleBlockID, testutil.MKVBlock(1, 0x80, packet)))
+		}
+
+		return out
+	}
+
+	var blockGroups [][]byte
+	for _, packet := range packets {
+		blockGroups = append(blockGroups, testutil.MKVElement(testutil.MKVBlockGroupID,
+			testutil.MKVElement(testutil.MKVBlockID, testutil.MKVBlock(1, 0, packet))))
+	}
+
+	// Laced blocks of two frames each.
+	size := len(packets[0])
+	ebmlSize := binary.BigEndian.AppendUint64(nil, uint64(size))
+	ebmlSize[0] = 0x01
+
+	var xiph, ebml, fixed [][]byte
+
+	for idx := 0; idx+1 < len(packets); idx += 2 {
+		pair := bytes.Join(packets[idx:idx+2], nil)
+		xiphSize := append(bytes.Repeat([]byte{0xFF}, size/0xFF), byte(size%0xFF))
+
+		xiph = append(xiph, testutil.MKVElement(testutil.MKVSimpleBlockID,
+			testutil.MKVBlock(1, 0x82, []byte{1}, xiphSize, pair)))
+		ebml = append(ebml, testutil.MKVElement(testutil.MKVSimpleBlockID,
+			testutil.MKVBlock(1, 0x86, []byte{1}, ebmlSize, pair)))
+		fixed = append(fixed, testutil.MKVElement(testutil.MKVSimpleBlockID,
+			testutil.MKVBlock(1, 0x84, []byte{1}, pair)))
+	}
+
+	even := pcm[:len(packets)/2*2*mkvFrameLength*mkvFrameBytes]
+	cues := testutil.MKVElement(testutil.MKVCuesID)
+
+	for _, tc := range []struct {
+		name string
+		mkv  []byte
+		want []byte
+	}{
+		{"simple blocks among another track", testutil.MKVStream(mkvTracks(),
+			testutil.MKVElement(testutil.MKVClusterID, simpleBlocks(packets)...)), pcm},
+		{"block groups", testutil.MKVStream(mkvTracks(),
+			testutil.MKVElement(testutil.MKVClusterID, blockGroups...)), pcm},
+		{"unknown-size clusters", testutil.MKVLiveStream(mkvTracks(),
+			testutil.MKVUnsizedElement(testutil.MKVClusterID, simpleBlocks(packets[:half])...),
+			testutil.MKVUnsizedElement(testutil.MKVClusterID, simpleBlocks(packets[half:])...),
+			cues), pcm},
+		{"xiph lacing", testutil.MKVStream(mkvTracks(), testutil.MKVElement(testutil.MKVClusterID, xiph...)), even},
+		{"ebml lacing", testutil.MKVStream(mkvTracks(), testutil.MKVElement(testutil.MKVClusterID, ebml...)), even},
+		{"fixed lacing", testutil.MKVStream(mkvTracks(), testutil.MKVElement(testutil.MKVClusterID, fixed...)), even},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := decodeMKV(t, tc.mkv); !bytes.Equal(got, tc.want) {
+				t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(tc.want))
+			}
+		})
+	}
+}
+
+func TestMatroska_Errors(t *testing.T) {
+	t.Parallel()
+
+	packets, _ := mkvSource()
+	cookie := testutil.Cookie(mkvFrameLength, 16, mkvChannels, mkvRate)
+	cluster := testutil.MKVElement(testutil.MKVClusterID,
+		testutil.MKVElement(testutil.MKVSimpleBlockID, testutil.MKVBlock(1, 0x80, packets[0])))
+	tracks := func(entries ...[]byte) []byte { return testutil.MKVElement(testutil.MKVTracksID, entries...) }
+
+	for _, tc := range []struct {
+		name string
+		mkv  []byte
+	}{
+		{"no ALAC track", testutil.MKVStream(tracks(testutil.MKVTrackEntry(1, "A_FLAC", cookie)), cluster)},
+		{"no CodecPrivate", testutil.MKVStream(tracks(testutil.MKVTrackEntry(1, "A_ALAC", nil)), cluster)},
+		{"no segment", testutil.MKVStream()[:20]},
+		{"bad lacing", testutil.MKVStream(tracks(testutil.MKVTrackEntry(1, "A_ALAC", cookie)),
+			testutil.MKVElement(testutil.MKVClusterID, testutil.MKVElement(testutil.MKVSimpleBlockID,
+				testutil.MKVBlock(1, 0x84, []byte{1}, packets[0][:5]))))},
+		{"truncated", testutil.BuildMKV(cookie, packets)[:100]},
+	} {
+		if _, err := alac.NewDecoder(bytes.NewReader(tc.mkv)); !errors.Is(err, alac.ErrNoTrack) {
+			t.Errorf("%s: got %v, want ErrNoTrack", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestSeek_PresentationTime(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleRate    = 8000
+		bytesPerFrame = 4 // 16-bit stereo
+		priming       = 2112
+	)
+
+	pcm := agar.GenerateWhiteNoise(sampleRate, 16, 2, 1)
+	frames := len(pcm) / bytesPerFrame
+
+	// A single edit presenting everything after the priming frames.
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:     sampleRate,
+		BitDepth:       16,
+		Channels:       2,
+		PCM:            pcm,
+		ExtraTrakBoxes: [][]byte{editListBox([2]int{frames - priming, priming})},
+	})
+
+	frameTime := func(frame int) time.Duration { return time.Duration(frame) * time.Second / sampleRate }
+
+	readFrom := func(t *testing.T, dec *alac.Decoder, frame int) {
+		t.Helper()
+
+		got, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+
+		if !bytes.Equal(got, pcm[frame*bytesPerFrame:]) {
+			t.Fatalf("got %d bytes, want the source from media frame %d", len(got), frame)
+		}
+	}
+
+	t.Run("presentation", func(t *testing.T) {
+		t.Parallel()
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+		if err != nil {
+			t.Fatalf("NewDecoder: %v", err)
+		}
+
+		if pos := dec.Position(); pos != -frameTime(priming) {
+			t.Fatalf("initial Position() = %v, want %v", pos, -frameTime(priming))
+		}
+
+		for _, frame := range []int{0, 4000, 100} {
+			got, err := dec.Seek(frameTime(frame))
+			if err != nil {
+				t.Fatalf("Seek: %v", err)
+			}
+
+			if got != frameTime(frame) || dec.Position() != got {
+				t.Fatalf("Seek(%v) = %v, Position() = %v", frameTime(frame), got, dec.Position())
+			}
+
+			readFrom(t, dec, priming+frame)
+		}
+	})
+
+	t.Run("media", func(t *testing.T) {
+		t.Parallel()
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithMediaTime())
+		if err != nil {
+			t.Fatalf("NewDecoder: %v", err)
+		}
+
+		if pos := dec.Position(); pos != 0 {
+			t.Fatalf("initial Position() = %v, want 0", pos)
+		}
+
+		got, err := dec.Seek(frameTime(5000))
+		if err != nil {
+			t.Fatalf("Seek: %v", err)
+		}
+
+		// Media-time seeking stays on packet boundaries.
+		if got != frameTime(4096) {
+			t.Fatalf("Seek = %v, want %v", got, frameTime(4096))
+		}
+
+		readFrom(t, dec, 4096)
+	})
+}
+
+func TestPresentationOffset_LargeMediaTime(t *testing.T) {
+	t.Parallel()
+
+	// A version 1 edit starting at media time 2^61 in a 16 kHz media timescale,
+	// for an 8 kHz stream: 2^61 times the sample rate wraps around 64 bits to
+	// zero, so it must be scaled without overflow.
+	entries := testutil.U32(1)
+	entries = append(entries, testutil.U64(8000)...)
+	entries = append(entries, testutil.U64(1<<61)...)
+	entries = append(entries, testutil.U32(0x00010000)...)
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:     16000,
+		BitDepth:       16,
+		Channels:       2,
+		PCM:            agar.GenerateWhiteNoise(8000, 16, 2, 1),
+		Cookie:         testutil.Cookie(4096, 16, 2, 8000),
+		ExtraTrakBoxes: [][]byte{testutil.Box("edts", testutil.Box("elst", []byte{1, 0, 0, 0}, entries))},
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got, want := dec.PositionFrames(), int64(-1<<60); got != want {
+		t.Fatalf("PositionFrames() = %d, want %d", got, want)
+	}
+}
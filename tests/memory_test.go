@@ -0,0 +1,75 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestDecoder_MemoryFootprint(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 4096 // synthetic default
+
+	m4a, _ := syntheticM4A(t, 8000, 24, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// Mixing and predictor buffers (3 × int32), stereo shift buffer (2 × uint16)
+	// and one frame of 24-bit stereo PCM.
+	minimum := frameLength*(3*4+2*2) + frameLength*2*3
+
+	before := dec.MemoryFootprint()
+	if before < minimum {
+		t.Fatalf("MemoryFootprint() = %d before decoding, want at least %d", before, minimum)
+	}
+
+	if _, err := io.Copy(io.Discard, dec); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	after := dec.MemoryFootprint()
+	if after < before {
+		t.Fatalf("MemoryFootprint() shrank from %d to %d", before, after)
+	}
+
+	// Decoding adds only the packet buffer and the bit reader's copy of it,
+	// each bounded by one verbatim packet plus element headers.
+	if maxPacket := 2 * (frameLength*2*3 + 64); after-before > maxPacket {
+		t.Fatalf("MemoryFootprint() grew by %d bytes while decoding, want at most %d", after-before, maxPacket)
+	}
+
+	// Reading again must reuse the buffers.
+	if _, err := dec.Seek(0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	if _, err := io.Copy(io.Discard, dec); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	if again := dec.MemoryFootprint(); again != after {
+		t.Fatalf("MemoryFootprint() = %d after a second pass, want %d", again, after)
+	}
+}
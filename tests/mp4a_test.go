@@ -0,0 +1,147 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// mp4aEntry builds an mp4a sample entry with the given QuickTime sound
+// description version and child boxes.
+func mp4aEntry(version, sampleRate, bitDepth, channels int, children ...[]byte) []byte {
+	parts := [][]byte{
+		make([]byte, 6), testutil.U16(1), testutil.U16(version), testutil.U16(0), testutil.U32(0),
+		testutil.U16(channels), testutil.U16(bitDepth), testutil.U16(0), testutil.U16(0),
+		testutil.U32(sampleRate << 16),
+	}
+
+	if version == 1 {
+		// samplesPerPacket, bytesPerPacket, bytesPerFrame, bytesPerSample.
+		parts = append(parts, make([]byte, 16))
+	}
+
+	return testutil.Box("mp4a", append(parts, children...)...)
+}
+
+func TestDecode_MP4AWrappedALAC(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+	cookie := testutil.FullBox("alac", testutil.Cookie(4096, 16, 2, 8000))
+
+	for _, tc := range []struct {
+		name  string
+		entry []byte
+	}{
+		{"wave v1", mp4aEntry(1, 8000, 16, 2, testutil.Box("wave",
+			testutil.Box("frma", []byte("alac")), cookie, testutil.Box("\x00\x00\x00\x00")))},
+		{"direct alac child", mp4aEntry(0, 8000, 16, 2, cookie)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+				SampleRate:  8000,
+				BitDepth:    16,
+				Channels:    2,
+				PCM:         pcm,
+				SampleEntry: tc.entry,
+			})
+
+			dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			got, err := io.ReadAll(dec)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			if !bytes.Equal(got, pcm) {
+				t.Fatal("decoded PCM differs from input")
+			}
+		})
+	}
+}
+
+func TestDecode_MP4ASiblingBoxes(t *testing.T) {
+	t.Parallel()
+
+	cookie := testutil.FullBox("alac", testutil.Cookie(4096, 16, 2, 8000))
+	btrt := testutil.Box("btrt", testutil.U32(6144), testutil.U32(512000), testutil.U32(300000))
+	chanBox := descriptionsChan(alac.LabelRight, alac.LabelLeft)
+
+	for _, tc := range []struct {
+		name  string
+		entry []byte
+	}{
+		{"mp4a children", mp4aEntry(0, 8000, 16, 2, cookie, btrt, chanBox)},
+		{"wave children", mp4aEntry(1, 8000, 16, 2, testutil.Box("wave",
+			testutil.Box("frma", []byte("alac")), cookie, chanBox, testutil.Box("\x00\x00\x00\x00")), btrt)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+				SampleRate:  8000,
+				BitDepth:    16,
+				Channels:    2,
+				PCM:         agar.GenerateWhiteNoise(8000, 16, 2, 1),
+				SampleEntry: tc.entry,
+			})
+
+			dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			if got := dec.MaxBitRate(); got != 512000 {
+				t.Errorf("MaxBitRate() = %d, want 512000", got)
+			}
+
+			if got, want := dec.ChannelLayout(), []alac.ChannelLabel{alac.LabelRight, alac.LabelLeft}; !slices.Equal(got, want) {
+				t.Errorf("ChannelLayout() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecode_MP4AWithoutALACIsSkipped(t *testing.T) {
+	t.Parallel()
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    2,
+		PCM:         agar.GenerateWhiteNoise(8000, 16, 2, 1),
+		SampleEntry: mp4aEntry(0, 8000, 16, 2, testutil.FullBox("esds", make([]byte, 32))),
+	})
+
+	if _, err := alac.NewDecoder(bytes.NewReader(m4a)); !errors.Is(err, alac.ErrNoTrack) {
+		t.Fatalf("expected ErrNoTrack, got: %v", err)
+	}
+}
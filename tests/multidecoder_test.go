@@ -0,0 +1,131 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestMultiDecoder_Gapless(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleRate    = 8000
+		bytesPerFrame = 4 // 16-bit stereo
+	)
+
+	// Per file: priming frames and trailing padding dropped by the edit list,
+	// or no edit list at all.
+	parts := []struct {
+		priming, padding int
+		editList         bool
+	}{
+		{priming: 2112, padding: 300, editList: true},
+		{},
+		{priming: 100, editList: true},
+	}
+
+	var (
+		readers []io.ReadSeeker
+		want    []byte
+	)
+
+	for _, part := range parts {
+		pcm := agar.GenerateWhiteNoise(sampleRate, 16, 2, 1)
+		frames := len(pcm) / bytesPerFrame
+		spec := testutil.SyntheticM4A{SampleRate: sampleRate, BitDepth: 16, Channels: 2, PCM: pcm}
+
+		if part.editList {
+			spec.ExtraTrakBoxes = [][]byte{editListBox([2]int{frames - part.priming - part.padding, part.priming})}
+		}
+
+		readers = append(readers, bytes.NewReader(testutil.BuildM4A(spec)))
+		want = append(want, pcm[part.priming*bytesPerFrame:(frames-part.padding)*bytesPerFrame]...)
+	}
+
+	dec, err := alac.NewMultiDecoder(readers)
+	if err != nil {
+		t.Fatalf("NewMultiDecoder: %v", err)
+	}
+
+	frameTime := func(frame int) time.Duration { return time.Duration(frame) * time.Second / sampleRate }
+
+	if got := dec.Duration(); got != frameTime(len(want)/bytesPerFrame) {
+		t.Fatalf("Duration() = %v, want %v", got, frameTime(len(want)/bytesPerFrame))
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want %d bytes of trimmed, concatenated PCM", len(got), len(want))
+	}
+
+	// Frames in the first, second and last file, and the exact file boundaries.
+	first := sampleRate - 2112 - 300
+
+	for _, frame := range []int{1000, first + 4321, first, first + sampleRate, first + sampleRate + 7000, 0} {
+		pos, err := dec.Seek(frameTime(frame))
+		if err != nil {
+			t.Fatalf("Seek: %v", err)
+		}
+
+		if pos != frameTime(frame) || dec.Position() != pos {
+			t.Fatalf("Seek(%v) = %v, Position() = %v", frameTime(frame), pos, dec.Position())
+		}
+
+		got, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll after Seek(%v): %v", pos, err)
+		}
+
+		if !bytes.Equal(got, want[frame*bytesPerFrame:]) {
+			t.Fatalf("after Seek(%v): got %d bytes, want %d", pos, len(got), len(want)-frame*bytesPerFrame)
+		}
+	}
+
+	if pos, err := dec.Seek(time.Hour); err != nil || pos != dec.Duration() {
+		t.Fatalf("Seek past end = %v, %v; want %v", pos, err, dec.Duration())
+	}
+
+	if n, err := dec.Read(make([]byte, 16)); n != 0 || err != io.EOF { //nolint:errorlint // io.EOF is returned unwrapped.
+		t.Fatalf("Read at end = %d, %v; want 0, io.EOF", n, err)
+	}
+}
+
+func TestMultiDecoder_FormatMismatch(t *testing.T) {
+	t.Parallel()
+
+	stereo, _ := syntheticM4A(t, 8000, 16, 2)
+	mono, _ := syntheticM4A(t, 8000, 16, 1)
+
+	_, err := alac.NewMultiDecoder([]io.ReadSeeker{bytes.NewReader(stereo), bytes.NewReader(mono)})
+	if !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("expected ErrConfig, got: %v", err)
+	}
+}
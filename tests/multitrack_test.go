@@ -0,0 +1,106 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// nonAudioTrak builds a trak with the given media handler, media header box
+// and sample entry, with an empty sample table.
+func nonAudioTrak(handler string, mediaHeader, entry []byte) []byte {
+	stbl := testutil.Box("stbl",
+		testutil.FullBox("stsd", testutil.U32(1), entry),
+		testutil.FullBox("stts", testutil.U32(0)),
+		testutil.FullBox("stsc", testutil.U32(0)),
+		testutil.FullBox("stsz", testutil.U32(0), testutil.U32(0)),
+		testutil.FullBox("stco", testutil.U32(0)),
+	)
+
+	return testutil.Box("trak",
+		testutil.FullBox("tkhd", make([]byte, 80)),
+		testutil.Box("mdia",
+			testutil.FullBox("mdhd", make([]byte, 20)),
+			testutil.FullBox("hdlr", testutil.U32(0), []byte(handler), make([]byte, 13)),
+			testutil.Box("minf", mediaHeader, testutil.Box("dinf"), stbl),
+		),
+	)
+}
+
+// TestDecode_SkipsNonAudioTracks places tracks the decoder must pass over
+// before the ALAC one: a QuickTime timecode track with a base media header
+// (gmhd), a video track, and a track whose minf holds a malformed box.
+func TestDecode_SkipsNonAudioTracks(t *testing.T) {
+	t.Parallel()
+
+	timecode := nonAudioTrak("tmcd",
+		testutil.Box("gmhd",
+			testutil.FullBox("gmin", make([]byte, 12)),
+			testutil.Box("tmcd", testutil.FullBox("tcmi", make([]byte, 20))),
+		),
+		testutil.Box("tmcd", make([]byte, 6), testutil.U16(1), make([]byte, 18)),
+	)
+	video := nonAudioTrak("vide",
+		testutil.FullBox("vmhd", make([]byte, 8)),
+		testutil.Box("avc1", make([]byte, 78)),
+	)
+	// A child box declaring a size smaller than its own header.
+	damaged := testutil.Box("trak", testutil.Box("mdia", testutil.Box("minf", testutil.U32(4), []byte("junk"))))
+
+	_, pcm := syntheticM4A(t, 8000, 16, 2)
+
+	for _, tc := range []struct {
+		name    string
+		leading [][]byte
+	}{
+		{"timecode", [][]byte{timecode}},
+		{"video", [][]byte{video}},
+		{"damaged", [][]byte{damaged}},
+		{"all", [][]byte{damaged, timecode, video}},
+	} {
+		m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+			SampleRate:       8000,
+			BitDepth:         16,
+			Channels:         2,
+			PCM:              pcm,
+			LeadingMoovBoxes: tc.leading,
+		})
+
+		if _, err := alac.ProbeFormat(bytes.NewReader(m4a)); err != nil {
+			t.Fatalf("%s: ProbeFormat: %v", tc.name, err)
+		}
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+		if err != nil {
+			t.Fatalf("%s: NewDecoder: %v", tc.name, err)
+		}
+
+		got, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("%s: ReadAll: %v", tc.name, err)
+		}
+
+		if !bytes.Equal(got, pcm) {
+			t.Fatalf("%s: decoded PCM differs from source", tc.name)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestNextFrameSize(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	const bytesPerFrame = 4
+
+	if got := dec.NextFrameSize(); got != 4096*bytesPerFrame {
+		t.Fatalf("first packet: NextFrameSize = %d, want %d", got, 4096*bytesPerFrame)
+	}
+
+	if _, err := io.ReadFull(dec, make([]byte, 1000)); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if got := dec.NextFrameSize(); got != 4096*bytesPerFrame-1000 {
+		t.Fatalf("after partial Read: NextFrameSize = %d, want %d", got, 4096*bytesPerFrame-1000)
+	}
+
+	if _, err := dec.ReadZeroCopy(); err != nil {
+		t.Fatalf("ReadZeroCopy: %v", err)
+	}
+
+	size := dec.NextFrameSize()
+	if size != 3904*bytesPerFrame {
+		t.Fatalf("final packet: NextFrameSize = %d, want %d", size, 3904*bytesPerFrame)
+	}
+
+	if n, err := dec.Read(make([]byte, size)); n != size || err != nil {
+		t.Fatalf("Read(%d): got (%d, %v)", size, n, err)
+	}
+
+	if got := dec.NextFrameSize(); got != 0 {
+		t.Fatalf("at end: NextFrameSize = %d, want 0", got)
+	}
+}
+
+// NextFrameSize must predict each packet ReadZeroCopy returns, whatever the trims.
+func TestNextFrameSize_MatchesReadZeroCopy(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 24, 2)
+
+	cases := map[string][]alac.Option{
+		"plain":      nil,
+		"trim start": {alac.WithTrimStart(5000)},
+		"trim end":   {alac.WithTrimEnd(100)},
+		"trim both":  {alac.WithTrimStart(10), alac.WithTrimEnd(4000)},
+		"padded":     {alac.WithPaddedFinalFrame(), alac.WithTrimStart(4100)},
+	}
+
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewDecoder(bytes.NewReader(m4a), opts...)
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			for packet := 0; ; packet++ {
+				want := dec.NextFrameSize()
+
+				pcm, err := dec.ReadZeroCopy()
+				if errors.Is(err, io.EOF) {
+					if want != 0 {
+						t.Fatalf("at end: NextFrameSize = %d, want 0", want)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("ReadZeroCopy: %v", err)
+				}
+
+				if len(pcm) != want {
+					t.Fatalf("packet %d: NextFrameSize = %d, ReadZeroCopy returned %d bytes", packet, want, len(pcm))
+				}
+			}
+		})
+	}
+}
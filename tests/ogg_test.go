@@ -0,0 +1,187 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+const (
+	oggSerial = 0x414C4143
+	// oggPageBody is smaller than one 24-bit mono packet, so packets span pages.
+	oggPageBody = 4000
+)
+
+// oggSource returns 24-bit mono verbatim packets of white noise, with the PCM
+// they carry.
+func oggSource() ([][]byte, []byte) {
+	pcm := agar.GenerateWhiteNoise(mkvRate, 24, 1, 1)
+
+	var packets [][]byte
+	for off := 0; off < len(pcm); off += 4096 * 3 {
+		packets = append(packets, testutil.EncodeVerbatimPacket(pcm[off:min(off+4096*3, len(pcm))], 24, 1, 4096))
+	}
+
+	return packets, pcm
+}
+
+// decodeOgg decodes a whole Ogg stream through both NewDecoder and
+// NewDecoderAt and checks that they agree.
+func decodeOgg(t *testing.T, ogg []byte) []byte {
+	t.Helper()
+
+	dec, err := alac.NewDecoder(bytes.NewReader(ogg))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got := dec.Info().Container; got != alac.ContainerOgg {
+		t.Fatalf("Info().Container = %v, want ogg", got)
+	}
+
+	out, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	decAt, err := alac.NewDecoderAt(bytes.NewReader(ogg), int64(len(ogg)))
+	if err != nil {
+		t.Fatalf("NewDecoderAt: %v", err)
+	}
+
+	if got, err := io.ReadAll(decAt); err != nil || !bytes.Equal(got, out) {
+		t.Fatalf("NewDecoderAt: ReadAll returned %d bytes, %v; NewDecoder returned %d", len(got), err, len(out))
+	}
+
+	return out
+}
+
+func TestOgg_Decode(t *testing.T) {
+	t.Parallel()
+
+	packets, pcm := oggSource()
+	ogg := testutil.BuildOgg(oggSerial, testutil.Cookie(4096, 24, 1, mkvRate), packets, oggPageBody)
+
+	if got := decodeOgg(t, ogg); !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+
+	dec, err := alac.NewDecoder(bytes.NewReader(ogg))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// Seeks land on the start of the packet holding the target frame.
+	frame, err := dec.SeekFrames(5000)
+	if err != nil || frame != 4096 {
+		t.Fatalf("SeekFrames(5000) = %d, %v, want 4096", frame, err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil || !bytes.Equal(got, pcm[frame*3:]) {
+		t.Fatalf("after SeekFrames: ReadAll returned %d bytes, %v", len(got), err)
+	}
+}
+
+func TestOgg_AtomCookie(t *testing.T) {
+	t.Parallel()
+
+	packets, pcm := oggSource()
+	cookie := testutil.FullBox("alac", testutil.Cookie(4096, 24, 1, mkvRate))
+	ogg := testutil.BuildOgg(oggSerial, cookie, packets, oggPageBody)
+
+	if got := decodeOgg(t, ogg); !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestOgg_Multiplexed(t *testing.T) {
+	t.Parallel()
+
+	packets, pcm := oggSource()
+	alacPages := testutil.OggPages(oggSerial, append([][]byte{testutil.Cookie(4096, 24, 1, mkvRate)}, packets...),
+		oggPageBody)
+	opusPages := testutil.OggPages(7, [][]byte{[]byte("OpusHead"), []byte("OpusTags"),
+		bytes.Repeat([]byte{0xAA}, 9000), bytes.Repeat([]byte{0x55}, 9000)}, 1000)
+
+	// Every beginning-of-stream page precedes the data pages, which interleave.
+	ogg := append(append([]byte{}, opusPages[0]...), alacPages[0]...)
+
+	for idx := 1; idx < max(len(alacPages), len(opusPages)); idx++ {
+		if idx < len(opusPages) {
+			ogg = append(ogg, opusPages[idx]...)
+		}
+
+		if idx < len(alacPages) {
+			ogg = append(ogg, alacPages[idx]...)
+		}
+	}
+
+	if got := decodeOgg(t, ogg); !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestOgg_TruncatedPage(t *testing.T) {
+	t.Parallel()
+
+	packets, pcm := oggSource()
+	pages := testutil.OggPages(oggSerial, append([][]byte{testutil.Cookie(4096, 24, 1, mkvRate)}, packets...),
+		oggPageBody)
+
+	// Cut the final page short: the packet it completes is lost, the rest decode.
+	last := pages[len(pages)-1]
+	pages[len(pages)-1] = last[:len(last)/2]
+	ogg := bytes.Join(pages, nil)
+
+	got := decodeOgg(t, ogg)
+	if len(got) == 0 || len(got) >= len(pcm) || !bytes.Equal(got, pcm[:len(got)]) {
+		t.Fatalf("decoded %d bytes, want a prefix of the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestOgg_NoTrack(t *testing.T) {
+	t.Parallel()
+
+	packets, _ := oggSource()
+	cookie := testutil.Cookie(4096, 24, 1, mkvRate)
+
+	// Break the capture pattern of the first page past the identification page.
+	badCapture := testutil.BuildOgg(oggSerial, cookie, packets, oggPageBody)
+	badCapture[len(testutil.OggPages(oggSerial, [][]byte{cookie}, oggPageBody)[0])+3] = 'X'
+
+	for name, ogg := range map[string][]byte{
+		"foreign stream": testutil.BuildOgg(oggSerial, []byte("OpusHead\x01\x02"), packets, oggPageBody),
+		"bad capture":    badCapture,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := alac.NewDecoder(bytes.NewReader(ogg)); !errors.Is(err, alac.ErrNoTrack) {
+				t.Fatalf("expected ErrNoTrack, got: %v", err)
+			}
+		})
+	}
+}
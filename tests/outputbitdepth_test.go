@@ -0,0 +1,181 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestWithOutputBitDepth(t *testing.T) {
+	t.Parallel()
+
+	for _, inDepth := range []int{16, 20, 24, 32} {
+		for _, outDepth := range []int{16, 20, 24, 32} {
+			for _, dither := range []bool{false, true} {
+				t.Run(fmt.Sprintf("%d_to_%d_dither_%v", inDepth, outDepth, dither), func(t *testing.T) {
+					t.Parallel()
+
+					m4a, pcm := syntheticM4A(t, 8000, inDepth, 2)
+
+					dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithOutputBitDepth(outDepth, dither))
+					if err != nil {
+						t.Fatalf("NewDecoder: %v", err)
+					}
+
+					if got := dec.Format().BitDepth; got != outDepth {
+						t.Fatalf("Format().BitDepth = %d, want %d", got, outDepth)
+					}
+
+					out, err := io.ReadAll(dec)
+					if err != nil {
+						t.Fatalf("ReadAll: %v", err)
+					}
+
+					inBytes := testutil.BytesPerSample(inDepth)
+					outBytes := testutil.BytesPerSample(outDepth)
+					samples := len(pcm) / inBytes
+
+					if len(out) != samples*outBytes {
+						t.Fatalf("got %d bytes, want %d", len(out), samples*outBytes)
+					}
+
+					for idx := range samples {
+						src := int64(testutil.PCMSample(pcm[idx*inBytes:], inDepth))
+						got := int64(testutil.PCMSample(out[idx*outBytes:], outDepth))
+
+						if outDepth >= inDepth {
+							if want := src << (outDepth - inDepth); got != want {
+								t.Fatalf("sample %d: got %d, want %d", idx, got, want)
+							}
+
+							continue
+						}
+
+						shift := inDepth - outDepth
+						if !dither {
+							if want := src >> shift; got != want {
+								t.Fatalf("sample %d: got %d, want %d", idx, got, want)
+							}
+
+							continue
+						}
+
+						// Dither noise moves the rounded result at most one step either way.
+						rounded := min((src+1<<(shift-1))>>shift, 1<<(outDepth-1)-1)
+						if got < rounded-1 || got > rounded+1 {
+							t.Fatalf("sample %d: got %d, want %d±1", idx, got, rounded)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestWithOutputBitDepth_Invalid(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 24, 2)
+
+	for _, opts := range [][]alac.Option{
+		{alac.WithOutputBitDepth(8, false)},
+		{alac.WithOutputBitDepth(-16, false)},
+		{alac.WithOutputBitDepth(16, true), alac.WithSampleFormat(alac.SampleFloat32)},
+	} {
+		_, err := alac.NewDecoder(bytes.NewReader(m4a), opts...)
+		if !errors.Is(err, alac.ErrConfig) {
+			t.Fatalf("expected ErrConfig, got: %v", err)
+		}
+	}
+}
+
+// 8-bit PCM is unsigned, as in WAV, unlike every wider depth: the conversion
+// must offset it by 128 both ways.
+func TestConvertBitDepth_Unsigned8(t *testing.T) {
+	t.Parallel()
+
+	signed := []int16{-32768, -256, -1, 0, 255, 256, 32767}
+	unsigned := []byte{0x00, 0x7F, 0x7F, 0x80, 0x80, 0x81, 0xFF}
+
+	src := make([]byte, 2*len(signed))
+	for idx, val := range signed {
+		src[2*idx] = byte(val)
+		src[2*idx+1] = byte(val >> 8)
+	}
+
+	narrowed := make([]byte, len(signed))
+	alacint.ConvertBitDepth(narrowed, src, 16, 8, nil)
+
+	if !bytes.Equal(narrowed, unsigned) {
+		t.Fatalf("16 to 8 bits: got %x, want %x", narrowed, unsigned)
+	}
+
+	widened := make([]byte, len(src))
+	alacint.ConvertBitDepth(widened, unsigned, 8, 16, nil)
+
+	for idx, want := range []int32{-32768, -256, -256, 0, 0, 256, 32512} {
+		if got := testutil.PCMSample(widened[2*idx:], 16); got != want {
+			t.Fatalf("8 to 16 bits, sample %d: got %d, want %d", idx, got, want)
+		}
+	}
+}
+
+func TestWithDitherSeed(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 24, 2)
+
+	decode := func(opts ...alac.Option) []byte {
+		t.Helper()
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a), append(opts, alac.WithOutputBitDepth(16, true))...)
+		if err != nil {
+			t.Fatalf("NewDecoder: %v", err)
+		}
+
+		out, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+
+		return out
+	}
+
+	if !bytes.Equal(decode(alac.WithDitherSeed(42)), decode(alac.WithDitherSeed(42))) {
+		t.Fatal("decodes with the same seed differ")
+	}
+
+	if !bytes.Equal(decode(), decode()) {
+		t.Fatal("decodes with the default seed differ")
+	}
+
+	if !bytes.Equal(decode(alac.WithDitherSeed(0)), decode()) {
+		t.Fatal("seed 0 does not select the default seed")
+	}
+
+	if bytes.Equal(decode(alac.WithDitherSeed(42)), decode(alac.WithDitherSeed(43))) {
+		t.Fatal("different seeds produce identical dither")
+	}
+}
@@ -0,0 +1,106 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestWithMaxOutputBytes_Read(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, 2)
+
+	// Not a packet boundary, nor a frame boundary: output stops at the last whole frame.
+	const limit = 10_003
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithMaxOutputBytes(limit))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if !errors.Is(err, alac.ErrOutputLimitExceeded) {
+		t.Fatalf("ReadAll: got %v, want ErrOutputLimitExceeded", err)
+	}
+
+	if want := pcm[:limit-limit%4]; !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want the first %d bytes of the source", len(got), len(want))
+	}
+
+	if n, err := dec.Read(make([]byte, 16)); n != 0 || !errors.Is(err, alac.ErrOutputLimitExceeded) {
+		t.Fatalf("Read past the limit: got (%d, %v), want ErrOutputLimitExceeded", n, err)
+	}
+}
+
+func TestWithMaxOutputBytes_NotReached(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, 1)
+
+	for _, limit := range []int64{0, -1, int64(len(pcm))} {
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithMaxOutputBytes(limit))
+		if err != nil {
+			t.Fatalf("NewDecoder: %v", err)
+		}
+
+		got, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("limit %d: ReadAll: %v", limit, err)
+		}
+
+		if !bytes.Equal(got, pcm) {
+			t.Fatalf("limit %d: decoded output differs from the source", limit)
+		}
+	}
+}
+
+func TestWithMaxOutputBytes_PacketDecoder(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 64
+
+	config, err := alac.ParseMagicCookie(testutil.Cookie(frameLength, 16, 1, 8000))
+	if err != nil {
+		t.Fatalf("ParseMagicCookie: %v", err)
+	}
+
+	// One and a half packets.
+	dec, err := alac.NewPacketDecoder(config, alac.WithMaxOutputBytes(frameLength*2*3/2))
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	packet := testutil.EncodeVerbatimPacket(make([]byte, frameLength*2), 16, 1, frameLength)
+
+	for idx, want := range []int{frameLength * 2, frameLength} {
+		out, err := dec.DecodePacket(packet)
+		if err != nil || len(out) != want {
+			t.Fatalf("packet %d: got (%d bytes, %v), want %d bytes", idx, len(out), err, want)
+		}
+	}
+
+	if _, err := dec.DecodePacket(packet); !errors.Is(err, alac.ErrOutputLimitExceeded) {
+		t.Fatalf("packet 2: got %v, want ErrOutputLimitExceeded", err)
+	}
+}
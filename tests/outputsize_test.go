@@ -0,0 +1,98 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecoder_OutputSize(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleRate = 8000
+		emptyEdit  = 0xFFFFFFFF
+	)
+
+	// One second at 4096 frames per packet: the final packet is partial.
+	pcm := agar.GenerateWhiteNoise(sampleRate, 16, 2, 1)
+	frames := len(pcm) / 4
+	spec := testutil.SyntheticM4A{SampleRate: sampleRate, BitDepth: 16, Channels: 2, PCM: pcm}
+
+	withEdits := spec
+	withEdits.ExtraTrakBoxes = [][]byte{editListBox([2]int{100, emptyEdit}, [2]int{frames, 0})}
+
+	for _, tc := range []struct {
+		name string
+		spec testutil.SyntheticM4A
+		opts []alac.Option
+		want int64
+	}{
+		{"exact final packet", spec, nil, int64(len(pcm))},
+		{"padded final frame", spec, []alac.Option{alac.WithPaddedFinalFrame()}, int64((frames+4095)/4096*4096) * 4},
+		{"edit list silence", withEdits, []alac.Option{alac.WithEditListSilence()}, int64(len(pcm)) + 100*4},
+		{"float output", spec, []alac.Option{alac.WithSampleFormat(alac.SampleFloat32)}, int64(frames) * 8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewDecoder(bytes.NewReader(testutil.BuildM4A(tc.spec)), tc.opts...)
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			if got := dec.OutputSize(); got != tc.want {
+				t.Fatalf("OutputSize = %d, want %d", got, tc.want)
+			}
+
+			out, err := io.ReadAll(dec)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			if int64(len(out)) != tc.want {
+				t.Fatalf("Read produced %d bytes, OutputSize promised %d", len(out), tc.want)
+			}
+		})
+	}
+}
+
+func TestDecoder_OutputSize_Fragmented(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 1, 1)
+	mvex := testutil.Box("mvex", testutil.FullBox("trex", make([]byte, 20)))
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 8000, BitDepth: 16, Channels: 1, PCM: pcm, ExtraMoovBoxes: [][]byte{mvex},
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got := dec.OutputSize(); got != -1 {
+		t.Fatalf("OutputSize = %d, want -1", got)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// paddedPacketM4A builds a three-packet stereo file whose second stsz entry
+// claims eight more bytes than the packet's elements use.
+func paddedPacketM4A(t *testing.T) []byte {
+	t.Helper()
+
+	const (
+		frameLength = 1024
+		frameBytes  = 4
+	)
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:3*frameLength*frameBytes]
+	packets := make([][]byte, 3)
+
+	for idx := range packets {
+		chunk := pcm[idx*frameLength*frameBytes : (idx+1)*frameLength*frameBytes]
+		packets[idx] = testutil.EncodeVerbatimPacket(chunk, 16, 2, frameLength)
+	}
+
+	packets[1] = append(packets[1], make([]byte, 8)...)
+
+	return testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    2,
+		FrameLength: frameLength,
+		Packets:     packets,
+	})
+}
+
+func TestPacketSizeCheck_Mismatch(t *testing.T) {
+	t.Parallel()
+
+	dec, err := alac.NewDecoder(bytes.NewReader(paddedPacketM4A(t)), alac.WithPacketSizeCheck())
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := io.ReadAll(dec); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	warnings := dec.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("got %d warnings, want 1: %v", len(warnings), warnings)
+	}
+
+	if warnings[0].Packet != 1 || warnings[0].Channel != -1 || !errors.Is(warnings[0].Err, alacint.ErrPacketSize) {
+		t.Fatalf("unexpected warning: %+v", warnings[0])
+	}
+}
+
+func TestPacketSizeCheck_OffByDefault(t *testing.T) {
+	t.Parallel()
+
+	dec, err := alac.NewDecoder(bytes.NewReader(paddedPacketM4A(t)))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := io.ReadAll(dec); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if warnings := dec.Warnings(); len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+}
+
+func TestPacketSizeCheck_CleanStreams(t *testing.T) {
+	t.Parallel()
+
+	for _, channels := range []int{1, 2, 3, 6} {
+		m4a, _ := syntheticM4A(t, 8000, 24, channels)
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithPacketSizeCheck())
+		if err != nil {
+			t.Fatalf("%dch: NewDecoder: %v", channels, err)
+		}
+
+		if _, err := io.ReadAll(dec); err != nil {
+			t.Fatalf("%dch: ReadAll: %v", channels, err)
+		}
+
+		if warnings := dec.Warnings(); len(warnings) != 0 {
+			t.Fatalf("%dch: unexpected warnings: %v", channels, warnings)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecodePaddedPacket(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 1024
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:2*frameLength*4]
+	first := testutil.EncodeVerbatimPacket(pcm[:frameLength*4], 16, 2, frameLength)
+	second := testutil.EncodeVerbatimPacket(pcm[frameLength*4:], 16, 2, frameLength)
+
+	// Both packets share one buffer, each followed by its zero padding.
+	buf := append(bytes.Clone(first), make([]byte, alac.PacketPadding)...)
+	buf = append(append(buf, second...), make([]byte, alac.PacketPadding)...)
+	original := bytes.Clone(buf)
+
+	config, err := alac.ParseMagicCookie(testutil.Cookie(frameLength, 16, 2, 8000))
+	if err != nil {
+		t.Fatalf("ParseMagicCookie: %v", err)
+	}
+
+	dec, err := alac.NewPacketDecoder(config)
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	var got []byte
+
+	for _, packet := range []struct{ off, size int }{
+		{0, len(first)},
+		{len(first) + alac.PacketPadding, len(second)},
+	} {
+		out, err := dec.DecodePaddedPacket(buf[packet.off:], packet.size)
+		if err != nil {
+			t.Fatalf("DecodePaddedPacket: %v", err)
+		}
+
+		got = append(got, out...)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatal("decoded PCM differs from input")
+	}
+
+	if !bytes.Equal(buf, original) {
+		t.Fatal("DecodePaddedPacket wrote into the caller's buffer")
+	}
+
+	// DecodePacket still copies after an in-place decode.
+	if out, err := dec.DecodePacket(first); err != nil || !bytes.Equal(out, pcm[:frameLength*4]) {
+		t.Fatalf("DecodePacket after DecodePaddedPacket: %d bytes, %v", len(out), err)
+	}
+}
+
+func TestDecodePaddedPacket_BadPadding(t *testing.T) {
+	t.Parallel()
+
+	config := alac.PacketConfig{FrameLength: 4096, BitDepth: 16, NumChannels: 1, SampleRate: 44100}
+
+	dec, err := alac.NewPacketDecoder(config)
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	dirty := append([]byte{0xFF}, bytes.Repeat([]byte{0xFF}, alac.PacketPadding)...)
+
+	for name, tc := range map[string]struct {
+		padded []byte
+		size   int
+	}{
+		"short":         {make([]byte, alac.PacketPadding), 1},
+		"negative size": {make([]byte, alac.PacketPadding), -1},
+		"nonzero":       {dirty, 1},
+	} {
+		if _, err := dec.DecodePaddedPacket(tc.padded, tc.size); !errors.Is(err, alac.ErrDecode) {
+			t.Errorf("%s: expected ErrDecode, got: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestWithPaddedFinalFrame(t *testing.T) {
+	t.Parallel()
+
+	const (
+		frameLength = 1024
+		channels    = 2
+		frames      = 2*frameLength + 100
+	)
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, channels, 1)[:frames*channels*2]
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    channels,
+		FrameLength: frameLength,
+		PCM:         pcm,
+	})
+
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+		if err != nil {
+			t.Fatalf("NewDecoder: %v", err)
+		}
+
+		got, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+
+		if !bytes.Equal(got, pcm) || dec.FinalPadding() != 0 {
+			t.Fatalf("got %d bytes, padding %d; want %d bytes, padding 0", len(got), dec.FinalPadding(), len(pcm))
+		}
+	})
+
+	t.Run("padded", func(t *testing.T) {
+		t.Parallel()
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithPaddedFinalFrame())
+		if err != nil {
+			t.Fatalf("NewDecoder: %v", err)
+		}
+
+		got, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+
+		if want := frameLength - 100; dec.FinalPadding() != want {
+			t.Fatalf("FinalPadding = %d, want %d", dec.FinalPadding(), want)
+		}
+
+		if len(got) != 3*frameLength*channels*2 {
+			t.Fatalf("got %d bytes, want %d", len(got), 3*frameLength*channels*2)
+		}
+
+		if !bytes.Equal(got[:len(pcm)], pcm) {
+			t.Fatal("decoded audio differs before the padding")
+		}
+
+		if !bytes.Equal(got[len(pcm):], make([]byte, len(got)-len(pcm))) {
+			t.Fatal("padding is not silent")
+		}
+	})
+}
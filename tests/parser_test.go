@@ -0,0 +1,133 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+var errNotFramed = errors.New("not a framed stream")
+
+// framedParser reads a toy container: "ALAC", a 32-bit cookie length and the
+// cookie, then packets each prefixed with their 32-bit length.
+type framedParser struct{}
+
+func (framedParser) Parse(rs io.ReadSeeker) ([]byte, []alac.SampleInfo, error) {
+	data, err := io.ReadAll(rs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(data) < 8 || string(data[:4]) != "ALAC" {
+		return nil, nil, errNotFramed
+	}
+
+	cookieEnd := 8 + int(binary.BigEndian.Uint32(data[4:]))
+	cookie := data[8:cookieEnd]
+
+	var samples []alac.SampleInfo
+
+	for off := cookieEnd; off+4 <= len(data); {
+		size := binary.BigEndian.Uint32(data[off:])
+		samples = append(samples, alac.SampleInfo{Offset: uint64(off + 4), Size: size})
+		off += 4 + int(size)
+	}
+
+	return cookie, samples, nil
+}
+
+func buildFramed(cookie []byte, packets [][]byte) []byte {
+	out := append([]byte("ALAC"), testutil.U32(len(cookie))...)
+	out = append(out, cookie...)
+
+	for _, packet := range packets {
+		out = append(out, testutil.U32(len(packet))...)
+		out = append(out, packet...)
+	}
+
+	return out
+}
+
+func decodeWithParser(t *testing.T, stream []byte, parser alac.ContainerParser) []byte {
+	t.Helper()
+
+	dec, err := alac.NewDecoderWithParser(bytes.NewReader(stream), parser)
+	if err != nil {
+		t.Fatalf("NewDecoderWithParser: %v", err)
+	}
+
+	if got := dec.Info().Container; got != alac.ContainerUnknown {
+		t.Fatalf("Info().Container = %v, want unknown", got)
+	}
+
+	out, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	return out
+}
+
+func TestNewDecoderWithParser_Custom(t *testing.T) {
+	t.Parallel()
+
+	packets, pcm := mkvSource()
+	stream := buildFramed(testutil.Cookie(mkvFrameLength, 16, mkvChannels, mkvRate), packets)
+
+	if got := decodeWithParser(t, stream, framedParser{}); !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestNewDecoderWithParser_BuiltIn(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, 2)
+	if got := decodeWithParser(t, m4a, alac.MP4Parser{}); !bytes.Equal(got, pcm) {
+		t.Fatalf("MP4Parser: decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+
+	packets, pcm := mkvSource()
+
+	mkv := testutil.BuildMKV(testutil.Cookie(mkvFrameLength, 16, mkvChannels, mkvRate), packets)
+	if got := decodeWithParser(t, mkv, alac.MatroskaParser{}); !bytes.Equal(got, pcm) {
+		t.Fatalf("MatroskaParser: decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestNewDecoderWithParser_ParseError(t *testing.T) {
+	t.Parallel()
+
+	_, err := alac.NewDecoderWithParser(bytes.NewReader([]byte("not framed")), framedParser{})
+	if !errors.Is(err, alac.ErrNoTrack) || !errors.Is(err, errNotFramed) {
+		t.Fatalf("expected ErrNoTrack wrapping the parser's error, got: %v", err)
+	}
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 2)
+
+	_, err = alac.NewDecoderWithParser(bytes.NewReader(m4a), alac.MatroskaParser{})
+	if !errors.Is(err, alac.ErrNoTrack) {
+		t.Fatalf("MatroskaParser on MP4: expected ErrNoTrack, got: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestProbeFormat(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 96000, 24, 6)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	format, err := alac.ProbeFormat(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("ProbeFormat: %v", err)
+	}
+
+	if format != dec.Format() {
+		t.Fatalf("ProbeFormat = %v, NewDecoder reports %v", format, dec.Format())
+	}
+}
+
+func TestProbeFormat_SkipsSampleTable(t *testing.T) {
+	t.Parallel()
+
+	// Without an stsz box the sample table cannot be built.
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 44100,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        agar.GenerateWhiteNoise(44100, 16, 2, 1),
+	})
+	copy(m4a[findFourCC(m4a, "stsz")+4:], "free")
+
+	if _, err := alac.NewDecoder(bytes.NewReader(m4a)); err == nil {
+		t.Fatal("NewDecoder accepted a broken sample table")
+	}
+
+	format, err := alac.ProbeFormat(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("ProbeFormat: %v", err)
+	}
+
+	if want := (alac.PCMFormat{SampleRate: 44100, BitDepth: 16, Channels: 2}); format != want {
+		t.Fatalf("ProbeFormat = %v, want %v", format, want)
+	}
+}
+
+func TestProbeFormat_NoTrack(t *testing.T) {
+	t.Parallel()
+
+	data := testutil.Box("ftyp", []byte("M4A "))
+
+	if _, err := alac.ProbeFormat(bytes.NewReader(data)); !errors.Is(err, alac.ErrNoTrack) {
+		t.Fatalf("expected ErrNoTrack, got: %v", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 1024
+
+	stereo := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:frameLength*4]
+	clean := testutil.EncodeVerbatimPacket(stereo, 16, 2, frameLength)
+
+	cce := bytes.Clone(clean)
+	cce[0] = cce[0]&0x1F | 2<<5 // coupling channel element tag
+
+	mono := testutil.EncodeVerbatimPacket(stereo[:frameLength*2], 16, 1, frameLength)
+
+	for _, tc := range []struct {
+		name      string
+		packets   [][]byte
+		decodable bool
+	}{
+		{"well-formed", [][]byte{clean, cce}, true},
+		{"unsupported element", [][]byte{cce, clean}, false},
+		{"channel count mismatch", [][]byte{mono, clean}, false},
+		{"truncated packet", [][]byte{clean[:len(clean)/2]}, false},
+		{"no packets", [][]byte{}, false},
+	} {
+		m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+			SampleRate:  8000,
+			BitDepth:    16,
+			Channels:    2,
+			FrameLength: frameLength,
+			Packets:     tc.packets,
+		})
+
+		result, err := alac.Probe(bytes.NewReader(m4a))
+		if err != nil {
+			t.Fatalf("%s: Probe: %v", tc.name, err)
+		}
+
+		if result.Format.Channels != 2 || result.Format.BitDepth != 16 {
+			t.Errorf("%s: unexpected format %v", tc.name, result.Format)
+		}
+
+		if result.Decodable != tc.decodable || (result.Reason == "") != tc.decodable {
+			t.Errorf("%s: got decodable %t (reason %q), want %t", tc.name, result.Decodable, result.Reason, tc.decodable)
+		}
+	}
+
+	if _, err := alac.Probe(bytes.NewReader([]byte("not an m4a file"))); !errors.Is(err, alac.ErrNoTrack) {
+		t.Errorf("garbage: got %v, want ErrNoTrack", err)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// rawPacketM4A builds a stereo file from known verbatim packets.
+func rawPacketM4A(t *testing.T) ([]byte, [][]byte, []byte) {
+	t.Helper()
+
+	const frameLength = 1024
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+	packets := make([][]byte, 0, 8)
+
+	for off := 0; off < len(pcm); off += frameLength * 4 {
+		chunk := pcm[off:min(off+frameLength*4, len(pcm))]
+		packets = append(packets, testutil.EncodeVerbatimPacket(chunk, 16, 2, frameLength))
+	}
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    2,
+		FrameLength: frameLength,
+		Packets:     packets,
+		PCM:         pcm,
+	})
+
+	return m4a, packets, pcm
+}
+
+func TestEachRawPacket(t *testing.T) {
+	t.Parallel()
+
+	m4a, packets, pcm := rawPacketM4A(t)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// Start mid-stream: EachRawPacket must not move the decoding position.
+	head := make([]byte, 1000)
+	if _, err := io.ReadFull(dec, head); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+
+	count := 0
+
+	err = dec.EachRawPacket(func(idx int, packet []byte) error {
+		if idx != count || !bytes.Equal(packet, packets[idx]) {
+			t.Fatalf("packet %d (call %d) differs from the source packet", idx, count)
+		}
+
+		count++
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachRawPacket: %v", err)
+	}
+
+	if count != len(packets) {
+		t.Fatalf("got %d packets, want %d", count, len(packets))
+	}
+
+	rest, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if !bytes.Equal(append(head, rest...), pcm) {
+		t.Fatal("decoded output changed after EachRawPacket")
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+
+	err = dec.EachRawPacket(func(int, []byte) error {
+		calls++
+
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("EachRawPacket = %v after %d calls, want the callback's error after 1", err, calls)
+	}
+}
+
+//nolint:paralleltest // testing.AllocsPerRun refuses to run in parallel tests.
+func TestEachRawPacket_ReusesBuffer(t *testing.T) {
+	m4a, _, _ := rawPacketM4A(t)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	noop := func(int, []byte) error { return nil }
+
+	// After the first pass has sized the buffer, iterating allocates nothing.
+	if allocs := testing.AllocsPerRun(10, func() { _ = dec.EachRawPacket(noop) }); allocs != 0 {
+		t.Fatalf("EachRawPacket allocated %.0f times per run", allocs)
+	}
+}
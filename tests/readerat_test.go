@@ -0,0 +1,69 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+// readerAtOnly hides every method but ReadAt, so the decoder cannot fall back to Seek.
+type readerAtOnly struct {
+	r io.ReaderAt
+}
+
+func (ra readerAtOnly) ReadAt(p []byte, off int64) (int, error) { return ra.r.ReadAt(p, off) }
+
+func TestNewDecoderAt(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 44100, 24, 2)
+	src := readerAtOnly{r: bytes.NewReader(m4a)}
+
+	// Several decoders share one ReaderAt concurrently; none may disturb the others.
+	const decoders = 4
+
+	var wg sync.WaitGroup
+
+	for range decoders {
+		wg.Go(func() {
+			dec, err := alac.NewDecoderAt(src, int64(len(m4a)))
+			if err != nil {
+				t.Errorf("NewDecoderAt: %v", err)
+
+				return
+			}
+
+			got, err := io.ReadAll(dec)
+			if err != nil {
+				t.Errorf("ReadAll: %v", err)
+
+				return
+			}
+
+			if !bytes.Equal(got, pcm) {
+				t.Errorf("decoded PCM mismatch: got %d bytes, want %d", len(got), len(pcm))
+			}
+		})
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestDecoder_ReaderOffset(t *testing.T) {
+	t.Parallel()
+
+	const (
+		frameLength = 1024
+		packetBytes = frameLength * 4
+	)
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:3*packetBytes]
+	packets := make([][]byte, 3)
+
+	for idx := range packets {
+		packets[idx] = testutil.EncodeVerbatimPacket(pcm[idx*packetBytes:(idx+1)*packetBytes], 16, 2, frameLength)
+	}
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    2,
+		FrameLength: frameLength,
+		Packets:     packets,
+	})
+
+	// Packets are stored back to back at the start of the mdat payload.
+	mdat := int64(bytes.Index(m4a, []byte("mdat")) + 4)
+
+	streaming, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	readerAt, err := alac.NewDecoderAt(bytes.NewReader(m4a), int64(len(m4a)))
+	if err != nil {
+		t.Fatalf("NewDecoderAt: %v", err)
+	}
+
+	if offset, err := readerAt.ReaderOffset(); err != nil || offset != 0 {
+		t.Fatalf("NewDecoderAt: ReaderOffset() before Read = %d, %v; want 0", offset, err)
+	}
+
+	for name, dec := range map[string]*alac.Decoder{"NewDecoder": streaming, "NewDecoderAt": readerAt} {
+		var got []byte
+
+		end := mdat
+
+		for idx := range packets {
+			buf := make([]byte, packetBytes)
+			if _, err := io.ReadFull(dec, buf); err != nil {
+				t.Fatalf("%s: packet %d: Read: %v", name, idx, err)
+			}
+
+			got = append(got, buf...)
+			end += int64(len(packets[idx]))
+
+			offset, err := dec.ReaderOffset()
+			if err != nil || offset != end {
+				t.Fatalf("%s: after packet %d: ReaderOffset() = %d, %v; want %d", name, idx, offset, err, end)
+			}
+		}
+
+		if !bytes.Equal(got, pcm) {
+			t.Fatalf("%s: decoded PCM differs with ReaderOffset calls in between", name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// growingFile creates a file holding data, for a writer to extend while a
+// Decoder reads it.
+func growingFile(t *testing.T, data []byte) *os.File {
+	t.Helper()
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "recording.m4a"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	t.Cleanup(func() { _ = file.Close() })
+
+	if _, err := file.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	return file
+}
+
+func TestRefreshSampleTable_Fragmented(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(fragSampleRate, fragBitDepth, fragChannels, 1)
+	packets := fragmentPackets(pcm)
+	half := len(packets) / 2
+
+	first := buildFragment(fragTrackID, 0x020000, 0, true, packets[:half])
+	second := buildFragment(fragTrackID, 0x020000, 0, true, packets[half:])
+
+	file := growingFile(t, fragmentedInit(0))
+
+	dec, err := alac.NewDecoder(file)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	var got []byte
+
+	// The second fragment arrives in two writes: the refresh in between must
+	// skip it rather than fail.
+	for _, write := range [][]byte{
+		append(first, second[:len(second)/2]...),
+		second[len(second)/2:],
+	} {
+		if _, err := file.WriteAt(write, mustSize(t, file)); err != nil {
+			t.Fatalf("WriteAt: %v", err)
+		}
+
+		if err := dec.RefreshSampleTable(); err != nil {
+			t.Fatalf("RefreshSampleTable: %v", err)
+		}
+
+		out, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+
+		got = append(got, out...)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("tailed %d bytes, want the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestRefreshSampleTable_RewrittenMoov(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, 2)
+	bytesPerFrame := 4
+
+	// The same recording, cut after its first packet.
+	partial := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 8000,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        pcm[:4096*bytesPerFrame],
+	})
+
+	file := growingFile(t, partial)
+
+	dec, err := alac.NewDecoder(file)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if _, err := file.WriteAt(m4a, 0); err != nil {
+		t.Fatalf("WriteAt: %v", err)
+	}
+
+	if err := dec.RefreshSampleTable(); err != nil {
+		t.Fatalf("RefreshSampleTable: %v", err)
+	}
+
+	if size := dec.OutputSize(); size != int64(len(pcm)) {
+		t.Fatalf("OutputSize after refresh = %d, want %d", size, len(pcm))
+	}
+
+	rest, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if got = append(got, rest...); !bytes.Equal(got, pcm) {
+		t.Fatalf("tailed %d bytes, want the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestRefreshSampleTable_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	packets, _ := mkvSource()
+	mkv := testutil.BuildMKV(testutil.Cookie(mkvFrameLength, 16, mkvChannels, mkvRate), packets)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(mkv))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if err := dec.RefreshSampleTable(); !errors.Is(err, alac.ErrUnsupportedContainer) {
+		t.Fatalf("expected ErrUnsupportedContainer, got: %v", err)
+	}
+}
+
+// mustSize returns the current size of file.
+func mustSize(t *testing.T, file *os.File) int64 {
+	t.Helper()
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	return info.Size()
+}
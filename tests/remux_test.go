@@ -0,0 +1,163 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// remux remuxes src into a new M4A file and returns its contents.
+func remux(t *testing.T, src []byte) []byte {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "out.m4a")
+
+	dst, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := alac.Remux(bytes.NewReader(src), dst, alac.ContainerMP4); err != nil {
+		t.Fatalf("Remux: %v", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	return out
+}
+
+func TestRemux_MP4(t *testing.T) {
+	t.Parallel()
+
+	const priming = 2112
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+	frames := len(pcm) / 4
+
+	src := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 8000,
+		BitDepth:   16,
+		Channels:   2,
+		PCM:        pcm,
+		SampleEntryBoxes: [][]byte{
+			testutil.FullBox("chan", testutil.U32(101<<16|2), testutil.U32(0), testutil.U32(0)),
+			testutil.Box("btrt", testutil.U32(0), testutil.U32(320000), testutil.U32(256000)),
+		},
+		ExtraTrakBoxes: [][]byte{editListBox([2]int{frames - priming - 100, priming})},
+	})
+
+	out := remux(t, src)
+
+	want, err := alac.NewDecoder(bytes.NewReader(src))
+	if err != nil {
+		t.Fatalf("NewDecoder(source): %v", err)
+	}
+
+	got, err := alac.NewDecoder(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("NewDecoder(remuxed): %v", err)
+	}
+
+	if got.Format() != want.Format() || got.OutputSize() != want.OutputSize() ||
+		got.Position() != want.Position() || got.MaxBitRate() != want.MaxBitRate() ||
+		got.ChannelLayoutName() != want.ChannelLayoutName() || got.Info() != want.Info() {
+		t.Fatalf("remuxed stream differs:\n got %v %d %v %d %q %+v\nwant %v %d %v %d %q %+v",
+			got.Format(), got.OutputSize(), got.Position(), got.MaxBitRate(), got.ChannelLayoutName(), got.Info(),
+			want.Format(), want.OutputSize(), want.Position(), want.MaxBitRate(), want.ChannelLayoutName(), want.Info())
+	}
+
+	wantPCM, err := io.ReadAll(want)
+	if err != nil {
+		t.Fatalf("ReadAll(source): %v", err)
+	}
+
+	gotPCM, err := io.ReadAll(got)
+	if err != nil {
+		t.Fatalf("ReadAll(remuxed): %v", err)
+	}
+
+	if !bytes.Equal(gotPCM, wantPCM) {
+		t.Fatalf("remuxed PCM differs: got %d bytes, want %d", len(gotPCM), len(wantPCM))
+	}
+}
+
+func TestRemux_Matroska(t *testing.T) {
+	t.Parallel()
+
+	packets, pcm := mkvSource()
+	// A shorter final packet, whose length only decoding reveals.
+	last := pcm[:100*mkvFrameBytes]
+	packets = append(packets, testutil.EncodeVerbatimPacket(last, 16, mkvChannels, mkvFrameLength))
+	pcm = append(pcm, last...)
+
+	out := remux(t, testutil.BuildMKV(testutil.Cookie(mkvFrameLength, 16, mkvChannels, mkvRate), packets))
+
+	dec, err := alac.NewDecoder(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got := dec.Info().Container; got != alac.ContainerMP4 {
+		t.Fatalf("Info().Container = %v, want mp4", got)
+	}
+
+	if got := dec.OutputSize(); got != int64(len(pcm)) {
+		t.Fatalf("OutputSize = %d, want %d", got, len(pcm))
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+}
+
+func TestRemux_UnsupportedContainer(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 1)
+
+	dst, err := os.Create(filepath.Join(t.TempDir(), "out.mkv"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer dst.Close()
+
+	if err := alac.Remux(bytes.NewReader(m4a), dst, alac.ContainerMatroska); !errors.Is(err, alac.ErrUnsupportedContainer) {
+		t.Fatalf("expected ErrUnsupportedContainer, got: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+// reversed returns pcm with its frames of frameBytes each in reverse order.
+func reversed(pcm []byte, frameBytes int) []byte {
+	out := make([]byte, 0, len(pcm))
+	for off := len(pcm) - frameBytes; off >= 0; off -= frameBytes {
+		out = append(out, pcm[off:off+frameBytes]...)
+	}
+
+	return out
+}
+
+func TestReverseDecoder(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		depth, channels int
+		opts            []alac.Option
+	}{
+		{16, 2, nil},
+		{24, 1, nil},
+		{24, 6, nil},
+		{16, 2, []alac.Option{alac.WithTrimStart(5000), alac.WithTrimEnd(100)}},
+		{16, 2, []alac.Option{alac.WithTrimEnd(4000)}},
+	} {
+		t.Run(fmt.Sprintf("%dbit_%dch_%dopts", tc.depth, tc.channels, len(tc.opts)), func(t *testing.T) {
+			t.Parallel()
+
+			// 8000 frames: a full packet, then a short one.
+			m4a, _ := syntheticM4A(t, 8000, tc.depth, tc.channels)
+
+			forward, err := alac.NewDecoder(bytes.NewReader(m4a), tc.opts...)
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			want, err := io.ReadAll(forward)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			dec, err := alac.NewReverseDecoder(bytes.NewReader(m4a), tc.opts...)
+			if err != nil {
+				t.Fatalf("NewReverseDecoder: %v", err)
+			}
+
+			frameBytes := dec.Format().BytesPerFrame()
+
+			// Small reads that split frames exercise the buffering.
+			var got []byte
+
+			buf := make([]byte, 1000)
+
+			for {
+				n, err := dec.Read(buf)
+				got = append(got, buf[:n]...)
+
+				if err == io.EOF {
+					break
+				}
+
+				if err != nil {
+					t.Fatalf("Read: %v", err)
+				}
+			}
+
+			if !bytes.Equal(got, reversed(want, frameBytes)) {
+				t.Fatalf("reverse decode: got %d bytes, want %d reversed", len(got), len(want))
+			}
+		})
+	}
+}
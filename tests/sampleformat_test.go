@@ -0,0 +1,103 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestWithSampleFormat_Float(t *testing.T) {
+	t.Parallel()
+
+	for _, bitDepth := range []int{16, 20, 24, 32} {
+		for _, channels := range []int{1, 2, 6} {
+			for _, format := range []alac.SampleFormat{alac.SampleFloat32, alac.SampleFloat64} {
+				t.Run(fmt.Sprintf("%dbit_%dch_%s", bitDepth, channels, format), func(t *testing.T) {
+					t.Parallel()
+
+					m4a, pcm := syntheticM4A(t, 8000, bitDepth, channels)
+
+					dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithSampleFormat(format))
+					if err != nil {
+						t.Fatalf("NewDecoder: %v", err)
+					}
+
+					if got := dec.Format().SampleFormat; got != format {
+						t.Fatalf("Format().SampleFormat = %v, want %v", got, format)
+					}
+
+					out, err := io.ReadAll(dec)
+					if err != nil {
+						t.Fatalf("ReadAll: %v", err)
+					}
+
+					inBytes := testutil.BytesPerSample(bitDepth)
+					outBytes := 4
+					if format == alac.SampleFloat64 {
+						outBytes = 8
+					}
+
+					samples := len(pcm) / inBytes
+					if len(out) != samples*outBytes {
+						t.Fatalf("got %d bytes, want %d", len(out), samples*outBytes)
+					}
+
+					scale := math.Ldexp(1, 1-bitDepth)
+
+					for idx := range samples {
+						want := float64(testutil.PCMSample(pcm[idx*inBytes:], bitDepth)) * scale
+
+						var got float64
+						if format == alac.SampleFloat64 {
+							got = math.Float64frombits(binary.LittleEndian.Uint64(out[idx*8:]))
+						} else {
+							got = float64(math.Float32frombits(binary.LittleEndian.Uint32(out[idx*4:])))
+							want = float64(float32(want))
+						}
+
+						if got != want {
+							t.Fatalf("sample %d: got %v, want %v", idx, got, want)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestWithSampleFormat_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := alac.NewPacketDecoder(alac.PacketConfig{
+		FrameLength: 4096,
+		BitDepth:    16,
+		NumChannels: 2,
+		SampleRate:  44100,
+	}, alac.WithSampleFormat(alac.SampleFormat(42)))
+	if !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("expected ErrConfig, got: %v", err)
+	}
+}
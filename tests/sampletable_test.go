@@ -0,0 +1,216 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// decodeSynthetic decodes a synthetic M4A and fails the test on any error.
+func decodeSynthetic(t *testing.T, spec testutil.SyntheticM4A) []byte {
+	t.Helper()
+
+	dec, err := alac.NewDecoder(bytes.NewReader(testutil.BuildM4A(spec)))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	return got
+}
+
+func TestSampleTable_StscNotStartingAtChunkOne(t *testing.T) {
+	t.Parallel()
+
+	// 8 packets of 1000 frames, laid out in chunks of 2, 2, 1 and 3 packets.
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+
+	for _, tc := range []struct {
+		name string
+		stsc []testutil.StscEntry
+	}{
+		{"first run at chunk 1", []testutil.StscEntry{
+			{FirstChunk: 1, SamplesPerChunk: 2},
+			{FirstChunk: 3, SamplesPerChunk: 1},
+			{FirstChunk: 4, SamplesPerChunk: 3},
+		}},
+		{"first run at chunk 2", []testutil.StscEntry{
+			{FirstChunk: 2, SamplesPerChunk: 2},
+			{FirstChunk: 3, SamplesPerChunk: 1},
+			{FirstChunk: 4, SamplesPerChunk: 3},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := decodeSynthetic(t, testutil.SyntheticM4A{
+				SampleRate:   8000,
+				BitDepth:     16,
+				Channels:     2,
+				FrameLength:  1000,
+				PCM:          pcm,
+				ChunkSamples: []int{2, 2, 1, 3},
+				Stsc:         tc.stsc,
+			})
+
+			// Verbatim packets decode to their exact source PCM only when
+			// every sample was read from its own offset.
+			if !bytes.Equal(got, pcm) {
+				t.Fatalf("decoded PCM mismatch: got %d bytes, want %d", len(got), len(pcm))
+			}
+		})
+	}
+}
+
+func TestSampleTable_SecondSampleDescription(t *testing.T) {
+	t.Parallel()
+
+	// Four 1000-frame ALAC packets, described by the second stsd entry, in
+	// chunks interleaved with single 500-frame packets of an AAC entry.
+	const frameBytes = 4
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:4000*frameBytes]
+
+	var alacPackets [][]byte
+	for off := 0; off < len(pcm); off += 1000 * frameBytes {
+		alacPackets = append(alacPackets, testutil.EncodeVerbatimPacket(pcm[off:off+1000*frameBytes], 16, 2, 1000))
+	}
+
+	foreign := []byte("not an alac packet")
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    2,
+		FrameLength: 1000,
+		Packets: [][]byte{
+			alacPackets[0], alacPackets[1], foreign,
+			alacPackets[2], alacPackets[3], foreign,
+		},
+		PacketFrames: []int{1000, 1000, 500, 1000, 1000, 500},
+		ChunkSamples: []int{2, 1, 2, 1},
+		Stsc: []testutil.StscEntry{
+			{FirstChunk: 1, SamplesPerChunk: 2, SampleDescriptionIndex: 2},
+			{FirstChunk: 2, SamplesPerChunk: 1, SampleDescriptionIndex: 1},
+			{FirstChunk: 3, SamplesPerChunk: 2, SampleDescriptionIndex: 2},
+			{FirstChunk: 4, SamplesPerChunk: 1, SampleDescriptionIndex: 1},
+		},
+		LeadingSampleEntries: [][]byte{testutil.Box("mp4a", make([]byte, 28))},
+	})
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got := dec.Duration(); got != 500*time.Millisecond {
+		t.Fatalf("Duration = %v, want 500ms", got)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded PCM mismatch: got %d bytes, want %d", len(got), len(pcm))
+	}
+
+	// The AAC packets take no time on the ALAC timeline.
+	if _, err := dec.Seek(250 * time.Millisecond); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	rest, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll after Seek: %v", err)
+	}
+
+	if !bytes.Equal(rest, pcm[2000*frameBytes:]) {
+		t.Fatalf("after Seek: got %d bytes, want the last %d", len(rest), len(pcm)-2000*frameBytes)
+	}
+}
+
+// TestSampleTable_ConstantSizeStsz replaces the stsz with a constant-size one
+// (no per-sample entries). A count that fits decodes; one claiming more
+// samples than the mdat holds is rejected when the file is opened.
+func TestSampleTable_ConstantSizeStsz(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 1024
+
+	// Equal-sized verbatim packets, so one stsz size covers them all.
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:4*frameLength*4]
+
+	var packets [][]byte
+	for off := 0; off < len(pcm); off += frameLength * 4 {
+		packets = append(packets, testutil.EncodeVerbatimPacket(pcm[off:off+frameLength*4], 16, 2, frameLength))
+	}
+
+	for _, count := range []int{len(packets), 1000} {
+		t.Run(fmt.Sprint(count), func(t *testing.T) {
+			t.Parallel()
+
+			m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+				SampleRate:  8000,
+				BitDepth:    16,
+				Channels:    2,
+				FrameLength: frameLength,
+				Packets:     packets,
+				Stsc:        []testutil.StscEntry{{FirstChunk: 1, SamplesPerChunk: count}},
+				ExtraStblBoxes: [][]byte{
+					testutil.FullBox("stsz", testutil.U32(len(packets[0])), testutil.U32(count)),
+				},
+			})
+
+			// Hide the generated stsz so the constant-size one is used.
+			copy(m4a[findFourCC(m4a, "stsz")+4:], "free")
+
+			dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+			if count > len(packets) {
+				if !errors.Is(err, alac.ErrNoTrack) {
+					t.Fatalf("expected ErrNoTrack, got: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			got, err := io.ReadAll(dec)
+			if err != nil || !bytes.Equal(got, pcm) {
+				t.Fatalf("decoded %d bytes (%v), want the %d-byte source", len(got), err, len(pcm))
+			}
+		})
+	}
+}
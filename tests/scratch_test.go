@@ -0,0 +1,83 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestWithScratch(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 1024
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:frameLength*4]
+	packet := testutil.EncodeVerbatimPacket(pcm, 16, 2, frameLength)
+
+	config, err := alac.ParseMagicCookie(testutil.Cookie(frameLength, 16, 2, 8000))
+	if err != nil {
+		t.Fatalf("ParseMagicCookie: %v", err)
+	}
+
+	mixU, mixV := make([]int32, frameLength), make([]int32, frameLength)
+
+	dec, err := alac.NewPacketDecoder(config,
+		alac.WithScratch(mixU, mixV, make([]int32, frameLength), make([]uint16, 2*frameLength)))
+	if err != nil {
+		t.Fatalf("NewPacketDecoder: %v", err)
+	}
+
+	got, err := dec.DecodePacket(packet)
+	if err != nil {
+		t.Fatalf("DecodePacket: %v", err)
+	}
+
+	if !bytes.Equal(got, pcm) {
+		t.Fatal("decoded PCM differs from input")
+	}
+
+	if !slices.ContainsFunc(mixU, func(v int32) bool { return v != 0 }) {
+		t.Error("decoder did not use the supplied mix buffer")
+	}
+}
+
+func TestWithScratch_TooShort(t *testing.T) {
+	t.Parallel()
+
+	config := alac.PacketConfig{FrameLength: 4096, BitDepth: 16, NumChannels: 2, SampleRate: 44100}
+	full, short := make([]int32, 4096), make([]int32, 4095)
+	shift := make([]uint16, 2*4096)
+
+	for name, opt := range map[string]alac.Option{
+		"mixU":      alac.WithScratch(short, full, full, shift),
+		"mixV":      alac.WithScratch(full, short, full, shift),
+		"predictor": alac.WithScratch(full, full, short, shift),
+		"shift":     alac.WithScratch(full, full, full, shift[:4096]),
+	} {
+		if _, err := alac.NewPacketDecoder(config, opt); !errors.Is(err, alac.ErrConfig) {
+			t.Errorf("short %s: expected ErrConfig, got: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestSeek_VariableFrameLengths(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleRate    = 8000
+		channels      = 2
+		bytesPerFrame = 2 * channels
+	)
+
+	// Four packets of 4096 frames then four of 1024: stts holds two runs.
+	frames := []int{4096, 4096, 4096, 4096, 1024, 1024, 1024, 1024}
+	pcm := agar.GenerateWhiteNoise(sampleRate, 16, channels, 3)
+
+	packets := make([][]byte, len(frames))
+	starts := make([]int, len(frames))
+	pos := 0
+
+	for idx, n := range frames {
+		starts[idx] = pos
+		packets[idx] = testutil.EncodeVerbatimPacket(pcm[pos*bytesPerFrame:(pos+n)*bytesPerFrame], 16, channels, 4096)
+		pos += n
+	}
+
+	pcm = pcm[:pos*bytesPerFrame]
+
+	dec, err := alac.NewDecoder(bytes.NewReader(testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:   sampleRate,
+		BitDepth:     16,
+		Channels:     channels,
+		Packets:      packets,
+		PacketFrames: frames,
+	})))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if dec.ConstantFrameLength() {
+		t.Fatal("ConstantFrameLength() = true for variable packet durations")
+	}
+
+	for _, tc := range []struct {
+		name   string
+		frame  int
+		packet int
+	}{
+		{"start", 0, 0},
+		{"inside first run", 5000, 1},
+		{"first packet of second run", starts[4], 4},
+		{"inside second run", starts[5] + 10, 5},
+		{"last packet", starts[7] + 1000, 7},
+	} {
+		target := time.Duration(tc.frame) * time.Second / sampleRate
+
+		got, err := dec.Seek(target)
+		if err != nil {
+			t.Fatalf("%s: Seek: %v", tc.name, err)
+		}
+
+		if want := time.Duration(starts[tc.packet]) * time.Second / sampleRate; got != want {
+			t.Fatalf("%s: Seek(%v) = %v, want %v", tc.name, target, got, want)
+		}
+
+		if pos := dec.Position(); pos != got {
+			t.Fatalf("%s: Position() = %v, want %v", tc.name, pos, got)
+		}
+
+		out, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("%s: ReadAll: %v", tc.name, err)
+		}
+
+		if !bytes.Equal(out, pcm[starts[tc.packet]*bytesPerFrame:]) {
+			t.Fatalf("%s: decoded PCM after seek does not match the source", tc.name)
+		}
+	}
+}
+
+func TestSeek_ConstantFrameLength(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sampleRate    = 8000
+		frameLength   = 4096
+		bytesPerFrame = 2
+	)
+
+	// 8000 frames: one full packet, then a partial one.
+	m4a, pcm := syntheticM4A(t, sampleRate, 16, 1)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if !dec.ConstantFrameLength() {
+		t.Fatal("ConstantFrameLength() = false for full packets and a partial final one")
+	}
+
+	for _, tc := range []struct {
+		frame int
+		start int
+	}{
+		{0, 0},
+		{frameLength - 1, 0},
+		{frameLength, frameLength},
+		{7999, frameLength},
+	} {
+		target := time.Duration(tc.frame) * time.Second / sampleRate
+
+		got, err := dec.Seek(target)
+		if err != nil {
+			t.Fatalf("Seek(%v): %v", target, err)
+		}
+
+		if want := time.Duration(tc.start) * time.Second / sampleRate; got != want {
+			t.Fatalf("Seek(%v) = %v, want %v", target, got, want)
+		}
+
+		out, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+
+		if !bytes.Equal(out, pcm[tc.start*bytesPerFrame:]) {
+			t.Fatalf("frame %d: decoded PCM after seek does not match the source", tc.frame)
+		}
+	}
+}
+
+func TestSeekFrames(t *testing.T) {
+	t.Parallel()
+
+	const bytesPerFrame = 4 // 16-bit stereo
+
+	m4a, pcm := syntheticM4A(t, 44100, 16, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// Mid-packet targets land on the packet's first frame.
+	got, err := dec.SeekFrames(5000)
+	if err != nil || got != 4096 {
+		t.Fatalf("SeekFrames(5000) = %d, %v; want 4096", got, err)
+	}
+
+	if pos := dec.PositionFrames(); pos != 4096 {
+		t.Fatalf("PositionFrames = %d, want 4096", pos)
+	}
+
+	buf := make([]byte, 100*bytesPerFrame)
+	if _, err := io.ReadFull(dec, buf); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+
+	if !bytes.Equal(buf, pcm[4096*bytesPerFrame:4196*bytesPerFrame]) {
+		t.Fatal("read after SeekFrames differs from the source")
+	}
+
+	if pos := dec.PositionFrames(); pos != 4196 {
+		t.Fatalf("PositionFrames after reading = %d, want 4196", pos)
+	}
+
+	if got, err := dec.SeekFrames(-10); err != nil || got != 0 {
+		t.Fatalf("SeekFrames(-10) = %d, %v; want 0", got, err)
+	}
+
+	// At 44.1 kHz frame 8192 starts at 185759637.18ns: the truncated time
+	// Seek returns must seek back to the same packet.
+	boundary, err := dec.Seek(2 * 4096 * time.Second / 44100)
+	if err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	if again, err := dec.Seek(boundary); err != nil || again != boundary || dec.PositionFrames() != 8192 {
+		t.Fatalf("Seek(%v) = %v, %v at frame %d; want frame 8192", boundary, again, err, dec.PositionFrames())
+	}
+}
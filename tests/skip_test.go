@@ -0,0 +1,82 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestDecoder_Skip(t *testing.T) {
+	t.Parallel()
+
+	const (
+		channels      = 2
+		bytesPerFrame = channels * 3
+	)
+
+	m4a, pcm := syntheticM4A(t, 22050, 24, channels)
+	totalFrames := int64(len(pcm) / bytesPerFrame)
+
+	for _, skip := range []int64{0, 1, 100, 4095, 4096, 4097, 12000, totalFrames - 11} {
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+		if err != nil {
+			t.Fatalf("NewDecoder: %v", err)
+		}
+
+		// Split the skip around a partial read to exercise the buffered path.
+		head := make([]byte, 10*bytesPerFrame)
+		if _, err := io.ReadFull(dec, head); err != nil {
+			t.Fatalf("ReadFull: %v", err)
+		}
+
+		skipped, err := dec.Skip(skip)
+		if err != nil || skipped != skip {
+			t.Fatalf("Skip(%d) = (%d, %v)", skip, skipped, err)
+		}
+
+		rest, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("ReadAll after Skip(%d): %v", skip, err)
+		}
+
+		if want := pcm[(10+skip)*bytesPerFrame:]; !bytes.Equal(rest, want) {
+			t.Fatalf("Skip(%d): got %d bytes, want %d matching bytes", skip, len(rest), len(want))
+		}
+	}
+}
+
+func TestDecoder_SkipPastEnd(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, 1)
+	totalFrames := int64(len(pcm) / 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	skipped, err := dec.Skip(totalFrames + 1000)
+	if !errors.Is(err, io.EOF) || skipped != totalFrames {
+		t.Fatalf("Skip past end = (%d, %v), want (%d, io.EOF)", skipped, err, totalFrames)
+	}
+}
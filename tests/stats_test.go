@@ -0,0 +1,132 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestDecoder_Stats(t *testing.T) {
+	t.Parallel()
+
+	// 8000 frames: a full 4096-frame packet and a partial one.
+	m4a, pcm := syntheticM4A(t, 8000, 24, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithStats())
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if stats := dec.Stats(); stats != (alac.DecodeStats{}) {
+		t.Fatalf("Stats before decoding = %+v, want zero", stats)
+	}
+
+	if _, err := io.Copy(io.Discard, dec); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	stats := dec.Stats()
+	if stats.PacketsDecoded != 2 || stats.FramesDecoded != 8000 || stats.BytesOut != int64(len(pcm)) {
+		t.Fatalf("Stats = %+v, want 2 packets, 8000 frames, %d bytes", stats, len(pcm))
+	}
+
+	if stats.DecodeTime < 0 {
+		t.Fatalf("negative DecodeTime %v", stats.DecodeTime)
+	}
+
+	// Decoding again after a seek adds to the totals.
+	if _, err := dec.Seek(0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	if _, err := io.Copy(io.Discard, dec); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	if again := dec.Stats(); again.PacketsDecoded != 4 || again.BytesOut != 2*int64(len(pcm)) {
+		t.Fatalf("Stats after a second pass = %+v", again)
+	}
+}
+
+func TestDecoder_StatsDisabled(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 1)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := io.Copy(io.Discard, dec); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	if stats := dec.Stats(); stats != (alac.DecodeStats{}) {
+		t.Fatalf("Stats without WithStats = %+v, want zero", stats)
+	}
+}
+
+func TestDecoder_PacketTimings(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithPacketTiming())
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	before := dec.PacketTimings()
+	if len(before) != 2 || before[0] != 0 || before[1] != 0 {
+		t.Fatalf("PacketTimings before decoding = %v, want two zeros", before)
+	}
+
+	if _, err := io.Copy(io.Discard, dec); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	timings := dec.PacketTimings()
+	if len(timings) != 2 {
+		t.Fatalf("got %d timings, want 2", len(timings))
+	}
+
+	for idx, elapsed := range timings {
+		if elapsed < 0 {
+			t.Fatalf("packet %d: negative time %v", idx, elapsed)
+		}
+	}
+
+	// The returned slice is a copy.
+	timings[0] = -1
+	if dec.PacketTimings()[0] == -1 {
+		t.Fatal("PacketTimings returned the decoder's own slice")
+	}
+
+	plain, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if got := plain.PacketTimings(); got != nil {
+		t.Fatalf("PacketTimings without WithPacketTiming = %v, want nil", got)
+	}
+}
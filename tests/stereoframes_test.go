@@ -0,0 +1,267 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	alacint "github.com/mycophonic/saprobe-alac/internal/alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// readStereoFrames drains dec through ReadStereoFrames in odd-sized requests
+// that cross packet boundaries.
+func readStereoFrames(t *testing.T, dec *alac.Decoder) [][2]int32 {
+	t.Helper()
+
+	var out [][2]int32
+
+	dst := make([][2]int32, 1000)
+
+	for {
+		n, err := dec.ReadStereoFrames(dst)
+		out = append(out, dst[:n]...)
+
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+
+		if err != nil {
+			t.Fatalf("ReadStereoFrames: %v", err)
+		}
+	}
+}
+
+// stereoPairs converts 16-bit interleaved stereo PCM to frame pairs.
+func stereoPairs(pcm []byte) [][2]int32 {
+	pairs := make([][2]int32, len(pcm)/4)
+	for idx := range pairs {
+		pairs[idx] = [2]int32{testutil.PCMSample(pcm[idx*4:], 16), testutil.PCMSample(pcm[idx*4+2:], 16)}
+	}
+
+	return pairs
+}
+
+func TestReadStereoFrames(t *testing.T) {
+	t.Parallel()
+
+	for _, depth := range []int{16, 20, 24, 32} {
+		m4a, pcm := syntheticM4A(t, 8000, depth, 2)
+		bps := testutil.BytesPerSample(depth)
+
+		// 20-bit samples stay left-aligned in 24 bits, as in the byte output;
+		// the noise generator fills the low nibble the encoder drops.
+		scale, mask := depth, int32(0)
+		if depth == 20 {
+			scale, mask = 24, 0xF
+		}
+
+		dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+		if err != nil {
+			t.Fatalf("%d-bit: NewDecoder: %v", depth, err)
+		}
+
+		// An odd request size crosses packet boundaries mid-request.
+		dst := make([][2]int32, 1000)
+		frame := 0
+
+		for {
+			n, err := dec.ReadStereoFrames(dst)
+			for _, pair := range dst[:n] {
+				off := frame * 2 * bps
+
+				want := [2]int32{
+					testutil.PCMSample(pcm[off:], scale) &^ mask,
+					testutil.PCMSample(pcm[off+bps:], scale) &^ mask,
+				}
+				if pair != want {
+					t.Fatalf("%d-bit frame %d: got %v, want %v", depth, frame, pair, want)
+				}
+
+				frame++
+			}
+
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				t.Fatalf("%d-bit: ReadStereoFrames: %v", depth, err)
+			}
+		}
+
+		if frame != len(pcm)/(2*bps) {
+			t.Fatalf("%d-bit: read %d frames, want %d", depth, frame, len(pcm)/(2*bps))
+		}
+	}
+}
+
+func TestReadStereoFrames_RejectsMono(t *testing.T) {
+	t.Parallel()
+
+	m4a, _ := syntheticM4A(t, 8000, 16, 1)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := dec.ReadStereoFrames(make([][2]int32, 16)); !errors.Is(err, alac.ErrConfig) {
+		t.Fatalf("expected ErrConfig, got: %v", err)
+	}
+}
+
+func TestReadStereoFrames_Paths(t *testing.T) {
+	t.Parallel()
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)
+	frames := len(pcm) / 4
+
+	var dualMono [][]byte
+	for off := 0; off < len(pcm); off += 4096 * 4 {
+		dualMono = append(dualMono, testutil.EncodeDualMonoPacket(pcm[off:min(off+4096*4, len(pcm))], 16, 4096))
+	}
+
+	pairM4A := testutil.BuildM4A(testutil.SyntheticM4A{SampleRate: 8000, BitDepth: 16, Channels: 2, PCM: pcm})
+	monoM4A := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate: 8000, BitDepth: 16, Channels: 2, Packets: dualMono, PCM: pcm,
+	})
+
+	for name, tc := range map[string]struct {
+		m4a  []byte
+		opts []alac.Option
+		want [][2]int32
+	}{
+		"channel pair": {pairM4A, nil, stereoPairs(pcm)},
+		"dual mono":    {monoM4A, nil, stereoPairs(pcm)},
+		"padded final frame": {
+			pairM4A, []alac.Option{alac.WithPaddedFinalFrame()},
+			append(stereoPairs(pcm), make([][2]int32, 4096-frames%4096)...),
+		},
+		"swapped selection": {
+			pairM4A, []alac.Option{alac.WithChannelSelection([]int{1, 0})},
+			func() [][2]int32 {
+				pairs := stereoPairs(pcm)
+				for idx := range pairs {
+					pairs[idx] = [2]int32{pairs[idx][1], pairs[idx][0]}
+				}
+
+				return pairs
+			}(),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := alac.NewDecoder(bytes.NewReader(tc.m4a), tc.opts...)
+			if err != nil {
+				t.Fatalf("NewDecoder: %v", err)
+			}
+
+			got := readStereoFrames(t, dec)
+			if len(got) != len(tc.want) {
+				t.Fatalf("read %d frames, want %d", len(got), len(tc.want))
+			}
+
+			for idx := range got {
+				if got[idx] != tc.want[idx] {
+					t.Fatalf("frame %d: got %v, want %v", idx, got[idx], tc.want[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestReadStereoFrames_ThenRead(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, 2)
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	// Stop mid-packet: Read picks up the rest of the packet as bytes.
+	dst := make([][2]int32, 1000)
+	if n, err := dec.ReadStereoFrames(dst[:1]); n != 1 || err != nil {
+		t.Fatalf("ReadStereoFrames = %d, %v", n, err)
+	}
+
+	footprint := dec.MemoryFootprint()
+
+	if n, err := dec.ReadStereoFrames(dst[1:]); n != len(dst)-1 || err != nil {
+		t.Fatalf("ReadStereoFrames = %d, %v", n, err)
+	}
+
+	if got := dec.MemoryFootprint(); got != footprint {
+		t.Errorf("MemoryFootprint grew from %d to %d: frames were staged", footprint, got)
+	}
+
+	rest, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if !bytes.Equal(rest, pcm[len(dst)*6:]) {
+		t.Fatalf("Read after ReadStereoFrames: got %d bytes, want the %d after frame %d",
+			len(rest), len(pcm)-len(dst)*6, len(dst))
+	}
+}
+
+func TestUnmixStereo(t *testing.T) {
+	t.Parallel()
+
+	const numSamples = 64
+
+	rng := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // deterministic test data
+
+	for _, tc := range []struct {
+		mixBits, mixRes int32
+		bytesShifted    int
+	}{
+		{0, 0, 0}, {2, 3, 0}, {1, -2, 1}, {0, 0, 2},
+	} {
+		mixU, mixV := make([]int32, numSamples), make([]int32, numSamples)
+		shift := make([]uint16, 2*numSamples)
+
+		for idx := range numSamples {
+			mixU[idx], mixV[idx] = rng.Int32N(1<<15)-1<<14, rng.Int32N(1<<15)-1<<14
+			shift[2*idx], shift[2*idx+1] = uint16(rng.UintN(1<<(8*tc.bytesShifted))), uint16(rng.UintN(1<<(8*tc.bytesShifted)))
+		}
+
+		want := make([]byte, numSamples*8)
+		alacint.WriteStereo32(want, mixU, mixV, 0, 2, numSamples, tc.mixBits, tc.mixRes, shift, tc.bytesShifted)
+
+		alacint.UnmixStereo(mixU, mixV, numSamples, tc.mixBits, tc.mixRes, shift, tc.bytesShifted)
+
+		got := make([]byte, numSamples*8)
+		alacint.WriteStereo32(got, mixU, mixV, 0, 2, numSamples, 0, 0, nil, 0)
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("mixBits %d, mixRes %d, bytesShifted %d: unmixed samples differ from WriteStereo32",
+				tc.mixBits, tc.mixRes, tc.bytesShifted)
+		}
+	}
+}
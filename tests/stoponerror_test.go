@@ -0,0 +1,88 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestWithStopOnError(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 1024
+
+	pcm := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:3*frameLength*4]
+	packets := make([][]byte, 3)
+
+	for idx := range packets {
+		packets[idx] = testutil.EncodeVerbatimPacket(pcm[idx*frameLength*4:(idx+1)*frameLength*4], 16, 2, frameLength)
+	}
+
+	packets[1] = bytes.Clone(packets[1])
+	packets[1][0] = packets[1][0]&0x1F | 2<<5 // coupling channel element tag
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    2,
+		FrameLength: frameLength,
+		Packets:     packets,
+	})
+
+	// Strict decoding fails at the bad packet.
+	strict, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	if _, err := io.ReadAll(strict); !errors.Is(err, alac.ErrDecode) {
+		t.Fatalf("strict: expected ErrDecode, got: %v", err)
+	}
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithStopOnError())
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if !bytes.Equal(got, pcm[:frameLength*4]) {
+		t.Fatalf("got %d bytes, want the %d bytes before the bad packet", len(got), frameLength*4)
+	}
+
+	warnings := dec.Warnings()
+	if len(warnings) != 1 || warnings[0].Packet != 1 || warnings[0].Channel != -1 ||
+		!errors.Is(warnings[0].Err, alac.ErrDecode) {
+		t.Fatalf("unexpected warnings: %+v", warnings)
+	}
+
+	// The stream stays ended.
+	if n, err := dec.Read(make([]byte, 64)); n != 0 || err != io.EOF { //nolint:errorlint // io.EOF is unwrapped.
+		t.Fatalf("Read after stop returned %d, %v", n, err)
+	}
+}
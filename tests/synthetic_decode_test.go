@@ -39,14 +39,14 @@ func decode(reader io.ReadSeeker) ([]byte, alac.PCMFormat, error) {
 }
 
 // decodeSaprobe decodes an encoded file using the saprobe (pure Go) decoder.
-func decodeSaprobe(path string) ([]byte, alac.PCMFormat, error) {
+func decodeSaprobe(path string, opts ...alac.Option) ([]byte, alac.PCMFormat, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, alac.PCMFormat{}, err
 	}
 	defer f.Close()
 
-	dec, decErr := alac.NewDecoder(f)
+	dec, decErr := alac.NewDecoder(f, opts...)
 	if decErr != nil {
 		return nil, alac.PCMFormat{}, decErr
 	}
@@ -0,0 +1,121 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions bounded by test stream sizes.
+package testutil
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Minimal Matroska streams: an EBML header, then a Segment holding Tracks and
+// Clusters of SimpleBlock or BlockGroup frames.
+
+// Matroska element IDs used by the builders.
+const (
+	MKVSegmentID     = 0x18538067
+	MKVTracksID      = 0x1654AE6B
+	MKVClusterID     = 0x1F43B675
+	MKVCuesID        = 0x1C53BB6B
+	MKVTrackEntryID  = 0xAE
+	MKVSimpleBlockID = 0xA3
+	MKVBlockGroupID  = 0xA0
+	MKVBlockID       = 0xA1
+	MKVTimecodeID    = 0xE7
+)
+
+// mkvUnknownSize is the 8-byte size vint marking an element of unknown size.
+//
+//nolint:gochecknoglobals
+var mkvUnknownSize = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// MKVElement builds an EBML element from its ID (with length marker bits)
+// and payload parts. The size is always coded on 8 bytes.
+func MKVElement(id uint32, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(data)))
+	size[0] = 0x01
+
+	return append(append(mkvID(id), size...), data...)
+}
+
+// MKVUnsizedElement is MKVElement with an unknown size, as live muxers write
+// Segment and Cluster.
+func MKVUnsizedElement(id uint32, payload ...[]byte) []byte {
+	return append(append(mkvID(id), mkvUnknownSize...), bytes.Join(payload, nil)...)
+}
+
+// mkvID returns the bytes of an element ID, without leading zero bytes.
+func mkvID(id uint32) []byte {
+	out := binary.BigEndian.AppendUint32(nil, id)
+
+	for len(out) > 1 && out[0] == 0 {
+		out = out[1:]
+	}
+
+	return out
+}
+
+// MKVTrackEntry builds a TrackEntry with the given number, CodecID and CodecPrivate.
+func MKVTrackEntry(number int, codecID string, codecPrivate []byte) []byte {
+	parts := [][]byte{
+		MKVElement(0xD7, []byte{byte(number)}), // TrackNumber
+		MKVElement(0x83, []byte{2}),            // TrackType: audio
+		MKVElement(0x86, []byte(codecID)),      // CodecID
+	}
+
+	if codecPrivate != nil {
+		parts = append(parts, MKVElement(0x63A2, codecPrivate))
+	}
+
+	return MKVElement(MKVTrackEntryID, parts...)
+}
+
+// MKVBlock builds the payload of a SimpleBlock or Block: track number,
+// relative timecode zero, flags, then data (lacing header and frames).
+func MKVBlock(track int, flags byte, data ...[]byte) []byte {
+	return append([]byte{0x80 | byte(track), 0, 0, flags}, bytes.Join(data, nil)...)
+}
+
+// BuildMKV builds a Matroska stream with one ALAC track, number 1, whose
+// cookie is the CodecPrivate, and one SimpleBlock per packet in a single cluster.
+func BuildMKV(cookie []byte, packets [][]byte) []byte {
+	blocks := [][]byte{MKVElement(MKVTimecodeID, []byte{0})}
+	for _, packet := range packets {
+		blocks = append(blocks, MKVElement(MKVSimpleBlockID, MKVBlock(1, 0x80, packet)))
+	}
+
+	return MKVStream(
+		MKVElement(MKVTracksID, MKVTrackEntry(1, "A_ALAC", cookie)),
+		MKVElement(MKVClusterID, blocks...),
+	)
+}
+
+// MKVStream wraps Segment children in an EBML header and a Segment.
+func MKVStream(children ...[]byte) []byte {
+	return append(mkvHeader(), MKVElement(MKVSegmentID, children...)...)
+}
+
+// MKVLiveStream is MKVStream with a Segment of unknown size, as live muxers write it.
+func MKVLiveStream(children ...[]byte) []byte {
+	return append(mkvHeader(), MKVUnsizedElement(MKVSegmentID, children...)...)
+}
+
+// mkvHeader returns an EBML header with the matroska DocType.
+func mkvHeader() []byte {
+	return MKVElement(0x1A45DFA3, MKVElement(0x4282, []byte("matroska"))) // EBML, DocType
+}
@@ -0,0 +1,118 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions bounded by test stream sizes.
+package testutil
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Minimal Ogg streams: one page sequence per logical stream, the first packet
+// alone on the beginning-of-stream page, as RFC 3533 requires of
+// identification packets.
+
+// Ogg page header flags.
+const (
+	OggContinued = 0x01
+	OggBOS       = 0x02
+	OggEOS       = 0x04
+)
+
+// oggMaxSegments is the largest segment table of a page.
+const oggMaxSegments = 255
+
+// OggPages paginates the packets of one logical stream. Every page after the
+// first holds at most pageBody bytes of packet data, so that longer packets
+// are split across pages.
+func OggPages(serial uint32, packets [][]byte, pageBody int) [][]byte {
+	var (
+		pages  [][]byte
+		lacing []byte
+		body   []byte
+		flags  byte
+	)
+
+	pages = append(pages, OggPage(serial, 0, OggBOS, oggLacing(len(packets[0])), packets[0]))
+
+	for _, packet := range packets[1:] {
+		for idx, lace := range oggLacing(len(packet)) {
+			if len(lacing) == oggMaxSegments || (len(body) > 0 && len(body)+int(lace) > pageBody) {
+				pages = append(pages, OggPage(serial, uint32(len(pages)), flags, lacing, body))
+				lacing, body, flags = nil, nil, 0
+
+				if idx > 0 {
+					flags = OggContinued
+				}
+			}
+
+			lacing = append(lacing, lace)
+			body = append(body, packet[idx*255:idx*255+int(lace)]...)
+		}
+	}
+
+	return append(pages, OggPage(serial, uint32(len(pages)), flags|OggEOS, lacing, body))
+}
+
+// BuildOgg builds an Ogg stream holding one logical stream: the
+// identification packet ident, then packets, paginated as by OggPages.
+func BuildOgg(serial uint32, ident []byte, packets [][]byte, pageBody int) []byte {
+	return bytes.Join(OggPages(serial, append([][]byte{ident}, packets...), pageBody), nil)
+}
+
+// OggPage builds one page from its segment table and body, with its CRC.
+func OggPage(serial, sequence uint32, flags byte, lacing, body []byte) []byte {
+	page := []byte("OggS\x00")
+	page = append(page, flags)
+	page = binary.LittleEndian.AppendUint64(page, 0) // granule position
+	page = binary.LittleEndian.AppendUint32(page, serial)
+	page = binary.LittleEndian.AppendUint32(page, sequence)
+	page = binary.LittleEndian.AppendUint32(page, 0) // CRC, set below
+	page = append(page, byte(len(lacing)))
+	page = append(append(page, lacing...), body...)
+
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+
+	return page
+}
+
+// oggLacing returns the lacing values of a packet of the given size.
+func oggLacing(size int) []byte {
+	lacing := bytes.Repeat([]byte{255}, size/255)
+
+	return append(lacing, byte(size%255))
+}
+
+// oggCRC computes the Ogg page checksum: CRC-32 with polynomial 0x04C11DB7,
+// unreflected, zero initial value and no final XOR.
+func oggCRC(data []byte) uint32 {
+	var crc uint32
+
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
@@ -0,0 +1,551 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+//nolint:gosec // Integer conversions bounded by audio format constraints.
+package testutil
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// Synthetic ALAC streams for tests that must not depend on external encoders.
+//
+// Packets are built from escape (verbatim) elements: samples are stored raw,
+// so the expected decode output is exactly the input PCM. The container is a
+// minimal faststart M4A (ftyp, moov, mdat) with one ALAC track per spec.
+
+// ALAC element tags.
+const (
+	elemSCE = 0
+	elemCPE = 1
+	elemLFE = 3
+	elemEND = 7
+)
+
+const defaultFrameLength = 4096
+
+// elementLayouts lists the bitstream elements for each channel count,
+// following the Apple reference encoder (MPEG element order).
+//
+//nolint:gochecknoglobals
+var elementLayouts = [8][]int{
+	{elemSCE},
+	{elemCPE},
+	{elemSCE, elemCPE},
+	{elemSCE, elemCPE, elemSCE},
+	{elemSCE, elemCPE, elemCPE},
+	{elemSCE, elemCPE, elemCPE, elemLFE},
+	{elemSCE, elemCPE, elemCPE, elemSCE, elemLFE},
+	{elemSCE, elemCPE, elemCPE, elemCPE, elemLFE},
+}
+
+// outputPositions maps bitstream channel index to SMPTE output channel index.
+// Mirrors the decoder's channelLayoutOffsets table.
+//
+//nolint:gochecknoglobals
+var outputPositions = [8][8]int{
+	{0},
+	{0, 1},
+	{2, 0, 1},
+	{2, 0, 1, 3},
+	{2, 0, 1, 3, 4},
+	{2, 0, 1, 4, 5, 3},
+	{2, 0, 1, 4, 5, 6, 3},
+	{2, 6, 7, 0, 1, 4, 5, 3},
+}
+
+// BitWriter is a big-endian, MSB-first bit writer matching the ALAC bit reader.
+type BitWriter struct {
+	buf    []byte
+	bitPos int
+}
+
+// Write appends the low numBits bits of val.
+func (w *BitWriter) Write(val uint32, numBits int) {
+	for i := numBits - 1; i >= 0; i-- {
+		if w.bitPos%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+
+		if (val>>uint(i))&1 != 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> uint(w.bitPos%8)
+		}
+
+		w.bitPos++
+	}
+}
+
+// ByteAlign pads with zero bits up to the next byte boundary.
+func (w *BitWriter) ByteAlign() {
+	if rem := w.bitPos % 8; rem != 0 {
+		w.bitPos += 8 - rem
+	}
+}
+
+// Bytes returns the written data.
+func (w *BitWriter) Bytes() []byte {
+	return w.buf
+}
+
+// BytesPerSample returns the container size of one decoded PCM sample.
+func BytesPerSample(bitDepth int) int {
+	switch bitDepth {
+	case 16:
+		return 2
+	case 20, 24:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// PCMSample reads one sample from decoder-layout PCM (LE signed; 20-bit
+// samples left-aligned in 3 bytes) and returns it at its native bit depth.
+func PCMSample(pcm []byte, bitDepth int) int32 {
+	switch bitDepth {
+	case 16:
+		return int32(int16(binary.LittleEndian.Uint16(pcm)))
+	case 20:
+		return int32(uint32(pcm[0])<<8|uint32(pcm[1])<<16|uint32(pcm[2])<<24) >> 12
+	case 24:
+		return int32(uint32(pcm[0])<<8|uint32(pcm[1])<<16|uint32(pcm[2])<<24) >> 8
+	default:
+		return int32(binary.LittleEndian.Uint32(pcm))
+	}
+}
+
+// WriteEscapeSamples writes raw samples the way the decoder's escape path reads them.
+func WriteEscapeSamples(bw *BitWriter, val int32, chanBits int) {
+	if chanBits <= 16 {
+		bw.Write(uint32(val)&(1<<chanBits-1), chanBits)
+
+		return
+	}
+
+	extra := chanBits - 16
+	bw.Write(uint32(val>>extra)&0xFFFF, 16)
+	bw.Write(uint32(val)&(1<<extra-1), extra)
+}
+
+// WriteElementHeader writes an SCE/CPE/LFE header with escape flag set.
+// When numSamples differs from frameLength the partial-frame count is included.
+func WriteElementHeader(bw *BitWriter, tag, numSamples, frameLength int) {
+	bw.Write(uint32(tag), 3)
+	bw.Write(0, 4)  // element instance tag
+	bw.Write(0, 12) // unused header bits
+
+	partial := uint32(0)
+	if numSamples != frameLength {
+		partial = 1
+	}
+
+	bw.Write(partial<<3|1, 4) // partialFrame, bytesShifted=0, escapeFlag=1
+
+	if partial != 0 {
+		bw.Write(uint32(numSamples)>>16, 16)
+		bw.Write(uint32(numSamples)&0xFFFF, 16)
+	}
+}
+
+// EncodeVerbatimPacket encodes one packet of interleaved decoder-layout PCM
+// using escape elements. The packet holds len(pcm)/(channels*bytesPerSample)
+// frames, which must not exceed frameLength.
+func EncodeVerbatimPacket(pcm []byte, bitDepth, channels, frameLength int) []byte {
+	return encodeVerbatimElements(pcm, bitDepth, channels, frameLength, elementLayouts[channels-1])
+}
+
+// EncodeDualMonoPacket is EncodeVerbatimPacket for stereo PCM, coded as two
+// independent SCEs rather than the CPE encoders write.
+func EncodeDualMonoPacket(pcm []byte, bitDepth, frameLength int) []byte {
+	return encodeVerbatimElements(pcm, bitDepth, 2, frameLength, []int{elemSCE, elemSCE})
+}
+
+// encodeVerbatimElements encodes one verbatim packet as the given sequence of elements.
+func encodeVerbatimElements(pcm []byte, bitDepth, channels, frameLength int, tags []int) []byte {
+	bps := BytesPerSample(bitDepth)
+	numSamples := len(pcm) / (channels * bps)
+	positions := outputPositions[channels-1]
+
+	sample := func(idx, ch int) int32 {
+		return PCMSample(pcm[(idx*channels+ch)*bps:], bitDepth)
+	}
+
+	var bw BitWriter
+
+	chanIdx := 0
+
+	for _, tag := range tags {
+		WriteElementHeader(&bw, tag, numSamples, frameLength)
+
+		outCh := positions[chanIdx]
+
+		if tag == elemCPE {
+			for idx := range numSamples {
+				WriteEscapeSamples(&bw, sample(idx, outCh), bitDepth)
+				WriteEscapeSamples(&bw, sample(idx, outCh+1), bitDepth)
+			}
+
+			chanIdx += 2
+
+			continue
+		}
+
+		for idx := range numSamples {
+			WriteEscapeSamples(&bw, sample(idx, outCh), bitDepth)
+		}
+
+		chanIdx++
+	}
+
+	bw.Write(elemEND, 3)
+	bw.ByteAlign()
+
+	return bw.Bytes()
+}
+
+// Cookie returns a 24-byte ALACSpecificConfig with reference-encoder tuning values.
+func Cookie(frameLength, bitDepth, channels, sampleRate int) []byte {
+	cookie := make([]byte, 24)
+	binary.BigEndian.PutUint32(cookie[0:4], uint32(frameLength))
+	cookie[4] = 0 // compatible version
+	cookie[5] = byte(bitDepth)
+	cookie[6] = 40 // pb
+	cookie[7] = 10 // mb
+	cookie[8] = 14 // kb
+	cookie[9] = byte(channels)
+	binary.BigEndian.PutUint16(cookie[10:12], 255) // maxRun
+	binary.BigEndian.PutUint32(cookie[12:16], 0)   // maxFrameBytes
+	binary.BigEndian.PutUint32(cookie[16:20], 0)   // avgBitRate
+	binary.BigEndian.PutUint32(cookie[20:24], uint32(sampleRate))
+
+	return cookie
+}
+
+// Box builds an MP4 box from a four-character type and payload parts.
+func Box(fourCC string, payload ...[]byte) []byte {
+	size := 8
+	for _, part := range payload {
+		size += len(part)
+	}
+
+	out := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(out[0:4], uint32(size))
+	copy(out[4:8], fourCC)
+
+	for _, part := range payload {
+		out = append(out, part...)
+	}
+
+	return out
+}
+
+// FullBox builds an MP4 full box (version and flags zero) from payload parts.
+func FullBox(fourCC string, payload ...[]byte) []byte {
+	return Box(fourCC, append([][]byte{make([]byte, 4)}, payload...)...)
+}
+
+// U16 returns v as 2 big-endian bytes.
+func U16(v int) []byte {
+	return binary.BigEndian.AppendUint16(nil, uint16(v))
+}
+
+// U32 returns v as 4 big-endian bytes.
+func U32(v int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(v))
+}
+
+// U64 returns v as 8 big-endian bytes.
+func U64(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+// StscEntry is one sample-to-chunk run.
+type StscEntry struct {
+	FirstChunk      int
+	SamplesPerChunk int
+	// SampleDescriptionIndex is the 1-based stsd entry of the run's samples. Defaults to 1.
+	SampleDescriptionIndex int
+}
+
+// SyntheticM4A describes a synthetic single-track ALAC M4A file.
+type SyntheticM4A struct {
+	SampleRate  int
+	BitDepth    int
+	Channels    int
+	FrameLength int // Defaults to 4096.
+
+	// Packets are the encoded ALAC packets. When nil, PCM is split into
+	// FrameLength-sized verbatim packets.
+	Packets [][]byte
+	// PCM is interleaved decoder-layout PCM, used when Packets is nil.
+	PCM []byte
+	// PacketFrames gives per-packet frame counts for stts. When nil, every
+	// packet is FrameLength frames except a shorter final one derived from PCM.
+	PacketFrames []int
+
+	// SamplesPerChunk groups packets into chunks. Defaults to all packets in one chunk.
+	SamplesPerChunk int
+	// ChunkSamples gives explicit per-chunk packet counts, overriding SamplesPerChunk.
+	ChunkSamples []int
+	// Stsc overrides the generated sample-to-chunk table.
+	Stsc []StscEntry
+
+	// SampleEntryBoxes are appended to the alac sample entry after the cookie box.
+	SampleEntryBoxes [][]byte
+	// SampleEntry overrides the whole generated sample entry box, making
+	// Cookie and SampleEntryBoxes unused.
+	SampleEntry []byte
+	// LeadingSampleEntries are placed in stsd before the alac sample entry.
+	LeadingSampleEntries [][]byte
+	// Cookie overrides the generated magic cookie.
+	Cookie []byte
+	// ExtraStblBoxes are appended to the stbl box after stco.
+	ExtraStblBoxes [][]byte
+	// ExtraTrakBoxes are appended to the trak box after mdia.
+	ExtraTrakBoxes [][]byte
+	// ExtraMoovBoxes are appended to the moov box after the trak.
+	ExtraMoovBoxes [][]byte
+	// LeadingMoovBoxes are placed in the moov box before the trak, such as
+	// other tracks the ALAC one must be found past.
+	LeadingMoovBoxes [][]byte
+}
+
+// BuildM4A assembles the synthetic file.
+func BuildM4A(spec SyntheticM4A) []byte {
+	return BuildMultiTrackM4A(spec)
+}
+
+// BuildMultiTrackM4A assembles a file with one ALAC track per spec, with
+// track IDs 1, 2, ... in order. The movie header, LeadingMoovBoxes and
+// ExtraMoovBoxes come from the first spec; each track's packets are stored
+// contiguously in mdat.
+func BuildMultiTrackM4A(specs ...SyntheticM4A) []byte {
+	tracks := make([]syntheticTrack, len(specs))
+	for idx, spec := range specs {
+		tracks[idx] = prepareTrack(spec)
+	}
+
+	ftyp := Box("ftyp", []byte("M4A "), U32(0), []byte("M4A mp42isom"))
+
+	// Chunk offsets depend on the moov size, which does not depend on their values.
+	moov := buildMoov(tracks)
+	offset := len(ftyp) + len(moov) + 8
+
+	var mdatPayload []byte
+
+	for idx := range tracks {
+		track := &tracks[idx]
+		track.offsets = nil
+		packetIdx := 0
+
+		for _, count := range track.chunks {
+			track.offsets = append(track.offsets, offset)
+
+			for range count {
+				offset += len(track.packets[packetIdx])
+				mdatPayload = append(mdatPayload, track.packets[packetIdx]...)
+				packetIdx++
+			}
+		}
+	}
+
+	moov = buildMoov(tracks)
+
+	out := append([]byte{}, ftyp...)
+	out = append(out, moov...)
+
+	return append(out, Box("mdat", mdatPayload)...)
+}
+
+// syntheticTrack is a spec resolved into packets and chunk layout.
+type syntheticTrack struct {
+	spec    SyntheticM4A
+	cookie  []byte
+	packets [][]byte
+	frames  []int
+	chunks  []int
+	offsets []int
+}
+
+// prepareTrack applies the spec defaults and encodes its packets.
+func prepareTrack(spec SyntheticM4A) syntheticTrack {
+	frameLength := spec.FrameLength
+	if frameLength == 0 {
+		frameLength = defaultFrameLength
+	}
+
+	packets, frames := spec.Packets, spec.PacketFrames
+	if packets == nil {
+		packets, frames = splitVerbatim(spec.PCM, spec.BitDepth, spec.Channels, frameLength)
+	}
+
+	if frames == nil {
+		frames = make([]int, len(packets))
+		for idx := range frames {
+			frames[idx] = frameLength
+		}
+	}
+
+	chunks := spec.ChunkSamples
+	if chunks == nil {
+		perChunk := spec.SamplesPerChunk
+		if perChunk == 0 {
+			perChunk = max(len(packets), 1)
+		}
+
+		for left := len(packets); left > 0; left -= perChunk {
+			chunks = append(chunks, min(perChunk, left))
+		}
+	}
+
+	cookie := spec.Cookie
+	if cookie == nil {
+		cookie = Cookie(frameLength, spec.BitDepth, spec.Channels, spec.SampleRate)
+	}
+
+	return syntheticTrack{spec: spec, cookie: cookie, packets: packets, frames: frames, chunks: chunks}
+}
+
+// splitVerbatim encodes PCM into verbatim packets of up to frameLength frames.
+func splitVerbatim(pcm []byte, bitDepth, channels, frameLength int) ([][]byte, []int) {
+	bytesPerFrame := BytesPerSample(bitDepth) * channels
+	totalFrames := len(pcm) / bytesPerFrame
+
+	var (
+		packets [][]byte
+		frames  []int
+	)
+
+	for start := 0; start < totalFrames; start += frameLength {
+		count := min(frameLength, totalFrames-start)
+		chunk := pcm[start*bytesPerFrame : (start+count)*bytesPerFrame]
+		packets = append(packets, EncodeVerbatimPacket(chunk, bitDepth, channels, frameLength))
+		frames = append(frames, count)
+	}
+
+	return packets, frames
+}
+
+// buildMoov assembles the movie box. Tracks without offsets get zero
+// placeholders of the right count.
+func buildMoov(tracks []syntheticTrack) []byte {
+	parts := slices.Clone(tracks[0].spec.LeadingMoovBoxes)
+	totalFrames := 0
+
+	for idx, track := range tracks {
+		trak, frames := buildTrak(track, idx+1)
+		parts = append(parts, trak)
+		totalFrames = max(totalFrames, frames)
+	}
+
+	first := tracks[0].spec
+	mvhd := FullBox("mvhd", U32(0), U32(0), U32(first.SampleRate), U32(totalFrames), make([]byte, 80))
+
+	return Box("moov", append(append([][]byte{mvhd}, parts...), first.ExtraMoovBoxes...)...)
+}
+
+// buildTrak assembles one track box and returns it with the track's frame count.
+func buildTrak(track syntheticTrack, trackID int) ([]byte, int) {
+	spec, cookie, packets, frames, chunks := track.spec, track.cookie, track.packets, track.frames, track.chunks
+
+	offsets := track.offsets
+	if offsets == nil {
+		offsets = make([]int, len(chunks))
+	}
+
+	totalFrames := 0
+	for _, count := range frames {
+		totalFrames += count
+	}
+
+	// alac sample entry: reserved(6) + dataRefIdx(2) + version(2) + revision(2) + vendor(4)
+	// + channels(2) + sampleSize(2) + compressionID(2) + packetSize(2) + sampleRate(4, 16.16).
+	entryParts := [][]byte{
+		make([]byte, 6), U16(1), U16(0), U16(0), U32(0),
+		U16(spec.Channels), U16(spec.BitDepth), U16(0), U16(0), U32(spec.SampleRate << 16 & 0xFFFF0000),
+		FullBox("alac", cookie),
+	}
+	entry := spec.SampleEntry
+	if entry == nil {
+		entry = Box("alac", append(entryParts, spec.SampleEntryBoxes...)...)
+	}
+
+	entries := append(slices.Clone(spec.LeadingSampleEntries), entry)
+	stsd := FullBox("stsd", append([][]byte{U32(len(entries))}, entries...)...)
+
+	// stts: run-length encode per-packet frame counts.
+	var sttsEntries []byte
+
+	runs := 0
+
+	for idx := 0; idx < len(frames); {
+		end := idx
+		for end < len(frames) && frames[end] == frames[idx] {
+			end++
+		}
+
+		sttsEntries = append(sttsEntries, U32(end-idx)...)
+		sttsEntries = append(sttsEntries, U32(frames[idx])...)
+		runs++
+		idx = end
+	}
+
+	stts := FullBox("stts", U32(runs), sttsEntries)
+
+	stscTable := spec.Stsc
+	if stscTable == nil {
+		for idx, count := range chunks {
+			if idx == 0 || count != chunks[idx-1] {
+				stscTable = append(stscTable, StscEntry{FirstChunk: idx + 1, SamplesPerChunk: count})
+			}
+		}
+	}
+
+	stscEntries := U32(len(stscTable))
+	for _, entry := range stscTable {
+		stscEntries = append(stscEntries, U32(entry.FirstChunk)...)
+		stscEntries = append(stscEntries, U32(entry.SamplesPerChunk)...)
+		stscEntries = append(stscEntries, U32(max(entry.SampleDescriptionIndex, 1))...)
+	}
+
+	stsc := FullBox("stsc", stscEntries)
+
+	stszEntries := append(U32(0), U32(len(packets))...)
+	for _, packet := range packets {
+		stszEntries = append(stszEntries, U32(len(packet))...)
+	}
+
+	stsz := FullBox("stsz", stszEntries)
+
+	stcoEntries := U32(len(offsets))
+	for _, off := range offsets {
+		stcoEntries = append(stcoEntries, U32(off)...)
+	}
+
+	stco := FullBox("stco", stcoEntries)
+
+	stbl := Box("stbl", append([][]byte{stsd, stts, stsc, stsz, stco}, spec.ExtraStblBoxes...)...)
+	minf := Box("minf", FullBox("smhd", make([]byte, 4)), stbl)
+	mdhd := FullBox("mdhd", U32(0), U32(0), U32(spec.SampleRate), U32(totalFrames), U16(0x55C4), U16(0))
+	hdlr := FullBox("hdlr", U32(0), []byte("soun"), make([]byte, 12), []byte("SoundHandler\x00"))
+	mdia := Box("mdia", mdhd, hdlr, minf)
+	tkhd := FullBox("tkhd", U32(0), U32(0), U32(trackID), U32(0), U32(totalFrames), make([]byte, 60))
+
+	trakParts := append([][]byte{tkhd, mdia}, spec.ExtraTrakBoxes...)
+
+	return Box("trak", trakParts...), totalFrames
+}
@@ -0,0 +1,71 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestWithTrace(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 16, 2)
+
+	events := map[string][]any{}
+	boxes := []string{}
+
+	trace := func(event string, args ...any) {
+		if event == "box" {
+			boxes = append(boxes, args[1].(string))
+		}
+
+		events[event] = args
+	}
+
+	if _, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithTrace(trace)); err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	for _, want := range []string{"moov", "trak", "stbl", "stsd", "stsz"} {
+		if !slices.Contains(boxes, want) {
+			t.Errorf("no box event for %q (got %v)", want, boxes)
+		}
+	}
+
+	selected, ok := events["track selected"]
+	if !ok {
+		t.Fatal("no track selected event")
+	}
+
+	if got, _ := selected[len(selected)-1].(int); got < 24 {
+		t.Errorf("cookie_length = %d, want at least 24", got)
+	}
+
+	table, ok := events["sample table"]
+	if !ok {
+		t.Fatal("no sample table event")
+	}
+
+	wantPackets := (len(pcm)/4 + 4095) / 4096
+	if got := table[1]; got != wantPackets {
+		t.Errorf("samples = %v, want %d", got, wantPackets)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/mycophonic/saprobe-alac"
+)
+
+func TestWithTrim(t *testing.T) {
+	t.Parallel()
+
+	const bytesPerFrame = 4 // 16-bit stereo
+
+	// 8000 frames: packets of 4096 and 3904 frames.
+	m4a, pcm := syntheticM4A(t, 8000, 16, 2)
+	frames := int64(len(pcm) / bytesPerFrame)
+
+	for _, tc := range []struct{ start, end int64 }{
+		{2112, 0},
+		{0, 100},
+		{2112, 100},
+		{5000, 0},    // the first packet is never decoded
+		{0, 4000},    // spans into the first packet
+		{4000, 4000}, // nothing left
+	} {
+		t.Run(fmt.Sprintf("start%d_end%d", tc.start, tc.end), func(t *testing.T) {
+			t.Parallel()
+
+			kept := max(frames-tc.start-tc.end, 0)
+			want := pcm[min(tc.start, frames)*bytesPerFrame : max(frames-tc.end, tc.start)*bytesPerFrame]
+
+			open := func() *alac.Decoder {
+				dec, err := alac.NewDecoder(bytes.NewReader(m4a), alac.WithTrimStart(tc.start), alac.WithTrimEnd(tc.end))
+				if err != nil {
+					t.Fatalf("NewDecoder: %v", err)
+				}
+
+				return dec
+			}
+
+			dec := open()
+
+			if pos := dec.PositionFrames(); pos != 0 {
+				t.Fatalf("PositionFrames before reading = %d, want 0", pos)
+			}
+
+			if size := dec.OutputSize(); size != kept*bytesPerFrame {
+				t.Fatalf("OutputSize = %d, want %d", size, kept*bytesPerFrame)
+			}
+
+			got, err := io.ReadAll(dec)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Fatalf("Read returned %d bytes, want %d from frame %d", len(got), len(want), tc.start)
+			}
+
+			if pos := dec.PositionFrames(); pos != kept {
+				t.Fatalf("PositionFrames at the end = %d, want %d", pos, kept)
+			}
+
+			// Seeking is relative to the first frame kept, and exact within it.
+			if tc.start > 0 && kept > 100 {
+				if at, err := dec.SeekFrames(100); err != nil || at != 100 {
+					t.Fatalf("SeekFrames(100) = %d, %v", at, err)
+				}
+
+				rest, err := io.ReadAll(dec)
+				if err != nil || !bytes.Equal(rest, want[100*bytesPerFrame:]) {
+					t.Fatalf("after SeekFrames(100): read %d bytes, %v", len(rest), err)
+				}
+			}
+
+			if skipped, err := open().Skip(math.MaxInt64); skipped != kept || err != io.EOF {
+				t.Fatalf("Skip = %d, %v; want %d, EOF", skipped, err, kept)
+			}
+
+			var fromFrames []byte
+			for _, pcm := range open().Frames() {
+				fromFrames = append(fromFrames, pcm...)
+			}
+
+			if !bytes.Equal(fromFrames, want) {
+				t.Fatalf("Frames yielded %d bytes, want %d", len(fromFrames), len(want))
+			}
+		})
+	}
+}
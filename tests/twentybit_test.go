@@ -0,0 +1,110 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+// twentyBitM4A builds a 20-bit stereo file whose first frames hold the
+// extremes of the range, followed by white noise.
+func twentyBitM4A(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	pcm := agar.GenerateWhiteNoise(8000, 20, 2, 1)
+
+	// The generator fills all 24 bits: clear the 4 below the sample.
+	for off := 0; off < len(pcm); off += 3 {
+		pcm[off] &= 0xF0
+	}
+
+	// 0x7FFFF, -0x80000, 1 and -1, left-justified in 3 bytes.
+	copy(pcm, []byte{
+		0xF0, 0xFF, 0x7F, 0x00, 0x00, 0x80,
+		0x10, 0x00, 0x00, 0xF0, 0xFF, 0xFF,
+	})
+
+	return testutil.BuildM4A(testutil.SyntheticM4A{SampleRate: 8000, BitDepth: 20, Channels: 2, PCM: pcm}), pcm
+}
+
+func decode20(t *testing.T, m4a []byte) []byte {
+	t.Helper()
+
+	dec, err := alac.NewDecoder(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	return got
+}
+
+func TestDecode_TwentyBitJustification(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := twentyBitM4A(t)
+
+	got := decode20(t, m4a)
+	if !bytes.Equal(got, pcm) {
+		t.Fatalf("decoded %d bytes differ from the %d-byte source", len(got), len(pcm))
+	}
+
+	// The low nibble of every sample is padding.
+	for off := 0; off < len(got); off += 3 {
+		if got[off]&0x0F != 0 {
+			t.Fatalf("sample at byte %d is not left-justified: %#x", off, got[off:off+3])
+		}
+	}
+}
+
+// TestDecode_TwentyBitMatchesFFmpeg checks the layout against ffmpeg, which
+// decodes 20-bit ALAC to 32-bit samples shifted left by 12; as s24le, that is
+// the same left-justified layout.
+func TestDecode_TwentyBitMatchesFFmpeg(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not found")
+	}
+
+	m4a, _ := twentyBitM4A(t)
+
+	path := filepath.Join(t.TempDir(), "20bit.m4a")
+	if err := os.WriteFile(path, m4a, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	want := agar.FFmpegDecode(t, agar.FFmpegDecodeOptions{Src: path, BitDepth: 24, Channels: 2})
+
+	if got := decode20(t, m4a); !bytes.Equal(got, want) {
+		t.Fatalf("saprobe's %d bytes differ from ffmpeg's %d", len(got), len(want))
+	}
+}
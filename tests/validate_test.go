@@ -0,0 +1,94 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tests_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mycophonic/agar/pkg/agar"
+
+	"github.com/mycophonic/saprobe-alac"
+	"github.com/mycophonic/saprobe-alac/tests/testutil"
+)
+
+func TestValidate_WellFormed(t *testing.T) {
+	t.Parallel()
+
+	m4a, pcm := syntheticM4A(t, 8000, 24, 6)
+
+	report, err := alac.Validate(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if !report.OK() {
+		t.Fatalf("unexpected anomalies: %v", report.Anomalies)
+	}
+
+	if want := (len(pcm)/(3*6) + 4095) / 4096; report.Packets != want {
+		t.Errorf("got %d packets, want %d", report.Packets, want)
+	}
+
+	if report.Format.Channels != 6 || report.Format.BitDepth != 24 {
+		t.Errorf("unexpected format: %v", report.Format)
+	}
+}
+
+func TestValidate_Anomalies(t *testing.T) {
+	t.Parallel()
+
+	const frameLength = 1024
+
+	stereo := agar.GenerateWhiteNoise(8000, 16, 2, 1)[:frameLength*4]
+	clean := testutil.EncodeVerbatimPacket(stereo, 16, 2, frameLength)
+
+	cce := bytes.Clone(clean)
+	cce[0] = cce[0]&0x1F | 2<<5 // coupling channel element tag
+
+	truncated := clean[:len(clean)/2]
+	mono := testutil.EncodeVerbatimPacket(stereo[:frameLength*2], 16, 1, frameLength)
+
+	m4a := testutil.BuildM4A(testutil.SyntheticM4A{
+		SampleRate:  8000,
+		BitDepth:    16,
+		Channels:    2,
+		FrameLength: frameLength,
+		Packets:     [][]byte{clean, cce, clean, truncated, mono},
+	})
+
+	report, err := alac.Validate(bytes.NewReader(m4a))
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if report.Packets != 5 {
+		t.Fatalf("got %d packets, want 5", report.Packets)
+	}
+
+	if len(report.Anomalies) != 3 {
+		t.Fatalf("got anomalies %v, want packets 1, 3 and 4", report.Anomalies)
+	}
+
+	for idx, want := range []int{1, 3, 4} {
+		anomaly := report.Anomalies[idx]
+		if anomaly.Packet != want || !errors.Is(anomaly.Err, alac.ErrDecode) {
+			t.Errorf("anomaly %d: packet %d (%v), want packet %d with ErrDecode", idx, anomaly.Packet, anomaly.Err, want)
+		}
+	}
+}
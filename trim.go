@@ -0,0 +1,100 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import "math"
+
+// WithTrimStart drops the first frames of the stream, such as an encoder's
+// priming when its length is known, without relying on an edit list. Read,
+// ReadZeroCopy, Frames and Skip never return the dropped frames, and
+// presentation time zero moves to the first frame kept, in place of the edit
+// list's start (WithMediaTime still keeps media time). Packets wholly before
+// it are not decoded. Negative counts are ignored.
+func WithTrimStart(frames int64) Option {
+	return func(o *options) { o.trimStart = max(frames, 0) }
+}
+
+// WithTrimEnd drops the last frames of the stream, such as encoder padding,
+// spanning packets as needed. The end is taken from stts; without it, the
+// final packet is assumed to hold FrameLength frames until it is decoded, so
+// a trim reaching before a short final packet may leave a few frames in.
+// WithPaddedFinalFrame has no effect alongside it. Negative counts are ignored.
+func WithTrimEnd(frames int64) Option {
+	return func(o *options) { o.trimEnd = max(frames, 0) }
+}
+
+// trimmed reports whether WithTrimStart or WithTrimEnd is set.
+func (s *Decoder) trimmed() bool { return s.trimStart > 0 || s.trimEnd > 0 }
+
+// trimLimit returns the media frame at which WithTrimEnd stops output, or
+// MaxInt64 without it. end is the end of the stream, in media frames.
+func (s *Decoder) trimLimit(end int64) int64 {
+	if s.trimEnd == 0 {
+		return math.MaxInt64
+	}
+
+	return end - s.trimEnd
+}
+
+// streamEnd returns the end of the stream in media frames, from stts when
+// known, and otherwise counting the final packet as FrameLength frames.
+func (s *Decoder) streamEnd() int64 {
+	if s.endFrame > 0 {
+		return s.endFrame
+	}
+
+	return s.packetFrame(len(s.samples))
+}
+
+// untrimmedPacket reports whether packet idx, other than the last, lies
+// wholly within the frames the trims keep.
+func (s *Decoder) untrimmedPacket(idx int) bool {
+	return !s.trimmed() ||
+		s.packetFrame(idx) >= s.trimStart && s.packetFrame(idx+1) <= s.trimLimit(s.streamEnd())
+}
+
+// skipTrimmedPackets moves past the packets that end before WithTrimStart,
+// which hold nothing to output. The final packet is always decoded.
+func (s *Decoder) skipTrimmedPackets() {
+	for s.sampleIdx < len(s.samples)-1 && s.packetFrame(s.sampleIdx+1) <= s.trimStart {
+		s.sampleIdx++
+	}
+}
+
+// clipTrimmed narrows the packet just decoded into buf to the frames the
+// trims keep, ending the stream once the end trim is reached.
+func (s *Decoder) clipTrimmed() {
+	bytesPerFrame := int64(s.dec.format.Channels * s.dec.sampleBytes)
+	idx := s.sampleIdx - 1
+	first := s.packetFrame(idx)
+	frames := int64(len(s.buf)) / bytesPerFrame
+
+	end := s.streamEnd()
+	if idx == len(s.samples)-1 {
+		end = first + frames
+	}
+
+	keepFrom := min(max(s.trimStart-first, 0), frames)
+	keepTo := min(max(s.trimLimit(end)-first, keepFrom), frames)
+
+	s.buf = s.buf[:keepTo*bytesPerFrame]
+	s.bufOff = int(keepFrom * bytesPerFrame)
+
+	if keepTo < frames {
+		s.eof = true
+	}
+}
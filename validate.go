@@ -0,0 +1,95 @@
+/*
+   Copyright Mycophonic.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package alac
+
+import (
+	"errors"
+	"io"
+)
+
+// ValidationReport summarizes the bitstream integrity check made by Validate.
+type ValidationReport struct {
+	Format PCMFormat
+	// Packets is the number of packets in the sample table.
+	Packets int
+	// Anomalies lists the malformed packets, in order.
+	Anomalies []PacketAnomaly
+}
+
+// PacketAnomaly describes one malformed packet.
+type PacketAnomaly struct {
+	Packet int   // sample table index
+	Err    error // what decoding the packet would have reported
+}
+
+// OK reports whether every packet is well-formed.
+func (r ValidationReport) OK() bool {
+	return len(r.Anomalies) == 0
+}
+
+// Validate checks that every packet of an M4A/MP4 ALAC stream is well-formed
+// without producing PCM: element tags, header fields, entropy-coded data and
+// the channel count carried by the elements are checked, but prediction,
+// channel unmixing and output are skipped. This is much cheaper than a full
+// decode, for batch integrity checks.
+//
+// Malformed or truncated packets are reported as anomalies; the error is
+// reserved for streams that cannot be opened or read at all.
+//
+//nolint:varnamelen // rs is idiomatic for io.ReadSeeker
+func Validate(rs io.ReadSeeker) (ValidationReport, error) {
+	dec, err := NewDecoder(rs)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	pdec := dec.dec
+	pdec.validateOnly = true
+
+	report := ValidationReport{Format: dec.Format(), Packets: len(dec.samples)}
+
+	for idx := range dec.samples {
+		packet, err := dec.loadPacket(idx)
+		if err != nil {
+			if !errors.Is(err, ErrTruncatedStream) {
+				return ValidationReport{}, err
+			}
+
+			// Every later packet is past the end as well.
+			report.Anomalies = append(report.Anomalies, PacketAnomaly{Packet: idx, Err: err})
+
+			break
+		}
+
+		pdec.packet = idx
+
+		if err := pdec.checkPacket(packet); err != nil {
+			report.Anomalies = append(report.Anomalies, PacketAnomaly{Packet: idx, Err: err})
+		}
+	}
+
+	return report, nil
+}
+
+// checkPacket parses a packet's elements and entropy-coded data without
+// producing PCM; decodeFrame checks that they carry the configured channel
+// count. The decoder must be in validateOnly mode.
+func (d *PacketDecoder) checkPacket(packet []byte) error {
+	_, err := d.decodeFrame(packet, nil)
+
+	return err
+}